@@ -0,0 +1,47 @@
+// protocol-schema 生成设备通信协议的JSON Schema，供固件侧校验消息格式
+//
+// 用法：
+//
+//	go run ./src/cmd/protocol-schema -out src/core/protocol/schema
+//
+// 协议结构体变更后在src/core/protocol目录执行go generate重新生成
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"angrymiao-ai-server/src/core/protocol"
+)
+
+func main() {
+	output := flag.String("out", "schema", "JSON Schema输出目录")
+	flag.Parse()
+
+	schemas, err := protocol.Schemas()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "创建输出目录失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(*output, name)
+		if err := os.WriteFile(path, schemas[name], 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "写入%s失败: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	}
+}
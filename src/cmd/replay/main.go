@@ -0,0 +1,68 @@
+// replay 回放会话录制存档，比对工具调用和下发消息与录制时是否一致
+//
+// 用法：
+//
+//	go run ./src/cmd/replay -archive logs/sessions/device-xxx_20250101-120000.jsonl
+//
+// 配置从当前目录的config.yaml读取，与服务端保持一致；存在差异时退出码为1
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/replay"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func main() {
+	archive := flag.String("archive", "", "会话存档路径")
+	speed := flag.Float64("speed", 1, "回放速度倍数，<=0为不等待尽快回放")
+	settle := flag.Duration("settle", 3*time.Second, "输入回放完毕后等待处理完成的时长")
+	output := flag.String("out", "", "回放过程的存档路径，为空时写入临时文件")
+	flag.Parse()
+
+	if *archive == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config, _, err := configs.LoadConfig(nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(2)
+	}
+	config.Recorder.Enabled = false // 回放过程由replay自行录制
+
+	logger, err := utils.NewLogger((*utils.LogCfg)(&config.Log))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化日志系统失败: %v\n", err)
+		os.Exit(2)
+	}
+	utils.DefaultLogger = logger
+
+	report, err := replay.Run(context.Background(), config, logger, *archive, replay.Options{
+		Speed:  *speed,
+		Settle: *settle,
+		Output: *output,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "回放失败: %v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("回放存档: %s\n", report.Output)
+	if report.OK() {
+		fmt.Println("回放结果与录制一致")
+		return
+	}
+	fmt.Printf("回放结果与录制存在 %d 处差异:\n", len(report.Diffs))
+	for _, diff := range report.Diffs {
+		fmt.Println(diff)
+	}
+	os.Exit(1)
+}
@@ -97,7 +97,7 @@ type Config struct {
 	} `yaml:"web" json:"web"`
 
 	DefaultPrompt    string   `yaml:"prompt"             json:"prompt"`
-	Roles            []string `yaml:"roles"              json:"roles"` // 角色列表
+	Roles            []string `yaml:"roles"              json:"roles"` // 角色列表，格式为name@prompt，数据库中没有全局角色时导入为全局角色
 	DeleteAudio      bool     `yaml:"delete_audio"       json:"delete_audio"`
 	QuickReply       bool     `yaml:"quick_reply"        json:"quick_reply"`
 	QuickReplyWords  []string `yaml:"quick_reply_words"  json:"quick_reply_words"`
@@ -118,6 +118,42 @@ type Config struct {
 
 	// 连通性检查配置
 	ConnectivityCheck ConnectivityCheckConfig `yaml:"connectivity_check" json:"connectivity_check"`
+
+	// 工具调用Agent循环配置
+	Agent AgentConfig `yaml:"agent" json:"agent"`
+
+	// 服务端语音活动检测配置
+	VAD VADConfig `yaml:"VAD" json:"VAD"`
+
+	// 用户长时间未说话时的处理策略
+	SilencePolicy SilencePolicyConfig `yaml:"silence_policy" json:"silence_policy"`
+
+	// 对话历史持久化配置
+	DialogueStore DialogueStoreConfig `yaml:"dialogue_store" json:"dialogue_store"`
+
+	// 用户长期记忆配置
+	Memory MemoryConfig `yaml:"memory" json:"memory"`
+
+	// 会话录制配置
+	Recorder RecorderConfig `yaml:"recorder" json:"recorder"`
+
+	// 回复情绪配置
+	Emotion EmotionConfig `yaml:"emotion" json:"emotion"`
+
+	// 用户每日用量配额配置
+	Quota QuotaConfig `yaml:"quota" json:"quota"`
+
+	// 内容审核配置
+	Moderation ModerationConfig `yaml:"moderation" json:"moderation"`
+
+	// 本地意图识别配置
+	Intent IntentConfig `yaml:"intent" json:"intent"`
+
+	// TTS结果缓存配置
+	TTSCache TTSCacheConfig `yaml:"tts_cache" json:"tts_cache"`
+
+	// 回复韵律标记配置
+	Prosody ProsodyConfig `yaml:"prosody" json:"prosody"`
 }
 
 type PoolConfig struct {
@@ -142,6 +178,7 @@ type VoiceInfo struct {
 	Sex         string `yaml:"sex"          json:"sex"`
 	Description string `yaml:"description"  json:"description"`
 	AudioURL    string `yaml:"audio_url"    json:"audio_url"`
+	Language    string `yaml:"language"     json:"language"` // 音色的语言，如zh、en，回复语言与当前音色不符时自动选用该语言的音色
 }
 
 // TTSConfig TTS配置结构
@@ -166,6 +203,9 @@ type LLMConfig struct {
 	MaxTokens   int                    `yaml:"max_tokens"  json:"max_tokens"`  // 最大令牌数
 	TopP        float64                `yaml:"top_p"       json:"top_p"`       // TopP参数
 	Extra       map[string]interface{} `yaml:",inline"     json:"extra"`       // 额外配置
+
+	ContextTokens int  `yaml:"context_tokens" json:"context_tokens"` // 上下文窗口的token预算，为0时使用默认值
+	Multimodal    bool `yaml:"multimodal"     json:"multimodal"`     // 模型是否支持图片输入，为false时图片只以文字说明发送
 }
 
 // SecurityConfig 图片安全配置结构
@@ -192,6 +232,162 @@ type ConnectivityCheckConfig struct {
 	} `yaml:"test_modes"     json:"test_modes"`
 }
 
+// AgentConfig 工具调用Agent循环配置结构
+type AgentConfig struct {
+	MaxSteps     int    `yaml:"max_steps"     json:"max_steps"`     // 单轮对话最多请求LLM的步数
+	StepTimeout  string `yaml:"step_timeout"  json:"step_timeout"`  // 每一步（LLM回复+工具调用）的超时时间
+	FallbackText string `yaml:"fallback_text" json:"fallback_text"` // 步数用尽或超时后的兜底回复
+}
+
+// VADConfig 服务端语音活动检测配置结构
+type VADConfig struct {
+	Enabled      bool    `yaml:"enabled"        json:"enabled"`        // 是否启用服务端VAD
+	Type         string  `yaml:"type"           json:"type"`           // VAD类型，默认energy
+	Threshold    float64 `yaml:"threshold"      json:"threshold"`      // 语音能量阈值（归一化RMS，0~1）
+	MinSpeechMs  int     `yaml:"min_speech_ms"  json:"min_speech_ms"`  // 判定开始说话的最短语音时长(ms)
+	MinSilenceMs int     `yaml:"min_silence_ms" json:"min_silence_ms"` // 判定说话结束的静音时长(ms)
+}
+
+// 静音策略动作
+const (
+	SilenceActionEndChat       = "end_chat"       // 交给LLM礼貌地结束对话后关闭连接
+	SilenceActionKeepListening = "keep_listening" // 清零静音计数，继续拾音
+	SilenceActionChime         = "chime"          // 播放提示音后继续拾音
+	SilenceActionSleep         = "sleep"          // 通知设备进入休眠并关闭连接
+	SilenceActionClose         = "close"          // 直接关闭连接
+)
+
+// SilencePolicyConfig 静音策略配置结构，用户设置中的非零字段会覆盖全局配置
+type SilencePolicyConfig struct {
+	MaxSilenceCount int    `yaml:"max_silence_count" json:"max_silence_count"` // 连续静音多少次后执行动作
+	IdleTimeout     string `yaml:"idle_timeout"      json:"idle_timeout"`      // 多长时间未说话计为一次静音，如"30s"
+	Action          string `yaml:"action"            json:"action"`            // 达到静音次数后的动作
+	Prompt          string `yaml:"prompt"            json:"prompt"`            // end_chat动作交给LLM的提示词
+	ChimeFile       string `yaml:"chime_file"        json:"chime_file"`        // chime动作播放的音频文件，只能在全局配置中指定
+}
+
+// Merge 返回以override中非零字段覆盖后的策略
+// 提示音文件只能在全局配置中指定，用户设置不能让服务端读取其他文件
+func (p SilencePolicyConfig) Merge(override SilencePolicyConfig) SilencePolicyConfig {
+	if override.MaxSilenceCount > 0 {
+		p.MaxSilenceCount = override.MaxSilenceCount
+	}
+	if override.IdleTimeout != "" {
+		p.IdleTimeout = override.IdleTimeout
+	}
+	if override.Action != "" {
+		p.Action = override.Action
+	}
+	if override.Prompt != "" {
+		p.Prompt = override.Prompt
+	}
+	return p
+}
+
+// DialogueStoreConfig 对话历史持久化配置结构
+type DialogueStoreConfig struct {
+	Enabled     bool   `yaml:"enabled"      json:"enabled"`      // 是否持久化对话历史
+	MaxMessages int    `yaml:"max_messages" json:"max_messages"` // 重连时恢复的最近消息条数
+	Retention   string `yaml:"retention"    json:"retention"`    // 对话历史保留时长，如"24h"
+}
+
+// MemoryConfig 用户长期记忆配置结构
+// 用户查看和删除记忆需要在local_mcp_fun中加入memory
+type MemoryConfig struct {
+	Enabled  bool   `yaml:"enabled"   json:"enabled"`   // 是否启用长期记忆
+	LLM      string `yaml:"llm"       json:"llm"`       // 用于提取记忆的LLM配置名，为空时使用selected_module中的LLM
+	TopK     int    `yaml:"top_k"     json:"top_k"`     // 每轮对话注入的相关记忆条数
+	MaxFacts int    `yaml:"max_facts" json:"max_facts"` // 每个用户最多保存的记忆条数
+}
+
+// RecorderConfig 会话录制配置结构，录制的存档可以离线回放以复现问题
+type RecorderConfig struct {
+	Enabled   bool     `yaml:"enabled"    json:"enabled"`    // 是否录制会话
+	Dir       string   `yaml:"dir"        json:"dir"`        // 存档目录，默认为logs/sessions
+	DeviceIDs []string `yaml:"device_ids" json:"device_ids"` // 只录制指定设备，为空时录制全部设备
+}
+
+// EmotionConfig 回复情绪配置结构
+// 情绪取自每句回复开头的表情符号或[happy]这样的情绪标签，可以在角色提示词中要求模型输出
+type EmotionConfig struct {
+	Enabled    bool `yaml:"enabled"    json:"enabled"`    // 是否在每句回复播放前下发情绪消息
+	Classifier bool `yaml:"classifier" json:"classifier"` // 回复中没有情绪标记时，是否按关键词推断情绪
+}
+
+// QuotaConfig 用户每日用量配额配置结构
+// 级别为basic/premium/business，资源为llm_tokens/tts_chars/asr_seconds/vision_calls，未配置的上限使用内置默认值，0表示不限制
+type QuotaConfig struct {
+	Enabled     bool                        `yaml:"enabled"      json:"enabled"`      // 是否限制用量
+	DefaultTier string                      `yaml:"default_tier" json:"default_tier"` // 未设置级别的用户使用的级别，默认为basic
+	Tiers       map[string]map[string]int64 `yaml:"tiers"        json:"tiers"`        // 各级别每项资源的每日上限
+}
+
+// ModerationConfig 内容审核配置结构
+// 审核用户说的话和每句回复，用户设置中的Moderation字段可以为用户指定审核配置，如儿童模式
+type ModerationConfig struct {
+	Enabled        bool                               `yaml:"enabled"         json:"enabled"`         // 是否审核内容
+	LLM            string                             `yaml:"llm"             json:"llm"`             // LLM分类器使用的LLM配置名，为空时使用selected_module中的LLM
+	LLMCategories  []string                           `yaml:"llm_categories"  json:"llm_categories"`  // LLM分类器判断的类别，为空时使用默认类别
+	DefaultProfile string                             `yaml:"default_profile" json:"default_profile"` // 默认的审核配置，默认为default
+	Keywords       []ModerationKeywordConfig          `yaml:"keywords"        json:"keywords"`        // 本地关键词列表
+	Profiles       map[string]ModerationProfileConfig `yaml:"profiles"        json:"profiles"`        // 审核配置，未配置default时使用关键词审核并只记录日志
+}
+
+// ModerationKeywordConfig 一个类别的关键词和正则表达式
+type ModerationKeywordConfig struct {
+	Category string   `yaml:"category" json:"category"`
+	Words    []string `yaml:"words"    json:"words"`
+	Patterns []string `yaml:"patterns" json:"patterns"`
+}
+
+// ModerationProfileConfig 审核配置结构
+// 动作可选block/rewrite/reply/log
+// llm提供者在每句回复播报前同步请求一次LLM，每次最多等待3秒，超时视为未命中：
+// 一轮回复有N句时最多增加N×3秒的播报延迟和N次LLM请求，输出审核建议只使用keyword
+type ModerationProfileConfig struct {
+	Providers    []string `yaml:"providers"     json:"providers"`     // 依次使用的审核提供者keyword/llm，默认为keyword
+	InputAction  string   `yaml:"input_action"  json:"input_action"`  // 用户输入命中后的动作，默认为log
+	OutputAction string   `yaml:"output_action" json:"output_action"` // 回复命中后的动作，默认为log
+	Reply        string   `yaml:"reply"         json:"reply"`         // reply动作使用的固定回复
+	Categories   []string `yaml:"categories"    json:"categories"`    // 只处理这些类别，为空时处理全部类别
+}
+
+// IntentConfig 本地意图识别配置结构
+// 用户输入命中意图规则时直接调用对应的工具，不再请求LLM，未命中时照常交给LLM
+type IntentConfig struct {
+	Enabled       bool                `yaml:"enabled"        json:"enabled"`        // 是否启用本地意图识别
+	MinConfidence float64             `yaml:"min_confidence" json:"min_confidence"` // 最低置信度，即模式覆盖输入文本的比例，默认为0.6
+	Synonyms      map[string][]string `yaml:"synonyms"       json:"synonyms"`       // 全局同义词，键为规范词，匹配前将同义词替换为规范词
+	Intents       []IntentRuleConfig  `yaml:"intents"        json:"intents"`        // 意图规则，置信度相同时优先使用靠前的规则
+}
+
+// IntentRuleConfig 意图规则配置结构
+// 模式中的{slot}引用槽位，*匹配任意文本，如"音量调{direction}一点"、"*下一首*"
+type IntentRuleConfig struct {
+	Name      string                         `yaml:"name"      json:"name"`      // 意图名称，用于日志
+	Patterns  []string                       `yaml:"patterns"  json:"patterns"`  // 匹配模式
+	Slots     map[string]map[string][]string `yaml:"slots"     json:"slots"`     // 槽位取值，键为规范值，值为同义说法；不配置取值的槽位匹配数字
+	Tool      string                         `yaml:"tool"      json:"tool"`      // 调用的本地工具或设备MCP工具
+	Arguments map[string]interface{}         `yaml:"arguments" json:"arguments"` // 工具参数，可以引用槽位，如"{volume}"
+	Reply     string                         `yaml:"reply"     json:"reply"`     // 调用后播报的回复，可以引用槽位；为空时由LLM根据工具结果回复
+}
+
+// TTSCacheConfig TTS结果缓存配置结构
+// 以TTS类型、音色、音频格式和文本为键缓存编码好的音频帧，重复播报相同文本时跳过合成和转码
+type TTSCacheConfig struct {
+	Enabled       bool   `yaml:"enabled"         json:"enabled"`         // 是否启用TTS缓存
+	Dir           string `yaml:"dir"             json:"dir"`             // 缓存目录，默认为tts_cache
+	MaxEntries    int    `yaml:"max_entries"     json:"max_entries"`     // 最多缓存的条目数，默认为5000
+	MaxMB         int64  `yaml:"max_mb"          json:"max_mb"`          // 最多占用的磁盘空间(MB)，默认为256
+	MaxTextLength int    `yaml:"max_text_length" json:"max_text_length"` // 只缓存不超过该字数的文本，默认为50
+}
+
+// ProsodyConfig 回复韵律标记配置
+// 启用后回复中的<break>、<emphasis>、<prosody>标记交给TTS提供者转换为停顿、重读和语速等，需要在提示词中说明标记的用法
+type ProsodyConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"` // 是否将韵律标记交给TTS，关闭时去除标记后合成
+}
+
 // VLLMConfig VLLLM配置结构（视觉语言大模型）
 type VLLMConfig struct {
 	Type        string                 `yaml:"type"        json:"type"`        // API类型，复用LLM的类型
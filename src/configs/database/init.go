@@ -179,6 +179,12 @@ func migrateTables(db *gorm.DB) error {
 		&models.DeviceBind{},
 		&models.UserAIConfig{},
 		&models.UserSessionConfig{},
+		&models.DialogueHistory{},
+		&models.UserMemory{},
+		&models.UserUsage{},
+		&models.UserTier{},
+		&models.Persona{},
+		&models.Reminder{},
 	)
 }
 
@@ -2,6 +2,7 @@ package chat
 
 import (
 	"encoding/json"
+	"sync"
 
 	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
@@ -11,9 +12,14 @@ type Message = types.Message
 
 // DialogueManager 管理对话上下文和历史
 type DialogueManager struct {
-	logger   *utils.Logger
-	dialogue []Message
-	memory   MemoryInterface
+	logger    *utils.Logger
+	dialogue  []Message
+	memory    MemoryInterface
+	summary   string // 已移出上下文窗口的早期对话摘要，由后台压缩更新，通过summaryMu访问
+	summaryMu sync.RWMutex
+	added     int               // 上次TakeNewMessages之后通过Put添加的消息数
+	media     map[string]string // 图片引用 -> base64数据，只在本次连接内有效，通过mediaMu访问
+	mediaMu   sync.Mutex
 }
 
 // NewDialogueManager 创建对话管理器实例
@@ -22,9 +28,20 @@ func NewDialogueManager(logger *utils.Logger, memory MemoryInterface) *DialogueM
 		logger:   logger,
 		dialogue: make([]Message, 0),
 		memory:   memory,
+		media:    make(map[string]string),
 	}
 }
 
+// SetMemory 设置长期记忆，为nil时不使用记忆
+func (dm *DialogueManager) SetMemory(memory MemoryInterface) {
+	dm.memory = memory
+}
+
+// Memory 获取长期记忆，未设置时返回nil
+func (dm *DialogueManager) Memory() MemoryInterface {
+	return dm.memory
+}
+
 func (dm *DialogueManager) SetSystemMessage(systemMessage string) {
 	if systemMessage == "" {
 		return
@@ -47,16 +64,16 @@ func (dm *DialogueManager) KeepRecentMessages(maxMessages int) {
 	if maxMessages <= 0 || len(dm.dialogue) <= maxMessages {
 		return
 	}
+	// 跳过开头缺少对应tool_calls的tool消息
+	recent := skipOrphanToolMessages(dm.dialogue[len(dm.dialogue)-maxMessages:])
 	// 保留system消息和最近的 maxMessages 条消息
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
 		// 保留system消息
-		dm.dialogue = append(dm.dialogue[:1], dm.dialogue[len(dm.dialogue)-maxMessages:]...)
+		dm.dialogue = append(dm.dialogue[:1], recent...)
 		return
 	}
 	// 如果没有system消息，直接保留最近的 maxMessages 条消息
-	if len(dm.dialogue) > maxMessages {
-		dm.dialogue = dm.dialogue[len(dm.dialogue)-maxMessages:]
-	}
+	dm.dialogue = recent
 }
 
 // GetRecentMessages 获取最近的对话消息
@@ -68,14 +85,38 @@ func (dm *DialogueManager) GetRecentMessages(maxMessages int) []Message {
 	// 保留system消息和最近的 maxMessages 条消息
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
 		// 保留system消息
-		return append([]Message{dm.dialogue[0]}, dm.dialogue[len(dm.dialogue)-maxMessages:]...)
+		return append([]Message{dm.dialogue[0]}, skipOrphanToolMessages(dm.dialogue[len(dm.dialogue)-maxMessages:])...)
 	}
 	return dm.dialogue
 }
 
-// Put 添加新消息到对话
+// skipOrphanToolMessages 跳过开头的tool消息，截断后这些消息对应的tool_calls已不在上下文中，
+// OpenAI兼容接口会拒绝这样的请求
+func skipOrphanToolMessages(messages []Message) []Message {
+	for len(messages) > 0 && messages[0].Role == "tool" {
+		messages = messages[1:]
+	}
+	return messages
+}
+
+// Put 添加新消息到对话，图片数据保存在媒体缓存中，对话历史只保留引用
 func (dm *DialogueManager) Put(message Message) {
-	dm.dialogue = append(dm.dialogue, message)
+	dm.dialogue = append(dm.dialogue, dm.storeMedia(message))
+	dm.added++
+	if message.HasMedia() {
+		dm.pruneMedia()
+	}
+}
+
+// TakeNewMessages 返回上次调用之后通过Put添加、且仍在对话中的消息，并重新开始计数
+// 从存储中恢复的消息不计入，用于只从本次连接的新对话中提取记忆
+func (dm *DialogueManager) TakeNewMessages() []Message {
+	n := min(dm.added, len(dm.dialogue)-dm.systemMessageCount())
+	dm.added = 0
+	if n <= 0 {
+		return nil
+	}
+	return append([]Message(nil), dm.dialogue[len(dm.dialogue)-n:]...)
 }
 
 func (dm *DialogueManager) GetLastTwoMessages() []Message {
@@ -85,9 +126,93 @@ func (dm *DialogueManager) GetLastTwoMessages() []Message {
 	return dm.dialogue[len(dm.dialogue)-2:]
 }
 
-// GetLLMDialogue 获取完整对话历史
+// GetLLMDialogue 获取完整对话历史，早期对话的摘要作为系统消息放在系统提示词之后
 func (dm *DialogueManager) GetLLMDialogue() []Message {
-	return dm.dialogue
+	summary := dm.Summary()
+	if summary == "" {
+		return dm.dialogue
+	}
+
+	start := dm.systemMessageCount()
+	dialogue := make([]Message, 0, len(dm.dialogue)+1)
+	dialogue = append(dialogue, dm.dialogue[:start]...)
+	dialogue = append(dialogue, summaryMessage(summary))
+	dialogue = append(dialogue, dm.dialogue[start:]...)
+	return dialogue
+}
+
+// SetSummary 设置早期对话的摘要
+func (dm *DialogueManager) SetSummary(summary string) {
+	dm.summaryMu.Lock()
+	defer dm.summaryMu.Unlock()
+	dm.summary = summary
+}
+
+// Summary 获取早期对话的摘要
+func (dm *DialogueManager) Summary() string {
+	dm.summaryMu.RLock()
+	defer dm.summaryMu.RUnlock()
+	return dm.summary
+}
+
+func summaryMessage(summary string) Message {
+	return Message{
+		Role:    "system",
+		Content: "以下是之前对话的摘要：\n" + summary,
+	}
+}
+
+// systemMessageCount 对话开头的系统提示词条数（0或1）
+func (dm *DialogueManager) systemMessageCount() int {
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		return 1
+	}
+	return 0
+}
+
+// TokenCount 估算请求LLM时对话的token数，包括系统提示词和摘要
+func (dm *DialogueManager) TokenCount() int {
+	total := 0
+	for _, msg := range dm.dialogue {
+		total += EstimateTokens(msg)
+	}
+	if summary := dm.Summary(); summary != "" {
+		total += EstimateTokens(summaryMessage(summary))
+	}
+	return total
+}
+
+// TrimToBudget 从最早的对话轮次开始移除消息，直到估算的token数不超过budget，返回被移除的消息
+// 只在用户消息处截断，保证助手的tool_calls和对应的tool消息不会被拆开；最近一轮对话始终保留
+func (dm *DialogueManager) TrimToBudget(budget int) []Message {
+	total := dm.TokenCount()
+	if total <= budget {
+		return nil
+	}
+
+	start := dm.systemMessageCount()
+	lastUser := -1
+	for i := len(dm.dialogue) - 1; i >= start; i-- {
+		if dm.dialogue[i].Role == "user" {
+			lastUser = i
+			break
+		}
+	}
+
+	cut, cutTokens, removedTokens := start, 0, 0
+	for i := start + 1; i <= lastUser && total-cutTokens > budget; i++ {
+		removedTokens += EstimateTokens(dm.dialogue[i-1])
+		if dm.dialogue[i].Role == "user" {
+			cut, cutTokens = i, removedTokens
+		}
+	}
+	if cut == start {
+		return nil
+	}
+
+	removed := append([]Message(nil), dm.dialogue[start:cut]...)
+	dm.dialogue = append(dm.dialogue[:start], dm.dialogue[cut:]...)
+	return removed
 }
 
 // GetLLMDialogueWithMemory 获取带记忆的对话
@@ -101,9 +226,10 @@ func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message
 		Content: memoryStr,
 	}
 
-	dialogue := make([]Message, 0, len(dm.dialogue)+1)
+	llmDialogue := dm.GetLLMDialogue()
+	dialogue := make([]Message, 0, len(llmDialogue)+1)
 	dialogue = append(dialogue, memoryMsg)
-	dialogue = append(dialogue, dm.dialogue...)
+	dialogue = append(dialogue, llmDialogue...)
 
 	return dialogue
 }
@@ -111,6 +237,8 @@ func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message
 // Clear 清空对话历史
 func (dm *DialogueManager) Clear() {
 	dm.dialogue = make([]Message, 0)
+	dm.SetSummary("")
+	dm.added = 0
 }
 
 func (dm *DialogueManager) Length() int {
@@ -135,3 +263,25 @@ func (dm *DialogueManager) ToJSON(keepSystemPrompt bool) (string, error) {
 func (dm *DialogueManager) LoadFromJSON(jsonStr string) error {
 	return json.Unmarshal([]byte(jsonStr), &dm.dialogue)
 }
+
+// Restore 从ToJSON(false)保存的对话历史中恢复最近的maxMessages条消息
+// 保留当前的系统消息，并丢弃开头缺少对应tool_calls的tool消息
+func (dm *DialogueManager) Restore(jsonStr string, maxMessages int) error {
+	var history []Message
+	if err := json.Unmarshal([]byte(jsonStr), &history); err != nil {
+		return err
+	}
+	if maxMessages > 0 && len(history) > maxMessages {
+		history = history[len(history)-maxMessages:]
+	}
+	for len(history) > 0 && (history[0].Role == "tool" || history[0].Role == "system") {
+		history = history[1:]
+	}
+
+	var dialogue []Message
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		dialogue = append(dialogue, dm.dialogue[0])
+	}
+	dm.dialogue = append(dialogue, history...)
+	return nil
+}
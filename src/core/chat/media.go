@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+// maxMediaImages 发送给模型的对话历史中最多保留的图片数，更早的图片只发送文字说明，
+// 避免每轮都重复发送全部历史图片
+const maxMediaImages = 2
+
+// mediaRef 根据base64数据生成媒体引用，相同的数据得到相同的引用
+func mediaRef(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "media:" + hex.EncodeToString(sum[:8])
+}
+
+// storeMedia 将消息中图片的base64数据移到媒体缓存，对话历史中只保留引用，
+// 保存到数据库和录制文件的对话不包含原始数据；音频只会发送文字说明，不保留数据
+func (dm *DialogueManager) storeMedia(message Message) Message {
+	if !message.HasMedia() {
+		return message
+	}
+	parts := make([]types.ContentPart, len(message.Parts))
+	for i, part := range message.Parts {
+		if part.Media() && part.Data != "" {
+			part.Ref = mediaRef(part.Data)
+			if part.Type == types.ContentPartImage {
+				dm.mediaMu.Lock()
+				dm.media[part.Ref] = part.Data
+				dm.mediaMu.Unlock()
+			}
+			part.Data = ""
+		}
+		parts[i] = part
+	}
+	message.Parts = parts
+	return message
+}
+
+// pruneMedia 删除不再会发送给模型的图片数据，只保留最近maxMediaImages张图片
+func (dm *DialogueManager) pruneMedia() {
+	keep := make(map[string]bool)
+	images := 0
+	for i := len(dm.dialogue) - 1; i >= 0 && images < maxMediaImages; i-- {
+		parts := dm.dialogue[i].Parts
+		for j := len(parts) - 1; j >= 0 && images < maxMediaImages; j-- {
+			if parts[j].Type == types.ContentPartImage {
+				keep[parts[j].Ref] = true
+				images++
+			}
+		}
+	}
+
+	dm.mediaMu.Lock()
+	defer dm.mediaMu.Unlock()
+	for ref := range dm.media {
+		if !keep[ref] {
+			delete(dm.media, ref)
+		}
+	}
+}
+
+// ResolveMedia 返回发送给模型的消息：最近maxMediaImages张图片按引用还原数据，更早的图片改为文字说明
+// 不修改对话历史；缓存中没有数据的引用（如从存储恢复的对话）由提供者使用文字说明
+func (dm *DialogueManager) ResolveMedia(messages []Message) []Message {
+	resolved := make([]Message, len(messages))
+	images := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		resolved[i] = msg
+		if !msg.HasMedia() {
+			continue
+		}
+		parts := make([]types.ContentPart, len(msg.Parts))
+		for j := len(msg.Parts) - 1; j >= 0; j-- {
+			part := msg.Parts[j]
+			if part.Type == types.ContentPartImage {
+				images++
+				switch {
+				case images > maxMediaImages:
+					part = types.TextPart(part.TextForm())
+				case part.Data == "" && part.Ref != "":
+					dm.mediaMu.Lock()
+					part.Data = dm.media[part.Ref]
+					dm.mediaMu.Unlock()
+				}
+			}
+			parts[j] = part
+		}
+		resolved[i].Parts = parts
+	}
+	return resolved
+}
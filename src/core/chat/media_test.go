@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+func imageMessage(data string) Message {
+	return types.NewMultimodalMessage("user",
+		types.TextPart("这是什么"),
+		types.ImagePart("", data, "png", "[图片]"),
+	)
+}
+
+func TestPutStoresMediaRef(t *testing.T) {
+	dm := NewDialogueManager(nil, nil)
+	dm.Put(imageMessage("aGVsbG8="))
+
+	saved, err := dm.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON() error = %v", err)
+	}
+	if strings.Contains(saved, "aGVsbG8=") {
+		t.Errorf("保存的对话包含图片数据: %s", saved)
+	}
+
+	resolved := dm.ResolveMedia(dm.GetLLMDialogue())
+	if data := resolved[0].Parts[1].Data; data != "aGVsbG8=" {
+		t.Errorf("ResolveMedia() 图片数据 = %q, want %q", data, "aGVsbG8=")
+	}
+	if dm.dialogue[0].Parts[1].Data != "" {
+		t.Error("ResolveMedia() 修改了对话历史")
+	}
+}
+
+func TestResolveMedia(t *testing.T) {
+	tests := []struct {
+		name     string
+		images   []string
+		restored bool
+		expected []string // 每条消息中图片片段发送的数据，为caption表示改为文字说明
+	}{
+		{name: "一张图片", images: []string{"a1"}, expected: []string{"a1"}},
+		{name: "不超过上限", images: []string{"a1", "b2"}, expected: []string{"a1", "b2"}},
+		{name: "更早的图片改为文字说明", images: []string{"a1", "b2", "c3"}, expected: []string{"caption", "b2", "c3"}},
+		{name: "从存储恢复的引用没有数据", images: []string{"a1"}, restored: true, expected: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dm := NewDialogueManager(nil, nil)
+			for _, data := range tt.images {
+				dm.Put(imageMessage(data))
+				dm.Put(Message{Role: "assistant", Content: "一张图片"})
+			}
+			if tt.restored {
+				saved, _ := dm.ToJSON(false)
+				dm = NewDialogueManager(nil, nil)
+				if err := dm.Restore(saved, 0); err != nil {
+					t.Fatalf("Restore() error = %v", err)
+				}
+			}
+
+			resolved := dm.ResolveMedia(dm.GetLLMDialogue())
+			var got []string
+			for _, msg := range resolved {
+				if msg.Role != "user" {
+					continue
+				}
+				part := msg.Parts[1]
+				if part.Type == types.ContentPartText {
+					got = append(got, "caption")
+				} else {
+					got = append(got, part.Data)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ResolveMedia() 图片 = %q, want %q", got, tt.expected)
+			}
+			if len(dm.media) > maxMediaImages {
+				t.Errorf("媒体缓存数 = %d, 超过上限 %d", len(dm.media), maxMediaImages)
+			}
+		})
+	}
+}
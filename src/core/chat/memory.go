@@ -11,3 +11,12 @@ type MemoryInterface interface {
 	// ClearMemory 清空记忆
 	ClearMemory() error
 }
+
+// MemoryEditor 支持用户查看和删除的记忆，用于“你记得我什么”“忘掉这个”等请求
+type MemoryEditor interface {
+	// ListMemory 列出全部记忆
+	ListMemory() ([]string, error)
+
+	// ForgetMemory 删除与描述相关的记忆，返回被删除的内容
+	ForgetMemory(query string) ([]string, error)
+}
@@ -0,0 +1,28 @@
+package chat
+
+import "context"
+
+// DialogueRecord 一个会话保存的对话历史
+type DialogueRecord struct {
+	SessionKey string // 会话标识，设备会话为device-<设备ID>
+	DeviceID   string
+	UserID     string
+	Dialogue   string // DialogueManager.ToJSON序列化的消息列表，不含系统提示词
+	Summary    string // 已移出对话历史的早期对话摘要
+}
+
+// DialogueStore 对话历史持久化接口，用于断线重连后恢复上下文
+type DialogueStore interface {
+	// Load 加载属于该设备和用户的会话对话历史，不存在、属于其他设备或用户、已超过保留时长时返回nil
+	Load(ctx context.Context, sessionKey, deviceID, userID string) (*DialogueRecord, error)
+
+	// Save 保存会话的对话历史和摘要，覆盖之前保存的内容；会话属于其他设备或用户时不覆盖
+	Save(ctx context.Context, record DialogueRecord) error
+
+	// SaveSummary 只更新会话的早期对话摘要，使用record中的会话标识、设备、用户和摘要，
+	// 会话不存在或属于其他设备或用户时不做任何处理
+	SaveSummary(ctx context.Context, record DialogueRecord) error
+
+	// DeleteExpired 删除超过保留时长的对话历史，返回删除的会话数
+	DeleteExpired(ctx context.Context) (int64, error)
+}
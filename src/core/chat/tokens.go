@@ -0,0 +1,28 @@
+package chat
+
+// messageOverheadTokens 每条消息的角色、分隔符等固定开销
+const messageOverheadTokens = 4
+
+// EstimateTokens 粗略估算一条消息占用的token数
+// 不依赖具体模型的分词器：ASCII字符约4个一个token，中文等其他字符按一字一个token计算
+func EstimateTokens(msg Message) int {
+	tokens := messageOverheadTokens + estimateTextTokens(msg.Content)
+	for _, call := range msg.ToolCalls {
+		tokens += messageOverheadTokens +
+			estimateTextTokens(call.Function.Name) +
+			estimateTextTokens(call.Function.Arguments)
+	}
+	return tokens
+}
+
+func estimateTextTokens(text string) int {
+	ascii, others := 0, 0
+	for _, r := range text {
+		if r < 0x80 {
+			ascii++
+		} else {
+			others++
+		}
+	}
+	return others + (ascii+3)/4
+}
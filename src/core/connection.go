@@ -11,20 +11,31 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/auth"
 	"angrymiao-ai-server/src/core/chat"
 	"angrymiao-ai-server/src/core/function"
-	"angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/hooks"
+	"angrymiao-ai-server/src/core/intent"
 	"angrymiao-ai-server/src/core/mcp"
+	"angrymiao-ai-server/src/core/memory"
+	"angrymiao-ai-server/src/core/metrics"
+	"angrymiao-ai-server/src/core/moderation"
+	"angrymiao-ai-server/src/core/persona"
 	"angrymiao-ai-server/src/core/pool"
 	"angrymiao-ai-server/src/core/providers"
 	"angrymiao-ai-server/src/core/providers/llm"
 	"angrymiao-ai-server/src/core/providers/tts"
 	"angrymiao-ai-server/src/core/providers/vlllm"
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/core/recorder"
+	"angrymiao-ai-server/src/core/reminder"
+	"angrymiao-ai-server/src/core/ttscache"
 	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/core/vad"
 	"angrymiao-ai-server/src/models"
 	"angrymiao-ai-server/src/services"
 	"angrymiao-ai-server/src/task"
@@ -96,6 +107,7 @@ type ConnectionHandler struct {
 	serverAudioFrameDuration int
 
 	clientListenMode string
+	protocolVersion  int // hello消息协商的协议版本，握手前为0
 	isDeviceVerified bool
 	closeAfterChat   bool
 
@@ -105,16 +117,60 @@ type ConnectionHandler struct {
 
 	opusDecoder *utils.OpusDecoder // Opus解码器
 
+	// 服务端语音活动检测
+	voiceDetector vad.VAD
+	idleSince     int64 // 开始计算用户未说话时长的时间(UnixNano)
+	idlePending   int32 // 是否已有等待处理的静音检查事件
+	activeRounds  int32 // 正在执行LLM和工具调用的对话轮次数，大于0时不计算静音
+	speechEndTime int64 // 服务端VAD检测到说话结束或客户端停止拾音的时间(UnixNano)，得到识别结果后清零
+	silencePolicy silencePolicy
+
 	// 对话相关
 	dialogueManager     *chat.DialogueManager
+	dialogueStore       chat.DialogueStore // 对话历史存储，为nil时不持久化
+	dialogueSaveMu      sync.Mutex         // 保存对话历史和后台更新摘要互斥
+	compacting          int32              // 是否正在后台压缩早期对话
+	recorder            *recorder.Recorder // 会话录制器，为nil时不录制
+	memoryManager       *memory.Manager    // 长期记忆管理器，为nil时不使用长期记忆
+	memoryPrompt        string             // 本轮检索到的记忆
+	reminderManager     *reminder.Manager  // 提醒管理器，为nil时不支持提醒
+	reminderMu          sync.Mutex         // 保护detachReminders，设备连接在单独的协程中登记
+	detachReminders     func()             // 注销设备连接，连接关闭后到期的提醒暂存到下次连接
+	quotaManager        *quota.Manager     // 用量配额管理器，为nil时不限制用量
+	asrAudioMs          int64              // 尚未记录为ASR用量的音频时长(毫秒)
 	tts_last_text_index int
 	client_asr_text     string // 客户端ASR文本
+	userLanguage        string // 用户最近一句话的语言，回复分段无法判断语言时使用
 	quickReplyCache     *utils.QuickReplyCache
+	ttsCache            *ttscache.Cache // TTS结果缓存，为nil时不缓存
+
+	// 内容审核
+	moderationManager *moderation.Manager // 内容审核管理器，为nil时不审核内容
+	moderationProfile *moderation.Profile // 当前用户使用的审核配置
+	mutedRound        int                 // 回复命中审核后不再播报的轮次
+	replyParts        []string            // 本步回复中实际播报的各段文本，审核后的结果
+	replyModerated    bool                // 本轮回复是否被审核改写或拦截，是时以实际播报的文本写入对话历史
+
+	// 本地意图识别，为nil时全部交给LLM
+	intentRouter *intent.Router
+
+	// 角色
+	personaManager *persona.Manager // 角色管理器，为nil时只能切换配置中的角色
+	persona        *persona.Persona // 当前角色，为nil时使用默认提示词和全部工具
+	personaNames   []string         // 当前用户可以切换的角色名称
+
+	// 对话钩子
+	hooks      *hooks.Chain
+	roundReply string // 本轮写入对话历史的最后一条助手回复
+
+	// 上一段回复中尚未结束的韵律标记，拼接到下一段开头
+	prosodyCarry string
 
 	// 并发控制
 	stopChan         chan struct{}
 	clientAudioQueue chan []byte
 	clientTextQueue  chan string
+	events           chan func() // 定时器等其他协程产生的事件，在文本消息处理协程中依次执行
 
 	// TTS任务队列
 	ttsQueue chan struct {
@@ -122,6 +178,7 @@ type ConnectionHandler struct {
 		round     int // 轮次
 		textIndex int
 		filepath  string // 如果有path，就直接使用
+		emotion   string // 句子的情绪，为空时不下发情绪消息
 	}
 
 	audioMessagesQueue chan struct {
@@ -129,13 +186,29 @@ type ConnectionHandler struct {
 		text      string
 		round     int // 轮次
 		textIndex int
+		stream    <-chan types.AudioChunk // 流式合成的音频，非空时优先于filepath
+		emotion   string
+		frames    [][]byte // 命中TTS缓存或分段合成时编码好的音频帧，非空时优先于stream和filepath
+		cacheKey  string   // 发送完成后写入TTS缓存使用的键，为空时不缓存
 	}
 
 	talkRound      int       // 轮次计数
 	roundStartTime time.Time // 轮次开始时间
+
+	// 当前轮次的上下文，打断、新轮次开始和关闭连接时取消
+	roundMu       sync.Mutex
+	roundCtx      context.Context
+	roundCancel   context.CancelFunc
+	roundCtxRound int // roundCtx对应的轮次
+
+	// 等待完成语音合成的主动播报，按轮次索引
+	noticeMu sync.Mutex
+	notices  map[int]*noticeWaiter
+
 	// functions
 	functionRegister *function.FunctionRegistry
 	mcpManager       *mcp.Manager
+	toolExecutor     ToolExecutor // 替代工具实际执行的执行器，为nil时直接执行
 
 	// 用户AI配置服务
 	userConfigService services.UserAIConfigService
@@ -144,6 +217,10 @@ type ConnectionHandler struct {
 
 	mcpResultHandlers map[string]func(interface{}) // MCP处理器映射
 	ctx               context.Context
+
+	headlessSink   func(HeadlessEvent) // 无音频会话的输出，非空时回复文本不合成语音
+	headlessLast   rune                // 无音频会话上一次输出的最后一个字符
+	untrackSession func()              // 无音频会话结束时减少活跃会话数
 }
 
 // NewConnectionHandler 创建新的连接处理器
@@ -161,20 +238,27 @@ func NewConnectionHandler(
 		stopChan:         make(chan struct{}),
 		clientAudioQueue: make(chan []byte, 100),
 		clientTextQueue:  make(chan string, 100),
+		events:           make(chan func(), 10),
 		ttsQueue: make(chan struct {
 			text      string
 			round     int // 轮次
 			textIndex int
 			filepath  string
+			emotion   string
 		}, 100),
 		audioMessagesQueue: make(chan struct {
 			filepath  string
 			text      string
 			round     int // 轮次
 			textIndex int
+			stream    <-chan types.AudioChunk
+			emotion   string
+			frames    [][]byte
+			cacheKey  string
 		}, 100),
 
 		tts_last_text_index: -1,
+		mutedRound:          -1,
 
 		talkRound: 0,
 
@@ -184,6 +268,7 @@ func NewConnectionHandler(
 		serverAudioFrameDuration: 60,
 
 		ctx:     ctx,
+		hooks:   hooks.NewChain(logger),
 		request: req, // 保存HTTP请求对象
 
 		headers: make(map[string]string),
@@ -307,19 +392,26 @@ func (h *ConnectionHandler) LogError(msg string) {
 func (h *ConnectionHandler) Handle(conn Connection) {
 	defer conn.Close()
 
-	h.conn = conn
+	h.conn = h.setupRecorder(conn)
+	defer h.trackSession()()
 
 	// 在WebSocket连接建立后加载用户AI配置
 	// 此时用户已通过JWT认证，可以安全地加载用户配置
 	if h.request != nil {
 		h.loadUserAIConfigurations(h.request)
 	}
+	h.loadSilencePolicy()
+	h.loadModerationProfile()
+	h.restoreDialogue()
+	h.setupMemory()
+	h.loadPersonaNames()
 
 	// 启动消息处理协程
 	go h.processClientAudioMessagesCoroutine() // 添加客户端音频消息处理协程
 	go h.processClientTextMessagesCoroutine()  // 添加客户端文本消息处理协程
 	go h.processTTSQueueCoroutine()            // 添加TTS队列处理协程
 	go h.sendAudioMessageCoroutine()           // 添加音频消息发送协程
+	go h.idleWatchCoroutine()                  // 添加用户未说话检测协程
 
 	// 优化后的MCP管理器处理
 	if h.mcpManager == nil {
@@ -336,7 +428,7 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 			"client_id":  h.clientId,
 			"token":      h.config.Server.Token,
 		}
-		if err := h.mcpManager.BindConnection(conn, h.functionRegister, params); err != nil {
+		if err := h.mcpManager.BindConnection(h.conn, h.functionRegister, params); err != nil {
 			h.LogError(fmt.Sprintf("绑定MCP管理器连接失败: %v", err))
 			return
 		}
@@ -350,7 +442,7 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 		case <-h.stopChan:
 			return
 		default:
-			messageType, message, err := conn.ReadMessage(h.stopChan)
+			messageType, message, err := h.conn.ReadMessage(h.stopChan)
 			if err != nil {
 				h.LogError(fmt.Sprintf("读取消息失败: %v, 退出主消息循环", err))
 				return
@@ -373,6 +465,8 @@ func (h *ConnectionHandler) processClientTextMessagesCoroutine() {
 			if err := h.processClientTextMessage(context.Background(), text); err != nil {
 				h.LogError(fmt.Sprintf("处理文本数据失败: %v", err))
 			}
+		case event := <-h.events:
+			event()
 		}
 	}
 }
@@ -387,6 +481,8 @@ func (h *ConnectionHandler) processClientAudioMessagesCoroutine() {
 			if h.closeAfterChat {
 				continue
 			}
+			h.countASRAudio(audioData)
+			h.processVAD(audioData)
 			if err := h.providers.asr.AddAudio(audioData); err != nil {
 				h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
 			}
@@ -400,7 +496,7 @@ func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 		case <-h.stopChan:
 			return
 		case task := <-h.audioMessagesQueue:
-			h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			h.sendAudioMessage(task.filepath, task.stream, task.frames, task.cacheKey, task.text, task.textIndex, task.round, task.emotion)
 		}
 	}
 }
@@ -408,18 +504,27 @@ func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 // OnAsrResult 实现 AsrEventListener 接口
 // 返回true则停止语音识别，返回false会继续语音识别
 func (h *ConnectionHandler) OnAsrResult(result string) bool {
+	h.recorder.ASRResult(result)
+	h.observeASRResult(result)
+	if result != "" {
+		h.resetIdleTimer()
+	}
+	h.consumeASRAudio(false)
+	return h.handleAsrResult(result)
+}
+
+// handleAsrResult 按监听模式处理识别结果，服务端静音检测也通过空结果进入此流程
+func (h *ConnectionHandler) handleAsrResult(result string) bool {
 	//h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-	if h.providers.asr.GetSilenceCount() >= 2 {
-		h.LogInfo("检测到连续两次静音，结束对话")
-		h.closeAfterChat = true // 如果连续两次静音，则结束对话
-		result = "长时间未检测到用户说话，请礼貌的结束对话"
+	if count := h.providers.asr.GetSilenceCount(); count > 0 && count >= h.silencePolicy.maxCount {
+		result = h.applySilencePolicy(count)
 	}
 	if h.clientListenMode == "auto" {
 		if result == "" {
 			return false
 		}
 		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-		h.handleChatMessage(context.Background(), result)
+		h.handleVoiceChat(result)
 		return true
 	} else if h.clientListenMode == "manual" {
 		h.client_asr_text += result
@@ -430,7 +535,7 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 			// 防止重复处理，只处理一次完整的ASR文本
 			asrText := h.client_asr_text
 			h.client_asr_text = "" // 清空文本，防止重复处理
-			h.handleChatMessage(context.Background(), asrText)
+			h.handleVoiceChat(asrText)
 			return true
 		}
 		return false
@@ -441,7 +546,7 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 		h.stopServerSpeak()
 		h.providers.asr.Reset() // 重置ASR状态，准备下一次识别
 		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-		h.handleChatMessage(context.Background(), result)
+		h.handleVoiceChat(result)
 		return true
 	}
 	return false
@@ -450,6 +555,7 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 // clientAbortChat 处理中止消息
 func (h *ConnectionHandler) clientAbortChat() error {
 	h.LogInfo("收到客户端中止消息，停止语音识别")
+	h.cancelRound()
 	h.stopServerSpeak()
 	h.sendTTSMessage("stop", "", 0)
 	h.clearSpeakStatus()
@@ -505,15 +611,27 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		return fmt.Errorf("用户请求退出对话")
 	}
 
-	// 增加对话轮次
-	h.talkRound++
+	// 增加对话轮次，同时取消上一轮仍在进行的任务
+	ctx, currentRound := h.startRound(ctx)
+	defer h.beginRoundWork()()
 	h.roundStartTime = time.Now()
-	currentRound := h.talkRound
 	h.LogInfo(fmt.Sprintf("开始新的对话轮次: %d", currentRound))
+	var err error
+	defer func(startTime time.Time) {
+		h.hookRoundEnd(currentRound, text, startTime, err)
+	}(h.roundStartTime)
+
+	// 审核用户输入，被拦截的输入不再显示和回复，钩子只收到审核后的文本
+	moderated := h.moderateInput(ctx, text)
+	text = moderated.Text
+	if moderated.Action == moderation.ActionBlock {
+		h.clientAbortChat()
+		return nil
+	}
 
 	// 普通文本消息处理流程
 	// 立即发送 stt 消息
-	err := h.sendSTTMessage(text)
+	err = h.sendSTTMessage(text)
 	if err != nil {
 		h.LogError(fmt.Sprintf("发送STT消息失败: %v", err))
 		return fmt.Errorf("发送STT消息失败: %v", err)
@@ -532,43 +650,68 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 	// }
 
 	h.LogInfo("收到聊天消息: " + text)
+	if language := utils.DetectLanguage(text); language != "" {
+		h.userLanguage = language
+	}
 
 	if h.quickReplyWakeUpWords(text) {
 		return nil
 	}
 
+	if !h.checkRoundQuota(currentRound, quota.ResourceLLMTokens, quota.ResourceTTSChars) {
+		return nil
+	}
+
+	if moderated.Action == moderation.ActionReply {
+		h.tts_last_text_index = 1 // 重置文本索引
+		h.SpeakAndPlay(moderated.Text, 1, currentRound)
+		return nil
+	}
+
 	// 添加用户消息到对话历史
 	h.dialogueManager.Put(chat.Message{
 		Role:    "user",
 		Content: text,
 	})
 
-	return h.genResponseByLLM(ctx, h.dialogueManager.GetLLMDialogue(), currentRound)
+	h.fitContext()
+	if !h.routeIntent(ctx, text, currentRound) {
+		h.queryMemory(text)
+		err = h.genResponseByLLM(ctx, h.llmDialogue(), currentRound)
+	}
+	h.compactDialogue()
+	h.saveDialogue()
+	return err
 }
 
-func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []providers.Message, round int) error {
-	defer func() {
-		if r := recover(); r != nil {
-			h.LogError(fmt.Sprintf("genResponseByLLM发生panic: %v", r))
-			errorMsg := "抱歉，处理您的请求时发生了错误"
-			h.tts_last_text_index = 1 // 重置文本索引
-			h.SpeakAndPlay(errorMsg, 1, round)
-		}
-	}()
-
+// genLLMStep 执行Agent循环中的一步：请求LLM并播报流式文本回复
+// 返回本步LLM回复中的工具调用（由调用方执行）以及已播报的文本分段序号
+func (h *ConnectionHandler) genLLMStep(ctx context.Context, messages []providers.Message, round int) ([]types.ToolCall, int, error) {
 	llmStartTime := time.Now()
+	h.replyParts = nil
 	//h.logger.Info("开始生成LLM回复, round:%d ", round)
 	for _, msg := range messages {
 		_ = msg
 		//msg.Print()
 	}
 	// 使用LLM生成回复
-	tools := h.functionRegister.GetAllFunctions()
-	responses, err := h.providers.llm.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
+	tools := h.personaTools(h.functionRegister.GetAllFunctions())
+	ctx = h.personaContext(ctx)
+	messages = h.hookPreLLM(ctx, messages, round)
+	h.recordLLMRequest(round, messages, tools)
+	llmType, transport := providerType(h.providers.llm), h.transportLabel()
+	responses, err := h.providers.llm.ResponseWithFunctions(ctx, h.sessionID, h.dialogueManager.ResolveMedia(messages), tools)
 	if err != nil {
-		return fmt.Errorf("LLM生成回复失败: %v", err)
+		metrics.LLMRequests.Inc(llmType, transport, "error")
+		return nil, 0, fmt.Errorf("LLM生成回复失败: %v", err)
 	}
 
+	// 录制本步收到的全部流式响应，回放时按原样返回
+	var chunks []types.Response
+	defer func() {
+		h.recorder.LLMResponse(round, chunks)
+	}()
+
 	// 处理回复
 	var responseMessage []string
 	processedChars := 0
@@ -578,21 +721,39 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 
 	// 处理流式响应
 	toolCallFlag := false
-	functionName := ""
-	functionID := ""
-	functionArguments := ""
+	toolCalls := newToolCallAccumulator()
 	contentArguments := ""
+	defer func() {
+		h.consumeLLMTokens(messages, chat.Message{
+			Role:      "assistant",
+			Content:   contentArguments,
+			ToolCalls: toolCalls.Calls(),
+		})
+	}()
 
+	firstResponse := true
 	for response := range responses {
+		if h.recorder != nil {
+			chunks = append(chunks, response)
+		}
+		if firstResponse && response.Error == "" {
+			firstResponse = false
+			metrics.LLMFirstToken.ObserveSince(llmStartTime, llmType, transport)
+		}
 		content := response.Content
 		toolCall := response.ToolCalls
 
 		if response.Error != "" {
+			if ctx.Err() != nil {
+				// 轮次被取消或单步超时导致的流中断，由调用方处理
+				return nil, textIndex, ctx.Err()
+			}
 			h.LogError(fmt.Sprintf("LLM响应错误: %s", response.Error))
+			metrics.LLMRequests.Inc(llmType, transport, "error")
 			errorMsg := "抱歉，服务暂时不可用，请稍后再试"
 			h.tts_last_text_index = 1 // 重置文本索引
 			h.SpeakAndPlay(errorMsg, 1, round)
-			return fmt.Errorf("LLM响应错误: %s", response.Error)
+			return nil, textIndex, fmt.Errorf("LLM响应错误: %s", response.Error)
 		}
 
 		if content != "" {
@@ -600,30 +761,23 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			contentArguments += content
 		}
 
-		if !toolCallFlag && strings.HasPrefix(contentArguments, "<tool_call>") {
+		if !toolCallFlag && strings.HasPrefix(contentArguments, toolCallTextPrefix) {
 			toolCallFlag = true
 		}
 
 		if len(toolCall) > 0 {
 			toolCallFlag = true
-			if toolCall[0].ID != "" {
-				functionID = toolCall[0].ID
-			}
-			if toolCall[0].Function.Name != "" {
-				functionName = toolCall[0].Function.Name
-			}
-			if toolCall[0].Function.Arguments != "" {
-				functionArguments += toolCall[0].Function.Arguments
-			}
+			toolCalls.Add(toolCall)
 		}
 
 		if content != "" {
 			if strings.Contains(content, "服务响应异常") {
 				h.LogError(fmt.Sprintf("检测到LLM服务异常: %s", content))
+				metrics.LLMRequests.Inc(llmType, transport, "error")
 				errorMsg := "抱歉，LLM服务暂时不可用，请稍后再试"
 				h.tts_last_text_index = 1 // 重置文本索引
 				h.SpeakAndPlay(errorMsg, 1, round)
-				return fmt.Errorf("LLM服务异常")
+				return nil, textIndex, fmt.Errorf("LLM服务异常")
 			}
 
 			if toolCallFlag {
@@ -641,8 +795,11 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 
 			// 按标点符号分割
 			if segment, charsCnt := utils.SplitAtLastPunctuation(currentText); charsCnt > 0 {
+				processedChars += charsCnt
+				if segment = h.hookLLMSegment(strings.TrimSpace(segment), round); segment == "" {
+					continue
+				}
 				textIndex++
-				segment = strings.TrimSpace(segment)
 				if textIndex == 1 {
 					now := time.Now()
 					llmSpentTime := now.Sub(llmStartTime)
@@ -655,102 +812,51 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 				if err != nil {
 					h.LogError(fmt.Sprintf("播放LLM回复分段失败: %v", err))
 				}
-				processedChars += charsCnt
 			}
 		}
 	}
 
+	if ctx.Err() != nil {
+		// 轮次被取消或单步超时，流被提前关闭，工具调用参数可能不完整，直接交由调用方处理
+		return nil, textIndex, ctx.Err()
+	}
+
+	metrics.LLMRequests.Inc(llmType, transport, "ok")
+
+	var calls []types.ToolCall
 	if toolCallFlag {
-		bHasError := false
-		if functionID == "" {
-			a := utils.Extract_json_from_string(contentArguments)
-			if a != nil {
-				functionName = a["name"].(string)
+		calls = toolCalls.Calls()
+		if len(calls) == 0 {
+			// 模型以<tool_call>文本的形式返回了函数调用
+			if a := utils.Extract_json_from_string(contentArguments); a != nil {
+				functionName, _ := a["name"].(string)
 				argumentsJson, err := json.Marshal(a["arguments"])
 				if err != nil {
 					h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
 				}
-				functionArguments = string(argumentsJson)
-				functionID = uuid.New().String()
+				calls = append(calls, types.ToolCall{
+					ID:   uuid.New().String(),
+					Type: "function",
+					Function: types.FunctionCall{
+						Name:      functionName,
+						Arguments: string(argumentsJson),
+					},
+				})
 			} else {
-				bHasError = true
-			}
-			if bHasError {
-				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
+				h.LogError(fmt.Sprintf("函数调用参数解析失败: %s", contentArguments))
 			}
 		}
-		if !bHasError {
+		if len(calls) > 0 {
 			// 清空responseMessage
 			responseMessage = []string{}
-			arguments := make(map[string]interface{})
-			if err := json.Unmarshal([]byte(functionArguments), &arguments); err != nil {
-				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
-			}
-			functionCallData := map[string]interface{}{
-				"id":        functionID,
-				"name":      functionName,
-				"arguments": functionArguments,
-			}
-			h.LogInfo(fmt.Sprintf("函数调用: %v", arguments))
-			if h.mcpManager.IsMCPTool(functionName) {
-				// 处理MCP函数调用
-				result, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
-				if err != nil {
-					h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
-					if result == nil {
-						result = "MCP工具调用失败"
-					}
-				}
-				// 判断result 是否是types.ActionResponse类型
-				if actionResult, ok := result.(types.ActionResponse); ok {
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				} else {
-					h.LogInfo(fmt.Sprintf("MCP函数调用结果: %v", result))
-					actionResult := types.ActionResponse{
-						Action: types.ActionTypeReqLLM, // 动作类型
-						Result: result,                 // 动作产生的结果
-					}
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				}
-
-			} else {
-				// 处理普通函数调用
-				userFunCallConfig := models.UserAIConfig{}
-				if userFunConfig := h.request.Context().Value("user_configs"); userFunConfig != nil {
-					if configs, ok := userFunConfig.([]*models.UserAIConfig); ok {
-						h.logger.Info("获得上下文user_configs配置: %v", configs)
-
-						for _, v := range configs {
-							if v.FunctionName == functionName {
-								userFunCallConfig = *v
-								break
-							}
-						}
-					}
-				}
-				if userFunCallConfig.FunctionName != "" {
-					funResult, err := h.executeUserFunctionCall(&userFunCallConfig, functionCallData)
-					if err != nil {
-						h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
-						if funResult.Result == "" {
-							funResult.Result = "BOT 模型调用失败"
-						}
-					}
-
-					actionResult := types.ActionResponse{
-						Action: types.ActionTypeReqLLM,
-						Result: funResult.Result,
-					}
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				}
-			}
+			h.LogInfo(fmt.Sprintf("本轮LLM回复包含 %d 个函数调用", len(calls)))
 		}
 	}
 
 	// 处理剩余文本
 	fullResponse := utils.JoinStrings(responseMessage)
 	if len(fullResponse) > processedChars {
-		remainingText := fullResponse[processedChars:]
+		remainingText := h.hookLLMSegment(fullResponse[processedChars:], round)
 		if remainingText != "" {
 			textIndex++
 			h.LogInfo(fmt.Sprintf("LLM回复分段[剩余文本]: %s, index: %d, round:%d", remainingText, textIndex, round))
@@ -764,81 +870,77 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 	// 分析回复并发送相应的情绪
 	content := utils.JoinStrings(responseMessage)
 
-	// 添加助手回复到对话历史
+	// 添加助手回复到对话历史，命中审核时只保存实际播报的文本
 	if !toolCallFlag {
+		content = h.moderatedReply(content)
 		h.dialogueManager.Put(chat.Message{
 			Role:    "assistant",
 			Content: content,
 		})
+		h.roundReply = content
 	}
 
-	return nil
+	return calls, textIndex, nil
 }
 
-func (h *ConnectionHandler) addToolCallMessage(toolResultText string, functionCallData map[string]interface{}) {
-
-	functionID := functionCallData["id"].(string)
-	functionName := functionCallData["name"].(string)
-	functionArguments := functionCallData["arguments"].(string)
-	h.LogInfo(fmt.Sprintf("函数调用结果: %s", toolResultText))
-	h.LogInfo(fmt.Sprintf("函数调用参数: %s", functionArguments))
-	h.LogInfo(fmt.Sprintf("函数调用名称: %s", functionName))
-	h.LogInfo(fmt.Sprintf("函数调用ID: %s", functionID))
+// addToolCallMessages 添加一条包含全部tool_calls的assistant消息，以及每个调用对应的tool消息
+func (h *ConnectionHandler) addToolCallMessages(calls []types.ToolCall, toolResults []string) {
+	for i, call := range calls {
+		h.LogInfo(fmt.Sprintf("函数调用结果: %s", toolResults[i]))
+		h.LogInfo(fmt.Sprintf("函数调用参数: %s", call.Function.Arguments))
+		h.LogInfo(fmt.Sprintf("函数调用名称: %s", call.Function.Name))
+		h.LogInfo(fmt.Sprintf("函数调用ID: %s", call.ID))
+	}
 
 	// 添加 assistant 消息，包含 tool_calls
 	h.dialogueManager.Put(chat.Message{
-		Role: "assistant",
-		ToolCalls: []types.ToolCall{{
-			ID: functionID,
-			Function: types.FunctionCall{
-				Arguments: functionArguments,
-				Name:      functionName,
-			},
-			Type:  "function",
-			Index: 0,
-		}},
+		Role:      "assistant",
+		ToolCalls: calls,
 	})
 
-	// 添加 tool 消息
-	toolCallID := functionID
-	if toolCallID == "" {
-		toolCallID = uuid.New().String()
+	// 添加 tool 消息，OpenAI兼容接口要求每个tool_call都有对应的结果
+	for i, call := range calls {
+		h.dialogueManager.Put(chat.Message{
+			Role:       "tool",
+			ToolCallID: call.ID,
+			Content:    toolResults[i],
+		})
 	}
-	h.dialogueManager.Put(chat.Message{
-		Role:       "tool",
-		ToolCallID: toolCallID,
-		Content:    toolResultText,
-	})
 }
 
-func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, functionCallData map[string]interface{}, textIndex int) {
+// handleFunctionResult 处理单个工具调用的结果
+// 返回写入对话历史的工具结果文本，以及是否需要再次请求LLM生成回复
+func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, call types.ToolCall, textIndex int) (string, bool) {
 	switch result.Action {
 	case types.ActionTypeError:
 		h.LogError(fmt.Sprintf("函数调用错误: %v", result.Result))
+		return fmt.Sprintf("调用工具失败: %v", result.Result), false
 	case types.ActionTypeNotFound:
 		h.LogError(fmt.Sprintf("函数未找到: %v", result.Result))
+		return fmt.Sprintf("%v", result.Result), false
 	case types.ActionTypeNone:
 		h.LogInfo(fmt.Sprintf("函数调用无操作: %v", result.Result))
+		return "调用工具成功: " + call.Function.Name, false
 	case types.ActionTypeResponse:
 		h.LogInfo(fmt.Sprintf("函数调用直接回复: %v", result.Response))
-		h.SystemSpeak(result.Response.(string))
+		text, _ := result.Response.(string)
+		h.SystemSpeak(text)
+		return text, false
 	case types.ActionTypeCallHandler:
-		resultStr := h.handleMCPResultCall(result)
-		h.addToolCallMessage(resultStr, functionCallData)
+		return h.handleMCPResultCall(result), false
 	case types.ActionTypeReqLLM:
 		h.LogInfo(fmt.Sprintf("函数调用后请求LLM: %v", result.Result))
 		text, ok := result.Result.(string)
 		if ok && len(text) > 0 {
-			h.addToolCallMessage(text, functionCallData)
-			h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
-
-		} else {
-			h.LogError(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
-			// 发送错误消息
-			errorMessage := fmt.Sprintf("函数调用结果解析失败 %v", result.Result)
-			h.SystemSpeak(errorMessage)
+			return text, true
 		}
+		h.LogError(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
+		// 发送错误消息
+		errorMessage := fmt.Sprintf("函数调用结果解析失败 %v", result.Result)
+		h.SystemSpeak(errorMessage)
+		return errorMessage, false
 	}
+	return fmt.Sprintf("%v", result.Result), false
 }
 
 func (h *ConnectionHandler) SystemSpeak(text string) error {
@@ -846,6 +948,9 @@ func (h *ConnectionHandler) SystemSpeak(text string) error {
 		h.logger.Warn("SystemSpeak 收到空文本，无法合成语音")
 		return errors.New("收到空文本，无法合成语音")
 	}
+	if h.headlessSink != nil {
+		return h.SpeakAndPlay(text, 0, h.talkRound)
+	}
 	texts := utils.SplitByPunctuation(text)
 	index := h.tts_last_text_index
 	for _, item := range texts {
@@ -863,7 +968,7 @@ func (h *ConnectionHandler) processTTSQueueCoroutine() {
 		case <-h.stopChan:
 			return
 		case task := <-h.ttsQueue:
-			h.processTTSTask(task.text, task.textIndex, task.round, task.filepath)
+			h.processTTSTask(h.roundContext(task.round), task.text, task.textIndex, task.round, task.filepath, task.emotion)
 		}
 	}
 }
@@ -880,6 +985,11 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 		return
 	}
 
+	// 静音提示音是配置的文件，不是本次合成的音频
+	if filepath == h.silencePolicy.chimeFile {
+		return
+	}
+
 	// 检查是否为快速回复缓存文件，如果是则不删除
 	if h.quickReplyCache != nil && h.quickReplyCache.IsCachedFile(filepath) {
 		h.LogInfo(fmt.Sprintf(reason+" 跳过删除缓存音频文件: %s", filepath))
@@ -901,19 +1011,32 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 }
 
 // processTTSTask 处理单个TTS任务
-func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int, filepath string) {
+func (h *ConnectionHandler) processTTSTask(ctx context.Context, text string, textIndex int, round int, filepath string, emotion string) {
+	var stream <-chan types.AudioChunk
+	var frames [][]byte
+	var cacheKey string
 	defer func() {
+		h.finishNotice(round, textIndex, filepath != "" || stream != nil || frames != nil)
 		h.audioMessagesQueue <- struct {
 			filepath  string
 			text      string
 			round     int
 			textIndex int
-		}{filepath, text, round, textIndex}
+			stream    <-chan types.AudioChunk
+			emotion   string
+			frames    [][]byte
+			cacheKey  string
+		}{filepath, text, round, textIndex, stream, emotion, frames, cacheKey}
 	}()
 	if filepath != "" {
 		return
 	}
 
+	if ctx.Err() != nil {
+		h.LogInfo(fmt.Sprintf("processTTSTask 对话轮次已取消, 跳过语音合成：%s, round: %d", text, round))
+		return
+	}
+
 	if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
 		// 尝试从缓存查找音频文件
 		if cachedFile := h.quickReplyCache.FindCachedAudio(text); cachedFile != "" {
@@ -925,18 +1048,88 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 	ttsStartTime := time.Now()
 	// 过滤表情
 	text = utils.RemoveAllEmoji(text)
+	// 韵律标记只用于合成，下发给客户端显示的文本去除标记
+	markup := text
+	text = strings.TrimSpace(tts.StripProsody(text))
 
 	if text == "" {
 		h.logger.Warn(fmt.Sprintf("收到空文本，无法合成语音, 索引: %d", textIndex))
 		return
 	}
 
-	// 生成语音文件
-	filepath, err := h.providers.tts.ToTTS(text)
+	// 快速回复词的音频会写入缓存，始终使用当前音色合成
+	var voiceProvider providers.TTSVoiceProvider
+	var voice string
+	if !utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
+		voiceProvider, voice = h.segmentVoice(text)
+	}
+
+	// 数字、日期、单位等转为朗读形式后合成，下发给客户端显示的仍是原文
+	speechText := normalizeSpeech(markup, h.segmentLanguage(text))
+	pieces := splitSpeech(speechText)
+	if len(pieces) == 0 {
+		h.logger.Warn(fmt.Sprintf("规范化后文本为空，无法合成语音, 索引: %d", textIndex))
+		return
+	}
+	if len(pieces) > 1 {
+		h.logger.Debug("文本过长，分%d段合成: %s", len(pieces), text)
+	}
+
+	// 命中TTS缓存时直接使用编码好的音频帧，不再合成，也不计入TTS用量
+	if cacheKey = h.ttsCacheKey(speechText, voice); cacheKey != "" {
+		if cached, ok := h.ttsCache.Get(cacheKey); ok {
+			h.logger.Debug("命中TTS缓存: %s, 帧数: %d", text, len(cached))
+			frames = cached
+			cacheKey = ""
+			return
+		}
+	}
+	h.consumeQuota(quota.ResourceTTSChars, int64(utf8.RuneCountInString(speechText)))
+
+	// 支持流式合成的提供者直接返回音频流，首帧音频无需等待整段合成完成
+	// 快速回复词需要完整的音频文件写入缓存，仍然走文件合成
+	if streamer, ok := h.providers.tts.(providers.TTSStreamProvider); ok && !utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
+		audioStream, err := h.synthesizeStream(ctx, pieces, func(ctx context.Context, piece string) (<-chan types.AudioChunk, error) {
+			if voice != "" {
+				return voiceProvider.ToTTSStreamWithVoice(ctx, piece, voice)
+			}
+			return streamer.ToTTSStream(ctx, piece)
+		})
+		if err != nil {
+			h.LogError(fmt.Sprintf("TTS流式转换失败:text(%s) %v", text, err))
+			return
+		}
+		stream = audioStream
+		metrics.TTSSynthesis.ObserveSince(ttsStartTime, providerType(h.providers.tts), h.transportLabel())
+		if textIndex == 1 {
+			h.logger.Debug("TTS流式转换建立耗时: %s, 文本: %s, 索引: %d", time.Since(ttsStartTime), text, textIndex)
+		}
+		return
+	}
+
+	// 生成语音文件，分段合成时直接得到编码好的音频帧
+	synthesize := func(piece string) (string, error) {
+		if voice != "" {
+			return voiceProvider.ToTTSWithVoice(piece, voice)
+		}
+		return h.providers.tts.ToTTS(piece)
+	}
+	if len(pieces) > 1 {
+		var err error
+		if frames, err = h.synthesizeFrames(ctx, pieces, synthesize); err != nil {
+			h.LogError(fmt.Sprintf("TTS分段转换失败:text(%s) %v", text, err))
+			frames = nil
+			return
+		}
+		metrics.TTSSynthesis.ObserveSince(ttsStartTime, providerType(h.providers.tts), h.transportLabel())
+		return
+	}
+	filepath, err := synthesize(speechText)
 	if err != nil {
 		h.LogError(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
 		return
 	} else {
+		metrics.TTSSynthesis.ObserveSince(ttsStartTime, providerType(h.providers.tts), h.transportLabel())
 		h.logger.Debug(fmt.Sprintf("TTS转换成功: text(%s), index(%d) %s", text, textIndex, filepath))
 		// 如果是快速回复词，保存到缓存
 		if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
@@ -947,10 +1140,11 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 			}
 		}
 	}
-	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
+	if atomic.LoadInt32(&h.serverVoiceStop) == 1 || ctx.Err() != nil { // 服务端语音停止或轮次已取消
 		h.LogInfo(fmt.Sprintf("processTTSTask 服务端语音停止, 不再发送音频数据：%s", text))
 		// 服务端语音停止时，根据配置删除已生成的音频文件
 		h.deleteAudioFileIfNeeded(filepath, "服务端语音停止时")
+		filepath = ""
 		return
 	}
 
@@ -961,8 +1155,27 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 	}
 }
 
-// speakAndPlay 合成并播放语音
+// segmentVoice 按分段文本的语言选择本次合成使用的音色，沿用当前音色时返回空
+// 分段中无法判断语言时（如纯数字、单个英文缩写）按用户最近一句话的语言选择
+func (h *ConnectionHandler) segmentVoice(text string) (providers.TTSVoiceProvider, string) {
+	provider, ok := h.providers.tts.(providers.TTSVoiceProvider)
+	if !ok {
+		return nil, ""
+	}
+	language := h.segmentLanguage(text)
+	voice := provider.VoiceForLanguage(language)
+	if voice != "" {
+		h.logger.Debug("分段语言为%s，使用音色%s合成: %s", language, voice, text)
+	}
+	return provider, voice
+}
+
+// speakAndPlay 合成并播放语音，无音频会话经过相同的情绪、审核和钩子处理后输出文本
 func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int) error {
+	if h.headlessSink != nil {
+		return h.emitHeadlessText(text, round)
+	}
+	var emotion string
 	defer func() {
 		// 将任务加入队列，不阻塞当前流程
 		h.ttsQueue <- struct {
@@ -970,28 +1183,34 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 			round     int
 			textIndex int
 			filepath  string
-		}{text, round, textIndex, ""}
+			emotion   string
+		}{text, round, textIndex, "", emotion}
 	}()
 
-	originText := text // 保存原始文本用于日志
+	originText := text                   // 保存原始文本用于日志
+	emotion, text = h.replyEmotion(text) // 提取情绪并去除情绪标记
 	text = utils.RemoveAllEmoji(text)
-	text = utils.RemoveMarkdownSyntax(text) // 移除Markdown语法
-	if text == "" {
+	text = tts.MapProsodyText(text, utils.RemoveMarkdownSyntax) // 移除Markdown语法
+	if tts.StripProsody(text) == "" {
 		h.logger.Warn("SpeakAndPlay 收到空文本，无法合成语音, %d, text:%s.", textIndex, originText)
 		return errors.New("收到空文本，无法合成语音")
 	}
 
+	// 先审核再延续上一段的韵律标记，审核的是本段实际的回复文本
+	if text = h.moderateOutput(text, round); text == "" {
+		return nil
+	}
+	text = h.applyProsody(text)
+	if text = h.hookPreTTS(text, round); text == "" {
+		return nil
+	}
+
 	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
 		h.LogInfo(fmt.Sprintf("speakAndPlay 服务端语音停止, 不再发送音频数据：%s", text))
 		text = ""
 		return errors.New("服务端语音已停止，无法合成语音")
 	}
 
-	if len(text) > 255 {
-		h.logger.Warn(fmt.Sprintf("文本过长，超过255字符限制，截断合成语音: %s", text))
-		text = text[:255] // 截断文本
-	}
-
 	return nil
 }
 
@@ -1033,6 +1252,9 @@ clearAudioQueue:
 		select {
 		case task := <-h.audioMessagesQueue:
 			h.LogInfo(fmt.Sprintf(msgPrefix+"丢弃一个音频任务: %s", task.text))
+			if task.stream != nil {
+				go drainAudioStream(task.stream)
+			}
 			// 根据配置删除被丢弃的音频文件
 			h.deleteAudioFileIfNeeded(task.filepath, msgPrefix+"丢弃音频任务时")
 		default:
@@ -1047,6 +1269,17 @@ clearAudioQueue:
 func (h *ConnectionHandler) Close() {
 	h.closeOnce.Do(func() {
 		close(h.stopChan)
+		h.cancelRound()
+		h.extractMemory()
+		h.reminderMu.Lock()
+		if h.detachReminders != nil {
+			h.detachReminders()
+		}
+		h.reminderMu.Unlock()
+		h.consumeASRAudio(true)
+		if err := h.recorder.Close(); err != nil {
+			h.LogError(fmt.Sprintf("关闭会话录制失败: %v", err))
+		}
 
 		h.closeOpusDecoder()
 		if h.providers.tts != nil {
@@ -1058,36 +1291,43 @@ func (h *ConnectionHandler) Close() {
 			}
 		}
 		h.cleanTTSAndAudioQueue(true)
+		if h.untrackSession != nil {
+			h.untrackSession()
+		}
 	})
 }
 
-// genResponseByVLLM 使用VLLLM处理包含图片的消息
-func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []providers.Message, imageData image.ImageData, text string, round int) error {
+// genResponseByVLLM 使用VLLLM处理包含图片的对话，messages为包含图片消息的对话历史，text为用户本轮的问题
+func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []providers.Message, text string, round int) error {
 	h.logger.Info("开始生成VLLLM回复 %v", map[string]interface{}{
 		"text":          text,
-		"has_url":       imageData.URL != "",
-		"has_data":      imageData.Data != "",
-		"format":        imageData.Format,
 		"message_count": len(messages),
 	})
 
-	// 使用VLLLM处理图片和文本
-	responses, err := h.providers.vlllm.ResponseWithImage(ctx, h.sessionID, messages, imageData, text)
+	// 使用VLLLM处理对话历史中的图片和文本，发送前按引用还原图片数据
+	responses, err := h.providers.vlllm.ResponseWithMessages(ctx, h.sessionID, h.dialogueManager.ResolveMedia(messages))
 	if err != nil {
 		h.LogError(fmt.Sprintf("VLLLM生成回复失败，尝试降级到普通LLM: %v", err))
-		// 降级策略：只使用文本部分调用普通LLM
-		fallbackText := fmt.Sprintf("用户发送了一张图片并询问：%s（注：当前无法处理图片，只能根据文字回答）", text)
-		fallbackMessages := append(messages, providers.Message{
-			Role:    "user",
-			Content: fallbackText,
-		})
+		// 降级策略：将本轮的图片消息替换为文字说明后调用普通LLM
+		fallbackMessages := append([]providers.Message(nil), messages...)
+		for i := len(fallbackMessages) - 1; i >= 0; i-- {
+			if fallbackMessages[i].Role == "user" {
+				fallbackMessages[i] = providers.Message{
+					Role:    "user",
+					Content: fmt.Sprintf("用户发送了一张图片并询问：%s（注：当前无法处理图片，只能根据文字回答）", text),
+				}
+				break
+			}
+		}
 		return h.genResponseByLLM(ctx, fallbackMessages, round)
 	}
+	h.consumeQuota(quota.ResourceVisionCalls, 1)
 
 	// 处理VLLLM流式回复
 	var responseMessage []string
 	processedChars := 0
 	textIndex := 0
+	h.replyParts = nil
 
 	atomic.StoreInt32(&h.serverVoiceStop, 0)
 
@@ -1103,29 +1343,38 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 
 		// 按标点符号分割
 		if segment, chars := utils.SplitAtLastPunctuation(currentText); chars > 0 {
+			processedChars += chars
+			if segment = h.hookLLMSegment(segment, round); segment == "" {
+				continue
+			}
 			textIndex++
 			h.tts_last_text_index = textIndex
 			h.SpeakAndPlay(segment, textIndex, round)
-			processedChars += chars
 		}
 	}
 
+	if ctx.Err() != nil {
+		h.LogInfo(fmt.Sprintf("VLLLM回复已取消, round: %d", round))
+		return nil
+	}
+
 	// 处理剩余文本
-	remainingText := utils.JoinStrings(responseMessage)[processedChars:]
+	remainingText := h.hookLLMSegment(utils.JoinStrings(responseMessage)[processedChars:], round)
 	if remainingText != "" {
 		textIndex++
 		h.tts_last_text_index = textIndex
 		h.SpeakAndPlay(remainingText, textIndex, round)
 	}
 
-	// 获取完整回复内容
-	content := utils.JoinStrings(responseMessage)
+	// 获取完整回复内容，命中审核时只保存实际播报的文本
+	content := h.moderatedReply(utils.JoinStrings(responseMessage))
 
 	// 添加VLLLM回复到对话历史
 	h.dialogueManager.Put(chat.Message{
 		Role:    "assistant",
 		Content: content,
 	})
+	h.roundReply = content
 
 	h.LogInfo(fmt.Sprintf("VLLLM回复处理完成 …%v", map[string]interface{}{
 		"content_length": len(content),
@@ -1241,7 +1490,7 @@ func (h *ConnectionHandler) convertConfigToOpenAITool(config *models.UserAIConfi
 }
 
 // executeUserFunctionCall 执行用户自定义Function Call
-func (h *ConnectionHandler) executeUserFunctionCall(config *models.UserAIConfig, args map[string]interface{}) (types.FunctionCallResult, error) {
+func (h *ConnectionHandler) executeUserFunctionCall(ctx context.Context, config *models.UserAIConfig, args map[string]interface{}) (types.FunctionCallResult, error) {
 	h.logger.Info("执行用户自定义Function Call: %s", config.FunctionName)
 
 	// 检查是否有LLM配置参数
@@ -1316,7 +1565,6 @@ func (h *ConnectionHandler) executeUserFunctionCall(config *models.UserAIConfig,
 	h.logger.Info("调用用户自定义LLM: %s, 模型: %s, 查询: %s", config.LLMType, config.ModelName, userMessage)
 
 	// 调用LLM生成回复
-	ctx := context.Background()
 	responses, err := provider.Response(ctx, h.sessionID, messages)
 	if err != nil {
 		h.logger.Error("LLM生成回复失败: %v", err)
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAgentMaxSteps     = 5
+	defaultAgentStepTimeout  = 30 * time.Second
+	defaultAgentFallbackText = "抱歉，这个问题我暂时没能处理完，请换个说法再试试吧"
+
+	// agentTraceArgsLimit 日志中工具参数的最大显示长度
+	agentTraceArgsLimit = 200
+)
+
+// agentToolCall Agent循环中一次工具调用的记录
+type agentToolCall struct {
+	Step       int           // 所属步数
+	Tool       string        // 工具名称
+	Arguments  string        // 调用参数
+	Duration   time.Duration // 执行耗时
+	ResultSize int           // 结果大小（字节）
+}
+
+// agentTrace 一轮对话中Agent循环的执行轨迹
+type agentTrace struct {
+	Round      int
+	Steps      int // 请求LLM的次数
+	ToolCalls  []agentToolCall
+	StopReason string
+	StartTime  time.Time
+}
+
+func newAgentTrace(round int) *agentTrace {
+	return &agentTrace{
+		Round:     round,
+		StartTime: time.Now(),
+	}
+}
+
+// addToolCall 记录当前步的一次工具调用
+func (t *agentTrace) addToolCall(call types.ToolCall, duration time.Duration, result string) {
+	t.ToolCalls = append(t.ToolCalls, agentToolCall{
+		Step:       t.Steps,
+		Tool:       call.Function.Name,
+		Arguments:  call.Function.Arguments,
+		Duration:   duration,
+		ResultSize: len(result),
+	})
+}
+
+func (t *agentTrace) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Agent执行轨迹 round: %d, 步数: %d, 工具调用: %d, 总耗时: %s, 结束原因: %s",
+		t.Round, t.Steps, len(t.ToolCalls), time.Since(t.StartTime), t.StopReason)
+	for _, call := range t.ToolCalls {
+		args := []rune(call.Arguments)
+		if len(args) > agentTraceArgsLimit {
+			args = append(args[:agentTraceArgsLimit], []rune("...")...)
+		}
+		fmt.Fprintf(&sb, "\n  [step %d] %s(%s) 耗时: %s, 结果大小: %d",
+			call.Step, call.Tool, string(args), call.Duration, call.ResultSize)
+	}
+	return sb.String()
+}
+
+// agentLimits 读取Agent循环的步数、单步超时和兜底回复配置，未配置时使用默认值
+func (h *ConnectionHandler) agentLimits() (int, time.Duration, string) {
+	maxSteps := defaultAgentMaxSteps
+	stepTimeout := defaultAgentStepTimeout
+	fallbackText := defaultAgentFallbackText
+	if h.config == nil {
+		return maxSteps, stepTimeout, fallbackText
+	}
+
+	cfg := h.config.Agent
+	if cfg.MaxSteps > 0 {
+		maxSteps = cfg.MaxSteps
+	}
+	if cfg.StepTimeout != "" {
+		if d, err := time.ParseDuration(cfg.StepTimeout); err == nil && d > 0 {
+			stepTimeout = d
+		} else {
+			h.LogError(fmt.Sprintf("Agent单步超时配置无效: %s, 使用默认值 %s", cfg.StepTimeout, stepTimeout))
+		}
+	}
+	if cfg.FallbackText != "" {
+		fallbackText = cfg.FallbackText
+	}
+	return maxSteps, stepTimeout, fallbackText
+}
+
+// genResponseByLLM 以Agent循环的方式生成回复
+// 每一步请求一次LLM并执行其返回的工具调用，工具结果需要LLM继续处理时进入下一步；
+// 步数用尽或单步超时后播报兜底回复，结束时输出本轮的执行轨迹
+func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []providers.Message, round int) error {
+	defer func() {
+		if r := recover(); r != nil {
+			h.LogError(fmt.Sprintf("genResponseByLLM发生panic: %v", r))
+			errorMsg := "抱歉，处理您的请求时发生了错误"
+			h.tts_last_text_index = 1 // 重置文本索引
+			h.SpeakAndPlay(errorMsg, 1, round)
+		}
+	}()
+
+	maxSteps, stepTimeout, fallbackText := h.agentLimits()
+	trace := newAgentTrace(round)
+	defer func() {
+		h.LogInfo(trace.String())
+	}()
+
+	for step := 1; ; step++ {
+		if ctx.Err() != nil {
+			trace.StopReason = "已取消"
+			return nil
+		}
+		if round != h.talkRound {
+			trace.StopReason = fmt.Sprintf("对话轮次已切换到 %d", h.talkRound)
+			return nil
+		}
+		if step > maxSteps {
+			trace.StopReason = fmt.Sprintf("达到最大步数 %d", maxSteps)
+			h.speakAgentFallback(fallbackText, round)
+			return nil
+		}
+		trace.Steps = step
+
+		stepCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+		calls, textIndex, err := h.genLLMStep(stepCtx, messages, round)
+		reqLLM := false
+		if err == nil && len(calls) > 0 {
+			reqLLM = h.executeToolCalls(stepCtx, calls, textIndex, trace)
+		}
+		timedOut := stepCtx.Err() == context.DeadlineExceeded
+		cancel()
+
+		if ctx.Err() != nil {
+			// 轮次被打断或连接关闭，不再播报兜底回复
+			trace.StopReason = "已取消"
+			return nil
+		}
+		if timedOut {
+			trace.StopReason = fmt.Sprintf("第 %d 步超时(%s)", step, stepTimeout)
+			h.speakAgentFallback(fallbackText, round)
+			return nil
+		}
+		if err != nil {
+			trace.StopReason = "LLM错误"
+			return err
+		}
+		if !reqLLM {
+			trace.StopReason = "完成"
+			return nil
+		}
+		messages = h.llmDialogue()
+	}
+}
+
+// speakAgentFallback 播报兜底回复，并写入对话历史以保证工具消息之后有对应的助手回复
+func (h *ConnectionHandler) speakAgentFallback(text string, round int) {
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: text,
+	})
+	h.roundReply = text
+	h.tts_last_text_index = 1 // 重置文本索引
+	if err := h.SpeakAndPlay(text, 1, round); err != nil {
+		h.LogError(fmt.Sprintf("播放兜底回复失败: %v", err))
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultContextTokens = 8000
+	// contextCompactRatio 估算token数超过预算的该比例时，在轮次结束后压缩早期对话
+	contextCompactRatio = 0.8
+	// contextRetainRatio 压缩后保留的近期对话占预算的比例
+	contextRetainRatio = 0.5
+	summaryTimeout     = 30 * time.Second
+)
+
+const summaryPrompt = `请将已有摘要和新移出的早期对话合并为一段新的摘要，供后续对话参考。
+要求：保留用户提到的关键信息、偏好、未完成的事项以及对话中已经得出的结论，省略寒暄和重复内容；
+使用第三人称陈述，不超过300字，只输出摘要内容。`
+
+// contextBudget 当前LLM的上下文token预算，已扣除为回复预留的max_tokens
+func (h *ConnectionHandler) contextBudget() int {
+	budget := defaultContextTokens
+	reserve := 0
+	if p, ok := h.providers.llm.(interface{ Config() *llm.Config }); ok && p.Config() != nil {
+		if p.Config().ContextTokens > 0 {
+			budget = p.Config().ContextTokens
+		}
+		reserve = p.Config().MaxTokens
+	}
+	if reserve > 0 && reserve < budget/2 {
+		budget -= reserve
+	}
+	return budget
+}
+
+// fitContext 请求LLM前确保对话不超过token预算
+// 正常情况下compactDialogue已将对话压缩到预算以内，摘要失败或单轮输入过长时直接丢弃最早的对话轮次
+func (h *ConnectionHandler) fitContext() {
+	budget := h.contextBudget()
+	if removed := h.dialogueManager.TrimToBudget(budget); len(removed) > 0 {
+		h.LogInfo(fmt.Sprintf("对话超过token预算 %d，丢弃最早的 %d 条消息", budget, len(removed)))
+	}
+}
+
+// compactDialogue 每轮对话结束后，对话接近token预算时将早期对话移出，并在后台压缩为摘要
+// 同一时间只进行一次压缩，压缩未完成时下一轮对话不会等待
+func (h *ConnectionHandler) compactDialogue() {
+	budget := h.contextBudget()
+	tokens := h.dialogueManager.TokenCount()
+	if tokens <= int(float64(budget)*contextCompactRatio) {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&h.compacting, 0, 1) {
+		return
+	}
+
+	removed := h.dialogueManager.TrimToBudget(int(float64(budget) * contextRetainRatio))
+	if len(removed) == 0 {
+		atomic.StoreInt32(&h.compacting, 0)
+		return
+	}
+
+	previous := h.dialogueManager.Summary()
+	go func() {
+		defer atomic.StoreInt32(&h.compacting, 0)
+		startTime := time.Now()
+		summary, err := h.summarizeDialogue(previous, removed)
+		if err != nil {
+			h.LogError(fmt.Sprintf("生成对话摘要失败，早期的 %d 条消息将被丢弃: %v", len(removed), err))
+			return
+		}
+		h.saveSummary(summary)
+		h.LogInfo(fmt.Sprintf("对话估算token数 %d 接近预算 %d，已将 %d 条早期消息压缩为摘要，耗时: %s",
+			tokens, budget, len(removed), time.Since(startTime)))
+	}()
+}
+
+// summarizeDialogue 使用LLM将已有摘要和移出的消息合并为新的摘要
+func (h *ConnectionHandler) summarizeDialogue(summary string, removed []chat.Message) (string, error) {
+	var sb strings.Builder
+	if summary != "" {
+		sb.WriteString("已有摘要：\n" + summary + "\n\n")
+	}
+	sb.WriteString("早期对话：\n")
+	for _, msg := range removed {
+		content := strings.TrimSpace(msg.Content)
+		switch {
+		case msg.Role == "user":
+			sb.WriteString("用户：" + content + "\n")
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			for _, call := range msg.ToolCalls {
+				sb.WriteString(fmt.Sprintf("助手调用工具：%s(%s)\n", call.Function.Name, call.Function.Arguments))
+			}
+		case msg.Role == "assistant" && content != "":
+			sb.WriteString("助手：" + content + "\n")
+		case msg.Role == "tool" && content != "":
+			sb.WriteString("工具结果：" + content + "\n")
+		}
+	}
+
+	// 压缩在轮次结束后进行，不随下一轮打断而取消，否则移出的消息会直接丢失
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+	responses, err := h.providers.llm.Response(ctx, h.sessionID, []chat.Message{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: sb.String()},
+	})
+	if err != nil {
+		return "", err
+	}
+	var result strings.Builder
+	for response := range responses {
+		result.WriteString(response)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	text := strings.TrimSpace(result.String())
+	if text == "" {
+		return "", errors.New("LLM返回的摘要为空")
+	}
+	if strings.HasPrefix(text, "【") {
+		// LLM提供者以【】包裹的文本返回服务异常
+		return "", errors.New(text)
+	}
+	return text, nil
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultRestoreMessages = 20
+	dialogueStoreTimeout   = 5 * time.Second
+)
+
+// SetDialogueStore 设置对话历史存储
+func (h *ConnectionHandler) SetDialogueStore(store chat.DialogueStore) {
+	h.dialogueStore = store
+}
+
+// restoreDialogue 加载同一会话之前保存的对话历史，用于断线重连后继续对话
+// 只恢复属于当前认证的设备和用户的对话历史
+func (h *ConnectionHandler) restoreDialogue() {
+	if h.dialogueStore == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialogueStoreTimeout)
+	defer cancel()
+	record, err := h.dialogueStore.Load(ctx, h.sessionID, h.deviceID, h.userID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("加载对话历史失败: %v", err))
+		return
+	}
+	if record == nil || record.Dialogue == "" {
+		return
+	}
+	if record.DeviceID != h.deviceID || record.UserID != h.userID {
+		h.LogError(fmt.Sprintf("会话 %s 的对话历史不属于当前设备或用户，拒绝恢复", h.sessionID))
+		return
+	}
+
+	maxMessages := defaultRestoreMessages
+	if h.config != nil && h.config.DialogueStore.MaxMessages > 0 {
+		maxMessages = h.config.DialogueStore.MaxMessages
+	}
+	if err := h.dialogueManager.Restore(record.Dialogue, maxMessages); err != nil {
+		h.LogError(fmt.Sprintf("恢复对话历史失败: %v", err))
+		return
+	}
+	h.dialogueManager.SetSummary(record.Summary)
+	h.LogInfo(fmt.Sprintf("已恢复会话 %s 的对话历史, 消息数: %d, 摘要长度: %d",
+		h.sessionID, h.dialogueManager.Length(), len([]rune(record.Summary))))
+}
+
+// saveDialogue 每轮对话结束后保存对话历史
+func (h *ConnectionHandler) saveDialogue() {
+	if h.dialogueStore == nil {
+		return
+	}
+
+	dialogue, err := h.dialogueManager.ToJSON(false)
+	if err != nil {
+		h.LogError(fmt.Sprintf("序列化对话历史失败: %v", err))
+		return
+	}
+
+	// 与后台压缩更新摘要互斥，避免用旧摘要覆盖刚保存的新摘要
+	h.dialogueSaveMu.Lock()
+	defer h.dialogueSaveMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), dialogueStoreTimeout)
+	defer cancel()
+	if err := h.dialogueStore.Save(ctx, chat.DialogueRecord{
+		SessionKey: h.sessionID,
+		DeviceID:   h.deviceID,
+		UserID:     h.userID,
+		Dialogue:   dialogue,
+		Summary:    h.dialogueManager.Summary(),
+	}); err != nil {
+		h.LogError(fmt.Sprintf("保存对话历史失败: %v", err))
+	}
+}
+
+// saveSummary 后台压缩完成后更新对话摘要
+func (h *ConnectionHandler) saveSummary(summary string) {
+	h.dialogueSaveMu.Lock()
+	defer h.dialogueSaveMu.Unlock()
+	h.dialogueManager.SetSummary(summary)
+	if h.dialogueStore == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialogueStoreTimeout)
+	defer cancel()
+	if err := h.dialogueStore.SaveSummary(ctx, chat.DialogueRecord{
+		SessionKey: h.sessionID,
+		DeviceID:   h.deviceID,
+		UserID:     h.userID,
+		Summary:    summary,
+	}); err != nil {
+		h.LogError(fmt.Sprintf("保存对话摘要失败: %v", err))
+	}
+}
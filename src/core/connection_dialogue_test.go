@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"angrymiao-ai-server/src/core/chat"
+)
+
+// stubDialogueStore 不按设备和用户过滤的存储，用于检查恢复时的归属校验
+type stubDialogueStore struct {
+	record *chat.DialogueRecord
+}
+
+func (s *stubDialogueStore) Load(ctx context.Context, sessionKey, deviceID, userID string) (*chat.DialogueRecord, error) {
+	return s.record, nil
+}
+
+func (s *stubDialogueStore) Save(ctx context.Context, record chat.DialogueRecord) error {
+	return nil
+}
+
+func (s *stubDialogueStore) SaveSummary(ctx context.Context, record chat.DialogueRecord) error {
+	return nil
+}
+
+func (s *stubDialogueStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestRestoreDialogueOwner(t *testing.T) {
+	saved := chat.NewDialogueManager(nil, nil)
+	saved.Put(chat.Message{Role: "user", Content: "你好"})
+	saved.Put(chat.Message{Role: "assistant", Content: "你好，有什么可以帮你"})
+	dialogue, err := saved.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		deviceID string
+		userID   string
+		expected int
+	}{
+		{name: "设备和用户一致", deviceID: "aa:bb", userID: "1", expected: 2},
+		{name: "用户不一致", deviceID: "aa:bb", userID: "2", expected: 0},
+		{name: "设备不一致", deviceID: "cc:dd", userID: "1", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ConnectionHandler{
+				sessionID:       "device-aa_bb",
+				deviceID:        tt.deviceID,
+				userID:          tt.userID,
+				dialogueManager: chat.NewDialogueManager(nil, nil),
+			}
+			h.SetDialogueStore(&stubDialogueStore{record: &chat.DialogueRecord{
+				SessionKey: "device-aa_bb",
+				DeviceID:   "aa:bb",
+				UserID:     "1",
+				Dialogue:   dialogue,
+				Summary:    "摘要",
+			}})
+			h.restoreDialogue()
+			if got := h.dialogueManager.Length(); got != tt.expected {
+				t.Errorf("恢复后消息数 = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
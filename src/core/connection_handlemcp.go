@@ -1,10 +1,10 @@
 package core
 
 import (
+	"angrymiao-ai-server/src/core/quota"
 	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 	"angrymiao-ai-server/src/vision"
-	"context"
 	"encoding/json"
 )
 
@@ -12,11 +12,17 @@ func (h *ConnectionHandler) initMCPResultHandlers() {
 	// 初始化MCP结果处理器
 	// 这里可以添加更多的处理器初始化逻辑
 	h.mcpResultHandlers = map[string]func(args interface{}){
-		"mcp_handler_exit":         h.mcp_handler_exit,
-		"mcp_handler_take_photo":   h.mcp_handler_take_photo,
-		"mcp_handler_change_voice": h.mcp_handler_change_voice,
-		"mcp_handler_change_role":  h.mcp_handler_change_role,
-		"mcp_handler_play_music":   h.mcp_handler_play_music,
+		"mcp_handler_exit":          h.mcp_handler_exit,
+		"mcp_handler_take_photo":    h.mcp_handler_take_photo,
+		"mcp_handler_change_voice":  h.mcp_handler_change_voice,
+		"mcp_handler_change_role":   h.mcp_handler_change_role,
+		"mcp_handler_play_music":    h.mcp_handler_play_music,
+		"mcp_handler_forget_memory": h.mcp_handler_forget_memory,
+		"mcp_handler_recall_memory": h.mcp_handler_recall_memory,
+
+		"mcp_handler_set_reminder":    h.mcp_handler_set_reminder,
+		"mcp_handler_list_reminders":  h.mcp_handler_list_reminders,
+		"mcp_handler_cancel_reminder": h.mcp_handler_cancel_reminder,
 	}
 }
 
@@ -62,7 +68,8 @@ func (h *ConnectionHandler) mcp_handler_play_music(args interface{}) {
 				round     int
 				textIndex int
 				filepath  string
-			}{name, h.talkRound, h.tts_last_text_index, path}
+				emotion   string
+			}{name, h.talkRound, h.tts_last_text_index, path, ""}
 		}
 	} else {
 		h.logger.Error("mcp_handler_play_music: args is not a string")
@@ -86,26 +93,10 @@ func (h *ConnectionHandler) mcp_handler_change_voice(args interface{}) {
 func (h *ConnectionHandler) mcp_handler_change_role(args interface{}) {
 	if params, ok := args.(map[string]string); ok {
 		role := params["role"]
-		prompt := params["prompt"]
-
 		h.logger.Info("mcp_handler_change_role: %s", role)
-		h.dialogueManager.SetSystemMessage(prompt)
-		h.dialogueManager.KeepRecentMessages(5) // 保留最近5条消息
-		if getter, ok := h.providers.tts.(configGetter); ok {
-			ttsProvider := getter.Config().Type
-			if ttsProvider == "edge" {
-				if role == "陕西女友" {
-					h.providers.tts.SetVoice("zh-CN-shaanxi-XiaoniNeural") // 陕西女友音色
-				} else if role == "英语老师" {
-					h.providers.tts.SetVoice("zh-CN-XiaoyiNeural") // 英语老师音色
-				} else if role == "好奇小男孩" {
-					h.providers.tts.SetVoice("zh-CN-YunxiNeural") // 好奇小男孩音色
-				}
-			}
-		}
-		h.SystemSpeak("已切换到新角色 " + role)
+		h.changePersona(role)
 	} else {
-		h.logger.Error("mcp_handler_change_role: args is not a string")
+		h.logger.Error("mcp_handler_change_role: args is not a map[string]string")
 	}
 }
 
@@ -130,8 +121,10 @@ func (h *ConnectionHandler) mcp_handler_take_photo(args interface{}) {
 
 	if !visionResponse.Success {
 		h.logger.Error("拍照失败: %s", visionResponse.Message)
-		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
+		h.genResponseByLLM(h.roundContext(h.talkRound), h.dialogueManager.GetLLMDialogue(), h.talkRound)
 
+	} else {
+		h.consumeQuota(quota.ResourceVisionCalls, 1)
 	}
 
 	h.SystemSpeak(visionResponse.Result)
@@ -1,14 +1,15 @@
 package core
 
 import (
-	"angrymiao-ai-server/src/core/chat"
 	"angrymiao-ai-server/src/core/image"
-	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/protocol"
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 )
 
 // handleMessage 处理接收到的消息
@@ -51,90 +52,94 @@ func (h *ConnectionHandler) handleMessage(messageType int, message []byte) error
 
 // processClientTextMessage 处理文本数据
 func (h *ConnectionHandler) processClientTextMessage(ctx context.Context, text string) error {
-	// 解析JSON消息
-	var msgJSON interface{}
-	if err := json.Unmarshal([]byte(text), &msgJSON); err != nil {
+	msg, err := protocol.Parse([]byte(text))
+	if err == protocol.ErrPlainText {
+		// 纯文本和数字消息原样回显，兼容旧客户端的心跳
 		return h.conn.WriteMessage(1, []byte(text))
 	}
-
-	// 检查是否为整数类型
-	if _, ok := msgJSON.(float64); ok {
-		return h.conn.WriteMessage(1, []byte(text))
+	if err != nil {
+		return h.replyProtocolError(err)
 	}
 
-	// 解析为map类型处理具体消息
-	msgMap, ok := msgJSON.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("消息格式错误")
+	switch m := msg.(type) {
+	case *protocol.HelloMessage:
+		err = h.handleHelloMessage(m)
+	case *protocol.AbortMessage:
+		err = h.clientAbortChat()
+	case *protocol.ListenMessage:
+		err = h.handleListenMessage(m)
+	case *protocol.ChatMessage:
+		err = h.handleChatMessage(ctx, m.Text)
+	case *protocol.VisionMessage:
+		err = h.handleVisionMessage(m)
+	case *protocol.ImageMessage:
+		err = h.handleImageMessage(ctx, m)
+	case *protocol.MCPMessage:
+		err = h.mcpManager.HandleAMMCPMessage(map[string]interface{}{
+			"type":       m.Type,
+			"session_id": m.SessionID,
+			"payload":    m.Payload,
+		})
 	}
+	return h.replyProtocolError(err)
+}
 
-	// 根据消息类型分发处理
-	// fmt.Println("msgMap", msgMap)
-	msgType, ok := msgMap["type"].(string)
-	if !ok {
-		return fmt.Errorf("消息类型错误")
+// replyProtocolError 协议版本支持时向客户端回复error消息，返回原错误供调用方记录
+func (h *ConnectionHandler) replyProtocolError(err error) error {
+	var protoErr *protocol.Error
+	if !errors.As(err, &protoErr) {
+		return err
 	}
-
-	switch msgType {
-	case "hello":
-		return h.handleHelloMessage(msgMap)
-	case "abort":
-		return h.clientAbortChat()
-	case "listen":
-		return h.handleListenMessage(msgMap)
-	case "chat":
-		return h.handleChatMessage(ctx, text)
-	case "vision":
-		return h.handleVisionMessage(msgMap)
-	case "image":
-		return h.handleImageMessage(ctx, msgMap)
-	case "mcp":
-		return h.mcpManager.HandleAMMCPMessage(msgMap)
-	default:
-		h.logger.Warn("=== 未知消息类型 ===", map[string]interface{}{
-			"unknown_type": msgType,
-			"full_message": msgMap,
-		})
-		return fmt.Errorf("未知的消息类型: %s", msgType)
+	if h.protocolVersion >= protocol.ErrorRepliesVersion {
+		if sendErr := h.sendProtocolMessage(protoErr.Reply(h.sessionID)); sendErr != nil {
+			h.LogError(fmt.Sprintf("发送错误消息失败: %v", sendErr))
+		}
 	}
+	return err
 }
 
-func (h *ConnectionHandler) handleVisionMessage(msgMap map[string]interface{}) error {
+func (h *ConnectionHandler) handleVisionMessage(msg *protocol.VisionMessage) error {
 	// 处理视觉消息
-	cmd := msgMap["cmd"].(string)
-	if cmd == "gen_pic" {
-	} else if cmd == "gen_video" {
-	} else if cmd == "read_img" {
-	}
+	h.LogInfo(fmt.Sprintf("收到视觉指令: %s", msg.Cmd))
 	return nil
 }
 
 // handleHelloMessage 处理欢迎消息
-// 客户端会上传语音格式和采样率等信息
-func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) error {
-	h.LogInfo("收到客户端欢迎消息: " + fmt.Sprintf("%v", msgMap))
+// 客户端会上传语音格式和采样率等信息，并协商协议版本
+func (h *ConnectionHandler) handleHelloMessage(msg *protocol.HelloMessage) error {
+	h.LogInfo(fmt.Sprintf("收到客户端欢迎消息: %+v", msg))
+	version, err := protocol.Negotiate(msg.Version)
+	if err != nil {
+		// 版本不受支持时仍按支持的最高版本回复错误，便于客户端提示升级
+		h.protocolVersion = protocol.CurrentVersion
+		return err
+	}
+	h.protocolVersion = version
+	h.LogInfo(fmt.Sprintf("协议版本协商完成: 客户端 %d, 使用 %d", msg.Version, version))
+
 	// 获取客户端编码格式
-	if audioParams, ok := msgMap["audio_params"].(map[string]interface{}); ok {
-		if format, ok := audioParams["format"].(string); ok {
-			h.clientAudioFormat = format
-			if format == "pcm" {
+	if audioParams := msg.AudioParams; audioParams != nil {
+		if audioParams.Format != "" {
+			h.clientAudioFormat = audioParams.Format
+			if audioParams.Format == "pcm" {
 				// 客户端使用PCM格式，服务端也使用PCM格式
 				h.serverAudioFormat = "pcm"
 			}
 		}
-		if sampleRate, ok := audioParams["sample_rate"].(float64); ok {
-			h.clientAudioSampleRate = int(sampleRate)
+		if audioParams.SampleRate > 0 {
+			h.clientAudioSampleRate = audioParams.SampleRate
 		}
-		if channels, ok := audioParams["channels"].(float64); ok {
-			h.clientAudioChannels = int(channels)
+		if audioParams.Channels > 0 {
+			h.clientAudioChannels = audioParams.Channels
 		}
-		if frameDuration, ok := audioParams["frame_duration"].(float64); ok {
-			h.clientAudioFrameDuration = int(frameDuration)
+		if audioParams.FrameDuration > 0 {
+			h.clientAudioFrameDuration = audioParams.FrameDuration
 		}
 		h.LogInfo(fmt.Sprintf("客户端音频参数: format=%s, sample_rate=%d, channels=%d, frame_duration=%d",
 			h.clientAudioFormat, h.clientAudioSampleRate, h.clientAudioChannels, h.clientAudioFrameDuration))
 	}
 	h.sendHelloMessage()
+	h.attachReminders()
 	h.closeOpusDecoder()
 	// 初始化opus解码器
 	opusDecoder, err := utils.NewOpusDecoder(&utils.OpusDecoderConfig{
@@ -147,35 +152,34 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		h.opusDecoder = opusDecoder
 		h.LogInfo("Opus解码器初始化成功")
 	}
+	h.setupVAD()
 
 	return nil
 }
 
 // handleListenMessage 处理语音相关消息
-func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) error {
-
-	// 处理state参数
-	state, ok := msgMap["state"].(string)
-	if !ok {
-		return fmt.Errorf("listen消息缺少state参数")
-	}
-
+func (h *ConnectionHandler) handleListenMessage(msg *protocol.ListenMessage) error {
 	// 处理mode参数
-	if mode, ok := msgMap["mode"].(string); ok {
-		h.clientListenMode = mode
-		h.LogInfo(fmt.Sprintf("客户端拾音模式：%s， %s", h.clientListenMode, state))
+	if msg.Mode != "" {
+		h.clientListenMode = msg.Mode
+		h.LogInfo(fmt.Sprintf("客户端拾音模式：%s， %s", h.clientListenMode, msg.State))
 		h.providers.asr.SetListener(h)
 	}
 
-	switch state {
+	switch msg.State {
 	case "start":
 		if h.client_asr_text != "" && h.clientListenMode == "manual" {
 			h.clientAbortChat()
 		}
 		h.clientVoiceStop = false
 		h.client_asr_text = ""
+		if h.voiceDetector != nil {
+			h.voiceDetector.Reset()
+		}
+		h.resetIdleTimer()
 	case "stop":
 		h.clientVoiceStop = true
+		h.markSpeechEnd()
 		// 重置ASR状态，停止语音识别
 		if h.providers.asr != nil {
 			if err := h.providers.asr.Reset(); err != nil {
@@ -184,9 +188,9 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 		}
 		h.LogInfo("客户端停止语音识别")
 	case "detect":
-		text, hasText := msgMap["text"].(string)
+		text := msg.Text
 
-		if hasText && text != "" {
+		if text != "" {
 			// 只有文本，使用普通LLM处理
 			h.LogInfo(fmt.Sprintf("检测到纯文本消息，使用LLM处理 %v", map[string]interface{}{
 				"text": text,
@@ -195,18 +199,22 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 		} else {
 			// 既没有图片也没有文本
 			h.logger.Warn("detect消息既没有text也没有image参数")
-			return fmt.Errorf("detect消息缺少text或image参数")
+			return protocol.Invalid(msg.Type, "state为detect时缺少text参数")
 		}
 	}
 	return nil
 }
 
 // handleImageMessage 处理图片消息
-func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[string]interface{}) error {
-	// 增加对话轮次
-	h.talkRound++
-	currentRound := h.talkRound
+func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msg *protocol.ImageMessage) error {
+	// 增加对话轮次，同时取消上一轮仍在进行的任务
+	ctx, currentRound := h.startRound(ctx)
+	defer h.beginRoundWork()()
 	h.LogInfo(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
+	var err error
+	defer func(startTime time.Time) {
+		h.hookRoundEnd(currentRound, msg.Text, startTime, err)
+	}(time.Now())
 
 	// 检查是否有VLLLM Provider
 	if h.providers.vlllm == nil {
@@ -215,31 +223,20 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 	}
 
 	// 解析文本内容
-	text, ok := msgMap["text"].(string)
-	if !ok {
+	text := msg.Text
+	if text == "" {
 		text = "请描述这张图片" // 默认提示
 	}
 
-	// 解析图片数据
-	imageDataMap, ok := msgMap["image_data"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("缺少图片数据")
-	}
-
-	imageData := image.ImageData{}
-	if url, ok := imageDataMap["url"].(string); ok {
-		imageData.URL = url
-	}
-	if data, ok := imageDataMap["data"].(string); ok {
-		imageData.Data = data
-	}
-	if format, ok := imageDataMap["format"].(string); ok {
-		imageData.Format = format
+	imageData := image.ImageData{
+		URL:    msg.ImageData.URL,
+		Data:   msg.ImageData.Data,
+		Format: msg.ImageData.Format,
 	}
 
 	// 验证图片数据
 	if imageData.URL == "" && imageData.Data == "" {
-		return fmt.Errorf("图片数据为空")
+		return protocol.Invalid(msg.Type, "image_data的url和data不能同时为空")
 	}
 
 	h.LogInfo(fmt.Sprintf("收到图片消息 %v", map[string]interface{}{
@@ -251,7 +248,7 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 	}))
 
 	// 立即发送STT消息
-	err := h.sendSTTMessage(text)
+	err = h.sendSTTMessage(text)
 	if err != nil {
 		h.logger.Error(fmt.Sprintf("发送STT消息失败: %v", err))
 		return fmt.Errorf("发送STT消息失败: %v", err)
@@ -264,30 +261,29 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 	}
 
 	// 发送思考状态的情绪
-	if err := h.sendEmotionMessage("thinking"); err != nil {
+	if err := h.sendEmotionMessage("thinking", 0); err != nil {
 		h.logger.Error(fmt.Sprintf("发送思考状态情绪消息失败: %v", err))
 		return fmt.Errorf("发送情绪消息失败: %v", err)
 	}
 
-	// 添加用户消息到对话历史（包含图片信息的描述）
-	userMessage := fmt.Sprintf("%s [用户发送了一张%s格式的图片]", text, imageData.Format)
-	h.dialogueManager.Put(chat.Message{
-		Role:    "user",
-		Content: userMessage,
-	})
+	if !h.checkRoundQuota(currentRound, quota.ResourceVisionCalls, quota.ResourceTTSChars) {
+		return nil
+	}
+
+	// 添加包含图片的用户消息到对话历史，后续追问时仍可使用图片，只支持文本的模型使用图片的文字说明
+	h.dialogueManager.Put(types.NewMultimodalMessage("user",
+		types.TextPart(text),
+		types.ImagePart(imageData.URL, imageData.Data, imageData.Format,
+			fmt.Sprintf("[用户发送了一张%s格式的图片]", imageData.Format)),
+	))
 
 	// 获取对话历史
-	messages := make([]providers.Message, 0)
-	for _, msg := range h.dialogueManager.GetLLMDialogue() {
-		// 排除包含图片信息的最后一条消息，因为我们要用VLLLM处理
-		if msg.Role == "user" && strings.Contains(msg.Content, "[用户发送了一张") {
-			continue
-		}
-		messages = append(messages, providers.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
+	h.fitContext()
+	h.queryMemory(text)
+	messages := h.llmDialogue()
 
-	return h.genResponseByVLLM(ctx, messages, imageData, text, currentRound)
+	err = h.genResponseByVLLM(ctx, messages, text, currentRound)
+	h.compactDialogue()
+	h.saveDialogue()
+	return err
 }
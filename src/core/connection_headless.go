@@ -0,0 +1,202 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/moderation"
+	"angrymiao-ai-server/src/core/persona"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// toolCallTextPrefix 模型以文本形式返回函数调用时的前缀
+const toolCallTextPrefix = "<tool_call>"
+
+// ErrInputBlocked 无音频会话中用户输入被内容审核拦截
+var ErrInputBlocked = errors.New("用户输入被内容审核拦截")
+
+// HeadlessEvent 无音频会话输出的事件，Text和Tool只会设置其中一个
+type HeadlessEvent struct {
+	Text string     // 回复文本增量
+	Tool *ToolTrace // 一次工具调用的记录
+}
+
+// ToolTrace 无音频会话中一次工具调用的记录
+type ToolTrace struct {
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"`
+	Result     string `json:"result"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// headlessConn 无音频会话使用的连接，没有设备端，下发的消息直接丢弃
+type headlessConn struct {
+	id       string
+	closed   int32
+	lastTime time.Time
+}
+
+func (c *headlessConn) WriteMessage(messageType int, data []byte) error {
+	return nil
+}
+
+func (c *headlessConn) ReadMessage(stopChan <-chan struct{}) (int, []byte, error) {
+	<-stopChan
+	return 0, nil, errors.New("无音频会话不接收消息")
+}
+
+func (c *headlessConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *headlessConn) GetID() string {
+	return c.id
+}
+
+func (c *headlessConn) GetType() string {
+	return "http"
+}
+
+func (c *headlessConn) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func (c *headlessConn) GetLastActiveTime() time.Time {
+	return c.lastTime
+}
+
+func (c *headlessConn) IsStale(timeout time.Duration) bool {
+	return false
+}
+
+// StartHeadless 以无音频模式初始化会话，用于HTTP接口复用语音对话的人设和工具
+// 加载用户自定义函数并绑定MCP工具（本地和外部服务，设备端工具不可用），回复文本和工具调用通过sink输出
+func (h *ConnectionHandler) StartHeadless(sink func(HeadlessEvent)) error {
+	h.headlessSink = sink
+	h.conn = &headlessConn{id: h.sessionID, lastTime: time.Now()}
+
+	if h.request != nil {
+		h.loadUserAIConfigurations(h.request)
+	}
+
+	if h.mcpManager == nil {
+		return errors.New("没有可用的MCP管理器")
+	}
+	params := map[string]interface{}{
+		"session_id": h.sessionID,
+		"vision_url": h.config.Web.VisionURL,
+		"device_id":  h.deviceID,
+		"client_id":  h.clientId,
+		"token":      h.config.Server.Token,
+	}
+	if err := h.mcpManager.BindConnection(h.conn, h.functionRegister, params); err != nil {
+		return fmt.Errorf("绑定MCP管理器连接失败: %v", err)
+	}
+	h.loadPersonaNames()
+	h.loadModerationProfile()
+	h.untrackSession = h.trackSession()
+	return nil
+}
+
+// UseRole 按名称或别名切换到当前用户可以使用的角色，角色不存在时返回false
+func (h *ConnectionHandler) UseRole(name string) bool {
+	p, err := h.findPersona(name)
+	if err != nil {
+		if !errors.Is(err, persona.ErrNotFound) {
+			h.LogError(fmt.Sprintf("查找角色%s失败: %v", name, err))
+		}
+		return false
+	}
+	h.applyPersona(p)
+	return true
+}
+
+// Chat 以给定的历史消息运行一轮对话，最后一条消息为本轮的用户输入
+func (h *ConnectionHandler) Chat(ctx context.Context, messages []chat.Message) error {
+	if h.headlessSink == nil {
+		return errors.New("会话未以无音频模式启动")
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return errors.New("最后一条消息必须是用户消息")
+	}
+
+	ctx, currentRound := h.startRound(ctx)
+	h.roundStartTime = time.Now()
+	input := messages[len(messages)-1].Content
+	h.LogInfo(fmt.Sprintf("无音频会话收到消息: %s, round: %d", input, currentRound))
+
+	// 审核本轮的用户输入，对话历史、钩子和记忆只使用审核后的文本
+	moderated := h.moderateInput(ctx, input)
+	input = moderated.Text
+	var err error
+	defer func() {
+		h.hookRoundEnd(currentRound, input, h.roundStartTime, err)
+	}()
+	switch moderated.Action {
+	case moderation.ActionBlock:
+		err = ErrInputBlocked
+		return err
+	case moderation.ActionReply:
+		err = h.SpeakAndPlay(moderated.Text, 1, currentRound)
+		return err
+	}
+
+	for _, msg := range messages[:len(messages)-1] {
+		h.dialogueManager.Put(msg)
+	}
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: input})
+	h.fitContext()
+	err = h.genResponseByLLM(ctx, h.llmDialogue(), currentRound)
+	return err
+}
+
+// emitHeadlessText 无音频会话中输出一段回复：去除情绪标记，经过内容审核和合成前钩子后去除韵律标记输出
+func (h *ConnectionHandler) emitHeadlessText(text string, round int) error {
+	_, text = h.replyEmotion(text)
+	if tts.StripProsody(text) == "" {
+		return nil
+	}
+	if text = h.moderateOutput(text, round); text == "" {
+		return nil
+	}
+	text = h.applyProsody(text)
+	if text = h.hookPreTTS(text, round); text == "" {
+		return nil
+	}
+	if text = tts.StripProsody(text); text == "" {
+		return nil
+	}
+	// 分段时去掉了句间的空白，英文等以空格分词的文本需要补回
+	first, _ := utf8.DecodeRuneInString(text)
+	if isWordRune(h.headlessLast) && first < utf8.RuneSelf && !unicode.IsSpace(first) {
+		text = " " + text
+	}
+	h.headlessLast, _ = utf8.DecodeLastRuneInString(text)
+	h.headlessSink(HeadlessEvent{Text: text})
+	return nil
+}
+
+// isWordRune 是否为需要与下一段之间保留空格的ASCII字符
+func isWordRune(r rune) bool {
+	return r != 0 && r < utf8.RuneSelf && !unicode.IsSpace(r)
+}
+
+// emitToolTrace 无音频会话中输出工具调用记录
+func (h *ConnectionHandler) emitToolTrace(call types.ToolCall, result string, duration time.Duration) {
+	if h.headlessSink == nil {
+		return
+	}
+	h.headlessSink(HeadlessEvent{Tool: &ToolTrace{
+		Name:       call.Function.Name,
+		Arguments:  call.Function.Arguments,
+		Result:     result,
+		DurationMs: duration.Milliseconds(),
+	}})
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/hooks"
+	"angrymiao-ai-server/src/core/types"
+	"context"
+	"fmt"
+	"time"
+)
+
+// hookSession 钩子使用的会话信息
+func (h *ConnectionHandler) hookSession(round int) hooks.Session {
+	return hooks.Session{
+		SessionID: h.sessionID,
+		DeviceID:  h.deviceID,
+		UserID:    h.userID,
+		Transport: h.transportLabel(),
+		Round:     round,
+	}
+}
+
+// hookASRResult 语音识别结果进入对话前调用钩子，返回空字符串表示丢弃
+func (h *ConnectionHandler) hookASRResult(text string) string {
+	if h.hooks.Empty() {
+		return text
+	}
+	result := h.hooks.OnASRResult(h.ctx, h.hookSession(h.talkRound+1), text)
+	if result == "" {
+		h.LogInfo(fmt.Sprintf("语音识别结果被钩子丢弃: %s", text))
+	}
+	return result
+}
+
+// hookPreLLM 请求LLM前调用钩子，返回实际发送的消息
+func (h *ConnectionHandler) hookPreLLM(ctx context.Context, messages []types.Message, round int) []types.Message {
+	if h.hooks.Empty() {
+		return messages
+	}
+	return h.hooks.PreLLM(ctx, h.hookSession(round), messages)
+}
+
+// hookLLMSegment 播报LLM回复分段前调用钩子，返回空字符串表示不播报
+func (h *ConnectionHandler) hookLLMSegment(segment string, round int) string {
+	if h.hooks.Empty() || segment == "" {
+		return segment
+	}
+	return h.hooks.OnLLMSegment(h.roundContext(round), h.hookSession(round), segment)
+}
+
+// hookPreToolCall 执行工具调用前调用钩子，被拒绝时返回交给LLM的结果
+func (h *ConnectionHandler) hookPreToolCall(ctx context.Context, call *types.ToolCall, round int) (types.ActionResponse, bool) {
+	if h.hooks.Empty() {
+		return types.ActionResponse{}, false
+	}
+	decision := h.hooks.PreToolCall(ctx, h.hookSession(round), call)
+	if !decision.Deny {
+		return types.ActionResponse{}, false
+	}
+	h.LogInfo(fmt.Sprintf("工具调用被钩子拒绝: %s, 原因: %s", call.Function.Name, decision.Reason))
+	return types.ActionResponse{
+		Action: types.ActionTypeReqLLM,
+		Result: decision.Reason,
+	}, true
+}
+
+// hookPostToolResult 处理工具结果前调用钩子
+func (h *ConnectionHandler) hookPostToolResult(ctx context.Context, call types.ToolCall, result *types.ActionResponse, round int) {
+	if h.hooks.Empty() {
+		return
+	}
+	h.hooks.PostToolResult(ctx, h.hookSession(round), call, result)
+}
+
+// hookPreTTS 合成语音前调用钩子，返回空字符串表示不播报
+func (h *ConnectionHandler) hookPreTTS(text string, round int) string {
+	if h.hooks.Empty() {
+		return text
+	}
+	return h.hooks.PreTTS(h.roundContext(round), h.hookSession(round), text)
+}
+
+// hookRoundEnd 一轮对话生成回复结束后调用钩子
+func (h *ConnectionHandler) hookRoundEnd(round int, input string, startTime time.Time, err error) {
+	if h.hooks.Empty() {
+		return
+	}
+	var reply string
+	if round == h.talkRound {
+		reply = h.roundReply
+	}
+	h.hooks.OnRoundEnd(h.ctx, h.hookSession(round), hooks.RoundSummary{
+		Input:     input,
+		Reply:     reply,
+		StartTime: startTime,
+		Duration:  time.Since(startTime),
+		Err:       err,
+	})
+}
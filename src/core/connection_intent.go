@@ -0,0 +1,73 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/intent"
+	"angrymiao-ai-server/src/core/types"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// SetIntentRouter 设置本地意图路由器，为nil时全部交给LLM处理
+func (h *ConnectionHandler) SetIntentRouter(router *intent.Router) {
+	h.intentRouter = router
+}
+
+// routeIntent 用本地规则识别用户意图，命中时直接调用对应的工具，用户消息需已写入对话历史
+// 返回true表示本轮已处理完毕；未命中、工具不可用或需要LLM根据工具结果生成回复时返回false
+func (h *ConnectionHandler) routeIntent(ctx context.Context, text string, round int) bool {
+	if h.intentRouter == nil {
+		return false
+	}
+	result := h.intentRouter.Match(text)
+	if result == nil {
+		return false
+	}
+	if !h.mcpManager.IsMCPTool(result.Tool) && h.findUserFunctionConfig(result.Tool) == nil {
+		h.LogInfo(fmt.Sprintf("命中本地意图 %s, 但工具 %s 不可用，交给LLM处理", result.Intent, result.Tool))
+		return false
+	}
+	if !h.personaAllowsTool(result.Tool) {
+		h.LogInfo(fmt.Sprintf("命中本地意图 %s, 但当前角色不能使用工具 %s, 交给LLM处理", result.Intent, result.Tool))
+		return false
+	}
+	arguments, err := json.Marshal(result.Arguments)
+	if err != nil {
+		h.LogError(fmt.Sprintf("本地意图 %s 的参数序列化失败: %v", result.Intent, err))
+		return false
+	}
+	h.LogInfo(fmt.Sprintf("命中本地意图: %s, 置信度: %.2f, 工具: %s, 参数: %s",
+		result.Intent, result.Confidence, result.Tool, arguments))
+
+	trace := newAgentTrace(round)
+	trace.StopReason = "本地意图: " + result.Intent
+	defer func() {
+		h.LogInfo(trace.String())
+	}()
+	call := types.ToolCall{
+		ID:   uuid.New().String(),
+		Type: "function",
+		Function: types.FunctionCall{
+			Name:      result.Tool,
+			Arguments: string(arguments),
+		},
+	}
+	reqLLM := h.executeToolCalls(ctx, []types.ToolCall{call}, 0, trace)
+	if result.Reply == "" {
+		return !reqLLM
+	}
+
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: result.Reply,
+	})
+	h.roundReply = result.Reply
+	h.tts_last_text_index = 1 // 重置文本索引
+	if err := h.SpeakAndPlay(result.Reply, 1, round); err != nil {
+		h.LogError(fmt.Sprintf("播放本地意图回复失败: %v", err))
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/memory"
+	"fmt"
+	"strings"
+)
+
+// SetMemoryManager 设置长期记忆管理器，为nil时不使用长期记忆
+func (h *ConnectionHandler) SetMemoryManager(manager *memory.Manager) {
+	h.memoryManager = manager
+}
+
+// setupMemory 为当前用户加载长期记忆，未登录的设备按会话区分
+func (h *ConnectionHandler) setupMemory() {
+	if h.memoryManager == nil {
+		return
+	}
+	userKey := h.userID
+	if userKey == "" {
+		userKey = h.sessionID
+	}
+	h.dialogueManager.SetMemory(h.memoryManager.ForUser(userKey))
+	h.LogInfo(fmt.Sprintf("已启用长期记忆: %s", userKey))
+}
+
+// queryMemory 检索与用户本轮输入相关的记忆，本轮后续请求LLM时作为系统消息注入
+func (h *ConnectionHandler) queryMemory(text string) {
+	h.memoryPrompt = ""
+	mem := h.dialogueManager.Memory()
+	if mem == nil {
+		return
+	}
+	prompt, err := mem.QueryMemory(text)
+	if err != nil {
+		h.LogError(fmt.Sprintf("检索长期记忆失败: %v", err))
+		return
+	}
+	h.memoryPrompt = prompt
+}
+
+// llmDialogue 获取请求LLM的对话，包含本轮检索到的记忆
+func (h *ConnectionHandler) llmDialogue() []chat.Message {
+	return h.dialogueManager.GetLLMDialogueWithMemory(h.memoryPrompt)
+}
+
+// extractMemory 连接结束后在后台从上次提取之后的新对话中提取记忆
+func (h *ConnectionHandler) extractMemory() {
+	mem := h.dialogueManager.Memory()
+	if mem == nil {
+		return
+	}
+	dialogue := h.dialogueManager.TakeNewMessages()
+	if len(dialogue) == 0 {
+		return
+	}
+	go func() {
+		if err := mem.SaveMemory(dialogue); err != nil {
+			h.LogError(fmt.Sprintf("提取长期记忆失败: %v", err))
+		}
+	}()
+}
+
+// memoryEditor 获取支持查看和删除的记忆，未启用时返回nil
+func (h *ConnectionHandler) memoryEditor() chat.MemoryEditor {
+	if editor, ok := h.dialogueManager.Memory().(chat.MemoryEditor); ok {
+		return editor
+	}
+	return nil
+}
+
+func (h *ConnectionHandler) mcp_handler_forget_memory(args interface{}) {
+	content, _ := args.(string)
+	h.logger.Info("mcp_handler_forget_memory: %s", content)
+	editor := h.memoryEditor()
+	if editor == nil {
+		h.SystemSpeak("我现在还没有开启记忆功能哦")
+		return
+	}
+	h.memoryPrompt = ""
+
+	if content == "all" {
+		if err := h.dialogueManager.Memory().ClearMemory(); err != nil {
+			h.LogError(fmt.Sprintf("清空长期记忆失败: %v", err))
+			h.SystemSpeak("抱歉，删除记忆时出了点问题")
+			return
+		}
+		h.dialogueManager.TakeNewMessages() // 清空之前的对话不再用于提取记忆
+		h.SystemSpeak("好的，我已经忘掉了关于你的所有记忆")
+		return
+	}
+
+	forgotten, err := editor.ForgetMemory(content)
+	if err != nil {
+		h.LogError(fmt.Sprintf("删除长期记忆失败: %v", err))
+		h.SystemSpeak("抱歉，删除记忆时出了点问题")
+		return
+	}
+	if len(forgotten) == 0 {
+		h.SystemSpeak("我没有找到关于这件事的记忆")
+		return
+	}
+	h.SystemSpeak("好的，我已经忘掉了：" + strings.Join(forgotten, "；"))
+}
+
+func (h *ConnectionHandler) mcp_handler_recall_memory(args interface{}) {
+	h.logger.Info("mcp_handler_recall_memory")
+	editor := h.memoryEditor()
+	if editor == nil {
+		h.SystemSpeak("我现在还没有开启记忆功能哦")
+		return
+	}
+
+	facts, err := editor.ListMemory()
+	if err != nil {
+		h.LogError(fmt.Sprintf("读取长期记忆失败: %v", err))
+		h.SystemSpeak("抱歉，读取记忆时出了点问题")
+		return
+	}
+	if len(facts) == 0 {
+		h.SystemSpeak("我还没有记住关于你的信息呢")
+		return
+	}
+	h.SystemSpeak("我记得这些关于你的事情：" + strings.Join(facts, "；"))
+}
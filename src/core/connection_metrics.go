@@ -0,0 +1,80 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/metrics"
+	"angrymiao-ai-server/src/core/providers/asr"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+	"sync/atomic"
+	"time"
+)
+
+// providerType 提供者的类型名称，用作指标标签
+func providerType(provider interface{}) string {
+	switch p := provider.(type) {
+	case interface{ Config() *asr.Config }:
+		if p.Config() != nil {
+			return p.Config().Type
+		}
+	case interface{ Config() *llm.Config }:
+		if p.Config() != nil {
+			return p.Config().Type
+		}
+	case interface{ Config() *tts.Config }:
+		if p.Config() != nil {
+			return p.Config().Type
+		}
+	}
+	return "unknown"
+}
+
+// transportLabel 当前会话的传输层类型，用作指标标签
+func (h *ConnectionHandler) transportLabel() string {
+	if h.conn != nil {
+		return h.conn.GetType()
+	}
+	if h.transportType != "" {
+		return h.transportType
+	}
+	return "unknown"
+}
+
+// trackSession 统计会话数，返回会话结束时调用的函数
+func (h *ConnectionHandler) trackSession() func() {
+	transport := h.transportLabel()
+	metrics.SessionsTotal.Inc(transport)
+	metrics.ActiveSessions.Inc(transport)
+	return func() {
+		metrics.ActiveSessions.Dec(transport)
+	}
+}
+
+// markSpeechEnd 记录用户说话结束的时间，用于统计ASR最终结果的延迟
+// 自动拾音模式下由服务端VAD检测，手动模式下为客户端停止拾音的时间
+func (h *ConnectionHandler) markSpeechEnd() {
+	atomic.StoreInt64(&h.speechEndTime, time.Now().UnixNano())
+}
+
+// observeASRResult 统计说话结束后第一个识别结果的延迟，没有检测到说话结束时不统计
+func (h *ConnectionHandler) observeASRResult(result string) {
+	if result == "" {
+		return
+	}
+	end := atomic.SwapInt64(&h.speechEndTime, 0)
+	if end == 0 {
+		return
+	}
+	metrics.ASRFinalLatency.ObserveDuration(time.Since(time.Unix(0, end)), providerType(h.providers.asr), h.transportLabel())
+}
+
+// observeToolCall 统计一次工具调用的耗时和结果
+func (h *ConnectionHandler) observeToolCall(call types.ToolCall, result types.ActionResponse, duration time.Duration) {
+	status := "ok"
+	if result.Action == types.ActionTypeError || result.Action == types.ActionTypeNotFound {
+		status = "error"
+	}
+	transport := h.transportLabel()
+	metrics.ToolCallDuration.ObserveDuration(duration, call.Function.Name, transport)
+	metrics.ToolCalls.Inc(call.Function.Name, transport, status)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/moderation"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SetModerationManager 设置内容审核管理器，为nil时不审核内容
+func (h *ConnectionHandler) SetModerationManager(manager *moderation.Manager) {
+	h.moderationManager = manager
+}
+
+// loadModerationProfile 按用户设置选择审核配置，用户没有设置时使用默认配置
+func (h *ConnectionHandler) loadModerationProfile() {
+	if h.moderationManager == nil {
+		return
+	}
+	var name string
+	if h.userConfigService != nil && h.userID != "" {
+		setting, err := h.userConfigService.GetUserSetting(context.Background(), h.userID)
+		if err != nil {
+			h.LogError(fmt.Sprintf("加载用户设置失败: %v", err))
+		} else if setting != nil {
+			name = setting.Moderation
+		}
+	}
+	h.moderationProfile = h.moderationManager.Profile(name)
+	h.LogInfo(fmt.Sprintf("内容审核配置: %s", h.moderationProfile.Name))
+}
+
+// moderateInput 审核用户输入，未启用审核时原样返回
+func (h *ConnectionHandler) moderateInput(ctx context.Context, text string) moderation.Decision {
+	if h.moderationProfile == nil {
+		return moderation.Decision{Text: text}
+	}
+	decision := h.moderationProfile.ModerateInput(ctx, text)
+	if decision.Flagged() {
+		h.LogInfo(fmt.Sprintf("用户输入命中内容审核[%s]: %s, 原因: %s, 动作: %s",
+			decision.Result.Category, text, decision.Result.Reason, decision.Action))
+	}
+	return decision
+}
+
+// moderateOutput 审核即将播报的一句回复，返回实际播报的文本，为空表示不播报
+// 命中reply动作后播报固定回复，本轮剩余的回复不再播报
+func (h *ConnectionHandler) moderateOutput(text string, round int) string {
+	if h.moderationProfile == nil {
+		return text
+	}
+	if round == h.mutedRound {
+		h.LogInfo(fmt.Sprintf("本轮回复已被内容审核拦截，不再播报: %s", text))
+		return ""
+	}
+	decision := h.moderationProfile.ModerateOutput(h.roundContext(round), text)
+	if decision.Flagged() {
+		h.LogInfo(fmt.Sprintf("回复命中内容审核[%s]: %s, 原因: %s, 动作: %s",
+			decision.Result.Category, text, decision.Result.Reason, decision.Action))
+		if decision.Action == moderation.ActionReply {
+			h.mutedRound = round
+		}
+		if decision.Action != moderation.ActionLog && round == h.talkRound {
+			h.replyModerated = true
+		}
+	}
+	if round == h.talkRound {
+		h.replyParts = append(h.replyParts, decision.Text)
+	}
+	return decision.Text
+}
+
+// moderatedReply 本轮回复被审核改写或拦截时返回实际播报的文本，
+// 写入对话历史、钩子和长期记忆的都是审核后的回复；未命中时原样返回
+func (h *ConnectionHandler) moderatedReply(content string) string {
+	if !h.replyModerated {
+		return content
+	}
+	return joinSegments(h.replyParts)
+}
+
+// joinSegments 拼接分段播报的文本，英文等以空格分词的分段之间补回空格
+func joinSegments(segments []string) string {
+	var b strings.Builder
+	var last rune
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		first, _ := utf8.DecodeRuneInString(segment)
+		if isWordRune(last) && isWordRune(first) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(segment)
+		last, _ = utf8.DecodeLastRuneInString(segment)
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// noticeTimeout 等待主动播报完成语音合成的最长时间
+const noticeTimeout = 30 * time.Second
+
+var errConnectionClosed = errors.New("连接已关闭")
+
+// noticeWaiter 等待主动播报的各分段完成语音合成
+type noticeWaiter struct {
+	lastIndex int
+	done      chan error // 容量为1，全部合成完成时收到nil，任一分段未合成时收到错误
+}
+
+// queueNotice 在新的对话轮次中主动播报，打断正在进行的回复，返回等待各分段完成语音合成的函数
+// 与客户端消息一样在连接的消息处理流程中调用，其他协程通过postNotice发起
+func (h *ConnectionHandler) queueNotice(text string) func() error {
+	_, round := h.startRound(context.Background())
+	if h.headlessSink != nil {
+		err := h.SpeakAndPlay(text, 0, round)
+		return func() error { return err }
+	}
+
+	texts := utils.SplitByPunctuation(text)
+	if len(texts) == 0 {
+		return func() error { return errors.New("播报文本为空") }
+	}
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		err = fmt.Errorf("发送TTS开始状态失败: %v", err)
+		return func() error { return err }
+	}
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+
+	waiter := h.addNoticeWaiter(round, len(texts))
+	h.tts_last_text_index = len(texts)
+	for i, item := range texts {
+		h.SpeakAndPlay(item, i+1, round)
+	}
+	return func() error { return h.waitNotice(round, waiter) }
+}
+
+// postNotice 从其他协程发起主动播报，交给连接的消息处理协程开始播报，并等待各分段完成语音合成
+// 超时仍未开始播报时放弃，不再播报
+func (h *ConnectionHandler) postNotice(text string) error {
+	var state int32 // 0等待中，1已开始播报，2已放弃
+	queued := make(chan func() error, 1)
+	if !h.postEvent(func() {
+		if atomic.CompareAndSwapInt32(&state, 0, 1) {
+			queued <- h.queueNotice(text)
+		}
+	}) {
+		return errConnectionClosed
+	}
+
+	timer := time.NewTimer(noticeTimeout)
+	defer timer.Stop()
+	select {
+	case wait := <-queued:
+		return wait()
+	case <-timer.C:
+		if atomic.CompareAndSwapInt32(&state, 0, 2) {
+			return errors.New("等待开始播报超时")
+		}
+		return (<-queued)()
+	case <-h.stopChan:
+		return errConnectionClosed
+	}
+}
+
+// addNoticeWaiter 登记等待轮次中的播报完成语音合成
+func (h *ConnectionHandler) addNoticeWaiter(round, lastIndex int) *noticeWaiter {
+	h.noticeMu.Lock()
+	defer h.noticeMu.Unlock()
+	if h.notices == nil {
+		h.notices = make(map[int]*noticeWaiter)
+	}
+	waiter := &noticeWaiter{lastIndex: lastIndex, done: make(chan error, 1)}
+	h.notices[round] = waiter
+	return waiter
+}
+
+// finishNotice TTS任务处理完成时调用，audio表示是否得到了音频
+func (h *ConnectionHandler) finishNotice(round, textIndex int, audio bool) {
+	h.noticeMu.Lock()
+	defer h.noticeMu.Unlock()
+	waiter, ok := h.notices[round]
+	if !ok {
+		return
+	}
+	switch {
+	case !audio:
+		waiter.done <- fmt.Errorf("第%d段播报未合成语音，轮次已取消或合成失败", textIndex)
+	case textIndex == waiter.lastIndex:
+		waiter.done <- nil
+	default:
+		return
+	}
+	delete(h.notices, round)
+}
+
+// failNotices 轮次被取消或被新轮次取代时，通知仍在等待的播报
+func (h *ConnectionHandler) failNotices(reason string) {
+	h.noticeMu.Lock()
+	defer h.noticeMu.Unlock()
+	for round, waiter := range h.notices {
+		waiter.done <- errors.New(reason)
+		delete(h.notices, round)
+	}
+}
+
+// waitNotice 等待播报的各分段完成语音合成
+func (h *ConnectionHandler) waitNotice(round int, waiter *noticeWaiter) error {
+	timer := time.NewTimer(noticeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-waiter.done:
+		return err
+	case <-timer.C:
+		h.noticeMu.Lock()
+		if h.notices[round] == waiter {
+			delete(h.notices, round)
+		}
+		h.noticeMu.Unlock()
+		return errors.New("等待播报语音合成超时")
+	case <-h.stopChan:
+		return errConnectionClosed
+	}
+}
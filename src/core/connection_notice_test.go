@@ -0,0 +1,42 @@
+package core
+
+import "testing"
+
+func TestNoticeWaiter(t *testing.T) {
+	type finish struct {
+		round int
+		index int
+		audio bool
+	}
+	tests := []struct {
+		name     string
+		finishes []finish
+		cancel   bool
+		wantErr  bool
+	}{
+		{name: "全部分段合成完成", finishes: []finish{{1, 1, true}, {1, 2, true}}, wantErr: false},
+		{name: "轮次取消后跳过合成", finishes: []finish{{1, 1, true}, {1, 2, false}}, wantErr: true},
+		{name: "第一段未合成", finishes: []finish{{1, 1, false}}, wantErr: true},
+		{name: "其他轮次的任务不影响", finishes: []finish{{2, 1, false}, {1, 1, true}, {1, 2, true}}, wantErr: false},
+		{name: "打断时通知等待的播报", finishes: []finish{{1, 1, true}}, cancel: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ConnectionHandler{}
+			waiter := h.addNoticeWaiter(1, 2)
+			for _, f := range tt.finishes {
+				h.finishNotice(f.round, f.index, f.audio)
+			}
+			if tt.cancel {
+				h.cancelRound()
+			}
+			if err := h.waitNotice(1, waiter); (err != nil) != tt.wantErr {
+				t.Errorf("waitNotice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(h.notices) != 0 {
+				t.Errorf("等待结束后仍有 %d 个登记的播报", len(h.notices))
+			}
+		})
+	}
+}
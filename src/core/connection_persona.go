@@ -0,0 +1,134 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/persona"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// changeRoleTool 切换角色的本地工具名称，任何角色都可以使用
+const changeRoleTool = "change_role"
+
+// SetPersonaManager 设置角色管理器，为nil时只能切换配置中的角色
+func (h *ConnectionHandler) SetPersonaManager(manager *persona.Manager) {
+	h.personaManager = manager
+}
+
+// loadPersonaNames 加载当前用户可以切换的角色名称，用于生成切换角色工具的描述
+func (h *ConnectionHandler) loadPersonaNames() {
+	if h.personaManager == nil {
+		h.personaNames = nil
+		for _, role := range h.config.Roles {
+			if items := strings.SplitN(role, "@", 2); len(items) == 2 {
+				h.personaNames = append(h.personaNames, items[0])
+			}
+		}
+		return
+	}
+	personas, err := h.personaManager.Available(h.ctx, h.userID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("加载角色列表失败: %v", err))
+		return
+	}
+	h.personaNames = persona.Names(personas)
+}
+
+// findPersona 按名称或别名查找当前用户可以切换的角色，没有角色管理器时查找配置中的角色
+func (h *ConnectionHandler) findPersona(name string) (*persona.Persona, error) {
+	if h.personaManager != nil {
+		return h.personaManager.Find(h.ctx, h.userID, name)
+	}
+	for _, role := range h.config.Roles {
+		items := strings.SplitN(role, "@", 2)
+		if len(items) == 2 && strings.EqualFold(items[0], strings.TrimSpace(name)) {
+			return &persona.Persona{Name: items[0], Prompt: items[1], IsActive: true}, nil
+		}
+	}
+	return nil, persona.ErrNotFound
+}
+
+// applyPersona 切换到角色：替换系统提示词，使用角色在当前TTS类型下的音色，没有设置时恢复初始音色
+func (h *ConnectionHandler) applyPersona(p *persona.Persona) {
+	h.persona = p
+	h.dialogueManager.SetSystemMessage(p.Prompt)
+
+	getter, ok := h.providers.tts.(configGetter)
+	if !ok {
+		return
+	}
+	voice := p.Voice(getter.Config().Type)
+	if voice == "" {
+		voice = h.initailVoice
+	}
+	if voice != "" && voice != getter.Config().Voice {
+		if err := h.providers.tts.SetVoice(voice); err != nil {
+			h.LogError(fmt.Sprintf("切换角色%s的音色%s失败: %v", p.Name, voice, err))
+		}
+	}
+}
+
+// personaAllowsTool 当前角色是否可以使用指定的工具，切换角色的工具总是可用
+func (h *ConnectionHandler) personaAllowsTool(name string) bool {
+	return h.persona == nil || name == changeRoleTool || h.persona.AllowsTool(name)
+}
+
+// personaTools 按当前角色过滤可用的工具，并在切换角色工具的描述中列出当前用户可以切换的角色
+func (h *ConnectionHandler) personaTools(tools []openai.Tool) []openai.Tool {
+	filtered := make([]openai.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			filtered = append(filtered, tool)
+			continue
+		}
+		if !h.personaAllowsTool(tool.Function.Name) {
+			continue
+		}
+		if tool.Function.Name == changeRoleTool && len(h.personaNames) > 0 {
+			function := *tool.Function
+			function.Description = "当用户想切换角色/模型性格/助手名字时调用,可选的角色有：[" + strings.Join(h.personaNames, ", ") + "]"
+			tool.Function = &function
+		}
+		filtered = append(filtered, tool)
+	}
+	return filtered
+}
+
+// personaContext 在ctx中携带当前角色的LLM参数
+func (h *ConnectionHandler) personaContext(ctx context.Context) context.Context {
+	if h.persona == nil {
+		return ctx
+	}
+	return llm.WithParams(ctx, h.persona.LLM)
+}
+
+// changePersona 切换到指定名称的角色并播报问候语，角色不存在时播报可以切换的角色
+func (h *ConnectionHandler) changePersona(name string) {
+	p, err := h.findPersona(name)
+	h.loadPersonaNames()
+	if errors.Is(err, persona.ErrNotFound) {
+		if len(h.personaNames) == 0 {
+			h.SystemSpeak("没有找到角色" + name)
+		} else {
+			h.SystemSpeak("没有找到角色" + name + "，可以切换的角色有" + strings.Join(h.personaNames, "、"))
+		}
+		return
+	}
+	if err != nil {
+		h.LogError(fmt.Sprintf("查找角色%s失败: %v", name, err))
+		h.SystemSpeak("切换角色失败，请稍后再试")
+		return
+	}
+
+	h.applyPersona(p)
+	h.dialogueManager.KeepRecentMessages(5) // 保留最近5条消息
+	greeting := p.Greeting
+	if greeting == "" {
+		greeting = "已切换到新角色 " + p.Name
+	}
+	h.SystemSpeak(greeting)
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/quota"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// quotaNotices 用量达到上限时播报的提示
+var quotaNotices = map[quota.Resource]string{
+	quota.ResourceLLMTokens:   "抱歉，你今天的对话次数已经用完了，明天再来找我聊天吧",
+	quota.ResourceTTSChars:    "抱歉，你今天的语音播报额度已经用完了，明天再来找我聊天吧",
+	quota.ResourceASRSeconds:  "抱歉，你今天的语音识别额度已经用完了，明天再来找我聊天吧",
+	quota.ResourceVisionCalls: "抱歉，你今天的看图次数已经用完了，明天再试试吧",
+}
+
+// SetQuotaManager 设置用量配额管理器，为nil时不限制用量
+func (h *ConnectionHandler) SetQuotaManager(manager *quota.Manager) {
+	h.quotaManager = manager
+}
+
+// quotaKey 用量按用户统计，未登录的设备按设备统计
+func (h *ConnectionHandler) quotaKey() string {
+	if h.userID != "" {
+		return h.userID
+	}
+	if h.deviceID != "" {
+		return "device-" + h.deviceID
+	}
+	return h.sessionID
+}
+
+// CheckQuota 检查当前用户当日的用量，任一资源达到上限时返回*quota.ExceededError
+func (h *ConnectionHandler) CheckQuota(resources ...quota.Resource) error {
+	if h.quotaManager == nil {
+		return nil
+	}
+	err := h.quotaManager.Check(h.quotaKey(), resources...)
+	if err != nil {
+		h.LogInfo(fmt.Sprintf("用户 %s 用量已达上限: %v", h.quotaKey(), err))
+	}
+	return err
+}
+
+// quotaNotice 用量检查未通过时的提示文本
+func quotaNotice(err error) string {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		if notice, ok := quotaNotices[exceeded.Resource]; ok {
+			return notice
+		}
+	}
+	return "抱歉，你今天的使用额度已经用完了，明天再来找我聊天吧"
+}
+
+// checkRoundQuota 在轮次开始请求模型前检查用量，已达上限时播报提示并返回false
+func (h *ConnectionHandler) checkRoundQuota(round int, resources ...quota.Resource) bool {
+	err := h.CheckQuota(resources...)
+	if err == nil {
+		return true
+	}
+	h.tts_last_text_index = 1 // 重置文本索引
+	h.SpeakAndPlay(quotaNotice(err), 1, round)
+	return false
+}
+
+// consumeQuota 记录当前用户的用量
+func (h *ConnectionHandler) consumeQuota(resource quota.Resource, amount int64) {
+	if h.quotaManager == nil {
+		return
+	}
+	h.quotaManager.Consume(h.quotaKey(), resource, amount)
+}
+
+// consumeLLMTokens 按估算的请求和回复token数记录LLM用量
+func (h *ConnectionHandler) consumeLLMTokens(messages []chat.Message, reply chat.Message) {
+	if h.quotaManager == nil {
+		return
+	}
+	tokens := chat.EstimateTokens(reply)
+	for _, msg := range messages {
+		tokens += chat.EstimateTokens(msg)
+	}
+	h.consumeQuota(quota.ResourceLLMTokens, int64(tokens))
+}
+
+// countASRAudio 累计送入语音识别的音频时长
+func (h *ConnectionHandler) countASRAudio(audio []byte) {
+	if h.quotaManager == nil {
+		return
+	}
+	ms := int64(h.clientAudioFrameDuration)
+	if h.clientAudioFormat == "pcm" {
+		// PCM数据按16位采样计算时长
+		if bytesPerMs := h.clientAudioSampleRate * h.clientAudioChannels * 2 / 1000; bytesPerMs > 0 {
+			ms = int64(len(audio) / bytesPerMs)
+		}
+	}
+	atomic.AddInt64(&h.asrAudioMs, ms)
+}
+
+// consumeASRAudio 将累计的音频时长按整秒记录为ASR用量，不足一秒的部分留到下次
+// final为true时在连接关闭时调用，不足一秒按一秒记录
+func (h *ConnectionHandler) consumeASRAudio(final bool) {
+	if h.quotaManager == nil {
+		return
+	}
+	ms := atomic.LoadInt64(&h.asrAudioMs)
+	seconds := ms / 1000
+	if final && ms%1000 > 0 {
+		seconds++
+	}
+	if seconds == 0 {
+		return
+	}
+	atomic.AddInt64(&h.asrAudioMs, -min(seconds*1000, ms))
+	h.consumeQuota(quota.ResourceASRSeconds, seconds)
+}
+
+// handleVoiceChat 语音识别结果经过钩子处理后进入对话，语音识别用量已达上限时只播报提示
+func (h *ConnectionHandler) handleVoiceChat(text string) {
+	if text = h.hookASRResult(text); text == "" {
+		return
+	}
+	if err := h.CheckQuota(quota.ResourceASRSeconds); err != nil {
+		wait := h.queueNotice(quotaNotice(err))
+		go func() {
+			if err := wait(); err != nil {
+				h.LogError(fmt.Sprintf("播报用量提示失败: %v", err))
+			}
+		}()
+		return
+	}
+	h.handleChatMessage(context.Background(), text)
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/recorder"
+	"angrymiao-ai-server/src/core/types"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const defaultRecorderDir = "sessions"
+
+// recordingConn 记录连接上收发的全部消息
+type recordingConn struct {
+	Connection
+	recorder *recorder.Recorder
+}
+
+func (c *recordingConn) ReadMessage(stopChan <-chan struct{}) (int, []byte, error) {
+	messageType, data, err := c.Connection.ReadMessage(stopChan)
+	if err == nil {
+		c.recorder.Inbound(messageType, data)
+	}
+	return messageType, data, err
+}
+
+func (c *recordingConn) WriteMessage(messageType int, data []byte) error {
+	c.recorder.Outbound(messageType, data)
+	return c.Connection.WriteMessage(messageType, data)
+}
+
+// SetRecorder 设置会话录制器，回放时用于录制回放过程
+func (h *ConnectionHandler) SetRecorder(r *recorder.Recorder) {
+	h.recorder = r
+}
+
+// setupRecorder 按配置为当前连接创建会话录制器，返回需要使用的连接
+func (h *ConnectionHandler) setupRecorder(conn Connection) Connection {
+	if h.recorder == nil && h.config != nil && h.config.Recorder.Enabled {
+		cfg := h.config.Recorder
+		if len(cfg.DeviceIDs) > 0 && !slices.Contains(cfg.DeviceIDs, h.deviceID) {
+			return conn
+		}
+		dir := cfg.Dir
+		if dir == "" {
+			dir = filepath.Join(h.config.Log.LogDir, defaultRecorderDir)
+		}
+		r, err := recorder.New(dir, recorder.SessionInfo{
+			SessionID: h.sessionID,
+			DeviceID:  h.deviceID,
+			ClientID:  h.clientId,
+			Headers:   h.headers,
+		})
+		if err != nil {
+			h.LogError(fmt.Sprintf("创建会话录制器失败: %v", err))
+			return conn
+		}
+		h.recorder = r
+		h.LogInfo(fmt.Sprintf("会话录制已开启: %s", r.Path()))
+	}
+	if h.recorder == nil {
+		return conn
+	}
+	return &recordingConn{Connection: conn, recorder: h.recorder}
+}
+
+// recordLLMRequest 记录本步请求LLM的消息和工具名称
+func (h *ConnectionHandler) recordLLMRequest(round int, messages []types.Message, tools []openai.Tool) {
+	if h.recorder == nil {
+		return
+	}
+	toolNames := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		toolNames = append(toolNames, tool.Function.Name)
+	}
+	h.recorder.LLMRequest(round, messages, toolNames)
+}
+
+// recordToolCall 记录一次工具调用及其结果
+func (h *ConnectionHandler) recordToolCall(round int, call types.ToolCall, result types.ActionResponse, text string, duration time.Duration) {
+	if h.recorder == nil {
+		return
+	}
+	h.recorder.ToolCall(round, recorder.ToolCallRecord{
+		Name:       call.Function.Name,
+		Arguments:  call.Function.Arguments,
+		Action:     int(result.Action),
+		Result:     text,
+		DurationMs: duration.Milliseconds(),
+	})
+}
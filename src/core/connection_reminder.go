@@ -0,0 +1,210 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/reminder"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetReminderManager 设置提醒管理器，为nil时不支持计时器、闹钟和提醒
+func (h *ConnectionHandler) SetReminderManager(manager *reminder.Manager) {
+	h.reminderManager = manager
+}
+
+// reminderKey 提醒按设备保存，没有设备ID时按会话区分
+func (h *ConnectionHandler) reminderKey() string {
+	if h.deviceID != "" {
+		return h.deviceID
+	}
+	return h.sessionID
+}
+
+// remindersEnabled 提醒需要通过安全回调主动播报，无音频会话不支持
+func (h *ConnectionHandler) remindersEnabled() bool {
+	return h.reminderManager != nil && h.safeCallbackFunc != nil
+}
+
+// attachReminders 握手完成后登记设备连接，到期的提醒通过安全回调播报
+// 登记时会补发离线期间到期的提醒，需要等待消息处理协程播报，在单独的协程中登记，不阻塞握手消息的处理
+func (h *ConnectionHandler) attachReminders() {
+	if !h.remindersEnabled() {
+		return
+	}
+	go func() {
+		detach := h.reminderManager.Attach(h.reminderKey(), func(r *reminder.Reminder, late bool) bool {
+			delivered := false
+			safeCallback := h.safeCallbackFunc(func(handler *ConnectionHandler) {
+				delivered = handler.speakReminder(r, late)
+			})
+			if safeCallback != nil {
+				safeCallback()
+			}
+			return delivered
+		})
+
+		h.reminderMu.Lock()
+		defer h.reminderMu.Unlock()
+		h.detachReminders = detach
+		select {
+		case <-h.stopChan:
+			// 登记完成前连接已关闭
+			detach()
+		default:
+		}
+	}()
+}
+
+// speakReminder 播报到期的提醒并等待完成语音合成
+// 连接已关闭、播报被打断或语音合成失败时返回false，提醒留待下次连接补发
+func (h *ConnectionHandler) speakReminder(r *reminder.Reminder, late bool) bool {
+	text := r.Speech(late)
+	h.LogInfo(fmt.Sprintf("播报提醒 %s: %s", r.ID, text))
+	if err := h.postNotice(text); err != nil {
+		h.LogError(fmt.Sprintf("播报提醒失败: %v", err))
+		return false
+	}
+	h.postEvent(func() {
+		h.dialogueManager.Put(chat.Message{
+			Role:    "assistant",
+			Content: text,
+		})
+	})
+	return true
+}
+
+func (h *ConnectionHandler) mcp_handler_set_reminder(args interface{}) {
+	params, _ := args.(map[string]interface{})
+	h.logger.Info("mcp_handler_set_reminder: %v", params)
+	if !h.remindersEnabled() {
+		h.SystemSpeak("我现在还不能设置提醒哦")
+		return
+	}
+
+	kind, _ := params["kind"].(string)
+	content, _ := params["content"].(string)
+	if content == "" {
+		content, _ = params["label"].(string)
+	}
+	delay := numberArg(params["duration_seconds"])
+	if delay <= 0 {
+		delay = numberArg(params["delay_seconds"])
+	}
+	at, _ := params["time"].(string)
+
+	now := time.Now()
+	dueAt, err := reminder.ParseDueTime(now, delay, at)
+	if err != nil {
+		h.LogError(fmt.Sprintf("解析提醒时间失败: %v", err))
+		h.SystemSpeak("抱歉，我没听清要在什么时间提醒你")
+		return
+	}
+	r, err := h.reminderManager.Schedule(h.reminderKey(), kind, strings.TrimSpace(content), dueAt)
+	if err != nil {
+		h.LogError(fmt.Sprintf("设置提醒失败: %v", err))
+		h.SystemSpeak("抱歉，设置提醒失败了")
+		return
+	}
+
+	switch kind {
+	case reminder.KindTimer:
+		h.SystemSpeak(fmt.Sprintf("好的，%s的计时开始了", formatDuration(r.DueAt.Sub(now))))
+	case reminder.KindAlarm:
+		h.SystemSpeak(fmt.Sprintf("好的，闹钟定在了%s", formatClock(now, r.DueAt)))
+	default:
+		h.SystemSpeak(fmt.Sprintf("好的，我会在%s提醒你%s", formatClock(now, r.DueAt), r.Content))
+	}
+}
+
+func (h *ConnectionHandler) mcp_handler_list_reminders(args interface{}) {
+	h.logger.Info("mcp_handler_list_reminders")
+	if !h.remindersEnabled() {
+		h.SystemSpeak("我现在还不能设置提醒哦")
+		return
+	}
+
+	reminders := h.reminderManager.List(h.reminderKey())
+	if len(reminders) == 0 {
+		h.SystemSpeak("你现在没有设置计时器、闹钟或提醒")
+		return
+	}
+	items := make([]string, 0, len(reminders))
+	for _, r := range reminders {
+		items = append(items, r.Describe())
+	}
+	h.SystemSpeak(fmt.Sprintf("你现在有%d个提醒：%s", len(reminders), strings.Join(items, "；")))
+}
+
+func (h *ConnectionHandler) mcp_handler_cancel_reminder(args interface{}) {
+	params, _ := args.(map[string]interface{})
+	id, _ := params["id"].(string)
+	h.logger.Info("mcp_handler_cancel_reminder: %s", id)
+	if !h.remindersEnabled() {
+		h.SystemSpeak("我现在还不能设置提醒哦")
+		return
+	}
+
+	if id == "only" {
+		reminders := h.reminderManager.List(h.reminderKey())
+		if len(reminders) != 1 {
+			h.SystemSpeak(fmt.Sprintf("你现在有%d个提醒，请告诉我要取消哪一个", len(reminders)))
+			return
+		}
+		id = reminders[0].ID
+	}
+	r := h.reminderManager.Cancel(h.reminderKey(), id)
+	if r == nil {
+		h.SystemSpeak("没有找到这个提醒，它可能已经到期了")
+		return
+	}
+	h.SystemSpeak("好的，已经取消了" + r.Describe())
+}
+
+// numberArg 解析工具参数中的数字，模型有时会以字符串形式传入
+func numberArg(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case string:
+		n, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n
+	}
+	return 0
+}
+
+// formatDuration 将时长转换为便于播报的文本
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours, minutes, seconds := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	text := ""
+	if hours > 0 {
+		text += fmt.Sprintf("%d小时", hours)
+	}
+	if minutes > 0 {
+		text += fmt.Sprintf("%d分钟", minutes)
+	}
+	if seconds > 0 || text == "" {
+		text += fmt.Sprintf("%d秒", seconds)
+	}
+	return text
+}
+
+// formatClock 将到期时刻转换为便于播报的文本，不在今天时带上日期
+func formatClock(now, t time.Time) string {
+	sameDay := func(a, b time.Time) bool {
+		ay, am, ad := a.Date()
+		by, bm, bd := b.Date()
+		return ay == by && am == bm && ad == bd
+	}
+	if sameDay(t, now) {
+		return t.Format("15点04分")
+	}
+	if sameDay(t, now.AddDate(0, 0, 1)) {
+		return "明天" + t.Format("15点04分")
+	}
+	return t.Format("1月2日15点04分")
+}
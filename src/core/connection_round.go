@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// cancelledContext 已被新轮次取代的旧轮次统一返回此上下文
+var cancelledContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// startRound 开始新的对话轮次，取消上一轮仍在进行的LLM、工具调用和TTS任务
+// 返回新轮次的上下文和轮次编号
+func (h *ConnectionHandler) startRound(parent context.Context) (context.Context, int) {
+	h.roundMu.Lock()
+	defer h.roundMu.Unlock()
+
+	if h.roundCancel != nil {
+		h.roundCancel()
+	}
+	h.failNotices("播报被新的对话打断")
+	h.talkRound++
+	h.roundReply = ""
+	h.replyParts = nil
+	h.replyModerated = false
+	h.prosodyCarry = ""
+	h.roundCtx, h.roundCancel = context.WithCancel(parent)
+	h.roundCtxRound = h.talkRound
+	return h.roundCtx, h.talkRound
+}
+
+// beginRoundWork 标记对话轮次开始执行LLM和工具调用，返回的函数在执行结束时调用
+// 执行期间用户在等待回复，不计算静音
+func (h *ConnectionHandler) beginRoundWork() func() {
+	atomic.AddInt32(&h.activeRounds, 1)
+	return func() {
+		atomic.AddInt32(&h.activeRounds, -1)
+	}
+}
+
+// postEvent 将事件交给文本消息处理协程执行，与客户端消息串行处理，连接关闭时返回false
+func (h *ConnectionHandler) postEvent(event func()) bool {
+	select {
+	case h.events <- event:
+		return true
+	case <-h.stopChan:
+		return false
+	}
+}
+
+// cancelRound 取消当前轮次的上下文，用于客户端打断和关闭连接
+func (h *ConnectionHandler) cancelRound() {
+	h.roundMu.Lock()
+	defer h.roundMu.Unlock()
+
+	if h.roundCancel != nil {
+		h.LogInfo("取消当前对话轮次的进行中任务")
+		h.roundCancel()
+	}
+	h.failNotices("播报已取消")
+}
+
+// roundContext 返回指定轮次的上下文
+// 已被新轮次取代的轮次返回已取消的上下文；没有对应轮次（如连接建立时的系统播报）时返回不可取消的上下文
+func (h *ConnectionHandler) roundContext(round int) context.Context {
+	h.roundMu.Lock()
+	defer h.roundMu.Unlock()
+
+	if h.roundCtx == nil || round > h.roundCtxRound {
+		return context.Background()
+	}
+	if round < h.roundCtxRound {
+		return cancelledContext
+	}
+	return h.roundCtx
+}
@@ -1,6 +1,10 @@
 package core
 
 import (
+	"angrymiao-ai-server/src/core/metrics"
+	"angrymiao-ai-server/src/core/protocol"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 	"encoding/json"
 	"fmt"
@@ -8,6 +12,15 @@ import (
 	"time"
 )
 
+// sendProtocolMessage 序列化并下发协议消息
+func (h *ConnectionHandler) sendProtocolMessage(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
+
 // sendHelloMessage 发送欢迎消息
 func (h *ConnectionHandler) sendHelloMessage() error {
 	// 添加安全检查
@@ -20,85 +33,94 @@ func (h *ConnectionHandler) sendHelloMessage() error {
 		return fmt.Errorf("配置对象未初始化")
 	}
 
-	hello := make(map[string]interface{})
-	hello["type"] = "hello"
-	hello["version"] = 1
-	hello["transport"] = "grpcgateway"
-	hello["session_id"] = h.sessionID
-	hello["audio_params"] = map[string]interface{}{
-		"format":         h.serverAudioFormat,
-		"sample_rate":    h.serverAudioSampleRate,
-		"channels":       h.serverAudioChannels,
-		"frame_duration": h.serverAudioFrameDuration,
-	}
-	data, err := json.Marshal(hello)
-	if err != nil {
-		return fmt.Errorf("序列化欢迎消息失败: %v", err)
+	version := h.protocolVersion
+	if version == 0 {
+		version = protocol.MinVersion
 	}
-
-	return h.conn.WriteMessage(1, data)
+	return h.sendProtocolMessage(&protocol.ServerHelloMessage{
+		Type:      "hello",
+		Version:   version,
+		Transport: "grpcgateway",
+		SessionID: h.sessionID,
+		AudioParams: protocol.AudioParams{
+			Format:        h.serverAudioFormat,
+			SampleRate:    h.serverAudioSampleRate,
+			Channels:      h.serverAudioChannels,
+			FrameDuration: h.serverAudioFrameDuration,
+		},
+	})
 }
 
 func (h *ConnectionHandler) sendTTSMessage(state string, text string, textIndex int) error {
 	// 发送TTS状态结束通知
-	stateMsg := map[string]interface{}{
-		"type":        "tts",
-		"state":       state,
-		"session_id":  h.sessionID,
-		"text":        text,
-		"index":       textIndex,
-		"audio_codec": h.serverAudioFormat, // 使用动态音频格式，与实际发送的格式保持一致
-	}
-	data, err := json.Marshal(stateMsg)
+	err := h.sendProtocolMessage(&protocol.TTSMessage{
+		Type:       "tts",
+		State:      state,
+		SessionID:  h.sessionID,
+		Text:       text,
+		Index:      textIndex,
+		AudioCodec: h.serverAudioFormat, // 使用动态音频格式，与实际发送的格式保持一致
+	})
 	if err != nil {
-		return fmt.Errorf("序列化%s状态失败: %v", state, err)
-	}
-	if err := h.conn.WriteMessage(1, data); err != nil {
 		return fmt.Errorf("发送%s状态失败: %v", state, err)
 	}
 	return nil
 }
 
 func (h *ConnectionHandler) sendSTTMessage(text string) error {
-	sttMsg := map[string]interface{}{
-		"type":       "stt",
-		"text":       text,
-		"session_id": h.sessionID,
-	}
-	jsonData, err := json.Marshal(sttMsg)
+	err := h.sendProtocolMessage(&protocol.STTMessage{
+		Type:      "stt",
+		Text:      text,
+		SessionID: h.sessionID,
+	})
 	if err != nil {
-		return fmt.Errorf("序列化 STT 消息失败: %v", err)
-	}
-	if err := h.conn.WriteMessage(1, jsonData); err != nil {
 		return fmt.Errorf("发送 STT 消息失败: %v", err)
 	}
-
 	return nil
 }
 
-// sendEmotionMessage 发送情绪消息
-func (h *ConnectionHandler) sendEmotionMessage(emotion string) error {
-	data := map[string]interface{}{
-		"type":       "llm",
-		"text":       utils.GetEmotionEmoji(emotion),
-		"emotion":    emotion,
-		"session_id": h.sessionID,
+// sendEmotionMessage 发送情绪消息，textIndex为情绪对应的句子序号，不对应句子时为0
+func (h *ConnectionHandler) sendEmotionMessage(emotion string, textIndex int) error {
+	return h.sendProtocolMessage(&protocol.LLMMessage{
+		Type:      "llm",
+		Text:      utils.GetEmotionEmoji(emotion),
+		Emotion:   emotion,
+		Index:     textIndex,
+		SessionID: h.sessionID,
+	})
+}
+
+// replyEmotion 提取一句回复的情绪并返回去除情绪标记后的文本
+// 未启用情绪消息时仍会去除情绪标记，避免标记被合成为语音
+func (h *ConnectionHandler) replyEmotion(text string) (string, string) {
+	emotion, text := utils.ExtractEmotion(text)
+	if !h.config.Emotion.Enabled {
+		return "", text
 	}
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("序列化情绪消息失败: %v", err)
+	if emotion == "" && h.config.Emotion.Classifier {
+		emotion = utils.ClassifyEmotion(text)
 	}
-	return h.conn.WriteMessage(1, jsonData)
+	return emotion, text
 }
 
-func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textIndex int, round int) {
+func (h *ConnectionHandler) sendAudioMessage(filepath string, stream <-chan types.AudioChunk, cached [][]byte, cacheKey string, text string, textIndex int, round int, emotion string) {
 	bFinishSuccess := false
+	var frames <-chan []byte
+	var encodeFailed atomic.Bool
 	defer func() {
+		// 未消费完的音频流需要排空，避免合成协程阻塞
+		if frames != nil {
+			go drainAudioFrames(frames)
+		} else if stream != nil {
+			go drainAudioStream(stream)
+		}
+
 		// 音频发送完成后，根据配置决定是否删除文件
 		h.deleteAudioFileIfNeeded(filepath, "音频发送完成")
 
 		h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.tts_last_text_index))
 		h.providers.asr.ResetStartListenTime()
+		h.resetIdleTimer()
 		if textIndex == h.tts_last_text_index {
 			h.sendTTSMessage("stop", "", textIndex)
 			if h.closeAfterChat {
@@ -109,7 +131,7 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		}
 	}()
 
-	if len(filepath) == 0 {
+	if len(filepath) == 0 && stream == nil && len(cached) == 0 {
 		return
 	}
 
@@ -129,45 +151,88 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		return
 	}
 
-	var audioData [][]byte
-	var duration float64
-	var err error
-
-	// 使用TTS提供者的方法将音频转为Opus格式
-	if h.serverAudioFormat == "pcm" {
-		h.LogInfo("服务端音频格式为PCM，直接发送")
-		audioData, duration, err = utils.AudioToPCMData(filepath)
-		if err != nil {
-			h.LogError(fmt.Sprintf("音频转PCM失败: %v", err))
-			return
+	if len(cached) > 0 {
+		// 命中TTS缓存或分段合成：音频帧已按服务端音频格式编码
+		cachedFrames := make(chan []byte, len(cached))
+		for _, frame := range cached {
+			cachedFrames <- frame
 		}
-	} else if h.serverAudioFormat == "opus" {
-		audioData, duration, err = utils.AudioToOpusData(filepath)
-		if err != nil {
-			h.LogError(fmt.Sprintf("音频转Opus失败: %v", err))
-			return
+		close(cachedFrames)
+		frames = cachedFrames
+		h.logger.Debug("TTS音频帧发送(%s): \"%s\" (索引:%d/%d，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, len(cached))
+	} else if stream != nil {
+		// 流式合成：边接收PCM边编码，首帧就绪即可开始发送
+		frames = h.encodeAudioStream(stream, text, &encodeFailed)
+		h.logger.Debug("TTS流式发送(%s): \"%s\" (索引:%d/%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index)
+	} else {
+		var audioData [][]byte
+		var duration float64
+		var err error
+
+		// 使用TTS提供者的方法将音频转为Opus格式
+		if h.serverAudioFormat == "pcm" {
+			h.LogInfo("服务端音频格式为PCM，直接发送")
+			audioData, duration, err = utils.AudioToPCMData(filepath)
+			if err != nil {
+				h.LogError(fmt.Sprintf("音频转PCM失败: %v", err))
+				return
+			}
+		} else if h.serverAudioFormat == "opus" {
+			audioData, duration, err = utils.AudioToOpusData(filepath)
+			if err != nil {
+				h.LogError(fmt.Sprintf("音频转Opus失败: %v", err))
+				return
+			}
 		}
-	}
 
-	// 发送TTS状态开始通知
-	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
-		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
-		return
+		// fmt.Println("TTS发送", h.serverAudioFormat, text, "(索引:", textIndex, h.tts_last_text_index, "时长:", duration, "帧数:", len(audioData), ")")
+		h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, duration, len(audioData))
+
+		fileFrames := make(chan []byte, len(audioData))
+		for _, chunk := range audioData {
+			fileFrames <- chunk
+		}
+		close(fileFrames)
+		frames = fileFrames
 	}
 
-	if textIndex == 1 {
-		now := time.Now()
-		spentTime := now.Sub(h.roundStartTime)
-		h.logger.Debug("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round)
+	// 记录发送的音频帧，完整发送后写入TTS缓存
+	var sentFrames func() [][]byte
+	if cacheKey != "" && h.ttsCache != nil {
+		frames, sentFrames = recordAudioFrames(frames)
 	}
-	// fmt.Println("TTS发送", h.serverAudioFormat, text, "(索引:", textIndex, h.tts_last_text_index, "时长:", duration, "帧数:", len(audioData), ")")
-	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, duration, len(audioData))
 
-	// 分时发送音频数据
-	if err := h.sendAudioFrames(audioData, text, round); err != nil {
+	// 分时发送音频数据，第一帧发送前通知客户端句子开始
+	sentenceStarted := false
+	err := h.sendAudioFrames(frames, text, round, func() error {
+		// 句子的情绪在句子开始前下发，设备据此切换表情
+		if emotion != "" {
+			if err := h.sendEmotionMessage(emotion, textIndex); err != nil {
+				h.LogError(fmt.Sprintf("发送情绪消息失败: %v", err))
+			}
+		}
+		// 发送TTS状态开始通知
+		if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
+			return fmt.Errorf("发送TTS开始状态失败: %v", err)
+		}
+		sentenceStarted = true
+
+		if textIndex == 1 {
+			now := time.Now()
+			spentTime := now.Sub(h.roundStartTime)
+			metrics.FirstAudioFrame.ObserveDuration(spentTime, providerType(h.providers.tts), h.transportLabel())
+			h.logger.Debug("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round)
+		}
+		return nil
+	})
+	if err != nil {
 		h.LogError(fmt.Sprintf("分时发送音频数据失败: %v", err))
 		return
 	}
+	if !sentenceStarted {
+		h.LogInfo(fmt.Sprintf("没有可发送的音频帧: %s", text))
+		return
+	}
 
 	// 发送TTS状态结束通知
 	if err := h.sendTTSMessage("sentence_end", text, textIndex); err != nil {
@@ -175,90 +240,213 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		return
 	}
 
+	// 被打断或合成出错时音频不完整，不写入缓存
+	if sentFrames != nil && !encodeFailed.Load() && !h.isAudioInterrupted(round) {
+		if recorded := sentFrames(); recorded != nil {
+			h.ttsCache.Put(cacheKey, recorded)
+		}
+	}
+
 	bFinishSuccess = true
 }
 
-// sendAudioFrames 分时发送音频帧，避免撑爆客户端缓冲区
-func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, round int) error {
-	if len(audioData) == 0 {
-		return nil
+// encodeAudioStream 将流式合成的PCM数据块按服务端音频格式转换为音频帧
+// 合成或编码出错时将failed置为true，此时得到的音频不完整
+func (h *ConnectionHandler) encodeAudioStream(stream <-chan types.AudioChunk, text string, failed *atomic.Bool) <-chan []byte {
+	frames := make(chan []byte, tts.StreamChunkBuffer)
+	go func() {
+		defer close(frames)
+
+		var encode func(pcmData []byte) ([][]byte, error)
+		var flush func() ([]byte, error)
+		if h.serverAudioFormat == "opus" {
+			encoder, err := utils.NewOpusEncoder(tts.StreamSampleRate, 1)
+			if err != nil {
+				h.LogError(fmt.Sprintf("创建流式Opus编码器失败: %v", err))
+				failed.Store(true)
+				drainAudioStream(stream)
+				return
+			}
+			defer encoder.Close()
+			encode = encoder.Encode
+			flush = encoder.Flush
+		} else {
+			framer := utils.NewPCMFramer(tts.StreamSampleRate, 1, h.serverAudioFrameDuration)
+			encode = func(pcmData []byte) ([][]byte, error) {
+				return framer.Write(pcmData), nil
+			}
+			flush = func() ([]byte, error) {
+				return framer.Flush(), nil
+			}
+		}
+
+		for chunk := range stream {
+			if chunk.Error != "" {
+				h.LogError(fmt.Sprintf("流式TTS合成失败: %s, 文本: %s", chunk.Error, text))
+				failed.Store(true)
+				continue
+			}
+			packets, err := encode(chunk.Data)
+			if err != nil {
+				h.LogError(fmt.Sprintf("流式音频编码失败: %v", err))
+				failed.Store(true)
+			}
+			for _, packet := range packets {
+				frames <- packet
+			}
+		}
+
+		last, err := flush()
+		if err != nil {
+			h.LogError(fmt.Sprintf("流式音频编码失败: %v", err))
+			failed.Store(true)
+			return
+		}
+		if last != nil {
+			frames <- last
+		}
+	}()
+	return frames
+}
+
+// drainAudioStream 丢弃音频流中剩余的数据块，直到合成协程关闭通道
+func drainAudioStream(stream <-chan types.AudioChunk) {
+	for range stream {
+	}
+}
+
+// recordAudioFrames 转发音频帧并记录下来
+// 返回的函数在全部音频帧转发完成后返回记录的音频帧，尚未完成时返回nil
+func recordAudioFrames(frames <-chan []byte) (<-chan []byte, func() [][]byte) {
+	out := make(chan []byte, cap(frames))
+	done := make(chan struct{})
+	var recorded [][]byte
+	go func() {
+		defer close(out)
+		for frame := range frames {
+			recorded = append(recorded, frame)
+			out <- frame
+		}
+		close(done)
+	}()
+	return out, func() [][]byte {
+		select {
+		case <-done:
+			return recorded
+		default:
+			return nil
+		}
+	}
+}
+
+// drainAudioFrames 丢弃剩余的音频帧，直到编码协程关闭通道
+func drainAudioFrames(frames <-chan []byte) {
+	for range frames {
 	}
+}
+
+// isAudioInterrupted 检查音频发送是否被打断或轮次已变化
+func (h *ConnectionHandler) isAudioInterrupted(round int) bool {
+	return atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound
+}
 
+// sendAudioFrames 分时发送音频帧，避免撑爆客户端缓冲区
+// 音频帧可以边合成边写入frames，onFirstFrame在发送第一帧之前调用
+func (h *ConnectionHandler) sendAudioFrames(frames <-chan []byte, text string, round int, onFirstFrame func() error) error {
 	startTime := time.Now()
 	playPosition := 0 // 播放位置（毫秒）
+	frameCount := 0
 
-	// 预缓冲：发送前几帧，提升播放流畅度
+	// 预缓冲：连续发送前几帧，提升播放流畅度
 	preBufferFrames := 3
-	if len(audioData) < preBufferFrames {
-		preBufferFrames = len(audioData)
-	}
 	preBufferTime := time.Duration(h.serverAudioFrameDuration*preBufferFrames) * time.Millisecond // 预缓冲时间（毫秒）
 
-	// 发送预缓冲帧
-	for i := 0; i < preBufferFrames; i++ {
-		// 检查是否被打断
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-			h.LogInfo(fmt.Sprintf("音频发送被中断(预缓冲阶段): 帧=%d/%d, 文本=%s", i+1, preBufferFrames, text))
-			return nil
+	ticker := time.NewTicker(10 * time.Millisecond) // 固定10ms检查间隔
+	defer ticker.Stop()
+
+frameLoop:
+	for {
+		// 等待下一帧，等待期间同样响应打断
+		var chunk []byte
+		var ok bool
+		for received := false; !received; {
+			select {
+			case chunk, ok = <-frames:
+				received = true
+			case <-ticker.C:
+				if h.isAudioInterrupted(round) {
+					h.LogInfo(fmt.Sprintf("音频发送在等待合成时被中断: 帧=%d, 文本=%s", frameCount, text))
+					return nil
+				}
+			case <-h.stopChan:
+				return nil
+			}
 		}
-
-		if err := h.conn.WriteMessage(2, audioData[i]); err != nil {
-			return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
+		if !ok {
+			break frameLoop
 		}
-		playPosition += h.serverAudioFrameDuration
-	}
+		frameCount++
 
-	// 发送剩余音频帧
-	remainingFrames := audioData[preBufferFrames:]
-	for i, chunk := range remainingFrames {
 		// 检查是否被打断或轮次变化
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-			h.LogInfo(fmt.Sprintf("音频发送被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
+		if h.isAudioInterrupted(round) {
+			h.LogInfo(fmt.Sprintf("音频发送被中断: 帧=%d, 文本=%s", frameCount, text))
 			return nil
 		}
 
-		// 检查连接是否关闭
-		select {
-		case <-h.stopChan:
-			return nil
-		default:
+		if frameCount == 1 {
+			startTime = time.Now()
+			if onFirstFrame != nil {
+				if err := onFirstFrame(); err != nil {
+					return err
+				}
+			}
 		}
 
-		// 计算预期发送时间
-		expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
-		currentTime := time.Now()
-		delay := expectedTime.Sub(currentTime)
-
-		// 流控延迟处理
-		if delay > 0 {
-			// 使用简单的可中断睡眠
-			ticker := time.NewTicker(10 * time.Millisecond) // 固定10ms检查间隔
-			defer ticker.Stop()
-
-			endTime := time.Now().Add(delay)
-			for time.Now().Before(endTime) {
-				select {
-				case <-ticker.C:
-					// 检查中断条件
-					if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-						h.LogInfo(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
+		// 预缓冲之后的帧按播放进度流控
+		if frameCount > preBufferFrames {
+			// 计算预期发送时间
+			expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
+			delay := time.Until(expectedTime)
+
+			// 流控延迟处理，使用简单的可中断睡眠
+			if delay > 0 {
+				endTime := time.Now().Add(delay)
+				for time.Now().Before(endTime) {
+					select {
+					case <-ticker.C:
+						// 检查中断条件
+						if h.isAudioInterrupted(round) {
+							h.LogInfo(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d, 文本=%s", frameCount, text))
+							return nil
+						}
+					case <-h.stopChan:
 						return nil
 					}
-				case <-h.stopChan:
-					return nil
 				}
 			}
 		}
 
 		// 发送音频帧
 		if err := h.conn.WriteMessage(2, chunk); err != nil {
+			if frameCount <= preBufferFrames {
+				return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
+			}
 			return fmt.Errorf("发送音频帧失败: %v", err)
 		}
 
 		playPosition += h.serverAudioFrameDuration
 	}
-	time.Sleep(preBufferTime) // 确保预缓冲时间已过
+
+	if frameCount == 0 {
+		return nil
+	}
+
+	// 确保预缓冲时间已过
+	if frameCount < preBufferFrames {
+		preBufferTime = time.Duration(playPosition) * time.Millisecond
+	}
+	time.Sleep(preBufferTime)
 	spentTime := time.Since(startTime).Milliseconds()
-	h.LogInfo(fmt.Sprintf("音频帧发送完成: 总帧数=%d, 总时长=%dms, 总耗时:%dms 文本=%s", len(audioData), playPosition, spentTime, text))
+	h.LogInfo(fmt.Sprintf("音频帧发送完成: 总帧数=%d, 总时长=%dms, 总耗时:%dms 文本=%s", frameCount, playPosition, spentTime, text))
 	return nil
 }
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/protocol"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxSilenceCount = 2
+	defaultIdleTimeout     = 30 * time.Second
+	defaultSilencePrompt   = "长时间未检测到用户说话，请礼貌的结束对话"
+	idleCheckInterval      = time.Second // 检查用户未说话时长的间隔
+)
+
+// silencePolicy 当前连接生效的静音策略
+type silencePolicy struct {
+	maxCount    int
+	idleTimeout time.Duration
+	action      string
+	prompt      string
+	chimeFile   string
+}
+
+// newSilencePolicy 根据配置生成静音策略，未配置的字段使用默认值
+func newSilencePolicy(cfg configs.SilencePolicyConfig) (silencePolicy, error) {
+	policy := silencePolicy{
+		maxCount:    defaultMaxSilenceCount,
+		idleTimeout: defaultIdleTimeout,
+		action:      configs.SilenceActionEndChat,
+		prompt:      defaultSilencePrompt,
+		chimeFile:   cfg.ChimeFile,
+	}
+	if cfg.MaxSilenceCount > 0 {
+		policy.maxCount = cfg.MaxSilenceCount
+	}
+	if cfg.Prompt != "" {
+		policy.prompt = cfg.Prompt
+	}
+	if cfg.IdleTimeout != "" {
+		d, err := time.ParseDuration(cfg.IdleTimeout)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("无效的idle_timeout: %s", cfg.IdleTimeout)
+		}
+		policy.idleTimeout = d
+	}
+
+	switch cfg.Action {
+	case "":
+	case configs.SilenceActionEndChat, configs.SilenceActionKeepListening,
+		configs.SilenceActionSleep, configs.SilenceActionClose:
+		policy.action = cfg.Action
+	case configs.SilenceActionChime:
+		if cfg.ChimeFile == "" {
+			return policy, fmt.Errorf("chime动作缺少chime_file配置")
+		}
+		policy.action = cfg.Action
+	default:
+		return policy, fmt.Errorf("未知的静音动作: %s", cfg.Action)
+	}
+	return policy, nil
+}
+
+// loadSilencePolicy 加载全局静音策略，并用用户设置中的策略覆盖
+func (h *ConnectionHandler) loadSilencePolicy() {
+	var cfg configs.SilencePolicyConfig
+	if h.config != nil {
+		cfg = h.config.SilencePolicy
+	}
+
+	if h.userConfigService != nil && h.userID != "" {
+		setting, err := h.userConfigService.GetUserSetting(context.Background(), h.userID)
+		if err != nil {
+			h.LogError(fmt.Sprintf("加载用户设置失败: %v", err))
+		} else if setting != nil && len(setting.SilencePolicy) > 0 {
+			var override configs.SilencePolicyConfig
+			if err := json.Unmarshal(setting.SilencePolicy, &override); err != nil {
+				h.LogError(fmt.Sprintf("解析用户静音策略失败: %v", err))
+			} else {
+				cfg = cfg.Merge(override)
+			}
+		}
+	}
+
+	policy, err := newSilencePolicy(cfg)
+	if err != nil {
+		h.LogError(fmt.Sprintf("静音策略配置无效，使用默认策略: %v", err))
+		policy, _ = newSilencePolicy(configs.SilencePolicyConfig{})
+	}
+	h.silencePolicy = policy
+	h.LogInfo(fmt.Sprintf("静音策略: 连续%d次静音后执行%s, 静音判定时长: %s",
+		policy.maxCount, policy.action, policy.idleTimeout))
+}
+
+// applySilencePolicy 静音次数达到上限时执行配置的动作
+// 返回需要交给LLM处理的文本，为空表示不发起新的对话
+func (h *ConnectionHandler) applySilencePolicy(count int) string {
+	policy := h.silencePolicy
+	h.LogInfo(fmt.Sprintf("连续%d次未检测到用户说话，执行静音动作: %s", count, policy.action))
+	if err := h.sendSilenceMessage(policy.action, count); err != nil {
+		h.LogError(fmt.Sprintf("发送静音状态失败: %v", err))
+	}
+
+	switch policy.action {
+	case configs.SilenceActionKeepListening:
+		h.providers.asr.ResetSilenceCount()
+		return ""
+	case configs.SilenceActionChime:
+		h.providers.asr.ResetSilenceCount()
+		h.playChime(policy.chimeFile)
+		return ""
+	case configs.SilenceActionSleep, configs.SilenceActionClose:
+		h.Close()
+		return ""
+	default:
+		h.closeAfterChat = true // 礼貌结束对话后关闭连接
+		return policy.prompt
+	}
+}
+
+// playChime 播放静音提示音
+func (h *ConnectionHandler) playChime(chimeFile string) {
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+		return
+	}
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.tts_last_text_index = 1 // 重置文本索引
+	h.ttsQueue <- struct {
+		text      string
+		round     int
+		textIndex int
+		filepath  string
+		emotion   string
+	}{"", h.talkRound, 1, chimeFile, ""}
+}
+
+// sendSilenceMessage 向客户端报告静音状态
+// state为idle表示累计了一次静音，其余取值为达到上限后执行的动作
+func (h *ConnectionHandler) sendSilenceMessage(state string, count int) error {
+	return h.sendProtocolMessage(&protocol.SilenceMessage{
+		Type:      "silence",
+		State:     state,
+		Count:     count,
+		MaxCount:  h.silencePolicy.maxCount,
+		SessionID: h.sessionID,
+	})
+}
+
+// idleWatchCoroutine 定时检查用户未说话的时长，不依赖服务端VAD
+func (h *ConnectionHandler) idleWatchCoroutine() {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-ticker.C:
+			h.checkIdle()
+		}
+	}
+}
+
+// checkIdle 用户超过idle_timeout未说话时，交给文本消息处理协程处理静音
+func (h *ConnectionHandler) checkIdle() {
+	if !h.idleElapsed() || !atomic.CompareAndSwapInt32(&h.idlePending, 0, 1) {
+		return
+	}
+	if !h.postEvent(h.handleIdle) {
+		atomic.StoreInt32(&h.idlePending, 0)
+	}
+}
+
+// idleElapsed 用户未说话的时长是否已超过idle_timeout
+func (h *ConnectionHandler) idleElapsed() bool {
+	idleSince := atomic.LoadInt64(&h.idleSince)
+	return idleSince != 0 && time.Since(time.Unix(0, idleSince)) >= h.silencePolicy.idleTimeout
+}
+
+// handleIdle 在文本消息处理协程中累加静音计数，并交由handleAsrResult按静音计数处理
+// 对话轮次正在执行LLM、工具调用或服务端正在说话时不计入静音
+func (h *ConnectionHandler) handleIdle() {
+	defer atomic.StoreInt32(&h.idlePending, 0)
+	if h.clientListenMode != "auto" || h.tts_last_text_index > 0 || atomic.LoadInt32(&h.activeRounds) > 0 {
+		return
+	}
+	if !h.idleElapsed() {
+		return
+	}
+
+	idleTimeout := h.silencePolicy.idleTimeout
+	h.resetIdleTimer()
+	count := h.providers.asr.IncreaseSilenceCount()
+	h.LogInfo(fmt.Sprintf("用户 %s 未说话, 静音计数: %d", idleTimeout, count))
+	if count < h.silencePolicy.maxCount {
+		if err := h.sendSilenceMessage("idle", count); err != nil {
+			h.LogError(fmt.Sprintf("发送静音状态失败: %v", err))
+		}
+	}
+	h.handleAsrResult("")
+}
+
+// resetIdleTimer 重新开始计算用户未说话的时长
+func (h *ConnectionHandler) resetIdleTimer() {
+	atomic.StoreInt64(&h.idleSince, time.Now().UnixNano())
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxSpeechTextBytes 单次合成的文本上限，超出时按句切分后依次合成
+const maxSpeechTextBytes = 255
+
+// segmentLanguage 分段文本的语言，无法判断时（如纯数字、OK、GPS等单个缩写）使用用户最近一句话的语言
+func (h *ConnectionHandler) segmentLanguage(text string) string {
+	if language := utils.DetectLanguage(text); language != "" {
+		return language
+	}
+	return h.userLanguage
+}
+
+// applyProsody 拼接上一段回复中未结束的韵律标记，并记录本段未结束的标记；未启用韵律控制时去除标记
+func (h *ConnectionHandler) applyProsody(text string) string {
+	if !h.config.Prosody.Enabled {
+		return tts.StripProsody(text)
+	}
+	text = h.prosodyCarry + text
+	h.prosodyCarry = tts.UnclosedProsody(text)
+	return text
+}
+
+// normalizeSpeech 将韵律标记之间的文本转为朗读形式，保留各段首尾的空白
+func normalizeSpeech(text string, language string) string {
+	return tts.MapProsodyText(text, func(s string) string {
+		trimmed := strings.TrimSpace(s)
+		if trimmed == "" {
+			return s
+		}
+		i := strings.Index(s, trimmed)
+		return s[:i] + utils.NormalizeForSpeech(trimmed, language) + s[i+len(trimmed):]
+	})
+}
+
+// splitSpeech 按合成长度上限切分文本，前一段未结束的韵律标记延续到后一段
+func splitSpeech(text string) []string {
+	pieces := utils.SplitForSpeech(text, maxSpeechTextBytes)
+	for i := 1; i < len(pieces); i++ {
+		pieces[i] = tts.UnclosedProsody(pieces[i-1]) + pieces[i]
+	}
+	return pieces
+}
+
+// synthesizeStream 依次流式合成各段文本并拼接为一个音频流，前一段合成结束后才开始合成下一段
+// 第一段建立失败时返回错误，后续段失败时在音频流中返回错误数据块
+func (h *ConnectionHandler) synthesizeStream(ctx context.Context, pieces []string,
+	synthesize func(context.Context, string) (<-chan types.AudioChunk, error)) (<-chan types.AudioChunk, error) {
+	first, err := synthesize(ctx, pieces[0])
+	if err != nil || len(pieces) == 1 {
+		return first, err
+	}
+
+	out := make(chan types.AudioChunk, tts.StreamChunkBuffer)
+	go func() {
+		defer close(out)
+		stream := first
+		for i := 1; ; i++ {
+			for chunk := range stream {
+				out <- chunk
+			}
+			if i >= len(pieces) || ctx.Err() != nil {
+				return
+			}
+			if stream, err = synthesize(ctx, pieces[i]); err != nil {
+				out <- types.AudioChunk{Error: fmt.Sprintf("第%d段合成失败: %v", i+1, err)}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// synthesizeFrames 依次合成各段文本，按服务端音频格式编码后拼接为音频帧
+func (h *ConnectionHandler) synthesizeFrames(ctx context.Context, pieces []string, synthesize func(string) (string, error)) ([][]byte, error) {
+	var frames [][]byte
+	for i, piece := range pieces {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		filepath, err := synthesize(piece)
+		if err != nil {
+			return nil, fmt.Errorf("第%d段合成失败: %v", i+1, err)
+		}
+		var data [][]byte
+		if h.serverAudioFormat == "pcm" {
+			data, _, err = utils.AudioToPCMData(filepath)
+		} else {
+			data, _, err = utils.AudioToOpusData(filepath)
+		}
+		h.deleteAudioFileIfNeeded(filepath, "分段合成完成")
+		if err != nil {
+			return nil, fmt.Errorf("第%d段音频编码失败: %v", i+1, err)
+		}
+		frames = append(frames, data...)
+	}
+	return frames, nil
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// toolCallAccumulator 按Index合并流式返回的工具调用分片
+// OpenAI兼容接口在一次回复中可能返回多个工具调用，每个调用的参数会分多次增量下发
+type toolCallAccumulator struct {
+	calls   []*types.ToolCall
+	byIndex map[int]*types.ToolCall // 分片中的Index -> 最近打开的工具调用
+	used    map[int]bool            // 已分配给工具调用的Index
+}
+
+func newToolCallAccumulator() *toolCallAccumulator {
+	return &toolCallAccumulator{
+		byIndex: make(map[int]*types.ToolCall),
+		used:    make(map[int]bool),
+	}
+}
+
+// Add 合并一次流式响应中的工具调用分片
+func (a *toolCallAccumulator) Add(deltas []types.ToolCall) {
+	for _, delta := range deltas {
+		call, ok := a.byIndex[delta.Index]
+		// 同一Index出现不同的ID，说明提供者没有下发Index，按新的工具调用处理
+		if ok && delta.ID != "" && call.ID != "" && delta.ID != call.ID {
+			ok = false
+		}
+		if !ok {
+			index := delta.Index
+			if a.used[index] {
+				index = a.nextIndex()
+			}
+			call = &types.ToolCall{Type: "function", Index: index}
+			a.used[index] = true
+			// 之后没有ID的分片仍带着原来的Index，归入最近打开的这个调用
+			a.byIndex[delta.Index] = call
+			a.calls = append(a.calls, call)
+		}
+
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+func (a *toolCallAccumulator) nextIndex() int {
+	next := 0
+	for index := range a.used {
+		if index >= next {
+			next = index + 1
+		}
+	}
+	return next
+}
+
+// Calls 返回合并后的完整工具调用，缺少ID的调用会补充生成ID
+func (a *toolCallAccumulator) Calls() []types.ToolCall {
+	calls := make([]types.ToolCall, 0, len(a.calls))
+	for _, call := range a.calls {
+		if call.Function.Name == "" {
+			continue
+		}
+		if call.ID == "" {
+			call.ID = uuid.New().String()
+		}
+		calls = append(calls, *call)
+	}
+	return calls
+}
+
+// executeToolCalls 执行同一轮LLM回复中的全部工具调用，返回是否需要再次请求LLM
+// 工具本身并发执行；结果处理会修改连接状态（播报、切换角色等），按调用顺序串行进行
+// 工具调用前后的钩子按调用顺序串行执行，被钩子拒绝的调用不执行，拒绝原因作为结果交给LLM
+// 当前角色不能使用的工具同样不执行，LLM可能调用历史消息中出现过或者凭空生成的工具
+func (h *ConnectionHandler) executeToolCalls(ctx context.Context, calls []types.ToolCall, textIndex int, trace *agentTrace) bool {
+	results := make([]types.ActionResponse, len(calls))
+	durations := make([]time.Duration, len(calls))
+	var wg sync.WaitGroup
+	for i := range calls {
+		if !h.personaAllowsTool(calls[i].Function.Name) {
+			h.LogInfo(fmt.Sprintf("当前角色不能使用工具: %s", calls[i].Function.Name))
+			results[i] = types.ActionResponse{
+				Action: types.ActionTypeReqLLM,
+				Result: "当前角色不能使用工具: " + calls[i].Function.Name,
+			}
+			continue
+		}
+		if denied, ok := h.hookPreToolCall(ctx, &calls[i], trace.Round); ok {
+			results[i] = denied
+			continue
+		}
+		call := calls[i]
+		wg.Add(1)
+		go func(i int, call types.ToolCall) {
+			defer wg.Done()
+			startTime := time.Now()
+			defer func() {
+				durations[i] = time.Since(startTime)
+				if r := recover(); r != nil {
+					h.LogError(fmt.Sprintf("工具调用发生panic: %s, %v", call.Function.Name, r))
+					results[i] = types.ActionResponse{
+						Action: types.ActionTypeError,
+						Result: fmt.Sprintf("%v", r),
+					}
+				}
+			}()
+			results[i] = h.executeToolCall(ctx, call)
+		}(i, call)
+	}
+	wg.Wait()
+	for i, call := range calls {
+		h.hookPostToolResult(ctx, call, &results[i], trace.Round)
+	}
+
+	toolResults := make([]string, len(calls))
+	reqLLM := false
+	for i, call := range calls {
+		text, needLLM := h.handleFunctionResult(results[i], call, textIndex)
+		toolResults[i] = text
+		reqLLM = reqLLM || needLLM
+		trace.addToolCall(call, durations[i], text)
+		h.recordToolCall(trace.Round, call, results[i], text, durations[i])
+		h.emitToolTrace(call, text, durations[i])
+		h.observeToolCall(call, results[i], durations[i])
+	}
+
+	h.addToolCallMessages(calls, toolResults)
+	return reqLLM
+}
+
+// ToolExecutor 替代工具的实际执行，回放时按存档返回工具调用结果
+type ToolExecutor interface {
+	// ExecuteTool 返回工具调用结果，handled为false时按正常流程执行
+	ExecuteTool(ctx context.Context, call types.ToolCall) (result types.ActionResponse, handled bool)
+}
+
+// SetToolExecutor 设置工具执行器，为nil时直接执行工具
+func (h *ConnectionHandler) SetToolExecutor(executor ToolExecutor) {
+	h.toolExecutor = executor
+}
+
+// executeToolCall 执行单个工具调用，依次查找MCP工具和用户自定义函数
+func (h *ConnectionHandler) executeToolCall(ctx context.Context, call types.ToolCall) types.ActionResponse {
+	if h.toolExecutor != nil {
+		if result, handled := h.toolExecutor.ExecuteTool(ctx, call); handled {
+			return result
+		}
+	}
+	functionName := call.Function.Name
+	arguments := make(map[string]interface{})
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+			h.LogError(fmt.Sprintf("函数调用参数解析失败: %s, %v", functionName, err))
+		}
+	}
+	h.LogInfo(fmt.Sprintf("函数调用: %s %v", functionName, arguments))
+
+	if h.mcpManager.IsMCPTool(functionName) {
+		// 处理MCP函数调用
+		result, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
+		if err != nil {
+			h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
+			if result == nil {
+				result = "MCP工具调用失败"
+			}
+		}
+		// 判断result 是否是types.ActionResponse类型
+		if actionResult, ok := result.(types.ActionResponse); ok {
+			return actionResult
+		}
+		h.LogInfo(fmt.Sprintf("MCP函数调用结果: %v", result))
+		return types.ActionResponse{
+			Action: types.ActionTypeReqLLM, // 动作类型
+			Result: result,                 // 动作产生的结果
+		}
+	}
+
+	// 处理用户自定义函数调用
+	if userFunCallConfig := h.findUserFunctionConfig(functionName); userFunCallConfig != nil {
+		funResult, err := h.executeUserFunctionCall(ctx, userFunCallConfig, arguments)
+		if err != nil {
+			h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
+			if funResult.Result == "" {
+				funResult.Result = "BOT 模型调用失败"
+			}
+		}
+		return types.ActionResponse{
+			Action: types.ActionTypeReqLLM,
+			Result: funResult.Result,
+		}
+	}
+
+	return types.ActionResponse{
+		Action: types.ActionTypeNotFound,
+		Result: "没有找到函数: " + functionName,
+	}
+}
+
+// findUserFunctionConfig 从请求上下文的用户配置中查找自定义函数
+func (h *ConnectionHandler) findUserFunctionConfig(functionName string) *models.UserAIConfig {
+	if h.request == nil {
+		return nil
+	}
+	if userFunConfig := h.request.Context().Value("user_configs"); userFunConfig != nil {
+		if configs, ok := userFunConfig.([]*models.UserAIConfig); ok {
+			for _, v := range configs {
+				if v.FunctionName == functionName {
+					return v
+				}
+			}
+		}
+	}
+	return nil
+}
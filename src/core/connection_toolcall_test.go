@@ -0,0 +1,91 @@
+package core
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+func toolDelta(index int, id, name, arguments string) types.ToolCall {
+	return types.ToolCall{
+		ID:       id,
+		Index:    index,
+		Function: types.FunctionCall{Name: name, Arguments: arguments},
+	}
+}
+
+func TestToolCallAccumulator(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   [][]types.ToolCall
+		expected []types.ToolCall
+	}{
+		{
+			name: "带Index的多个调用交替下发",
+			chunks: [][]types.ToolCall{
+				{toolDelta(0, "call_a", "get_weather", `{"city":`)},
+				{toolDelta(1, "call_b", "play_music", `{"song":`)},
+				{toolDelta(0, "", "", `"北京"}`)},
+				{toolDelta(1, "", "", `"晴天"}`)},
+			},
+			expected: []types.ToolCall{
+				{ID: "call_a", Type: "function", Index: 0, Function: types.FunctionCall{Name: "get_weather", Arguments: `{"city":"北京"}`}},
+				{ID: "call_b", Type: "function", Index: 1, Function: types.FunctionCall{Name: "play_music", Arguments: `{"song":"晴天"}`}},
+			},
+		},
+		{
+			name: "没有Index的单个调用",
+			chunks: [][]types.ToolCall{
+				{toolDelta(0, "call_a", "get_weather", `{"city":`)},
+				{toolDelta(0, "", "", `"北京"}`)},
+			},
+			expected: []types.ToolCall{
+				{ID: "call_a", Type: "function", Index: 0, Function: types.FunctionCall{Name: "get_weather", Arguments: `{"city":"北京"}`}},
+			},
+		},
+		{
+			name: "没有Index时按ID切换到新的调用",
+			chunks: [][]types.ToolCall{
+				{toolDelta(0, "call_a", "get_weather", `{"city":`)},
+				{toolDelta(0, "", "", `"北京"}`)},
+				{toolDelta(0, "call_b", "play_music", `{"song":`)},
+				{toolDelta(0, "", "", `"晴天"}`)},
+				{toolDelta(0, "call_c", "set_volume", `{"volume":`)},
+				{toolDelta(0, "", "", `50}`)},
+			},
+			expected: []types.ToolCall{
+				{ID: "call_a", Type: "function", Index: 0, Function: types.FunctionCall{Name: "get_weather", Arguments: `{"city":"北京"}`}},
+				{ID: "call_b", Type: "function", Index: 1, Function: types.FunctionCall{Name: "play_music", Arguments: `{"song":"晴天"}`}},
+				{ID: "call_c", Type: "function", Index: 2, Function: types.FunctionCall{Name: "set_volume", Arguments: `{"volume":50}`}},
+			},
+		},
+		{
+			name: "同一分片中包含多个调用",
+			chunks: [][]types.ToolCall{
+				{toolDelta(0, "call_a", "get_weather", `{}`), toolDelta(1, "call_b", "play_music", `{}`)},
+			},
+			expected: []types.ToolCall{
+				{ID: "call_a", Type: "function", Index: 0, Function: types.FunctionCall{Name: "get_weather", Arguments: `{}`}},
+				{ID: "call_b", Type: "function", Index: 1, Function: types.FunctionCall{Name: "play_music", Arguments: `{}`}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := newToolCallAccumulator()
+			for _, chunk := range tt.chunks {
+				acc.Add(chunk)
+			}
+			calls := acc.Calls()
+			if len(calls) != len(tt.expected) {
+				t.Fatalf("Calls() = %+v, want %+v", calls, tt.expected)
+			}
+			for i := range calls {
+				if calls[i] != tt.expected[i] {
+					t.Errorf("Calls()[%d] = %+v, want %+v", i, calls[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/ttscache"
+	"fmt"
+)
+
+// SetTTSCache 设置TTS结果缓存，为nil时不缓存
+func (h *ConnectionHandler) SetTTSCache(cache *ttscache.Cache) {
+	h.ttsCache = cache
+}
+
+// ttsCacheKey 生成文本的TTS缓存键，voice为本次合成指定的音色，为空时使用当前音色
+// 未启用缓存、文本不适合缓存或无法确定TTS类型和音色时返回空
+func (h *ConnectionHandler) ttsCacheKey(text string, voice string) string {
+	if h.ttsCache == nil || !h.ttsCache.Cacheable(text) {
+		return ""
+	}
+	getter, ok := h.providers.tts.(configGetter)
+	if !ok || getter.Config() == nil {
+		return ""
+	}
+	if voice == "" {
+		voice = getter.Config().Voice
+	}
+	format := fmt.Sprintf("%s/%d/%d/%d", h.serverAudioFormat, h.serverAudioSampleRate, h.serverAudioChannels, h.serverAudioFrameDuration)
+	return ttscache.Key(getter.Config().Type, voice, format, text)
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/vad"
+	"fmt"
+	"time"
+)
+
+// setupVAD 根据客户端音频参数创建服务端VAD，未启用时不做任何处理
+func (h *ConnectionHandler) setupVAD() {
+	h.voiceDetector = nil
+	if h.config == nil || !h.config.VAD.Enabled {
+		return
+	}
+
+	sampleRate := h.clientAudioSampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	cfg := h.config.VAD
+	detector, err := vad.Create(&vad.Config{
+		Type:       cfg.Type,
+		SampleRate: sampleRate,
+		Threshold:  cfg.Threshold,
+		MinSpeech:  time.Duration(cfg.MinSpeechMs) * time.Millisecond,
+		MinSilence: time.Duration(cfg.MinSilenceMs) * time.Millisecond,
+	})
+	if err != nil {
+		h.LogError(fmt.Sprintf("初始化服务端VAD失败: %v", err))
+		return
+	}
+	h.voiceDetector = detector
+	h.resetIdleTimer()
+	h.LogInfo(fmt.Sprintf("服务端VAD初始化成功: type=%s, sample_rate=%d", cfg.Type, sampleRate))
+}
+
+// clientAudioIsPCM 客户端音频队列中的数据是否为解码后的PCM
+func (h *ConnectionHandler) clientAudioIsPCM() bool {
+	return h.clientAudioFormat == "pcm" || h.opusDecoder != nil
+}
+
+// processVAD 对送入ASR之前的PCM数据做语音活动检测
+func (h *ConnectionHandler) processVAD(pcm []byte) {
+	detector := h.voiceDetector
+	if detector == nil || !h.clientAudioIsPCM() {
+		return
+	}
+
+	for _, event := range detector.Process(pcm) {
+		h.logger.Debug("服务端VAD事件: %s, offset: %s", event.Type, event.Offset)
+		switch event.Type {
+		case vad.EventSpeechStart:
+			h.onSpeechStart()
+		case vad.EventSpeechEnd:
+			h.onSpeechEnd()
+		}
+	}
+
+	if detector.IsSpeaking() {
+		// 说话期间不计算未说话时长，由连接级的空闲检测处理静音
+		h.resetIdleTimer()
+	}
+}
+
+// onSpeechStart 检测到用户开始说话
+func (h *ConnectionHandler) onSpeechStart() {
+	h.resetIdleTimer()
+	h.providers.asr.ResetSilenceCount()
+}
+
+// onSpeechEnd 检测到用户说话结束，auto模式下由服务端决定断句
+func (h *ConnectionHandler) onSpeechEnd() {
+	h.resetIdleTimer()
+	h.markSpeechEnd()
+	if h.clientListenMode != "auto" {
+		return
+	}
+	if notifier, ok := h.providers.asr.(providers.ASRSpeechEndNotifier); ok {
+		if err := notifier.NotifySpeechEnd(); err != nil {
+			h.LogError(fmt.Sprintf("通知ASR说话结束失败: %v", err))
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package hooks 在对话轮次的各个阶段调用注册的钩子，用于注入提示词、记录日志、过滤内容和统计分析等定制
+// 钩子在启动时通过Register注册，按注册顺序执行；钩子只需实现关心的阶段对应的接口，
+// 各阶段的钩子可以修改传入的数据，发生panic时记录日志并跳过该钩子
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// Session 钩子可以访问的会话信息
+type Session struct {
+	SessionID string
+	DeviceID  string
+	UserID    string // 未登录时为空
+	Transport string // 传输层类型，如websocket、http
+	Round     int    // 当前对话轮次
+}
+
+// Hook 对话钩子，需要再实现下列阶段接口中的一个或多个
+type Hook interface {
+	// Name 钩子名称，用于日志
+	Name() string
+}
+
+// ASRResultHook 语音识别出最终结果、进入对话之前调用
+// 返回交给对话处理的文本，返回空字符串表示丢弃这句话
+type ASRResultHook interface {
+	OnASRResult(ctx context.Context, s Session, text string) string
+}
+
+// PreLLMHook 每次请求LLM之前调用，返回实际发送给LLM的消息
+type PreLLMHook interface {
+	PreLLM(ctx context.Context, s Session, messages []types.Message) []types.Message
+}
+
+// LLMSegmentHook LLM回复按标点分段后、播报之前调用，返回空字符串表示不播报该段
+type LLMSegmentHook interface {
+	OnLLMSegment(ctx context.Context, s Session, segment string) string
+}
+
+// ToolDecision 工具调用前钩子的决定
+type ToolDecision struct {
+	Deny   bool   // 是否拒绝执行
+	Reason string // 拒绝的原因，作为工具结果交给LLM
+}
+
+// PreToolCallHook 执行工具调用之前调用，可以修改调用参数或拒绝执行
+type PreToolCallHook interface {
+	PreToolCall(ctx context.Context, s Session, call *types.ToolCall) ToolDecision
+}
+
+// PostToolResultHook 工具调用返回之后、处理结果之前调用，可以修改结果
+type PostToolResultHook interface {
+	PostToolResult(ctx context.Context, s Session, call types.ToolCall, result *types.ActionResponse)
+}
+
+// PreTTSHook 每段文本合成语音之前调用，包括系统播报，返回空字符串表示不播报
+type PreTTSHook interface {
+	PreTTS(ctx context.Context, s Session, text string) string
+}
+
+// RoundSummary 一轮对话的概要
+type RoundSummary struct {
+	Input     string        // 用户输入
+	Reply     string        // 本轮最后一条助手回复，没有回复时为空
+	StartTime time.Time     // 轮次开始时间
+	Duration  time.Duration // 从轮次开始到生成回复结束的耗时，不包含播报完成的时间
+	Err       error         // 生成回复时的错误
+}
+
+// RoundEndHook 一轮对话生成回复结束后调用
+type RoundEndHook interface {
+	OnRoundEnd(ctx context.Context, s Session, summary RoundSummary)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Hook
+)
+
+// Register 注册钩子，需要在启动时、创建连接之前调用
+func Register(hook Hook) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, hook)
+}
+
+// Chain 一个连接使用的钩子链
+type Chain struct {
+	hooks  []Hook
+	logger *utils.Logger
+}
+
+// NewChain 以当前注册的钩子创建钩子链
+func NewChain(logger *utils.Logger) *Chain {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return &Chain{
+		hooks:  append([]Hook(nil), registry...),
+		logger: logger,
+	}
+}
+
+// Empty 是否没有注册任何钩子
+func (c *Chain) Empty() bool {
+	return c == nil || len(c.hooks) == 0
+}
+
+// OnASRResult 依次调用语音识别结果钩子，某个钩子丢弃文本后不再调用后续钩子
+func (c *Chain) OnASRResult(ctx context.Context, s Session, text string) string {
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(ASRResultHook); ok {
+			text = h.OnASRResult(ctx, s, text)
+		}
+		return text != ""
+	})
+	return text
+}
+
+// PreLLM 依次调用请求LLM前钩子
+func (c *Chain) PreLLM(ctx context.Context, s Session, messages []types.Message) []types.Message {
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(PreLLMHook); ok {
+			messages = h.PreLLM(ctx, s, messages)
+		}
+		return true
+	})
+	return messages
+}
+
+// OnLLMSegment 依次调用回复分段钩子，某个钩子丢弃分段后不再调用后续钩子
+func (c *Chain) OnLLMSegment(ctx context.Context, s Session, segment string) string {
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(LLMSegmentHook); ok {
+			segment = h.OnLLMSegment(ctx, s, segment)
+		}
+		return segment != ""
+	})
+	return segment
+}
+
+// PreToolCall 依次调用工具调用前钩子，某个钩子拒绝后不再调用后续钩子
+func (c *Chain) PreToolCall(ctx context.Context, s Session, call *types.ToolCall) ToolDecision {
+	var decision ToolDecision
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(PreToolCallHook); ok {
+			decision = h.PreToolCall(ctx, s, call)
+			if decision.Deny && decision.Reason == "" {
+				decision.Reason = fmt.Sprintf("工具调用被%s拒绝", hook.Name())
+			}
+		}
+		return !decision.Deny
+	})
+	return decision
+}
+
+// PostToolResult 依次调用工具结果钩子
+func (c *Chain) PostToolResult(ctx context.Context, s Session, call types.ToolCall, result *types.ActionResponse) {
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(PostToolResultHook); ok {
+			h.PostToolResult(ctx, s, call, result)
+		}
+		return true
+	})
+}
+
+// PreTTS 依次调用合成语音前钩子，某个钩子丢弃文本后不再调用后续钩子
+func (c *Chain) PreTTS(ctx context.Context, s Session, text string) string {
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(PreTTSHook); ok {
+			text = h.PreTTS(ctx, s, text)
+		}
+		return text != ""
+	})
+	return text
+}
+
+// OnRoundEnd 依次调用轮次结束钩子
+func (c *Chain) OnRoundEnd(ctx context.Context, s Session, summary RoundSummary) {
+	c.each(func(hook Hook) bool {
+		if h, ok := hook.(RoundEndHook); ok {
+			h.OnRoundEnd(ctx, s, summary)
+		}
+		return true
+	})
+}
+
+// each 按注册顺序调用fn，fn返回false时停止，单个钩子panic时记录日志并继续
+func (c *Chain) each(fn func(hook Hook) bool) {
+	if c.Empty() {
+		return
+	}
+	for _, hook := range c.hooks {
+		if !c.call(hook, fn) {
+			return
+		}
+	}
+}
+
+func (c *Chain) call(hook Hook, fn func(hook Hook) bool) (next bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("对话钩子 %s 发生panic: %v", hook.Name(), r)
+			next = true
+		}
+	}()
+	return fn(hook)
+}
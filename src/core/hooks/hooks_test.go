@@ -0,0 +1,203 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// textHook 按transform处理文本的钩子，记录调用顺序
+type textHook struct {
+	name      string
+	calls     *[]string
+	transform func(text string) string
+}
+
+func (h *textHook) Name() string { return h.name }
+
+func (h *textHook) handle(text string) string {
+	*h.calls = append(*h.calls, h.name)
+	return h.transform(text)
+}
+
+func (h *textHook) OnASRResult(ctx context.Context, s Session, text string) string {
+	return h.handle(text)
+}
+
+func (h *textHook) OnLLMSegment(ctx context.Context, s Session, segment string) string {
+	return h.handle(segment)
+}
+
+func (h *textHook) PreTTS(ctx context.Context, s Session, text string) string {
+	return h.handle(text)
+}
+
+// toolHook 按decision决定工具调用的钩子
+type toolHook struct {
+	name     string
+	calls    *[]string
+	decision ToolDecision
+}
+
+func (h *toolHook) Name() string { return h.name }
+
+func (h *toolHook) PreToolCall(ctx context.Context, s Session, call *types.ToolCall) ToolDecision {
+	*h.calls = append(*h.calls, h.name)
+	return h.decision
+}
+
+// nameOnly 没有实现任何阶段接口的钩子
+type nameOnly struct{}
+
+func (nameOnly) Name() string { return "name_only" }
+
+func newTestChain(t *testing.T, hooks ...Hook) *Chain {
+	t.Helper()
+	registryMu.Lock()
+	registry = nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = nil
+		registryMu.Unlock()
+	})
+	for _, hook := range hooks {
+		Register(hook)
+	}
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	return NewChain(logger)
+}
+
+func TestChainText(t *testing.T) {
+	appendSuffix := func(suffix string) func(string) string {
+		return func(text string) string { return text + suffix }
+	}
+	drop := func(string) string { return "" }
+	panics := func(string) string { panic("钩子出错") }
+
+	tests := []struct {
+		name       string
+		transforms []func(string) string
+		expected   string
+		calls      []string
+	}{
+		{
+			name:       "按注册顺序执行",
+			transforms: []func(string) string{appendSuffix("1"), appendSuffix("2")},
+			expected:   "你好12",
+			calls:      []string{"h0", "h1"},
+		},
+		{
+			name:       "返回空文本后不再调用后续钩子",
+			transforms: []func(string) string{drop, appendSuffix("2")},
+			expected:   "",
+			calls:      []string{"h0"},
+		},
+		{
+			name:       "panic的钩子被跳过",
+			transforms: []func(string) string{panics, appendSuffix("2")},
+			expected:   "你好2",
+			calls:      []string{"h0", "h1"},
+		},
+	}
+
+	stages := map[string]func(c *Chain, text string) string{
+		"OnASRResult": func(c *Chain, text string) string {
+			return c.OnASRResult(context.Background(), Session{}, text)
+		},
+		"OnLLMSegment": func(c *Chain, text string) string {
+			return c.OnLLMSegment(context.Background(), Session{}, text)
+		},
+		"PreTTS": func(c *Chain, text string) string {
+			return c.PreTTS(context.Background(), Session{}, text)
+		},
+	}
+
+	for stage, run := range stages {
+		for _, tt := range tests {
+			t.Run(stage+"/"+tt.name, func(t *testing.T) {
+				var calls []string
+				hooks := []Hook{nameOnly{}}
+				for i, transform := range tt.transforms {
+					hooks = append(hooks, &textHook{name: fmt.Sprintf("h%d", i), calls: &calls, transform: transform})
+				}
+				chain := newTestChain(t, hooks...)
+				if result := run(chain, "你好"); result != tt.expected {
+					t.Errorf("%s() = %q, want %q", stage, result, tt.expected)
+				}
+				if !reflect.DeepEqual(calls, tt.calls) {
+					t.Errorf("调用顺序 = %v, want %v", calls, tt.calls)
+				}
+			})
+		}
+	}
+}
+
+func TestChainPreToolCall(t *testing.T) {
+	tests := []struct {
+		name      string
+		decisions []ToolDecision
+		expected  ToolDecision
+		calls     []string
+	}{
+		{
+			name:      "全部允许",
+			decisions: []ToolDecision{{}, {}},
+			expected:  ToolDecision{},
+			calls:     []string{"h0", "h1"},
+		},
+		{
+			name:      "拒绝后不再调用后续钩子",
+			decisions: []ToolDecision{{Deny: true, Reason: "不允许"}, {}},
+			expected:  ToolDecision{Deny: true, Reason: "不允许"},
+			calls:     []string{"h0"},
+		},
+		{
+			name:      "没有原因时使用钩子名称",
+			decisions: []ToolDecision{{}, {Deny: true}},
+			expected:  ToolDecision{Deny: true, Reason: "工具调用被h1拒绝"},
+			calls:     []string{"h0", "h1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var hooks []Hook
+			for i, decision := range tt.decisions {
+				hooks = append(hooks, &toolHook{name: fmt.Sprintf("h%d", i), calls: &calls, decision: decision})
+			}
+			chain := newTestChain(t, hooks...)
+			call := types.ToolCall{Function: types.FunctionCall{Name: "play_music"}}
+			if result := chain.PreToolCall(context.Background(), Session{}, &call); result != tt.expected {
+				t.Errorf("PreToolCall() = %+v, want %+v", result, tt.expected)
+			}
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("调用顺序 = %v, want %v", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestChainSnapshotsRegistry(t *testing.T) {
+	var calls []string
+	chain := newTestChain(t, &textHook{name: "h0", calls: &calls, transform: func(text string) string { return text }})
+	// 创建钩子链之后注册的钩子不影响已有的钩子链
+	Register(&textHook{name: "h1", calls: &calls, transform: func(string) string { return "" }})
+	if result := chain.PreTTS(context.Background(), Session{}, "你好"); result != "你好" {
+		t.Errorf("PreTTS() = %q, want %q", result, "你好")
+	}
+
+	var empty *Chain
+	if !empty.Empty() || empty.PreTTS(context.Background(), Session{}, "你好") != "你好" {
+		t.Error("nil钩子链应原样返回")
+	}
+}
@@ -0,0 +1,38 @@
+package hooks
+
+import (
+	"context"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// RoundLogger 在每轮对话结束时以Debug级别记录本轮概要的钩子
+type RoundLogger struct {
+	logger *utils.Logger
+}
+
+var _ RoundEndHook = (*RoundLogger)(nil)
+
+// NewRoundLogger 创建轮次日志钩子
+func NewRoundLogger(logger *utils.Logger) *RoundLogger {
+	return &RoundLogger{logger: logger}
+}
+
+// Name 钩子名称
+func (l *RoundLogger) Name() string {
+	return "round_logger"
+}
+
+// OnRoundEnd 记录本轮的输入、回复、耗时和错误
+func (l *RoundLogger) OnRoundEnd(ctx context.Context, s Session, summary RoundSummary) {
+	l.logger.Debug("对话轮次结束 %v", map[string]interface{}{
+		"session_id": s.SessionID,
+		"device_id":  s.DeviceID,
+		"user_id":    s.UserID,
+		"round":      s.Round,
+		"input":      summary.Input,
+		"reply":      summary.Reply,
+		"duration":   summary.Duration.String(),
+		"error":      summary.Err,
+	})
+}
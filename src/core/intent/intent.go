@@ -0,0 +1,289 @@
+// Package intent 在请求LLM之前用本地规则识别用户意图
+// 规则由模式、槽位和同义词组成，命中后直接调用对应的本地工具或设备MCP工具，省去一次LLM往返
+package intent
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const numberPattern = `[0-9]+`
+
+// DefaultMinConfidence 默认的最低置信度
+const DefaultMinConfidence = 0.6
+
+var slotRef = regexp.MustCompile(`\{(\w+)\}`)
+
+// Rule 一条意图规则
+type Rule struct {
+	Name string
+	// Patterns 匹配用户输入的模式，{slot}引用槽位，*匹配任意文本
+	Patterns []string
+	// Slots 槽位的取值，键为传给工具的规范值，值为该取值的各种说法；没有配置取值的槽位匹配数字
+	Slots map[string]map[string][]string
+	// Tool 命中后调用的工具名称
+	Tool string
+	// Arguments 工具参数，字符串中的{slot}替换为槽位的规范值
+	Arguments map[string]interface{}
+	// Reply 工具调用后播报的回复，可以引用槽位；为空时将工具结果交给LLM生成回复
+	Reply string
+}
+
+// Result 意图识别结果
+type Result struct {
+	Intent     string
+	Tool       string
+	Arguments  map[string]interface{}
+	Slots      map[string]string // 槽位的规范值
+	Reply      string
+	Confidence float64 // 模式中普通文本和槽位覆盖的输入文本比例，*匹配的文本不计入
+}
+
+// compiledPattern 编译后的模式，groups为各捕获组对应的槽位，*匹配的任意文本对应空字符串
+type compiledPattern struct {
+	re     *regexp.Regexp
+	groups []string
+}
+
+type compiledRule struct {
+	rule     Rule
+	patterns []compiledPattern
+	values   map[string]map[string]string // 槽位 -> 说法 -> 规范值
+}
+
+// Router 本地意图路由器
+type Router struct {
+	rules         []compiledRule
+	synonyms      map[string]string // 同义词 -> 规范词
+	synonymKeys   []string          // 按长度降序排列的同义词，优先替换较长的词
+	minConfidence float64
+}
+
+// NewRouter 创建意图路由器，synonyms的键为规范词，值为它的同义词
+func NewRouter(rules []Rule, synonyms map[string][]string, minConfidence float64) (*Router, error) {
+	if minConfidence <= 0 {
+		minConfidence = DefaultMinConfidence
+	}
+	r := &Router{
+		synonyms:      make(map[string]string),
+		minConfidence: minConfidence,
+	}
+	for canonical, words := range synonyms {
+		for _, word := range words {
+			if word = normalize(word); word != "" {
+				r.synonyms[word] = normalize(canonical)
+			}
+		}
+	}
+	for word := range r.synonyms {
+		r.synonymKeys = append(r.synonymKeys, word)
+	}
+	sortByLength(r.synonymKeys)
+
+	for _, rule := range rules {
+		compiled, err := r.compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("意图 %s 配置无效: %v", rule.Name, err)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// compile 将规则的各个模式编译为正则表达式
+func (r *Router) compile(rule Rule) (compiledRule, error) {
+	if rule.Name == "" || rule.Tool == "" {
+		return compiledRule{}, fmt.Errorf("名称和工具不能为空")
+	}
+	compiled := compiledRule{rule: rule, values: make(map[string]map[string]string)}
+	for slot, values := range rule.Slots {
+		compiled.values[slot] = make(map[string]string)
+		for value, words := range values {
+			compiled.values[slot][r.canonical(value)] = value
+			for _, word := range words {
+				if word = r.canonical(word); word != "" {
+					compiled.values[slot][word] = value
+				}
+			}
+		}
+	}
+
+	for _, pattern := range rule.Patterns {
+		var sb strings.Builder
+		var groups []string
+		rest := pattern
+		for rest != "" {
+			loc := slotRef.FindStringSubmatchIndex(rest)
+			if loc == nil {
+				groups = r.literal(&sb, rest, groups)
+				break
+			}
+			groups = r.literal(&sb, rest[:loc[0]], groups)
+			slot := rest[loc[2]:loc[3]]
+			sb.WriteString("(" + compiled.slotPattern(slot) + ")")
+			groups = append(groups, slot)
+			rest = rest[loc[1]:]
+		}
+		re, err := regexp.Compile(sb.String())
+		if err != nil {
+			return compiledRule{}, err
+		}
+		compiled.patterns = append(compiled.patterns, compiledPattern{re: re, groups: groups})
+	}
+	if len(compiled.patterns) == 0 {
+		return compiledRule{}, fmt.Errorf("至少需要一个模式")
+	}
+	return compiled, nil
+}
+
+// slotPattern 槽位的正则表达式，匹配该槽位的全部说法
+func (c *compiledRule) slotPattern(slot string) string {
+	values := c.values[slot]
+	if len(values) == 0 {
+		return numberPattern
+	}
+	words := make([]string, 0, len(values))
+	for word := range values {
+		words = append(words, word)
+	}
+	sortByLength(words)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return strings.Join(words, "|")
+}
+
+// Match 识别用户输入的意图，没有置信度达到阈值的规则时返回nil
+// 置信度只计算模式中普通文本和槽位匹配的字数，避免*匹配的否定词等内容也算作命中
+// 多条规则命中时选择置信度最高的，置信度相同时选择配置在前的
+func (r *Router) Match(text string) *Result {
+	text = r.canonical(text)
+	length := utf8.RuneCountInString(text)
+	if length == 0 {
+		return nil
+	}
+	var best *Result
+	for i := range r.rules {
+		rule := &r.rules[i]
+		for _, pattern := range rule.patterns {
+			loc := pattern.re.FindStringSubmatchIndex(text)
+			if loc == nil {
+				continue
+			}
+			matched := utf8.RuneCountInString(text[loc[0]:loc[1]])
+			slots := make(map[string]string)
+			for j, slot := range pattern.groups {
+				word := text[loc[2+2*j]:loc[3+2*j]]
+				if slot == "" {
+					matched -= utf8.RuneCountInString(word)
+				} else if value, ok := rule.values[slot][word]; ok {
+					slots[slot] = value
+				} else {
+					slots[slot] = word
+				}
+			}
+			confidence := float64(matched) / float64(length)
+			if confidence < r.minConfidence || (best != nil && confidence <= best.Confidence) {
+				continue
+			}
+			best = &Result{
+				Intent:     rule.rule.Name,
+				Tool:       rule.rule.Tool,
+				Arguments:  fillArguments(rule.rule.Arguments, slots),
+				Slots:      slots,
+				Reply:      fill(rule.rule.Reply, slots),
+				Confidence: confidence,
+			}
+		}
+	}
+	return best
+}
+
+// canonical 规范化文本并将同义词替换为规范词
+func (r *Router) canonical(text string) string {
+	text = normalize(text)
+	if len(r.synonymKeys) == 0 || text == "" {
+		return text
+	}
+	var sb strings.Builder
+	for text != "" {
+		replaced := false
+		for _, word := range r.synonymKeys {
+			if strings.HasPrefix(text, word) {
+				sb.WriteString(r.synonyms[word])
+				text = text[len(word):]
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			_, size := utf8.DecodeRuneInString(text)
+			sb.WriteString(text[:size])
+			text = text[size:]
+		}
+	}
+	return sb.String()
+}
+
+// normalize 去除标点和空白并转为小写
+func normalize(text string) string {
+	text = utils.RemoveAllPunctuation(text)
+	return strings.ToLower(strings.Join(strings.Fields(text), ""))
+}
+
+// literal 将模式中的普通文本规范化后转义写入sb，*转为匹配任意文本的捕获组，返回追加了这些捕获组的groups
+func (r *Router) literal(sb *strings.Builder, text string, groups []string) []string {
+	for i, part := range strings.Split(text, "*") {
+		if i > 0 {
+			sb.WriteString("(.*)")
+			groups = append(groups, "")
+		}
+		sb.WriteString(regexp.QuoteMeta(r.canonical(part)))
+	}
+	return groups
+}
+
+// fillArguments 替换参数中的槽位引用，整个值只引用一个数字槽位时按数字传递
+func fillArguments(arguments map[string]interface{}, slots map[string]string) map[string]interface{} {
+	filled := make(map[string]interface{}, len(arguments))
+	for key, value := range arguments {
+		s, ok := value.(string)
+		if !ok {
+			filled[key] = value
+			continue
+		}
+		if m := slotRef.FindStringSubmatch(s); m != nil && m[0] == s {
+			if n, err := strconv.ParseFloat(slots[m[1]], 64); err == nil {
+				filled[key] = n
+				continue
+			}
+		}
+		filled[key] = fill(s, slots)
+	}
+	return filled
+}
+
+// fill 将文本中的{slot}替换为槽位的规范值
+func fill(text string, slots map[string]string) string {
+	return slotRef.ReplaceAllStringFunc(text, func(ref string) string {
+		if value, ok := slots[ref[1:len(ref)-1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+func sortByLength(words []string) {
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+}
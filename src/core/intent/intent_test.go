@@ -0,0 +1,144 @@
+package intent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestRouter(t *testing.T) *Router {
+	t.Helper()
+	rules := []Rule{
+		{
+			Name:     "next_song",
+			Patterns: []string{"下一首", "*下一首*", "切歌"},
+			Tool:     "play_next",
+		},
+		{
+			Name:      "volume",
+			Patterns:  []string{"音量调到{level}", "把音量调到{level}*"},
+			Tool:      "set_volume",
+			Arguments: map[string]interface{}{"volume": "{level}", "unit": "{level}%"},
+			Reply:     "音量已调到{level}",
+		},
+		{
+			Name:     "light",
+			Patterns: []string{"{action}灯"},
+			Slots: map[string]map[string][]string{
+				"action": {"on": {"打开", "开"}, "off": {"关闭", "关"}},
+			},
+			Tool:      "light",
+			Arguments: map[string]interface{}{"state": "{action}"},
+		},
+	}
+	synonyms := map[string][]string{"下一首": {"下一曲", "下首"}}
+	router, err := NewRouter(rules, synonyms, 0)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+	return router
+}
+
+func TestMatch(t *testing.T) {
+	router := newTestRouter(t)
+	tests := []struct {
+		name       string
+		input      string
+		intent     string
+		confidence float64
+		arguments  map[string]interface{}
+		reply      string
+	}{
+		{name: "完整匹配", input: "下一首", intent: "next_song", confidence: 1},
+		{name: "同义词", input: "下一曲！", intent: "next_song", confidence: 1},
+		{name: "通配符不计入置信度", input: "播放下一首", intent: "next_song", confidence: 0.6},
+		{name: "否定句不命中", input: "我不想听下一首"},
+		{name: "前后都有无关内容", input: "等一下再放下一首吧"},
+		{
+			name:       "数字槽位",
+			input:      "音量调到50",
+			intent:     "volume",
+			confidence: 1,
+			arguments:  map[string]interface{}{"volume": 50.0, "unit": "50%"},
+			reply:      "音量已调到50",
+		},
+		{
+			name:       "槽位后的通配符",
+			input:      "把音量调到30吧",
+			intent:     "volume",
+			confidence: 7.0 / 8,
+			arguments:  map[string]interface{}{"volume": 30.0, "unit": "30%"},
+			reply:      "音量已调到30",
+		},
+		{
+			name:       "槽位说法转为规范值",
+			input:      "打开灯",
+			intent:     "light",
+			confidence: 1,
+			arguments:  map[string]interface{}{"state": "on"},
+		},
+		{
+			name:       "较短的说法",
+			input:      "关灯",
+			intent:     "light",
+			confidence: 1,
+			arguments:  map[string]interface{}{"state": "off"},
+		},
+		{name: "无关输入", input: "今天天气怎么样"},
+		{name: "只有标点", input: "。。"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := router.Match(tt.input)
+			if tt.intent == "" {
+				if result != nil {
+					t.Errorf("Match(%q) = %s(%.2f), want nil", tt.input, result.Intent, result.Confidence)
+				}
+				return
+			}
+			if result == nil {
+				t.Fatalf("Match(%q) = nil, want %s", tt.input, tt.intent)
+			}
+			if result.Intent != tt.intent || result.Confidence != tt.confidence {
+				t.Errorf("Match(%q) = %s(%.3f), want %s(%.3f)", tt.input, result.Intent, result.Confidence, tt.intent, tt.confidence)
+			}
+			if tt.arguments != nil && !reflect.DeepEqual(result.Arguments, tt.arguments) {
+				t.Errorf("Match(%q).Arguments = %v, want %v", tt.input, result.Arguments, tt.arguments)
+			}
+			if result.Reply != tt.reply {
+				t.Errorf("Match(%q).Reply = %q, want %q", tt.input, result.Reply, tt.reply)
+			}
+		})
+	}
+}
+
+func TestMatchMinConfidence(t *testing.T) {
+	rules := []Rule{{Name: "next_song", Patterns: []string{"*下一首*"}, Tool: "play_next"}}
+	router, err := NewRouter(rules, nil, 0.3)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+	result := router.Match("我不想听下一首")
+	if result == nil || result.Confidence != 3.0/7 {
+		t.Errorf("降低阈值后 Match() = %v, want next_song(0.43)", result)
+	}
+}
+
+func TestNewRouterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{name: "缺少工具", rules: []Rule{{Name: "a", Patterns: []string{"你好"}}}},
+		{name: "缺少名称", rules: []Rule{{Tool: "t", Patterns: []string{"你好"}}}},
+		{name: "缺少模式", rules: []Rule{{Name: "a", Tool: "t"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRouter(tt.rules, nil, 0); err == nil {
+				t.Error("NewRouter() error = nil, want error")
+			}
+		})
+	}
+}
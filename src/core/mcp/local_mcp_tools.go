@@ -63,20 +63,12 @@ func (c *LocalClient) AddToolTime() error {
 	return nil
 }
 
+// AddToolChangeRole 注册切换角色的工具，角色由连接按用户查找，描述中的角色列表也由连接按用户替换
 func (c *LocalClient) AddToolChangeRole() error {
-	roles := c.cfg.Roles
-	prompts := map[string]string{}
-	roleNames := ""
-	if roles == nil {
-		c.logger.Warn(
-			"AddToolChangeRole: roles settings is nil or empty, Skipping tool registration",
-		)
-		return nil
-	} else {
-		for _, role := range roles {
-			items := strings.Split(role, "@")
-			prompts[items[0]] = items[1]
-			roleNames += items[0] + ", "
+	roleNames := []string{}
+	for _, role := range c.cfg.Roles {
+		if items := strings.SplitN(role, "@", 2); len(items) == 2 {
+			roleNames = append(roleNames, items[0])
 		}
 	}
 
@@ -92,17 +84,16 @@ func (c *LocalClient) AddToolChangeRole() error {
 	}
 
 	c.AddTool("change_role",
-		"当用户想切换角色/模型性格/助手名字时调用,可选的角色有：["+roleNames+"]",
+		"当用户想切换角色/模型性格/助手名字时调用,可选的角色有：["+strings.Join(roleNames, ", ")+"]",
 		InputSchema,
 		func(ctx context.Context, args map[string]any) (interface{}, error) {
-			role := args["role"].(string)
+			role, _ := args["role"].(string)
 			res := types.ActionResponse{
 				Action: types.ActionTypeCallHandler, // 动作类型
 				Result: types.ActionResponseCall{
 					FuncName: "mcp_handler_change_role", // 函数名
 					Args: map[string]string{
-						"role":   role, // 函数参数
-						"prompt": prompts[role],
+						"role": role, // 函数参数
 					},
 				},
 			}
@@ -181,3 +172,159 @@ func (c *LocalClient) AddToolPlayMusic() error {
 
 	return nil
 }
+
+func (c *LocalClient) AddToolMemory() error {
+	InputSchema := ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "需要忘记的内容，根据上下文补全用户所指的具体信息，如用户说“忘掉这个”时填写上文提到的事实；用户要求忘记全部时填写'all'",
+			},
+		},
+		Required: []string{"content"},
+	}
+
+	c.AddTool("forget_memory",
+		"当用户要求忘记/删除你记住的关于他的某些信息时调用",
+		InputSchema,
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			content, _ := args["content"].(string)
+			res := types.ActionResponse{
+				Action: types.ActionTypeCallHandler, // 动作类型
+				Result: types.ActionResponseCall{
+					FuncName: "mcp_handler_forget_memory", // 函数名
+					Args:     content,                     // 函数参数
+				},
+			}
+			return res, nil
+		})
+
+	c.AddTool("recall_memory",
+		"当用户询问你记得关于他的哪些信息时调用",
+		ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+			Required:   []string{},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			res := types.ActionResponse{
+				Action: types.ActionTypeCallHandler, // 动作类型
+				Result: types.ActionResponseCall{
+					FuncName: "mcp_handler_recall_memory", // 函数名
+				},
+			}
+			return res, nil
+		})
+
+	return nil
+}
+
+func (c *LocalClient) AddToolReminder() error {
+	c.AddTool("set_timer",
+		"当用户想要倒计时/设置计时器时调用，例如“计时5分钟”“10分钟后叫我”",
+		ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"duration_seconds": map[string]any{
+					"type":        "number",
+					"description": "计时的总秒数，例如5分钟为300",
+				},
+				"label": map[string]any{
+					"type":        "string",
+					"description": "计时的用途，如“煮鸡蛋”，没有则为空字符串",
+				},
+			},
+			Required: []string{"duration_seconds"},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			args["kind"] = "timer"
+			return reminderCall("mcp_handler_set_reminder", args), nil
+		})
+
+	c.AddTool("set_alarm",
+		"当用户想要设置闹钟时调用，例如“明天早上7点叫我起床”",
+		ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"time": map[string]any{
+					"type":        "string",
+					"description": "闹钟时间，今天或最近的该时刻使用\"HH:MM\"，指定日期时使用\"YYYY-MM-DD HH:MM\"，需要时先调用get_time获取当前日期",
+				},
+				"label": map[string]any{
+					"type":        "string",
+					"description": "闹钟的用途，如“起床”，没有则为空字符串",
+				},
+			},
+			Required: []string{"time"},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			args["kind"] = "alarm"
+			return reminderCall("mcp_handler_set_reminder", args), nil
+		})
+
+	c.AddTool("set_reminder",
+		"当用户要求在某个时间提醒他做某件事时调用，例如“20分钟后提醒我关火”“下午3点提醒我开会”",
+		ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"content": map[string]any{
+					"type":        "string",
+					"description": "需要提醒的事情，如“关火”",
+				},
+				"delay_seconds": map[string]any{
+					"type":        "number",
+					"description": "多少秒后提醒，用户说的是相对时间时填写，例如20分钟后为1200",
+				},
+				"time": map[string]any{
+					"type":        "string",
+					"description": "提醒的时刻，用户说的是具体时间时填写，格式同set_alarm的time",
+				},
+			},
+			Required: []string{"content"},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			args["kind"] = "reminder"
+			return reminderCall("mcp_handler_set_reminder", args), nil
+		})
+
+	c.AddTool("list_reminders",
+		"当用户询问设置了哪些计时器/闹钟/提醒时调用",
+		ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+			Required:   []string{},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			return reminderCall("mcp_handler_list_reminders", args), nil
+		})
+
+	c.AddTool("cancel_reminder",
+		"当用户想要取消计时器/闹钟/提醒时调用，不确定取消哪一个时先调用list_reminders",
+		ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"id": map[string]any{
+					"type":        "string",
+					"description": "要取消的提醒编号，来自list_reminders的结果；只有一个提醒时可以填写'only'",
+				},
+			},
+			Required: []string{"id"},
+		},
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			return reminderCall("mcp_handler_cancel_reminder", args), nil
+		})
+
+	return nil
+}
+
+// reminderCall 将提醒相关的工具调用交给连接处理，连接负责按设备管理提醒
+func reminderCall(funcName string, args map[string]any) types.ActionResponse {
+	return types.ActionResponse{
+		Action: types.ActionTypeCallHandler, // 动作类型
+		Result: types.ActionResponseCall{
+			FuncName: funcName, // 函数名
+			Args:     args,     // 函数参数
+		},
+	}
+}
@@ -64,6 +64,21 @@ func NewManagerForPool(lg *utils.Logger, cfg *configs.Config) *Manager {
 	return mgr
 }
 
+// NewLocalManager 创建只包含本地工具的MCP管理器，不连接外部MCP服务器，用于离线回放
+func NewLocalManager(lg *utils.Logger, cfg *configs.Config) *Manager {
+	mgr := &Manager{
+		logger:    lg,
+		clients:   make(map[string]MCPClient),
+		tools:     make([]string, 0),
+		systemCfg: cfg,
+	}
+	mgr.localClient, _ = NewLocalClient(lg, cfg)
+	mgr.localClient.Start(context.Background())
+	mgr.clients["local"] = mgr.localClient
+	mgr.isInitialized = true
+	return mgr
+}
+
 // preInitializeServers 预初始化不依赖连接的MCP服务器
 func (m *Manager) preInitializeServers() error {
 	m.localClient, _ = NewLocalClient(m.logger, m.systemCfg)
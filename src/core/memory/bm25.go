@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Tokenize 将文本切分为检索用的词元
+// 英文和数字按单词切分，中文等无空格文字按单字和相邻两字切分
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		for i, r := range cjk {
+			tokens = append(tokens, string(r))
+			if i+1 < len(cjk) {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			flushCJK()
+			word = append(word, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushWord()
+			cjk = append(cjk, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// Score 使用BM25计算查询与每个文档的相关度得分，得分为0表示没有共同的词元
+func Score(query string, docs []string) []float64 {
+	scores := make([]float64, len(docs))
+	queryTokens := Tokenize(query)
+	if len(queryTokens) == 0 || len(docs) == 0 {
+		return scores
+	}
+
+	docTokens := make([][]string, len(docs))
+	docFreq := make(map[string]int)
+	totalLen := 0
+	for i, doc := range docs {
+		docTokens[i] = Tokenize(doc)
+		totalLen += len(docTokens[i])
+		seen := make(map[string]bool)
+		for _, token := range docTokens[i] {
+			if !seen[token] {
+				seen[token] = true
+				docFreq[token]++
+			}
+		}
+	}
+	avgLen := float64(totalLen) / float64(len(docs))
+	if avgLen == 0 {
+		return scores
+	}
+
+	n := float64(len(docs))
+	for i, tokens := range docTokens {
+		termFreq := make(map[string]int)
+		for _, token := range tokens {
+			termFreq[token]++
+		}
+		for _, token := range queryTokens {
+			tf := float64(termFreq[token])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[token])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(len(tokens))/avgLen))
+		}
+	}
+	return scores
+}
+
+// Rank 返回BM25得分大于0的文档下标，按得分从高到低排列，最多topK个
+func Rank(query string, docs []string, topK int) []int {
+	scores := Score(query, docs)
+	var indexes []int
+	for i, score := range scores {
+		if score > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return scores[indexes[i]] > scores[indexes[j]]
+	})
+	if len(indexes) > topK {
+		indexes = indexes[:max(topK, 0)]
+	}
+	return indexes
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "英文和数字按单词切分",
+			input:    "I like Go 1.22!",
+			expected: []string{"i", "like", "go", "1", "22"},
+		},
+		{
+			name:     "中文按单字和相邻两字切分",
+			input:    "喜欢猫",
+			expected: []string{"喜", "喜欢", "欢", "欢猫", "猫"},
+		},
+		{
+			name:     "中英文混合",
+			input:    "用户叫Tom，喜欢猫",
+			expected: []string{"用", "用户", "户", "户叫", "叫", "tom", "喜", "喜欢", "欢", "欢猫", "猫"},
+		},
+		{
+			name:     "只有标点",
+			input:    "，。！?",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Tokenize(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	docs := []string{"用户喜欢猫", "用户住在北京", "用户的女儿叫小雨"}
+
+	scores := Score("我住在哪里", docs)
+	if scores[1] <= 0 {
+		t.Errorf("相关文档得分 = %f, want > 0", scores[1])
+	}
+	if scores[0] != 0 || scores[2] != 0 {
+		t.Errorf("无关文档得分 = %v, want 0", scores)
+	}
+
+	// 所有文档都包含的词元区分度低，得分应低于只在个别文档中出现的词元
+	common := Score("用户", docs)
+	rare := Score("北京", docs)
+	if common[1] >= rare[1] {
+		t.Errorf("常见词得分 %f 不应高于罕见词得分 %f", common[1], rare[1])
+	}
+
+	if scores := Score("", docs); !reflect.DeepEqual(scores, []float64{0, 0, 0}) {
+		t.Errorf("空查询得分 = %v, want 全为0", scores)
+	}
+	if scores := Score("北京", nil); len(scores) != 0 {
+		t.Errorf("没有文档时得分 = %v, want 空", scores)
+	}
+}
+
+func TestRank(t *testing.T) {
+	docs := []string{
+		"用户喜欢猫",
+		"用户喜欢吃火锅，尤其是重庆火锅",
+		"用户住在北京",
+		"用户喜欢在周末吃火锅",
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		topK     int
+		expected []int
+	}{
+		{
+			name:     "按得分从高到低排列",
+			query:    "重庆火锅",
+			topK:     5,
+			expected: []int{1, 3},
+		},
+		{
+			name:     "最多返回topK个",
+			query:    "喜欢",
+			topK:     2,
+			expected: []int{0, 3},
+		},
+		{
+			name:     "没有相关文档",
+			query:    "上海",
+			topK:     5,
+			expected: nil,
+		},
+		{
+			name:     "topK为0",
+			query:    "北京",
+			topK:     0,
+			expected: []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Rank(tt.query, docs, tt.topK)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Rank(%q, %d) = %v, want %v", tt.query, tt.topK, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const (
+	defaultTopK     = 5
+	defaultMaxFacts = 100
+
+	storeTimeout   = 5 * time.Second
+	extractTimeout = 60 * time.Second
+
+	// maxFactLength 单条记忆的最大长度，超过的提取结果视为无效
+	maxFactLength = 200
+	// forgetScoreRatio 删除记忆时，得分不低于最佳匹配该比例的记忆一并删除
+	forgetScoreRatio = 0.75
+)
+
+const extractPrompt = `你是记忆提取助手。请从对话中提取关于用户的、长期有效的事实，例如姓名、年龄、职业、喜好、家庭成员、生活习惯、重要的计划和约定。
+要求：
+1. 只提取用户自己说出的信息，忽略闲聊、一次性的请求和助手说的内容；
+2. 每行输出一条，使用“用户……”开头的简洁陈述句，不要编号；
+3. 已记住的事实不要重复输出；
+4. 没有可提取的内容时只输出“无”。`
+
+// Fact 一条关于用户的记忆
+type Fact struct {
+	ID        uint
+	Content   string
+	CreatedAt time.Time
+}
+
+// Store 记忆存储接口
+type Store interface {
+	// List 按创建时间从早到晚列出用户的全部记忆
+	List(ctx context.Context, userKey string) ([]Fact, error)
+
+	// Add 为用户添加记忆
+	Add(ctx context.Context, userKey string, contents []string) error
+
+	// Delete 删除用户的指定记忆
+	Delete(ctx context.Context, userKey string, ids []uint) error
+
+	// Clear 删除用户的全部记忆
+	Clear(ctx context.Context, userKey string) error
+}
+
+// Config 长期记忆配置
+type Config struct {
+	TopK     int // 每次查询返回的相关记忆条数
+	MaxFacts int // 每个用户最多保存的记忆条数，超过时删除最早的记忆
+}
+
+// Manager 长期记忆管理器，所有连接共享，按用户创建记忆实例
+type Manager struct {
+	store    Store
+	llm      types.LLMProvider // 用于从对话中提取记忆
+	logger   *utils.Logger
+	topK     int
+	maxFacts int
+}
+
+// NewManager 创建长期记忆管理器
+func NewManager(store Store, llm types.LLMProvider, logger *utils.Logger, config Config) *Manager {
+	m := &Manager{
+		store:    store,
+		llm:      llm,
+		logger:   logger,
+		topK:     defaultTopK,
+		maxFacts: defaultMaxFacts,
+	}
+	if config.TopK > 0 {
+		m.topK = config.TopK
+	}
+	if config.MaxFacts > 0 {
+		m.maxFacts = config.MaxFacts
+	}
+	return m
+}
+
+// ForUser 获取用户的记忆实例
+func (m *Manager) ForUser(userKey string) *UserMemory {
+	return &UserMemory{
+		manager: m,
+		userKey: userKey,
+	}
+}
+
+// UserMemory 单个用户的长期记忆
+type UserMemory struct {
+	manager *Manager
+	userKey string
+
+	mu        sync.Mutex
+	forgotten []string // 本次连接中用户要求忘记的记忆，提取时不再保存
+}
+
+var (
+	_ chat.MemoryInterface = (*UserMemory)(nil)
+	_ chat.MemoryEditor    = (*UserMemory)(nil)
+)
+
+// QueryMemory 检索与用户当前输入相关的记忆，返回注入对话的系统消息内容，没有相关记忆时返回空字符串
+func (u *UserMemory) QueryMemory(query string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	facts, err := u.manager.store.List(ctx, u.userKey)
+	if err != nil {
+		return "", err
+	}
+	if len(facts) == 0 {
+		return "", nil
+	}
+
+	var relevant []string
+	if len(facts) <= u.manager.topK {
+		// 记忆较少时全部注入，避免检索遗漏
+		for _, fact := range facts {
+			relevant = append(relevant, fact.Content)
+		}
+	} else {
+		for _, i := range Rank(query, contents(facts), u.manager.topK) {
+			relevant = append(relevant, facts[i].Content)
+		}
+	}
+	if len(relevant) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("以下是你记得的关于用户的信息，回答时可以自然地参考，不要逐条复述：")
+	for _, content := range relevant {
+		sb.WriteString("\n- ")
+		sb.WriteString(content)
+	}
+	return sb.String(), nil
+}
+
+// SaveMemory 使用LLM从对话中提取新的事实并保存
+func (u *UserMemory) SaveMemory(dialogue []chat.Message) error {
+	transcript := formatDialogue(dialogue)
+	if transcript == "" {
+		return nil
+	}
+	if u.manager.llm == nil {
+		return errors.New("未配置用于提取记忆的LLM")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), extractTimeout)
+	defer cancel()
+	facts, err := u.manager.store.List(ctx, u.userKey)
+	if err != nil {
+		return fmt.Errorf("读取已有记忆失败: %v", err)
+	}
+
+	forgotten := u.forgottenFacts()
+	var prompt strings.Builder
+	if len(facts) > 0 {
+		prompt.WriteString("已记住的事实：\n")
+		for _, fact := range facts {
+			prompt.WriteString("- " + fact.Content + "\n")
+		}
+		prompt.WriteString("\n")
+	}
+	if len(forgotten) > 0 {
+		prompt.WriteString("用户要求忘记的事实，不要输出：\n")
+		for _, content := range forgotten {
+			prompt.WriteString("- " + content + "\n")
+		}
+		prompt.WriteString("\n")
+	}
+	prompt.WriteString("对话：\n" + transcript)
+
+	output, err := u.complete(ctx, []chat.Message{
+		{Role: "system", Content: extractPrompt},
+		{Role: "user", Content: prompt.String()},
+	})
+	if err != nil {
+		return fmt.Errorf("提取记忆失败: %v", err)
+	}
+
+	newFacts := parseFacts(output, facts, forgotten)
+	if len(newFacts) == 0 {
+		return nil
+	}
+	if len(newFacts) > u.manager.maxFacts {
+		newFacts = newFacts[:u.manager.maxFacts]
+	}
+	if err := u.manager.store.Add(ctx, u.userKey, newFacts); err != nil {
+		return fmt.Errorf("保存记忆失败: %v", err)
+	}
+	u.manager.logger.Info("用户 %s 新增 %d 条记忆: %v", u.userKey, len(newFacts), newFacts)
+
+	if err := u.trimFacts(ctx); err != nil {
+		return fmt.Errorf("清理超出上限的记忆失败: %v", err)
+	}
+	return nil
+}
+
+// trimFacts 重新读取用户的记忆，超过上限时删除最早的记忆
+// 读取保存后的结果，同一用户的多个连接同时保存时也不会超过上限
+func (u *UserMemory) trimFacts(ctx context.Context) error {
+	facts, err := u.manager.store.List(ctx, u.userKey)
+	if err != nil {
+		return err
+	}
+	overflow := len(facts) - u.manager.maxFacts
+	if overflow <= 0 {
+		return nil
+	}
+	ids := make([]uint, 0, overflow)
+	for _, fact := range facts[:overflow] {
+		ids = append(ids, fact.ID)
+	}
+	return u.manager.store.Delete(ctx, u.userKey, ids)
+}
+
+// ClearMemory 清空用户的全部记忆
+func (u *UserMemory) ClearMemory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	return u.manager.store.Clear(ctx, u.userKey)
+}
+
+// ListMemory 列出用户的全部记忆
+func (u *UserMemory) ListMemory() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	facts, err := u.manager.store.List(ctx, u.userKey)
+	if err != nil {
+		return nil, err
+	}
+	return contents(facts), nil
+}
+
+// ForgetMemory 删除与描述最相关的记忆，返回被删除的内容
+func (u *UserMemory) ForgetMemory(query string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	facts, err := u.manager.store.List(ctx, u.userKey)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := Score(query, contents(facts))
+	best := 0.0
+	for _, score := range scores {
+		best = max(best, score)
+	}
+	if best == 0 {
+		return nil, nil
+	}
+
+	var ids []uint
+	var forgotten []string
+	for i, score := range scores {
+		if score >= best*forgetScoreRatio {
+			ids = append(ids, facts[i].ID)
+			forgotten = append(forgotten, facts[i].Content)
+		}
+	}
+	if err := u.manager.store.Delete(ctx, u.userKey, ids); err != nil {
+		return nil, err
+	}
+	u.mu.Lock()
+	u.forgotten = append(u.forgotten, forgotten...)
+	u.mu.Unlock()
+	u.manager.logger.Info("用户 %s 删除了 %d 条记忆: %v", u.userKey, len(forgotten), forgotten)
+	return forgotten, nil
+}
+
+// forgottenFacts 返回本次连接中被删除的记忆
+func (u *UserMemory) forgottenFacts() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.forgotten...)
+}
+
+// complete 请求LLM并拼接完整回复
+func (u *UserMemory) complete(ctx context.Context, messages []chat.Message) (string, error) {
+	responses, err := u.manager.llm.Response(ctx, "memory-"+u.userKey, messages)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for response := range responses {
+		sb.WriteString(response)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	output := strings.TrimSpace(sb.String())
+	if strings.HasPrefix(output, "【") {
+		// LLM提供者以【】包裹的文本返回服务异常
+		return "", errors.New(output)
+	}
+	return output, nil
+}
+
+// formatDialogue 将对话中用户和助手的文本消息整理为文字记录
+func formatDialogue(dialogue []chat.Message) string {
+	var sb strings.Builder
+	hasUser := false
+	for _, msg := range dialogue {
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			continue
+		}
+		switch msg.Role {
+		case "user":
+			hasUser = true
+			sb.WriteString("用户：" + content + "\n")
+		case "assistant":
+			sb.WriteString("助手：" + content + "\n")
+		}
+	}
+	if !hasUser {
+		return ""
+	}
+	return sb.String()
+}
+
+// parseFacts 解析LLM提取的事实，去掉序号、与已有记忆重复的内容和用户要求忘记的内容
+func parseFacts(output string, existing []Fact, forgotten []string) []string {
+	seen := make(map[string]bool)
+	for _, fact := range existing {
+		seen[normalizeFact(fact.Content)] = true
+	}
+	for _, content := range forgotten {
+		seen[normalizeFact(content)] = true
+	}
+
+	var facts []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•·0123456789.、) "))
+		if line == "" || line == "无" || len([]rune(line)) > maxFactLength {
+			continue
+		}
+		key := normalizeFact(line)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		facts = append(facts, line)
+	}
+	return facts
+}
+
+// normalizeFact 去掉标点和空白，用于判断记忆是否重复
+func normalizeFact(content string) string {
+	return strings.Join(Tokenize(content), " ")
+}
+
+func contents(facts []Fact) []string {
+	result := make([]string, len(facts))
+	for i, fact := range facts {
+		result[i] = fact.Content
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseFacts(t *testing.T) {
+	existing := []Fact{{ID: 1, Content: "用户叫小明"}}
+	forgotten := []string{"用户喜欢猫"}
+
+	tests := []struct {
+		name     string
+		output   string
+		expected []string
+	}{
+		{
+			name:     "去掉序号和列表符号",
+			output:   "1. 用户住在北京\n- 用户是程序员\n",
+			expected: []string{"用户住在北京", "用户是程序员"},
+		},
+		{
+			name:     "跳过已记住的事实",
+			output:   "用户叫小明。\n用户住在北京",
+			expected: []string{"用户住在北京"},
+		},
+		{
+			name:     "跳过用户要求忘记的事实",
+			output:   "用户喜欢猫！\n用户养了一条狗",
+			expected: []string{"用户养了一条狗"},
+		},
+		{
+			name:     "输出中重复的事实只保留一条",
+			output:   "用户住在北京\n用户住在北京。",
+			expected: []string{"用户住在北京"},
+		},
+		{
+			name:     "没有可提取的内容",
+			output:   "无",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseFacts(tt.output, existing, forgotten)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseFacts(%q) = %q, want %q", tt.output, result, tt.expected)
+			}
+		})
+	}
+}
+
+// memStore 内存中的记忆存储
+type memStore struct {
+	facts  []Fact
+	nextID uint
+}
+
+func (s *memStore) List(ctx context.Context, userKey string) ([]Fact, error) {
+	return append([]Fact(nil), s.facts...), nil
+}
+
+func (s *memStore) Add(ctx context.Context, userKey string, contents []string) error {
+	for _, content := range contents {
+		s.nextID++
+		s.facts = append(s.facts, Fact{ID: s.nextID, Content: content})
+	}
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, userKey string, ids []uint) error {
+	deleted := make(map[uint]bool)
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	var kept []Fact
+	for _, fact := range s.facts {
+		if !deleted[fact.ID] {
+			kept = append(kept, fact)
+		}
+	}
+	s.facts = kept
+	return nil
+}
+
+func (s *memStore) Clear(ctx context.Context, userKey string) error {
+	s.facts = nil
+	return nil
+}
+
+func TestTrimFacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		expected []string
+	}{
+		{name: "未超过上限", existing: []string{"a", "b"}, expected: []string{"a", "b"}},
+		{name: "刚好达到上限", existing: []string{"a", "b", "c"}, expected: []string{"a", "b", "c"}},
+		{name: "超过上限时删除最早的记忆", existing: []string{"a", "b", "c", "d", "e"}, expected: []string{"c", "d", "e"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{}
+			store.Add(context.Background(), "user", tt.existing)
+			u := NewManager(store, nil, nil, Config{MaxFacts: 3}).ForUser("user")
+			if err := u.trimFacts(context.Background()); err != nil {
+				t.Fatalf("trimFacts() error = %v", err)
+			}
+			if result := contents(store.facts); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("trimFacts() 后的记忆 = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
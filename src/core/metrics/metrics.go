@@ -0,0 +1,292 @@
+// Package metrics 提供按标签统计的计数器、仪表盘和直方图，
+// 并以Prometheus文本格式输出，供HTTP服务的/metrics接口抓取
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets 延迟类直方图的默认分桶（秒）
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 30}
+
+// collector 可以输出自身全部时间序列的指标
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry 指标注册表
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// defaultRegistry 本包创建的指标默认注册到此表
+var defaultRegistry = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write 以Prometheus文本格式输出全部指标
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler 输出默认注册表中全部指标的HTTP处理器
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		defaultRegistry.Write(w)
+	})
+}
+
+// vec 按标签值分组的时间序列
+type vec[T any] struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	newSeries  func() *T
+
+	mu     sync.Mutex
+	series map[string]*T
+	labels map[string][]string
+}
+
+func newVec[T any](name, help, kind string, labelNames []string, newSeries func() *T) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		newSeries:  newSeries,
+		series:     make(map[string]*T),
+		labels:     make(map[string][]string),
+	}
+}
+
+// with 取出标签值对应的时间序列，调用方需持有锁；标签值数量不足时以空字符串补齐
+func (v *vec[T]) with(labelValues []string) *T {
+	values := make([]string, len(v.labelNames))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newSeries()
+		v.series[key] = s
+		v.labels[key] = values
+	}
+	return s
+}
+
+// each 按标签值排序遍历全部时间序列，调用方需持有锁
+func (v *vec[T]) each(fn func(labels []string, s *T)) {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(v.labels[key], v.series[key])
+	}
+}
+
+func (v *vec[T]) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+}
+
+// formatLabels 输出{name="value",...}形式的标签，extra为附加的标签对
+func (v *vec[T]) formatLabels(values []string, extra ...string) string {
+	var pairs []string
+	for i, name := range v.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	vec *vec[float64]
+}
+
+// NewCounter 创建计数器并注册到默认注册表
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labelNames, func() *float64 { return new(float64) })}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc 计数加一
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 计数增加value，value必须非负
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	*c.vec.with(labelValues) += value
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	c.vec.writeHeader(w)
+	c.vec.each(func(labels []string, value *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.vec.name, c.vec.formatLabels(labels), formatFloat(*value))
+	})
+}
+
+// Gauge 可增可减的仪表盘
+type Gauge struct {
+	vec *vec[float64]
+}
+
+// NewGauge 创建仪表盘并注册到默认注册表
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labelNames, func() *float64 { return new(float64) })}
+	defaultRegistry.register(g)
+	return g
+}
+
+// Inc 加一
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec 减一
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Add 增加value，value可以为负
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+	*g.vec.with(labelValues) += value
+}
+
+// Set 设置为value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+	*g.vec.with(labelValues) = value
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+	g.vec.writeHeader(w)
+	g.vec.each(func(labels []string, value *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.vec.name, g.vec.formatLabels(labels), formatFloat(*value))
+	})
+}
+
+// histogramSeries 一组标签值对应的直方图数据，counts为各分桶（不含+Inf）的非累计计数
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram 直方图
+type Histogram struct {
+	vec     *vec[histogramSeries]
+	buckets []float64
+}
+
+// NewHistogram 创建直方图并注册到默认注册表，buckets为空时使用DefaultBuckets
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{buckets: buckets}
+	h.vec = newVec(name, help, "histogram", labelNames, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets))}
+	})
+	defaultRegistry.register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+	s := h.vec.with(labelValues)
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// ObserveDuration 以秒为单位记录一个时长
+func (h *Histogram) ObserveDuration(d time.Duration, labelValues ...string) {
+	h.Observe(d.Seconds(), labelValues...)
+}
+
+// ObserveSince 记录从start到现在的时长
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.ObserveDuration(time.Since(start), labelValues...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+	h.vec.writeHeader(w)
+	name := h.vec.name
+	h.vec.each(func(labels []string, s *histogramSeries) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.vec.formatLabels(labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.vec.formatLabels(labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, h.vec.formatLabels(labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, h.vec.formatLabels(labels), s.count)
+	})
+}
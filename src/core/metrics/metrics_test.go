@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// output 返回指标的文本格式输出
+func output(c collector) string {
+	var buf bytes.Buffer
+	c.write(&buf)
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_requests_total", "请求数", "method", "code")
+	c.Inc("GET", "200")
+	c.Add(2, "GET", "200")
+	c.Inc("POST") // 标签值不足时以空字符串补齐
+	c.Add(-1, "GET", "200")
+
+	expected := `# HELP test_requests_total 请求数
+# TYPE test_requests_total counter
+test_requests_total{method="GET",code="200"} 3
+test_requests_total{method="POST",code=""} 1
+`
+	if result := output(c); result != expected {
+		t.Errorf("Counter输出 =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	g := NewGauge("test_active", "活跃数")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	g.Add(0.5)
+
+	expected := `# HELP test_active 活跃数
+# TYPE test_active gauge
+test_active 1.5
+`
+	if result := output(g); result != expected {
+		t.Errorf("Gauge输出 =\n%s\nwant\n%s", result, expected)
+	}
+
+	g.Set(-2)
+	if result := output(g); result != "# HELP test_active 活跃数\n# TYPE test_active gauge\ntest_active -2\n" {
+		t.Errorf("Set后输出 = %q", result)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_latency_seconds", "延迟", []float64{1, 0.1}, "provider")
+	h.Observe(0.05, "a")
+	h.Observe(0.1, "a") // 等于上界的值计入该分桶
+	h.ObserveDuration(500*time.Millisecond, "a")
+	h.Observe(3, "a") // 超出最大分桶只计入+Inf
+
+	expected := `# HELP test_latency_seconds 延迟
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{provider="a",le="0.1"} 2
+test_latency_seconds_bucket{provider="a",le="1"} 3
+test_latency_seconds_bucket{provider="a",le="+Inf"} 4
+test_latency_seconds_sum{provider="a"} 3.65
+test_latency_seconds_count{provider="a"} 4
+`
+	if result := output(h); result != expected {
+		t.Errorf("Histogram输出 =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "普通文本", input: "doubao", expected: "doubao"},
+		{name: "反斜杠", input: `a\b`, expected: `a\\b`},
+		{name: "双引号", input: `say "hi"`, expected: `say \"hi\"`},
+		{name: "换行", input: "a\nb", expected: `a\nb`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := escapeLabel(tt.input); result != tt.expected {
+				t.Errorf("escapeLabel(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := &Counter{vec: newVec("test_a_total", "A", "counter", nil, func() *float64 { return new(float64) })}
+	g := &Gauge{vec: newVec("test_b", "B", "gauge", nil, func() *float64 { return new(float64) })}
+	r.register(c)
+	r.register(g)
+	c.Inc()
+	g.Set(2)
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	expected := "# HELP test_a_total A\n# TYPE test_a_total counter\ntest_a_total 1\n" +
+		"# HELP test_b B\n# TYPE test_b gauge\ntest_b 2\n"
+	if buf.String() != expected {
+		t.Errorf("Registry输出 =\n%s\nwant\n%s", buf.String(), expected)
+	}
+}
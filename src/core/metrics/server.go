@@ -0,0 +1,60 @@
+package metrics
+
+const namespace = "angrymiao_"
+
+// 对话流程的延迟指标，provider为提供者类型，transport为传输层类型
+var (
+	// ASRFinalLatency 用户说话结束（服务端VAD检测或客户端停止拾音）到得到识别结果的耗时
+	ASRFinalLatency = NewHistogram(namespace+"asr_final_latency_seconds",
+		"ASR识别结果相对用户说话结束的延迟", nil, "provider", "transport")
+
+	// LLMFirstToken 请求LLM到收到首个响应的耗时
+	LLMFirstToken = NewHistogram(namespace+"llm_first_token_seconds",
+		"LLM首个token的延迟", nil, "provider", "transport")
+
+	// TTSSynthesis 单句语音合成的耗时，流式合成为建立音频流的耗时
+	TTSSynthesis = NewHistogram(namespace+"tts_synthesis_seconds",
+		"TTS单句合成耗时", nil, "provider", "transport")
+
+	// FirstAudioFrame 轮次开始到下发首帧音频的耗时
+	FirstAudioFrame = NewHistogram(namespace+"first_audio_frame_seconds",
+		"轮次开始到首帧音频下发的延迟", nil, "provider", "transport")
+
+	// ToolCallDuration 工具调用耗时
+	ToolCallDuration = NewHistogram(namespace+"tool_call_duration_seconds",
+		"工具调用耗时", nil, "tool", "transport")
+
+	// PoolWait 从资源池获取提供者的耗时，包括池中无资源时新建资源的耗时
+	PoolWait = NewHistogram(namespace+"pool_wait_seconds",
+		"从资源池获取提供者的耗时", []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2, 5}, "pool")
+)
+
+// 会话和请求计数
+var (
+	// ActiveSessions 当前活跃的会话数
+	ActiveSessions = NewGauge(namespace+"active_sessions", "当前活跃的会话数", "transport")
+
+	// SessionsTotal 累计会话数
+	SessionsTotal = NewCounter(namespace+"sessions_total", "累计会话数", "transport")
+
+	// LLMRequests LLM请求数，status为ok或error
+	LLMRequests = NewCounter(namespace+"llm_requests_total", "LLM请求数", "provider", "transport", "status")
+
+	// ToolCalls 工具调用次数，status为ok或error
+	ToolCalls = NewCounter(namespace+"tool_calls_total", "工具调用次数", "tool", "transport", "status")
+)
+
+// TTS缓存统计
+var (
+	// TTSCacheRequests TTS缓存查询次数，result为hit或miss
+	TTSCacheRequests = NewCounter(namespace+"tts_cache_requests_total", "TTS缓存查询次数", "result")
+
+	// TTSCacheEvictions 因超出上限被淘汰的TTS缓存条目数
+	TTSCacheEvictions = NewCounter(namespace+"tts_cache_evictions_total", "TTS缓存淘汰的条目数")
+
+	// TTSCacheEntries 当前TTS缓存的条目数
+	TTSCacheEntries = NewGauge(namespace+"tts_cache_entries", "TTS缓存条目数")
+
+	// TTSCacheBytes 当前TTS缓存占用的磁盘空间
+	TTSCacheBytes = NewGauge(namespace+"tts_cache_bytes", "TTS缓存占用的字节数")
+)
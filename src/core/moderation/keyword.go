@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// KeywordRule 一个类别的关键词和正则表达式
+type KeywordRule struct {
+	Category string
+	Words    []string // 不区分大小写的关键词
+	Patterns []string // 正则表达式
+}
+
+type compiledRule struct {
+	category string
+	words    []string
+	patterns []*regexp.Regexp
+}
+
+// KeywordProvider 基于本地关键词和正则表达式的审核提供者
+type KeywordProvider struct {
+	rules []compiledRule
+}
+
+var _ Provider = (*KeywordProvider)(nil)
+
+// NewKeywordProvider 创建关键词审核提供者
+func NewKeywordProvider(rules []KeywordRule) (*KeywordProvider, error) {
+	p := &KeywordProvider{}
+	for _, rule := range rules {
+		compiled := compiledRule{category: rule.Category}
+		for _, word := range rule.Words {
+			if word = strings.TrimSpace(word); word != "" {
+				compiled.words = append(compiled.words, strings.ToLower(word))
+			}
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("类别 %s 的正则表达式无效: %v", rule.Category, err)
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+// Name 提供者名称
+func (p *KeywordProvider) Name() string {
+	return "keyword"
+}
+
+// Check 按顺序匹配各类别的关键词和正则表达式，返回第一个命中类别的全部命中片段
+func (p *KeywordProvider) Check(ctx context.Context, text string) (*Result, error) {
+	lower := strings.ToLower(text)
+	for _, rule := range p.rules {
+		var matches []string
+		for _, word := range rule.words {
+			matches = append(matches, findFold(text, lower, word)...)
+		}
+		for _, re := range rule.patterns {
+			matches = append(matches, re.FindAllString(text, -1)...)
+		}
+		if len(matches) > 0 {
+			return &Result{
+				Flagged:  true,
+				Category: rule.category,
+				Reason:   fmt.Sprintf("命中关键词: %s", strings.Join(matches, ",")),
+				Matches:  matches,
+			}, nil
+		}
+	}
+	return &Result{}, nil
+}
+
+// findFold 找出text中与word不区分大小写相同的全部片段，返回原文中的写法
+func findFold(text, lower, word string) []string {
+	var found []string
+	for offset := 0; ; {
+		i := strings.Index(lower[offset:], word)
+		if i < 0 {
+			return found
+		}
+		start := offset + i
+		if len(lower) == len(text) {
+			found = append(found, text[start:start+len(word)])
+		} else {
+			// 大小写转换改变了字节长度，无法对应回原文，直接使用关键词
+			found = append(found, word)
+		}
+		offset = start + len(word)
+	}
+}
@@ -0,0 +1,76 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const classifyPrompt = `你是内容安全审核员。判断用户给出的文本是否属于以下任一类别：%s。
+只输出一个JSON对象，不要输出其他内容：
+{"flagged": true或false, "category": "命中的类别，未命中时为空", "reason": "简短的原因"}`
+
+// DefaultLLMCategories LLM分类器默认判断的类别
+var DefaultLLMCategories = []string{"色情", "暴力", "自残", "违法犯罪", "仇恨歧视", "政治敏感"}
+
+// LLMProvider 使用LLM对文本分类的审核提供者，比关键词更准确，但每次审核都会请求LLM
+// 用于输出审核时每句回复都要等待一次分类，最多checkTimeout
+type LLMProvider struct {
+	llm        types.LLMProvider
+	categories []string
+}
+
+var _ Provider = (*LLMProvider)(nil)
+
+// NewLLMProvider 创建LLM审核提供者，categories为空时使用默认类别
+func NewLLMProvider(llm types.LLMProvider, categories []string) *LLMProvider {
+	if len(categories) == 0 {
+		categories = DefaultLLMCategories
+	}
+	return &LLMProvider{
+		llm:        llm,
+		categories: categories,
+	}
+}
+
+// Name 提供者名称
+func (p *LLMProvider) Name() string {
+	return "llm"
+}
+
+// Check 请求LLM判断文本的类别
+func (p *LLMProvider) Check(ctx context.Context, text string) (*Result, error) {
+	messages := []types.Message{
+		{Role: "system", Content: fmt.Sprintf(classifyPrompt, strings.Join(p.categories, "、"))},
+		{Role: "user", Content: text},
+	}
+	responses, err := p.llm.Response(ctx, "moderation", messages)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for response := range responses {
+		sb.WriteString(response)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	output := sb.String()
+	parsed := utils.Extract_json_from_string(output)
+	if parsed == nil {
+		return nil, errors.New("无法解析审核结果: " + output)
+	}
+	flagged, _ := parsed["flagged"].(bool)
+	category, _ := parsed["category"].(string)
+	reason, _ := parsed["reason"].(string)
+	return &Result{
+		Flagged:  flagged,
+		Category: category,
+		Reason:   reason,
+	}, nil
+}
@@ -0,0 +1,179 @@
+// Package moderation 审核用户输入和模型回复的内容
+// 审核由一组Provider依次完成，命中后按审核配置中输入或输出对应的动作处理：
+// 拦截、改写、以固定回复代替或只记录日志
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// Action 命中审核后的动作
+type Action string
+
+const (
+	ActionBlock   Action = "block"   // 拦截：输入不再交给模型，输出不播报
+	ActionRewrite Action = "rewrite" // 改写：将命中的片段替换为*，无法定位片段时按reply处理
+	ActionReply   Action = "reply"   // 以固定回复代替，输出命中后本轮剩余的回复不再播报
+	ActionLog     Action = "log"     // 只记录日志
+)
+
+// checkTimeout 一次审核的超时时间，超时的提供者视为未命中
+// 回复按句审核，使用LLM提供者时每句回复的播报都可能因此延迟
+const checkTimeout = 3 * time.Second
+
+// Result 一次审核的结果
+type Result struct {
+	Flagged  bool
+	Category string   // 命中的类别
+	Reason   string   // 命中的原因，用于日志
+	Matches  []string // 命中的文本片段，改写时替换这些片段
+}
+
+// Provider 内容审核提供者
+type Provider interface {
+	// Name 提供者名称，在审核配置中引用
+	Name() string
+
+	// Check 审核一段文本，未命中时返回Flagged为false的结果
+	Check(ctx context.Context, text string) (*Result, error)
+}
+
+// Decision 审核后的处理结果
+type Decision struct {
+	Action Action  // 未命中时为空
+	Text   string  // 处理后的文本，拦截时为空
+	Result *Result // 命中的审核结果，未命中时为nil
+}
+
+// Flagged 是否命中审核
+func (d Decision) Flagged() bool {
+	return d.Result != nil
+}
+
+// Profile 审核配置，不同用户可以使用不同的配置，如儿童模式
+type Profile struct {
+	Name         string
+	Providers    []Provider
+	InputAction  Action
+	OutputAction Action
+	Reply        string   // reply动作使用的固定回复
+	Categories   []string // 只处理这些类别，为空时处理全部类别
+}
+
+// ModerateInput 审核用户输入
+func (p *Profile) ModerateInput(ctx context.Context, text string) Decision {
+	return p.moderate(ctx, text, p.InputAction)
+}
+
+// ModerateOutput 审核模型回复
+func (p *Profile) ModerateOutput(ctx context.Context, text string) Decision {
+	return p.moderate(ctx, text, p.OutputAction)
+}
+
+// moderate 依次使用各提供者审核，第一个命中的结果决定处理方式
+// 提供者出错时视为未命中，审核失败不影响正常对话
+func (p *Profile) moderate(ctx context.Context, text string, action Action) Decision {
+	decision := Decision{Text: text}
+	if strings.TrimSpace(text) == "" {
+		return decision
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	for _, provider := range p.Providers {
+		result, err := provider.Check(ctx, text)
+		if err != nil || result == nil || !result.Flagged || !p.handles(result.Category) {
+			continue
+		}
+		decision.Result = result
+		decision.Action = action
+		switch action {
+		case ActionBlock:
+			decision.Text = ""
+		case ActionRewrite:
+			if len(result.Matches) > 0 {
+				decision.Text = mask(text, result.Matches)
+			} else {
+				decision.Action = ActionReply
+				decision.Text = p.Reply
+			}
+		case ActionReply:
+			decision.Text = p.Reply
+		}
+		return decision
+	}
+	return decision
+}
+
+// handles 是否处理该类别
+func (p *Profile) handles(category string) bool {
+	if len(p.Categories) == 0 {
+		return true
+	}
+	for _, c := range p.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// mask 将命中的片段替换为等长的*
+func mask(text string, matches []string) string {
+	for _, match := range matches {
+		if match == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, match, strings.Repeat("*", len([]rune(match))))
+	}
+	return text
+}
+
+// ParseAction 解析配置中的动作，为空时返回def
+func ParseAction(action string, def Action) (Action, error) {
+	switch Action(action) {
+	case "":
+		return def, nil
+	case ActionBlock, ActionRewrite, ActionReply, ActionLog:
+		return Action(action), nil
+	}
+	return "", fmt.Errorf("未知的审核动作: %s", action)
+}
+
+// Manager 内容审核管理器，所有连接共享
+type Manager struct {
+	profiles       map[string]*Profile
+	defaultProfile string
+	logger         *utils.Logger
+}
+
+// NewManager 创建内容审核管理器，defaultProfile必须存在
+func NewManager(profiles []*Profile, defaultProfile string, logger *utils.Logger) (*Manager, error) {
+	m := &Manager{
+		profiles:       make(map[string]*Profile, len(profiles)),
+		defaultProfile: defaultProfile,
+		logger:         logger,
+	}
+	for _, profile := range profiles {
+		m.profiles[profile.Name] = profile
+	}
+	if _, ok := m.profiles[defaultProfile]; !ok {
+		return nil, fmt.Errorf("默认审核配置不存在: %s", defaultProfile)
+	}
+	return m, nil
+}
+
+// Profile 获取指定的审核配置，不存在或为空时使用默认配置
+func (m *Manager) Profile(name string) *Profile {
+	if profile, ok := m.profiles[name]; ok {
+		return profile
+	}
+	if name != "" {
+		m.logger.Warn("审核配置 %s 不存在，使用默认配置 %s", name, m.defaultProfile)
+	}
+	return m.profiles[m.defaultProfile]
+}
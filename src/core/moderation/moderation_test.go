@@ -0,0 +1,216 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeywordProvider(t *testing.T) {
+	p, err := NewKeywordProvider([]KeywordRule{
+		{Category: "广告", Words: []string{"加微信", " VX ", ""}},
+		{Category: "隐私", Patterns: []string{`1[3-9]\d{9}`}},
+		{Category: "脏话", Words: []string{"笨蛋"}},
+	})
+	if err != nil {
+		t.Fatalf("NewKeywordProvider() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		category string
+		matches  []string
+	}{
+		{name: "未命中", input: "今天天气不错"},
+		{name: "关键词", input: "有事加微信聊", category: "广告", matches: []string{"加微信"}},
+		{name: "关键词不区分大小写并返回原文写法", input: "加我vx或者Vx", category: "广告", matches: []string{"vx", "Vx"}},
+		{name: "正则表达式", input: "我的电话是13800138000", category: "隐私", matches: []string{"13800138000"}},
+		{name: "按顺序返回第一个命中的类别", input: "笨蛋，加微信", category: "广告", matches: []string{"加微信"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := p.Check(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if result.Flagged != (tt.category != "") || result.Category != tt.category {
+				t.Errorf("Check(%q) = %+v, want category %q", tt.input, result, tt.category)
+			}
+			if !reflect.DeepEqual(result.Matches, tt.matches) {
+				t.Errorf("Check(%q).Matches = %q, want %q", tt.input, result.Matches, tt.matches)
+			}
+		})
+	}
+
+	if _, err := NewKeywordProvider([]KeywordRule{{Category: "无效", Patterns: []string{"("}}}); err == nil {
+		t.Error("NewKeywordProvider() 无效的正则表达式 error = nil")
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		matches  []string
+		expected string
+	}{
+		{name: "按字符数替换", input: "你这个笨蛋", matches: []string{"笨蛋"}, expected: "你这个**"},
+		{name: "替换全部出现", input: "bad and bad", matches: []string{"bad"}, expected: "*** and ***"},
+		{name: "多个片段", input: "加微信13800138000", matches: []string{"加微信", "13800138000"}, expected: "**************"},
+		{name: "忽略空片段", input: "正常", matches: []string{""}, expected: "正常"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := mask(tt.input, tt.matches); result != tt.expected {
+				t.Errorf("mask(%q, %q) = %q, want %q", tt.input, tt.matches, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Action
+		wantErr  bool
+	}{
+		{input: "", expected: ActionLog},
+		{input: "block", expected: ActionBlock},
+		{input: "rewrite", expected: ActionRewrite},
+		{input: "reply", expected: ActionReply},
+		{input: "log", expected: ActionLog},
+		{input: "drop", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseAction(tt.input, ActionLog)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAction(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseAction(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// stubProvider 返回固定结果的审核提供者
+type stubProvider struct {
+	result *Result
+	err    error
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) Check(ctx context.Context, text string) (*Result, error) {
+	return p.result, p.err
+}
+
+func TestProfileModerate(t *testing.T) {
+	withMatches := &stubProvider{result: &Result{Flagged: true, Category: "脏话", Matches: []string{"笨蛋"}}}
+	withoutMatches := &stubProvider{result: &Result{Flagged: true, Category: "暴力"}}
+	failing := &stubProvider{err: errors.New("审核服务不可用")}
+	clean := &stubProvider{result: &Result{}}
+
+	tests := []struct {
+		name       string
+		providers  []Provider
+		action     Action
+		categories []string
+		input      string
+		expected   Decision
+	}{
+		{
+			name:      "未命中原样返回",
+			providers: []Provider{clean},
+			action:    ActionBlock,
+			input:     "你好",
+			expected:  Decision{Text: "你好"},
+		},
+		{
+			name:      "空文本不审核",
+			providers: []Provider{withMatches},
+			action:    ActionBlock,
+			input:     "  ",
+			expected:  Decision{Text: "  "},
+		},
+		{
+			name:      "拦截",
+			providers: []Provider{withMatches},
+			action:    ActionBlock,
+			input:     "你这个笨蛋",
+			expected:  Decision{Action: ActionBlock, Result: withMatches.result},
+		},
+		{
+			name:      "改写命中的片段",
+			providers: []Provider{withMatches},
+			action:    ActionRewrite,
+			input:     "你这个笨蛋",
+			expected:  Decision{Action: ActionRewrite, Text: "你这个**", Result: withMatches.result},
+		},
+		{
+			name:      "无法定位片段时改写按固定回复处理",
+			providers: []Provider{withoutMatches},
+			action:    ActionRewrite,
+			input:     "某些内容",
+			expected:  Decision{Action: ActionReply, Text: "换个话题吧", Result: withoutMatches.result},
+		},
+		{
+			name:      "固定回复",
+			providers: []Provider{withMatches},
+			action:    ActionReply,
+			input:     "你这个笨蛋",
+			expected:  Decision{Action: ActionReply, Text: "换个话题吧", Result: withMatches.result},
+		},
+		{
+			name:      "只记录日志",
+			providers: []Provider{withMatches},
+			action:    ActionLog,
+			input:     "你这个笨蛋",
+			expected:  Decision{Action: ActionLog, Text: "你这个笨蛋", Result: withMatches.result},
+		},
+		{
+			name:      "提供者出错时使用下一个提供者",
+			providers: []Provider{failing, withMatches},
+			action:    ActionBlock,
+			input:     "你这个笨蛋",
+			expected:  Decision{Action: ActionBlock, Result: withMatches.result},
+		},
+		{
+			name:       "不处理的类别视为未命中",
+			providers:  []Provider{withMatches},
+			action:     ActionBlock,
+			categories: []string{"暴力"},
+			input:      "你这个笨蛋",
+			expected:   Decision{Text: "你这个笨蛋"},
+		},
+		{
+			name:       "处理的类别",
+			providers:  []Provider{withoutMatches, withMatches},
+			action:     ActionBlock,
+			categories: []string{"脏话"},
+			input:      "你这个笨蛋",
+			expected:   Decision{Action: ActionBlock, Result: withMatches.result},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{
+				Providers:    tt.providers,
+				InputAction:  tt.action,
+				OutputAction: ActionLog,
+				Reply:        "换个话题吧",
+				Categories:   tt.categories,
+			}
+			if result := p.ModerateInput(context.Background(), tt.input); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ModerateInput(%q) = %+v, want %+v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
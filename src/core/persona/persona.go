@@ -0,0 +1,292 @@
+// Package persona 角色管理，角色包含系统提示词、各TTS类型的默认音色、问候语、可用工具和LLM参数
+// 全局角色对所有用户可用，用户角色只对创建者可用，名称相同时用户角色优先
+package persona
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const storeTimeout = 5 * time.Second
+
+var (
+	// ErrNotFound 角色不存在或不属于当前用户
+	ErrNotFound = errors.New("角色不存在")
+	// ErrDuplicateName 角色名称或别名与已有角色重复
+	ErrDuplicateName = errors.New("角色名称或别名已存在")
+	// ErrInvalid 角色缺少名称或提示词
+	ErrInvalid = errors.New("角色信息不完整")
+)
+
+// legacyVoices 旧版本中为配置角色写死的edge音色，导入配置角色时保留
+var legacyVoices = map[string]map[string]string{
+	"陕西女友":  {"edge": "zh-CN-shaanxi-XiaoniNeural"},
+	"英语老师":  {"edge": "zh-CN-XiaoyiNeural"},
+	"好奇小男孩": {"edge": "zh-CN-YunxiNeural"},
+}
+
+// Persona 角色
+type Persona struct {
+	ID       uint              `json:"id"`
+	UserID   string            `json:"user_id"` // 为空表示全局角色
+	Name     string            `json:"name"`
+	Aliases  []string          `json:"aliases,omitempty"`
+	Prompt   string            `json:"prompt"`
+	Voices   map[string]string `json:"voices,omitempty"` // TTS类型到音色的映射
+	Greeting string            `json:"greeting,omitempty"`
+	Tools    []string          `json:"tools,omitempty"` // 可用的工具，为空时不限制
+	LLM      llm.Params        `json:"llm"`
+	IsActive bool              `json:"is_active"`
+}
+
+// Global 是否为全局角色
+func (p *Persona) Global() bool {
+	return p.UserID == ""
+}
+
+// Matches 名称或别名是否与name相同，忽略大小写和首尾空白
+func (p *Persona) Matches(name string) bool {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(p.Name, name) {
+		return true
+	}
+	for _, alias := range p.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Voice 返回角色在指定TTS类型下的音色，未设置时返回空字符串
+func (p *Persona) Voice(ttsType string) string {
+	return p.Voices[ttsType]
+}
+
+// AllowsTool 角色是否可以使用指定的工具
+func (p *Persona) AllowsTool(name string) bool {
+	if len(p.Tools) == 0 {
+		return true
+	}
+	for _, tool := range p.Tools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Store 角色存储接口
+type Store interface {
+	// List 列出用户创建的角色，userID为空时列出全局角色
+	List(ctx context.Context, userID string) ([]Persona, error)
+
+	// Get 获取角色，不存在时返回ErrNotFound
+	Get(ctx context.Context, id uint) (*Persona, error)
+
+	// Create 创建角色，成功后设置ID
+	Create(ctx context.Context, p *Persona) error
+
+	// Update 更新角色
+	Update(ctx context.Context, p *Persona) error
+
+	// Delete 删除角色
+	Delete(ctx context.Context, id uint) error
+}
+
+// Manager 角色管理器，所有连接共享
+type Manager struct {
+	store  Store
+	logger *utils.Logger
+}
+
+// NewManager 创建角色管理器
+func NewManager(store Store, logger *utils.Logger) *Manager {
+	return &Manager{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// List 列出用户创建的角色，userID为空时列出全局角色
+func (m *Manager) List(ctx context.Context, userID string) ([]Persona, error) {
+	return m.store.List(ctx, userID)
+}
+
+// Available 列出用户可以切换的全部角色，同名时用户角色覆盖全局角色，结果按名称排序
+func (m *Manager) Available(ctx context.Context, userID string) ([]Persona, error) {
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	global, err := m.store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var own []Persona
+	if userID != "" {
+		if own, err = m.store.List(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	var personas []Persona
+	for _, p := range own {
+		if p.IsActive {
+			personas = append(personas, p)
+		}
+	}
+	for _, p := range global {
+		if p.IsActive && !conflicts(personas, &p) {
+			personas = append(personas, p)
+		}
+	}
+	sort.SliceStable(personas, func(i, j int) bool { return personas[i].Name < personas[j].Name })
+	return personas, nil
+}
+
+// Find 按名称或别名查找用户可以切换的角色，不存在时返回ErrNotFound
+func (m *Manager) Find(ctx context.Context, userID, name string) (*Persona, error) {
+	personas, err := m.Available(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range personas {
+		if personas[i].Matches(name) {
+			return &personas[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Get 获取用户创建的角色，userID为空时获取全局角色
+func (m *Manager) Get(ctx context.Context, userID string, id uint) (*Persona, error) {
+	p, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
+// Create 创建角色，名称或别名不能与同一用户（或全局）的其他角色重复
+func (m *Manager) Create(ctx context.Context, p *Persona) error {
+	if err := m.validate(ctx, p); err != nil {
+		return err
+	}
+	return m.store.Create(ctx, p)
+}
+
+// Update 更新角色，p.ID和p.UserID须与已有角色一致
+func (m *Manager) Update(ctx context.Context, p *Persona) error {
+	if _, err := m.Get(ctx, p.UserID, p.ID); err != nil {
+		return err
+	}
+	if err := m.validate(ctx, p); err != nil {
+		return err
+	}
+	return m.store.Update(ctx, p)
+}
+
+// Delete 删除用户创建的角色，userID为空时删除全局角色
+func (m *Manager) Delete(ctx context.Context, userID string, id uint) error {
+	if _, err := m.Get(ctx, userID, id); err != nil {
+		return err
+	}
+	return m.store.Delete(ctx, id)
+}
+
+// validate 整理并校验角色的名称和别名
+func (m *Manager) validate(ctx context.Context, p *Persona) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalid)
+	}
+	if strings.TrimSpace(p.Prompt) == "" {
+		return fmt.Errorf("%w: 提示词不能为空", ErrInvalid)
+	}
+	aliases := p.Aliases[:0]
+	for _, alias := range p.Aliases {
+		if alias = strings.TrimSpace(alias); alias != "" && !strings.EqualFold(alias, p.Name) {
+			aliases = append(aliases, alias)
+		}
+	}
+	p.Aliases = aliases
+
+	existing, err := m.store.List(ctx, p.UserID)
+	if err != nil {
+		return err
+	}
+	for i := range existing {
+		if existing[i].ID != p.ID && conflicts(existing[i:i+1], p) {
+			return fmt.Errorf("%w: %s", ErrDuplicateName, existing[i].Name)
+		}
+	}
+	return nil
+}
+
+// conflicts p的名称或别名是否与personas中的某个角色相同
+func conflicts(personas []Persona, p *Persona) bool {
+	for i := range personas {
+		if personas[i].Matches(p.Name) {
+			return true
+		}
+		for _, alias := range p.Aliases {
+			if personas[i].Matches(alias) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ImportRoles 没有全局角色时，将配置中name@prompt格式的角色导入为全局角色
+func (m *Manager) ImportRoles(ctx context.Context, roles []string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	global, err := m.store.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	if len(global) > 0 {
+		return nil
+	}
+	imported := 0
+	for _, role := range roles {
+		items := strings.SplitN(role, "@", 2)
+		if len(items) != 2 {
+			m.logger.Warn("忽略格式错误的角色配置: %s", role)
+			continue
+		}
+		p := &Persona{
+			Name:     strings.TrimSpace(items[0]),
+			Prompt:   items[1],
+			Voices:   legacyVoices[strings.TrimSpace(items[0])],
+			IsActive: true,
+		}
+		if err := m.Create(ctx, p); err != nil {
+			return fmt.Errorf("导入角色%s失败: %w", p.Name, err)
+		}
+		imported++
+	}
+	m.logger.Info("已将配置中的%d个角色导入为全局角色", imported)
+	return nil
+}
+
+// Names 返回角色名称列表
+func Names(personas []Persona) []string {
+	names := make([]string, len(personas))
+	for i, p := range personas {
+		names[i] = p.Name
+	}
+	return names
+}
@@ -0,0 +1,163 @@
+package persona
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// memoryStore 测试用的内存角色存储
+type memoryStore struct {
+	personas []Persona
+}
+
+func (s *memoryStore) List(ctx context.Context, userID string) ([]Persona, error) {
+	var personas []Persona
+	for _, p := range s.personas {
+		if p.UserID == userID {
+			personas = append(personas, p)
+		}
+	}
+	return personas, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id uint) (*Persona, error) {
+	for i := range s.personas {
+		if s.personas[i].ID == id {
+			p := s.personas[i]
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) Create(ctx context.Context, p *Persona) error {
+	p.ID = uint(len(s.personas) + 1)
+	s.personas = append(s.personas, *p)
+	return nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, p *Persona) error {
+	for i := range s.personas {
+		if s.personas[i].ID == p.ID {
+			s.personas[i] = *p
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id uint) error {
+	for i := range s.personas {
+		if s.personas[i].ID == id {
+			s.personas = append(s.personas[:i], s.personas[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func newTestManager() *Manager {
+	return NewManager(&memoryStore{personas: []Persona{
+		{ID: 1, Name: "英语老师", Aliases: []string{"teacher"}, Prompt: "全局", IsActive: true},
+		{ID: 2, Name: "好奇小男孩", Prompt: "全局", IsActive: true},
+		{ID: 3, Name: "陕西女友", Prompt: "已停用", IsActive: false},
+		{ID: 4, UserID: "u1", Name: "英语老师", Aliases: []string{"老师"}, Prompt: "用户", IsActive: true},
+		{ID: 5, UserID: "u1", Name: "助手", Prompt: "已停用", IsActive: false},
+		{ID: 6, UserID: "u2", Name: "小秘书", Prompt: "其他用户", IsActive: true},
+	}}, nil)
+}
+
+func TestAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		expected []uint
+	}{
+		{name: "未登录只有启用的全局角色", userID: "", expected: []uint{2, 1}},
+		{name: "同名时用户角色覆盖全局角色", userID: "u1", expected: []uint{2, 4}},
+		{name: "不包含其他用户的角色", userID: "u3", expected: []uint{2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			personas, err := newTestManager().Available(context.Background(), tt.userID)
+			if err != nil {
+				t.Fatalf("Available() error = %v", err)
+			}
+			var ids []uint
+			for _, p := range personas {
+				ids = append(ids, p.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("Available(%q) = %v, want %v", tt.userID, ids, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		query    string
+		expected uint // 为0表示找不到
+	}{
+		{name: "按名称查找", userID: "", query: "好奇小男孩", expected: 2},
+		{name: "按别名查找忽略大小写和空白", userID: "", query: " Teacher ", expected: 1},
+		{name: "用户角色优先", userID: "u1", query: "英语老师", expected: 4},
+		{name: "被覆盖的全局角色别名不可用", userID: "u1", query: "teacher", expected: 0},
+		{name: "停用的角色不可用", userID: "", query: "陕西女友", expected: 0},
+		{name: "其他用户的角色不可用", userID: "u1", query: "小秘书", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newTestManager().Find(context.Background(), tt.userID, tt.query)
+			if tt.expected == 0 {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Find(%q, %q) = %+v, %v, want ErrNotFound", tt.userID, tt.query, p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find(%q, %q) error = %v", tt.userID, tt.query, err)
+			}
+			if p.ID != tt.expected {
+				t.Errorf("Find(%q, %q).ID = %d, want %d", tt.userID, tt.query, p.ID, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		id     uint
+		found  bool
+	}{
+		{name: "获取自己的角色", userID: "u1", id: 4, found: true},
+		{name: "获取停用的角色", userID: "u1", id: 5, found: true},
+		{name: "获取全局角色", userID: "", id: 1, found: true},
+		{name: "用户不能通过Get获取全局角色", userID: "u1", id: 1, found: false},
+		{name: "不能获取其他用户的角色", userID: "u1", id: 6, found: false},
+		{name: "角色不存在", userID: "u1", id: 99, found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newTestManager().Get(context.Background(), tt.userID, tt.id)
+			if !tt.found {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get(%q, %d) = %+v, %v, want ErrNotFound", tt.userID, tt.id, p, err)
+				}
+				return
+			}
+			if err != nil || p.ID != tt.id {
+				t.Errorf("Get(%q, %d) = %+v, %v", tt.userID, tt.id, p, err)
+			}
+		})
+	}
+}
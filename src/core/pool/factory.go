@@ -108,6 +108,9 @@ func NewLLMFactory(llmType string, config *configs.Config, logger *utils.Logger)
 				MaxTokens:   llmCfg.MaxTokens,
 				TopP:        llmCfg.TopP,
 				Extra:       llmCfg.Extra,
+
+				ContextTokens: llmCfg.ContextTokens,
+				Multimodal:    llmCfg.Multimodal,
 			},
 			logger: logger,
 		}
@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"angrymiao-ai-server/src/core/metrics"
 	"angrymiao-ai-server/src/core/utils"
 	"context"
 	"fmt"
@@ -79,6 +80,7 @@ func NewResourcePool(
 
 // Get 获取资源
 func (p *ResourcePool) Get() (interface{}, error) {
+	defer metrics.PoolWait.ObserveSince(time.Now(), p.poolName)
 	select {
 	case resource := <-p.pool:
 		p.mutex.Lock()
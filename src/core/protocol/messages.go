@@ -0,0 +1,128 @@
+package protocol
+
+// 字段标签说明：
+//   json   字段名，没有omitempty的字段为必填字段，必填的指针和对象不能为null
+//   proto  校验规则，逗号分隔：const=值、enum=值1|值2、nonempty（字符串或对象不能为空）、
+//          default=值（字符串缺省时填入的值，有default的字段为选填）
+//   desc   字段说明，写入JSON Schema
+
+// AudioParams 音频参数
+type AudioParams struct {
+	Format        string `json:"format" proto:"enum=opus|pcm,default=opus" desc:"音频编码格式"`
+	SampleRate    int    `json:"sample_rate,omitempty" desc:"采样率(Hz)"`
+	Channels      int    `json:"channels,omitempty" desc:"声道数"`
+	FrameDuration int    `json:"frame_duration,omitempty" desc:"每帧时长(ms)"`
+}
+
+// ImageData 图片数据，URL和Data至少提供一个
+type ImageData struct {
+	URL    string `json:"url,omitempty" desc:"图片地址"`
+	Data   string `json:"data,omitempty" desc:"Base64编码的图片数据"`
+	Format string `json:"format,omitempty" desc:"图片格式，如jpeg、png"`
+}
+
+// HelloMessage 客户端握手消息，version为客户端支持的最高协议版本
+type HelloMessage struct {
+	Type        string       `json:"type" proto:"const=hello"`
+	Version     int          `json:"version,omitempty" desc:"客户端支持的最高协议版本，缺省为1"`
+	Transport   string       `json:"transport,omitempty" desc:"传输层类型"`
+	AudioParams *AudioParams `json:"audio_params,omitempty" desc:"客户端上传音频的参数"`
+}
+
+// ListenMessage 拾音状态消息
+type ListenMessage struct {
+	Type      string `json:"type" proto:"const=listen"`
+	SessionID string `json:"session_id,omitempty"`
+	State     string `json:"state" proto:"enum=start|stop|detect" desc:"start开始拾音，stop停止拾音，detect检测到唤醒词或直接提交文本"`
+	Mode      string `json:"mode,omitempty" proto:"enum=auto|manual|realtime" desc:"拾音模式"`
+	Text      string `json:"text,omitempty" desc:"state为detect时提交的文本"`
+}
+
+// AbortMessage 客户端打断消息
+type AbortMessage struct {
+	Type      string `json:"type" proto:"const=abort"`
+	SessionID string `json:"session_id,omitempty"`
+	Reason    string `json:"reason,omitempty" desc:"打断原因"`
+}
+
+// ChatMessage 客户端文本对话消息
+type ChatMessage struct {
+	Type      string `json:"type" proto:"const=chat"`
+	SessionID string `json:"session_id,omitempty"`
+	Text      string `json:"text" proto:"nonempty" desc:"用户输入的文本"`
+}
+
+// ImageMessage 客户端图片对话消息
+type ImageMessage struct {
+	Type      string     `json:"type" proto:"const=image"`
+	SessionID string     `json:"session_id,omitempty"`
+	Text      string     `json:"text,omitempty" desc:"关于图片的提问，缺省为描述图片"`
+	ImageData *ImageData `json:"image_data" desc:"图片数据"`
+}
+
+// VisionMessage 客户端视觉指令消息
+type VisionMessage struct {
+	Type      string `json:"type" proto:"const=vision"`
+	SessionID string `json:"session_id,omitempty"`
+	Cmd       string `json:"cmd" proto:"enum=gen_pic|gen_video|read_img" desc:"视觉指令"`
+}
+
+// MCPMessage 设备端MCP消息，双向使用，payload为JSON-RPC 2.0消息
+type MCPMessage struct {
+	Type      string                 `json:"type" proto:"const=mcp"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Payload   map[string]interface{} `json:"payload" proto:"nonempty" desc:"JSON-RPC 2.0消息"`
+}
+
+// ServerHelloMessage 服务端握手回复，version为协商后的协议版本
+type ServerHelloMessage struct {
+	Type        string      `json:"type" proto:"const=hello"`
+	Version     int         `json:"version" desc:"协商后的协议版本"`
+	Transport   string      `json:"transport" desc:"传输层类型"`
+	SessionID   string      `json:"session_id"`
+	AudioParams AudioParams `json:"audio_params" desc:"服务端下发音频的参数"`
+}
+
+// TTSMessage 服务端语音播放状态消息
+type TTSMessage struct {
+	Type       string `json:"type" proto:"const=tts"`
+	State      string `json:"state" proto:"enum=start|sentence_start|sentence_end|stop" desc:"播放状态"`
+	SessionID  string `json:"session_id"`
+	Text       string `json:"text" desc:"sentence_start和sentence_end时为当前句子的文本"`
+	Index      int    `json:"index" desc:"句子序号"`
+	AudioCodec string `json:"audio_codec" proto:"enum=opus|pcm" desc:"下发音频的编码格式"`
+}
+
+// STTMessage 服务端识别结果消息
+type STTMessage struct {
+	Type      string `json:"type" proto:"const=stt"`
+	Text      string `json:"text" desc:"识别出的用户输入"`
+	SessionID string `json:"session_id"`
+}
+
+// LLMMessage 服务端情绪消息
+type LLMMessage struct {
+	Type      string `json:"type" proto:"const=llm"`
+	Text      string `json:"text" desc:"情绪对应的表情"`
+	Emotion   string `json:"emotion" desc:"情绪名称"`
+	Index     int    `json:"index,omitempty" desc:"对应句子的序号，在该句的tts sentence_start之前下发"`
+	SessionID string `json:"session_id"`
+}
+
+// SilenceMessage 服务端静音状态消息
+type SilenceMessage struct {
+	Type      string `json:"type" proto:"const=silence"`
+	State     string `json:"state" desc:"idle表示累计了一次静音，其余取值为达到上限后执行的动作"`
+	Count     int    `json:"count" desc:"连续静音次数"`
+	MaxCount  int    `json:"max_count" desc:"静音次数上限"`
+	SessionID string `json:"session_id"`
+}
+
+// ErrorMessage 服务端错误回复，协议版本2起下发
+type ErrorMessage struct {
+	Type        string `json:"type" proto:"const=error"`
+	Code        string `json:"code" proto:"enum=invalid_json|unknown_type|invalid_message|unsupported_version" desc:"错误码"`
+	Message     string `json:"message" desc:"错误说明"`
+	MessageType string `json:"message_type,omitempty" desc:"出错的客户端消息类型"`
+	SessionID   string `json:"session_id"`
+}
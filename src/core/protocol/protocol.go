@@ -0,0 +1,140 @@
+// Package protocol 定义设备与服务端之间的文本消息协议
+// 每种消息对应一个结构体，字段标签同时用于解析校验和生成JSON Schema，
+// 协议版本在hello消息中协商：客户端上报支持的最高版本，服务端回复双方都支持的版本
+package protocol
+
+//go:generate go run ../../cmd/protocol-schema -out schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// MinVersion 服务端支持的最低协议版本
+	MinVersion = 1
+	// CurrentVersion 服务端支持的最高协议版本
+	CurrentVersion = 2
+	// ErrorRepliesVersion 开始下发error消息的协议版本
+	ErrorRepliesVersion = 2
+)
+
+// 错误码
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeUnknownType        = "unknown_type"
+	CodeInvalidMessage     = "invalid_message"
+	CodeUnsupportedVersion = "unsupported_version"
+)
+
+// Error 协议错误，会以error消息回复给客户端
+type Error struct {
+	Code        string
+	Message     string
+	MessageType string
+}
+
+func (e *Error) Error() string {
+	if e.MessageType != "" {
+		return fmt.Sprintf("%s消息无效(%s): %s", e.MessageType, e.Code, e.Message)
+	}
+	return fmt.Sprintf("消息无效(%s): %s", e.Code, e.Message)
+}
+
+// Invalid 创建消息内容不合法的错误
+func Invalid(messageType string, format string, args ...interface{}) *Error {
+	return &Error{Code: CodeInvalidMessage, Message: fmt.Sprintf(format, args...), MessageType: messageType}
+}
+
+// Reply 转换为回复给客户端的error消息
+func (e *Error) Reply(sessionID string) *ErrorMessage {
+	return &ErrorMessage{
+		Type:        "error",
+		Code:        e.Code,
+		Message:     e.Message,
+		MessageType: e.MessageType,
+		SessionID:   sessionID,
+	}
+}
+
+// Negotiate 按客户端上报的最高版本协商协议版本，未上报时按版本1处理
+func Negotiate(clientVersion int) (int, error) {
+	if clientVersion == 0 {
+		clientVersion = MinVersion
+	}
+	if clientVersion < MinVersion {
+		return 0, &Error{
+			Code:        CodeUnsupportedVersion,
+			Message:     fmt.Sprintf("不支持的协议版本 %d，服务端支持 %d-%d", clientVersion, MinVersion, CurrentVersion),
+			MessageType: "hello",
+		}
+	}
+	return min(clientVersion, CurrentVersion), nil
+}
+
+// spec 一种消息的说明
+type spec struct {
+	Type        string
+	Description string
+	New         func() interface{}
+}
+
+// inboundSpecs 客户端上行消息
+var inboundSpecs = []spec{
+	{"hello", "握手，上报协议版本和音频参数", func() interface{} { return &HelloMessage{} }},
+	{"listen", "拾音状态变化", func() interface{} { return &ListenMessage{} }},
+	{"abort", "打断服务端播放", func() interface{} { return &AbortMessage{} }},
+	{"chat", "文本对话", func() interface{} { return &ChatMessage{} }},
+	{"image", "图片对话", func() interface{} { return &ImageMessage{} }},
+	{"vision", "视觉指令", func() interface{} { return &VisionMessage{} }},
+	{"mcp", "设备端MCP的JSON-RPC消息", func() interface{} { return &MCPMessage{} }},
+}
+
+// outboundSpecs 服务端下行消息
+var outboundSpecs = []spec{
+	{"hello", "握手回复，下发协商后的协议版本和音频参数", func() interface{} { return &ServerHelloMessage{} }},
+	{"tts", "语音播放状态", func() interface{} { return &TTSMessage{} }},
+	{"stt", "语音识别结果", func() interface{} { return &STTMessage{} }},
+	{"llm", "回复的情绪", func() interface{} { return &LLMMessage{} }},
+	{"silence", "静音状态", func() interface{} { return &SilenceMessage{} }},
+	{"mcp", "服务端调用设备端MCP工具的JSON-RPC消息", func() interface{} { return &MCPMessage{} }},
+	{"error", "客户端消息无效时的错误回复（协议版本2起）", func() interface{} { return &ErrorMessage{} }},
+}
+
+// ErrPlainText 消息是纯文本或数字而不是JSON对象，旧客户端用作心跳，由调用方原样回显
+var ErrPlainText = &Error{Code: CodeInvalidJSON, Message: "消息不是JSON对象"}
+
+// Parse 解析并校验客户端文本消息，返回对应消息类型的结构体指针
+// 纯文本或数字消息返回ErrPlainText，其余错误均为*Error
+func Parse(data []byte) (interface{}, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, ErrPlainText
+	}
+	if _, ok := raw.(float64); ok {
+		return nil, ErrPlainText
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, &Error{Code: CodeInvalidJSON, Message: "消息必须是JSON对象"}
+	}
+
+	msgType, ok := fields["type"].(string)
+	if !ok || msgType == "" {
+		return nil, &Error{Code: CodeInvalidMessage, Message: "缺少type字段"}
+	}
+	for _, s := range inboundSpecs {
+		if s.Type != msgType {
+			continue
+		}
+		msg := s.New()
+		if err := json.Unmarshal(data, msg); err != nil {
+			return nil, Invalid(msgType, "字段类型错误: %v", err)
+		}
+		if err := validate(msg); err != nil {
+			return nil, Invalid(msgType, "%v", err)
+		}
+		return msg, nil
+	}
+	return nil, &Error{Code: CodeUnknownType, Message: "未知的消息类型: " + msgType, MessageType: msgType}
+}
@@ -0,0 +1,170 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{
+			name:     "hello使用默认音频格式",
+			input:    `{"type":"hello","version":2,"audio_params":{"sample_rate":16000}}`,
+			expected: &HelloMessage{Type: "hello", Version: 2, AudioParams: &AudioParams{Format: "opus", SampleRate: 16000}},
+		},
+		{
+			name:     "hello指定音频格式",
+			input:    `{"type":"hello","audio_params":{"format":"pcm"}}`,
+			expected: &HelloMessage{Type: "hello", AudioParams: &AudioParams{Format: "pcm"}},
+		},
+		{
+			name:     "hello不带音频参数",
+			input:    `{"type":"hello"}`,
+			expected: &HelloMessage{Type: "hello"},
+		},
+		{
+			name:     "listen",
+			input:    `{"type":"listen","state":"start","mode":"auto"}`,
+			expected: &ListenMessage{Type: "listen", State: "start", Mode: "auto"},
+		},
+		{
+			name:     "chat",
+			input:    `{"type":"chat","text":"你好"}`,
+			expected: &ChatMessage{Type: "chat", Text: "你好"},
+		},
+		{
+			name:     "image",
+			input:    `{"type":"image","image_data":{"url":"http://example.com/a.png"}}`,
+			expected: &ImageMessage{Type: "image", ImageData: &ImageData{URL: "http://example.com/a.png"}},
+		},
+		{
+			name:     "mcp",
+			input:    `{"type":"mcp","payload":{"jsonrpc":"2.0"}}`,
+			expected: &MCPMessage{Type: "mcp", Payload: map[string]interface{}{"jsonrpc": "2.0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse(%s) error = %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Parse(%s) = %+v, want %+v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string // 错误码
+	}{
+		{name: "纯文本", input: `ping`, expected: CodeInvalidJSON},
+		{name: "数字", input: `123`, expected: CodeInvalidJSON},
+		{name: "JSON数组", input: `[1,2]`, expected: CodeInvalidJSON},
+		{name: "JSON字符串", input: `"hello"`, expected: CodeInvalidJSON},
+		{name: "截断的JSON", input: `{"type":"chat","text":"你`, expected: CodeInvalidJSON},
+		{name: "缺少type", input: `{"text":"你好"}`, expected: CodeInvalidMessage},
+		{name: "type不是字符串", input: `{"type":1}`, expected: CodeInvalidMessage},
+		{name: "未知type", input: `{"type":"foo"}`, expected: CodeUnknownType},
+		{name: "字段类型错误", input: `{"type":"hello","version":"2"}`, expected: CodeInvalidMessage},
+		{name: "缺少必填字段", input: `{"type":"listen"}`, expected: CodeInvalidMessage},
+		{name: "枚举值无效", input: `{"type":"listen","state":"pause"}`, expected: CodeInvalidMessage},
+		{name: "可选枚举值无效", input: `{"type":"listen","state":"start","mode":"push"}`, expected: CodeInvalidMessage},
+		{name: "嵌套枚举值无效", input: `{"type":"hello","audio_params":{"format":"mp3"}}`, expected: CodeInvalidMessage},
+		{name: "必填字符串为空", input: `{"type":"chat","text":""}`, expected: CodeInvalidMessage},
+		{name: "必填对象为null", input: `{"type":"image","image_data":null}`, expected: CodeInvalidMessage},
+		{name: "缺少必填对象", input: `{"type":"mcp"}`, expected: CodeInvalidMessage},
+		{name: "必填对象为空", input: `{"type":"mcp","payload":{}}`, expected: CodeInvalidMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input))
+			var protoErr *Error
+			if !errors.As(err, &protoErr) {
+				t.Fatalf("Parse(%s) error = %v, want *Error", tt.input, err)
+			}
+			if protoErr.Code != tt.expected {
+				t.Errorf("Parse(%s) code = %s, want %s", tt.input, protoErr.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int
+		expected int
+	}{
+		{name: "未上报版本", input: 0, expected: MinVersion},
+		{name: "最低版本", input: MinVersion, expected: MinVersion},
+		{name: "当前版本", input: CurrentVersion, expected: CurrentVersion},
+		{name: "更高版本降级", input: CurrentVersion + 1, expected: CurrentVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Negotiate(tt.input)
+			if err != nil || result != tt.expected {
+				t.Errorf("Negotiate(%d) = %d, %v, want %d", tt.input, result, err, tt.expected)
+			}
+		})
+	}
+
+	if _, err := Negotiate(-1); err == nil {
+		t.Error("Negotiate(-1)应返回错误")
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	schemas, err := Schemas()
+	if err != nil {
+		t.Fatalf("Schemas() error = %v", err)
+	}
+	if len(schemas) != len(inboundSpecs)+len(outboundSpecs) {
+		t.Errorf("生成了%d个Schema, want %d", len(schemas), len(inboundSpecs)+len(outboundSpecs))
+	}
+
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			AudioParams struct {
+				Required   []string `json:"required"`
+				Properties struct {
+					Format struct {
+						Default string   `json:"default"`
+						Enum    []string `json:"enum"`
+					} `json:"format"`
+				} `json:"properties"`
+			} `json:"audio_params"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(schemas["client.hello.schema.json"], &schema); err != nil {
+		t.Fatalf("解析hello的Schema失败: %v", err)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"type"}) {
+		t.Errorf("hello的必填字段 = %v, want [type]", schema.Required)
+	}
+	audioParams := schema.Properties.AudioParams
+	if len(audioParams.Required) != 0 {
+		t.Errorf("audio_params的必填字段 = %v, want []", audioParams.Required)
+	}
+	if audioParams.Properties.Format.Default != "opus" {
+		t.Errorf("format的默认值 = %q, want opus", audioParams.Properties.Format.Default)
+	}
+	if !reflect.DeepEqual(audioParams.Properties.Format.Enum, []string{"opus", "pcm"}) {
+		t.Errorf("format的可选值 = %v", audioParams.Properties.Format.Enum)
+	}
+}
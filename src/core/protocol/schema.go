@@ -0,0 +1,199 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// fieldRule 由字段标签解析出的规则
+type fieldRule struct {
+	name     string
+	required bool
+	constant string
+	enum     []string
+	nonempty bool
+	fallback string
+	desc     string
+}
+
+func parseField(field reflect.StructField) (fieldRule, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || !field.IsExported() {
+		return fieldRule{}, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	rule := fieldRule{
+		name:     name,
+		required: !strings.Contains(opts, "omitempty"),
+		desc:     field.Tag.Get("desc"),
+	}
+	for _, opt := range strings.Split(field.Tag.Get("proto"), ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "const":
+			rule.constant = value
+		case "enum":
+			rule.enum = strings.Split(value, "|")
+		case "nonempty":
+			rule.nonempty = true
+		case "default":
+			rule.fallback = value
+			rule.required = false
+		}
+	}
+	return rule, true
+}
+
+// validate 按字段标签校验消息：必填的指针和对象不能为null，const、enum和nonempty规则，缺省的字符串字段填入default值
+func validate(msg interface{}) error {
+	return validateStruct(reflect.Indirect(reflect.ValueOf(msg)), "")
+}
+
+func validateStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		rule, ok := parseField(t.Field(i))
+		if !ok {
+			continue
+		}
+		value := v.Field(i)
+		path := prefix + rule.name
+
+		switch value.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice:
+			if value.IsNil() {
+				if rule.required {
+					return fmt.Errorf("缺少%s字段", path)
+				}
+				continue
+			}
+			if rule.nonempty && value.Kind() != reflect.Pointer && value.Len() == 0 {
+				return fmt.Errorf("%s不能为空", path)
+			}
+			if value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Struct {
+				if err := validateStruct(value.Elem(), path+"."); err != nil {
+					return err
+				}
+			}
+		case reflect.Struct:
+			if err := validateStruct(value, path+"."); err != nil {
+				return err
+			}
+		case reflect.String:
+			s := value.String()
+			if s == "" && rule.fallback != "" {
+				s = rule.fallback
+				value.SetString(s)
+			}
+			if rule.constant != "" && s != rule.constant {
+				return fmt.Errorf("%s必须为%s", path, rule.constant)
+			}
+			if rule.nonempty && s == "" {
+				return fmt.Errorf("%s不能为空", path)
+			}
+			if len(rule.enum) > 0 && (s != "" || rule.required) && !slices.Contains(rule.enum, s) {
+				return fmt.Errorf("%s取值%q无效，可选值: %s", path, s, strings.Join(rule.enum, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// schemaFor 生成结构体的JSON Schema
+func schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+	default:
+		return map[string]interface{}{}
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		rule, ok := parseField(t.Field(i))
+		if !ok {
+			continue
+		}
+		prop := schemaFor(t.Field(i).Type)
+		if rule.desc != "" {
+			prop["description"] = rule.desc
+		}
+		if rule.constant != "" {
+			prop["const"] = rule.constant
+		}
+		if len(rule.enum) > 0 {
+			prop["enum"] = rule.enum
+		}
+		if rule.fallback != "" {
+			prop["default"] = rule.fallback
+		}
+		if rule.nonempty {
+			if prop["type"] == "object" {
+				prop["minProperties"] = 1
+			} else {
+				prop["minLength"] = 1
+			}
+		}
+		properties[rule.name] = prop
+		if rule.required {
+			required = append(required, rule.name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// Schemas 生成全部消息的JSON Schema，键为文件名：client.<type>.schema.json为上行消息，server.<type>.schema.json为下行消息
+func Schemas() (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	add := func(direction string, specs []spec) error {
+		for _, s := range specs {
+			schema := schemaFor(reflect.TypeOf(s.New()))
+			name := fmt.Sprintf("%s.%s.schema.json", direction, s.Type)
+			schema["$schema"] = schemaDraft
+			schema["$id"] = name
+			schema["title"] = s.Type
+			schema["description"] = s.Description
+			schema["x-protocol-version"] = CurrentVersion
+			data, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				return fmt.Errorf("生成%s的JSON Schema失败: %v", name, err)
+			}
+			result[name] = append(data, '\n')
+		}
+		return nil
+	}
+	if err := add("client", inboundSpecs); err != nil {
+		return nil, err
+	}
+	if err := add("server", outboundSpecs); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
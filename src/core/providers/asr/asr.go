@@ -66,6 +66,11 @@ func (p *BaseProvider) ResetSilenceCount() {
 	p.SilenceCount = 0
 }
 
+func (p *BaseProvider) IncreaseSilenceCount() int {
+	p.SilenceCount++
+	return p.SilenceCount
+}
+
 // SetListener 设置事件监听器
 func (p *BaseProvider) SetListener(listener providers.AsrEventListener) {
 	p.listener = listener
@@ -387,6 +387,22 @@ func (p *Provider) sendAudioData(data []byte, isLast bool) error {
 	return nil
 }
 
+// NotifySpeechEnd asks Deepgram to flush the pending audio and return a final transcript
+// after the server-side VAD detected the end of speech
+func (p *Provider) NotifySpeechEnd() error {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+
+	if !p.isStreaming || p.conn == nil {
+		return nil
+	}
+	p.logger.Debug("Server VAD detected end of speech, finalizing transcription")
+	if err := p.conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"Finalize"}`)); err != nil {
+		return fmt.Errorf("failed to send finalize message: %v", err)
+	}
+	return nil
+}
+
 // Reset resets the ASR state
 func (p *Provider) Reset() error {
 	p.connMutex.Lock()
@@ -341,7 +341,9 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 		}
 	}
 
-	// 检查是否有实际数据需要发送
+	// 检查是否有实际数据需要发送，持有锁避免与NotifySpeechEnd结束流式识别并发
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
 	if len(data) > 0 && p.isStreaming {
 		// 直接发送音频数据
 		if err := p.sendAudioData(data, false); err != nil {
@@ -474,48 +476,51 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	p.logger.Debug("[DEBUG] 流式识别初始化成功, connectID=%s, reqID=%s", p.connectID, p.reqID)
 	// 开启一个协程来处理响应，读取最后的结果，读取完成后关闭协程
 	go func() {
-		p.ReadMessage()
+		p.ReadMessage(conn)
 	}()
 	return nil
 }
 
-func (p *Provider) ReadMessage() {
+// ReadMessage 读取conn上的识别结果，直到连接关闭或收到最终结果
+// 说话结束后conn已不是当前连接，仍继续读取最终结果，不影响新开始的流式识别
+func (p *Provider) ReadMessage(conn *websocket.Conn) {
 	p.logger.Info("doubao流式识别协程已启动")
 	defer func() {
 		if r := recover(); r != nil {
 			p.logger.Error("流式识别协程发生错误: %v", r)
 		}
 		p.connMutex.Lock()
-		p.isStreaming = false // 标记流式识别结束
-		if p.conn != nil {
+		if p.conn == conn {
+			p.isStreaming = false // 标记流式识别结束
 			p.closeConnection()
+		} else {
+			_ = conn.Close()
 		}
 		p.connMutex.Unlock()
 		p.logger.Info("doubao流式识别协程已结束")
 	}()
 
 	for {
-		// 检查连接状态，避免在连接关闭后继续读取
+		// 检查连接状态，避免在重置后继续读取
 		p.connMutex.Lock()
-		if !p.isStreaming || p.conn == nil {
+		if p.conn == conn && !p.isStreaming {
 			p.connMutex.Unlock()
 			p.logger.Info("流式识别已结束或连接已关闭，退出读取循环")
 			return
 		}
-		conn := p.conn
 		p.connMutex.Unlock()
 
 		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 
 		_, response, err := conn.ReadMessage()
 		if err != nil {
-			p.setErrorAndStop(err)
+			p.setErrorAndStop(conn, err)
 			return
 		}
 
 		result, err := p.parseResponse(response)
 		if err != nil {
-			p.setErrorAndStop(fmt.Errorf("解析响应失败: %v", err))
+			p.setErrorAndStop(conn, fmt.Errorf("解析响应失败: %v", err))
 			return
 		}
 
@@ -523,7 +528,7 @@ func (p *Provider) ReadMessage() {
 			p.logger.Info("检测到code字段: 解析结果=%v", result)
 			codeValue := code.(uint32)
 			if codeValue != 0 {
-				p.setErrorAndStop(fmt.Errorf("ASR服务端错误: Code=%d", codeValue))
+				p.setErrorAndStop(conn, fmt.Errorf("ASR服务端错误: Code=%d", codeValue))
 				return
 			}
 		}
@@ -557,16 +562,23 @@ func (p *Provider) ReadMessage() {
 				}
 			} else if errorData, hasError := payloadMsg["error"]; hasError {
 				// 处理错误响应中的 error 字段
-				p.setErrorAndStop(fmt.Errorf("ASR响应错误: %v", errorData))
+				p.setErrorAndStop(conn, fmt.Errorf("ASR响应错误: %v", errorData))
 				return
 			}
 		}
 
 	}
 }
-func (p *Provider) setErrorAndStop(err error) {
+
+// setErrorAndStop 结束conn上的识别，conn仍是当前连接时记录错误并停止流式识别
+func (p *Provider) setErrorAndStop(conn *websocket.Conn, err error) {
 	p.connMutex.Lock()
 	defer p.connMutex.Unlock()
+	if p.conn != conn {
+		_ = conn.Close()
+		p.logger.Debug("已结束的流式识别连接退出: %v", err)
+		return
+	}
 	p.err = err
 	p.isStreaming = false
 	errMsg := err.Error()
@@ -642,6 +654,22 @@ func (p *Provider) sendAudioData(data []byte, isLast bool) error {
 	return nil
 }
 
+// NotifySpeechEnd 服务端VAD检测到说话结束，发送最后一包音频使服务端立即返回最终结果
+// 之后服务端不再接收这个流的音频：连接交给读取协程等待最终结果，下一段音频到来时开始新的流式识别
+func (p *Provider) NotifySpeechEnd() error {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+
+	if !p.isStreaming || p.conn == nil {
+		return nil
+	}
+	p.logger.Debug("服务端VAD检测到说话结束，结束本次流式识别")
+	err := p.sendAudioData(nil, true)
+	p.isStreaming = false
+	p.conn = nil
+	return err
+}
+
 // Reset 重置ASR状态
 func (p *Provider) Reset() error {
 	// 使用锁保护状态变更
@@ -4,61 +4,115 @@ import (
 	"angrymiao-ai-server/src/core/providers/asr"
 	"angrymiao-ai-server/src/core/utils"
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// doneMessage 通知sherpa服务端音频输入结束，服务端返回最终结果后关闭连接
+const doneMessage = "Done"
+
 type Provider struct {
 	*asr.BaseProvider
-	conn *websocket.Conn
+	addr   string
+	logger *utils.Logger
+
+	connMutex sync.Mutex
+	conn      *websocket.Conn
 }
 
 func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
 	base := asr.NewBaseProvider(config, deleteFile)
 
+	addr, _ := config.Data["addr"].(string)
 	provider := &Provider{
 		BaseProvider: base,
+		addr:         addr,
+		logger:       logger,
 	}
 	// 初始化音频处理
 	provider.InitAudioProcessing()
+	if err := provider.connect(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// connect 建立到sherpa服务端的连接并启动结果读取，调用方需持有connMutex或处于初始化阶段
+func (p *Provider) connect() error {
 	dialer := websocket.Dialer{
 		HandshakeTimeout: 10 * time.Second, // 设置握手超时
 	}
-	conn, _, err := dialer.DialContext(context.Background(), config.Data["addr"].(string), map[string][]string{})
+	conn, _, err := dialer.DialContext(context.Background(), p.addr, map[string][]string{})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	provider.conn = conn
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-			}
-		}()
-		for {
-			messageType, p, _ := conn.ReadMessage()
-			if messageType == websocket.TextMessage {
-				if listener := provider.GetListener(); listener != nil {
-					if finished := listener.OnAsrResult(string(p)); finished {
-
-					}
-				}
-			}
-		}
+	p.conn = conn
+	go p.readResults(conn)
+	return nil
+}
 
+// readResults 读取识别结果，连接关闭后退出
+func (p *Provider) readResults(conn *websocket.Conn) {
+	defer func() {
+		if err := recover(); err != nil {
+			p.logger.Error("读取sherpa识别结果异常: %v", err)
+		}
+		p.connMutex.Lock()
+		if p.conn == conn {
+			p.conn = nil
+		}
+		p.connMutex.Unlock()
+		conn.Close()
 	}()
-
-	return provider, nil
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType == websocket.TextMessage {
+			if listener := p.GetListener(); listener != nil {
+				listener.OnAsrResult(string(data))
+			}
+		}
+	}
 }
 
 func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
 	return "", nil
 }
 
-// 添加音频数据到缓冲区
+// 添加音频数据到缓冲区，上一次识别已结束时重新建立连接
 func (p *Provider) AddAudio(data []byte) error {
-	p.conn.WriteMessage(websocket.BinaryMessage, data)
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
 
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return fmt.Errorf("连接sherpa服务失败: %v", err)
+		}
+	}
+	return p.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// NotifySpeechEnd 服务端VAD检测到说话结束，通知sherpa服务端结束输入并返回最终结果
+// 服务端返回结果后会关闭连接，下一段音频到来时重新连接
+func (p *Provider) NotifySpeechEnd() error {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	p.logger.Debug("服务端VAD检测到说话结束，结束本次sherpa识别")
+	conn := p.conn
+	p.conn = nil
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(doneMessage)); err != nil {
+		conn.Close()
+		return fmt.Errorf("发送结束消息失败: %v", err)
+	}
 	return nil
 }
 
@@ -29,10 +29,20 @@ type ASRProvider interface {
 
 	// 获取当前静音计数
 	GetSilenceCount() int
+	// 静音计数加一并返回新的计数
+	IncreaseSilenceCount() int
+	// 检测到用户说话时清零静音计数
+	ResetSilenceCount()
 
 	ResetStartListenTime()
 }
 
+// ASRSpeechEndNotifier 支持由服务端VAD通知说话结束的ASR提供者
+// 收到通知后应尽快结束本次识别，并通过监听器返回最终结果
+type ASRSpeechEndNotifier interface {
+	NotifySpeechEnd() error
+}
+
 // TTSProvider 语音合成提供者接口
 type TTSProvider interface {
 	Provider
@@ -43,6 +53,29 @@ type TTSProvider interface {
 	SetVoice(voice string) error
 }
 
+// TTSStreamProvider 流式语音合成提供者接口
+// 合成过程中逐块返回16kHz单声道16位PCM数据，无需等待整段音频合成完成
+type TTSStreamProvider interface {
+	TTSProvider
+
+	// 合成音频并以数据块流的形式返回，最后一块的EOF为true
+	// ctx取消后应尽快停止合成并关闭数据块流
+	ToTTSStream(ctx context.Context, text string) (<-chan types.AudioChunk, error)
+}
+
+// TTSVoiceProvider 支持按次指定音色的语音合成提供者接口
+// 指定的音色只对本次合成生效，不修改会话当前的音色，voice为空时使用当前音色
+type TTSVoiceProvider interface {
+	TTSStreamProvider
+
+	// 返回适合朗读指定语言的音色，当前音色已经适合或没有配置该语言的音色时返回空
+	VoiceForLanguage(language string) string
+
+	ToTTSWithVoice(text string, voice string) (string, error)
+
+	ToTTSStreamWithVoice(ctx context.Context, text string, voice string) (<-chan types.AudioChunk, error)
+}
+
 // LLMProvider 大语言模型提供者接口
 type LLMProvider interface {
 	types.LLMProvider
@@ -16,6 +16,9 @@ type Config struct {
 	MaxTokens   int                    `yaml:"max_tokens,omitempty"`
 	TopP        float64                `yaml:"top_p,omitempty"`
 	Extra       map[string]interface{} `yaml:",inline"`
+
+	ContextTokens int  `yaml:"context_tokens,omitempty"` // 上下文窗口的token预算，为0时使用默认值
+	Multimodal    bool `yaml:"multimodal,omitempty"`     // 模型是否支持图片输入，为false时图片只以文字说明发送
 }
 
 // Provider LLM提供者接口
@@ -83,21 +83,24 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 
 		// 转换消息格式
 		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+		multimodal := p.Config().Multimodal
 		for i, msg := range messages {
+			content, parts := msg.OpenAIContent(multimodal)
 			chatMessages[i] = openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:         msg.Role,
+				Content:      content,
+				MultiContent: parts,
 			}
 		}
 
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.modelName,
-				Messages: chatMessages,
-				Stream:   true,
-			},
-		)
+		chatRequest := openai.ChatCompletionRequest{
+			Model:    p.modelName,
+			Messages: chatMessages,
+			Stream:   true,
+		}
+		llm.ApplyParams(ctx, &chatRequest)
+
+		stream, err := p.client.CreateChatCompletionStream(ctx, chatRequest)
 		if err != nil {
 			responseChan <- fmt.Sprintf("【Ollama服务响应异常: %v】", err)
 			return
@@ -149,10 +152,13 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 
 		// 转换消息格式
 		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+		multimodal := p.Config().Multimodal
 		for i, msg := range messages {
+			content, parts := msg.OpenAIContent(multimodal)
 			chatMessage := openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:         msg.Role,
+				Content:      content,
+				MultiContent: parts,
 			}
 
 			// 处理tool_call_id字段（tool消息必需）
@@ -179,15 +185,15 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 			chatMessages[i] = chatMessage
 		}
 
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.modelName,
-				Messages: chatMessages,
-				Tools:    tools,
-				Stream:   true,
-			},
-		)
+		chatRequest := openai.ChatCompletionRequest{
+			Model:    p.modelName,
+			Messages: chatMessages,
+			Tools:    tools,
+			Stream:   true,
+		}
+		llm.ApplyParams(ctx, &chatRequest)
+
+		stream, err := p.client.CreateChatCompletionStream(ctx, chatRequest)
 		if err != nil {
 			responseChan <- types.Response{
 				Content: fmt.Sprintf("【Ollama服务响应异常: %v】", err),
@@ -213,6 +219,11 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 				if len(delta.ToolCalls) > 0 {
 					toolCalls := make([]types.ToolCall, len(delta.ToolCalls))
 					for i, tc := range delta.ToolCalls {
+						// 一次回复包含多个工具调用时，依靠Index区分参数分片属于哪个调用
+						index := i
+						if tc.Index != nil {
+							index = *tc.Index
+						}
 						toolCalls[i] = types.ToolCall{
 							ID:   tc.ID,
 							Type: string(tc.Type),
@@ -220,6 +231,7 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 								Name:      tc.Function.Name,
 								Arguments: tc.Function.Arguments,
 							},
+							Index: index,
 						}
 					}
 					responseChan <- types.Response{
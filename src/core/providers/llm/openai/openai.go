@@ -65,10 +65,13 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 
 		// 转换消息格式
 		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+		multimodal := p.Config().Multimodal
 		for i, msg := range messages {
+			content, parts := msg.OpenAIContent(multimodal)
 			chatMessages[i] = openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:         msg.Role,
+				Content:      content,
+				MultiContent: parts,
 			}
 		}
 
@@ -82,6 +85,7 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 		if extra, ok := p.Config().Extra["enable_search"]; ok && extra.(bool) {
 			chatRequest.EnableSearch = true
 		}
+		llm.ApplyParams(ctx, &chatRequest)
 
 		stream, err := p.client.CreateChatCompletionStream(
 			ctx,
@@ -124,10 +128,13 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 
 		// 转换消息格式
 		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+		multimodal := p.Config().Multimodal
 		for i, msg := range messages {
+			content, parts := msg.OpenAIContent(multimodal)
 			chatMessage := openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:         msg.Role,
+				Content:      content,
+				MultiContent: parts,
 			}
 
 			// 处理tool_call_id字段（tool消息必需）
@@ -154,15 +161,15 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 			chatMessages[i] = chatMessage
 		}
 
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.Config().ModelName,
-				Messages: chatMessages,
-				Tools:    tools,
-				Stream:   true,
-			},
-		)
+		chatRequest := openai.ChatCompletionRequest{
+			Model:    p.Config().ModelName,
+			Messages: chatMessages,
+			Tools:    tools,
+			Stream:   true,
+		}
+		llm.ApplyParams(ctx, &chatRequest)
+
+		stream, err := p.client.CreateChatCompletionStream(ctx, chatRequest)
 		if err != nil {
 			responseChan <- types.Response{
 				Content: fmt.Sprintf("【OpenAI服务响应异常: %v】", err),
@@ -188,6 +195,11 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 				if len(delta.ToolCalls) > 0 {
 					toolCalls := make([]types.ToolCall, len(delta.ToolCalls))
 					for i, tc := range delta.ToolCalls {
+						// 一次回复包含多个工具调用时，依靠Index区分参数分片属于哪个调用
+						index := i
+						if tc.Index != nil {
+							index = *tc.Index
+						}
 						toolCalls[i] = types.ToolCall{
 							ID:   tc.ID,
 							Type: string(tc.Type),
@@ -195,6 +207,7 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 								Name:      tc.Function.Name,
 								Arguments: tc.Function.Arguments,
 							},
+							Index: index,
 						}
 					}
 					chunk.ToolCalls = toolCalls
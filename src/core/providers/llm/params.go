@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Params 单次请求的生成参数，为nil的字段使用提供者的默认值
+type Params struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+}
+
+// Empty 是否没有设置任何参数
+func (p Params) Empty() bool {
+	return p.Temperature == nil && p.MaxTokens == nil && p.TopP == nil
+}
+
+type paramsKey struct{}
+
+// WithParams 返回携带生成参数的ctx，提供者在发起请求时读取
+func WithParams(ctx context.Context, params Params) context.Context {
+	if params.Empty() {
+		return ctx
+	}
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// ParamsFromContext 读取ctx中的生成参数
+func ParamsFromContext(ctx context.Context) Params {
+	params, _ := ctx.Value(paramsKey{}).(Params)
+	return params
+}
+
+// ApplyParams 将ctx中的生成参数写入OpenAI兼容的请求
+func ApplyParams(ctx context.Context, req *openai.ChatCompletionRequest) {
+	params := ParamsFromContext(ctx)
+	if params.Temperature != nil {
+		req.Temperature = float32(*params.Temperature)
+	}
+	if params.MaxTokens != nil {
+		req.MaxTokens = *params.MaxTokens
+	}
+	if params.TopP != nil {
+		req.TopP = float32(*params.TopP)
+	}
+}
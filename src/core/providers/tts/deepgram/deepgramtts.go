@@ -2,7 +2,9 @@ package deepgram
 
 import (
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -32,35 +34,63 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 	}, nil
 }
 
-// ToTTS 实现文本到语音的转换
-func (p *Provider) ToTTS(text string) (string, error) {
+// dial 建立WebSocket连接并提交合成请求
+func (p *Provider) dial(url string, text string) (*websocket.Conn, error) {
 	// 创建WebSocket连接
 	header := http.Header{"Authorization": []string{fmt.Sprintf("token %s", p.Config().Token)}}
-	conn, _, err := websocket.DefaultDialer.Dial(p.baseURL, header)
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
 	if err != nil {
-		return "", fmt.Errorf("连接Deepgram TTS服务器失败: %v", err)
+		return nil, fmt.Errorf("连接Deepgram TTS服务器失败: %v", err)
 	}
-	defer conn.Close()
 
-	// 发送文本消息
+	// 发送文本消息，包含韵律标记时以SSML提交
+	if tts.HasProsody(text) {
+		text = tts.SSMLDocument(text)
+	}
 	speakRequest := map[string]string{
 		"type": "Speak",
 		"text": text,
 	}
 	requestBytes, err := json.Marshal(speakRequest)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
 	}
 
 	if err := conn.WriteMessage(websocket.TextMessage, requestBytes); err != nil {
-		return "", fmt.Errorf("发送speak请求失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("发送speak请求失败: %v", err)
 	}
 
 	// 发送Flush控制消息确保所有音频数据返回
 	flushRequest := map[string]string{"type": "Flush"}
 	if err := conn.WriteJSON(flushRequest); err != nil {
-		return "", fmt.Errorf("发送Flush请求失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("发送Flush请求失败: %v", err)
+	}
+	return conn, nil
+}
+
+// modelURL 返回合成使用的地址，Deepgram的音色即模型名，voice为空时使用当前音色
+func (p *Provider) modelURL(voice string) string {
+	if voice == "" {
+		return p.baseURL
 	}
+	return fmt.Sprintf("%v?model=%s", p.Config().Cluster, voice)
+}
+
+// ToTTS 实现文本到语音的转换
+func (p *Provider) ToTTS(text string) (string, error) {
+	return p.ToTTSWithVoice(text, "")
+}
+
+// ToTTSWithVoice 使用指定的音色合成音频文件，voice为空时使用当前音色
+func (p *Provider) ToTTSWithVoice(text string, voice string) (string, error) {
+	conn, err := p.dial(p.modelURL(voice), text)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
 
 	// 创建临时文件
 	outputDir := p.Config().OutputDir
@@ -136,6 +166,73 @@ loop:
 	return tempFile, nil
 }
 
+// ToTTSStream 流式合成，请求原始linear16 PCM并在收到每个音频消息时立即输出
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (<-chan types.AudioChunk, error) {
+	return p.ToTTSStreamWithVoice(ctx, text, "")
+}
+
+// ToTTSStreamWithVoice 使用指定的音色流式合成，voice为空时使用当前音色
+func (p *Provider) ToTTSStreamWithVoice(ctx context.Context, text string, voice string) (<-chan types.AudioChunk, error) {
+	url := fmt.Sprintf("%s&encoding=linear16&sample_rate=%d", p.modelURL(voice), tts.StreamSampleRate)
+	conn, err := p.dial(url, text)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan types.AudioChunk, tts.StreamChunkBuffer)
+	go func() {
+		defer close(stream)
+		defer conn.Close()
+		// ctx取消时关闭连接，使阻塞中的读取立即返回
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure) {
+					stream <- types.AudioChunk{Text: text, EOF: true, Error: fmt.Sprintf("接收响应异常: %v", err)}
+					return
+				}
+				break // 正常关闭
+			}
+
+			if messageType == websocket.BinaryMessage {
+				stream <- types.AudioChunk{
+					Text:      text,
+					Data:      message,
+					Timestamp: time.Now().UnixMilli(),
+					Encoding:  "pcm",
+				}
+				continue
+			}
+			if messageType != websocket.TextMessage {
+				continue
+			}
+
+			// 处理控制消息响应
+			var response struct {
+				Type  string `json:"type"`
+				Error string `json:"error,omitempty"`
+			}
+			if err := json.Unmarshal(message, &response); err != nil {
+				stream <- types.AudioChunk{Text: text, EOF: true, Error: fmt.Sprintf("解析控制消息失败: %v", err)}
+				return
+			}
+			if response.Type == "error" {
+				stream <- types.AudioChunk{Text: text, EOF: true, Error: fmt.Sprintf("Deepgram TTS错误: %s", response.Error)}
+				return
+			}
+			if response.Type == "Flushed" || response.Type == "close" {
+				break
+			}
+		}
+		stream <- types.AudioChunk{Text: text, EOF: true, Encoding: "pcm"}
+	}()
+
+	return stream, nil
+}
+
 // getFileExtension 根据编码获取文件扩展名
 func getFileExtension(encoding string) string {
 	switch encoding {
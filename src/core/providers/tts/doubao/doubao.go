@@ -3,17 +3,21 @@ package doubao
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -65,17 +69,27 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 	}, nil
 }
 
-// ToTTS 实现文本到语音的转换
-func (p *Provider) ToTTS(text string) (string, error) {
+// dial 建立WebSocket连接并提交合成请求，rate为0时使用服务端默认采样率，voice为空时使用当前音色
+func (p *Provider) dial(text string, voice string, encoding string, rate int) (*websocket.Conn, error) {
 	// 创建WebSocket连接
 	header := http.Header{"Authorization": []string{fmt.Sprintf("Bearer;%s", p.Config().Token)}}
 	conn, _, err := websocket.DefaultDialer.Dial(p.baseURL, header)
 	if err != nil {
-		return "", fmt.Errorf("连接WebSocket服务器失败: %v", err)
+		return nil, fmt.Errorf("连接WebSocket服务器失败: %v", err)
 	}
-	defer conn.Close()
 
 	// 准备请求参数
+	text, textType, speed, volume, pitch := convertProsody(text)
+	audioParams := map[string]interface{}{
+		"voice_type":   p.VoiceOrDefault(voice),
+		"encoding":     encoding,
+		"speed_ratio":  speed,
+		"volume_ratio": volume,
+		"pitch_ratio":  pitch,
+	}
+	if rate > 0 {
+		audioParams["rate"] = rate
+	}
 	reqParams := map[string]map[string]interface{}{
 		"app": {
 			"appid":   p.Config().AppID,
@@ -85,17 +99,11 @@ func (p *Provider) ToTTS(text string) (string, error) {
 		"user": {
 			"uid": "uid",
 		},
-		"audio": {
-			"voice_type":   p.Config().Voice,
-			"encoding":     "mp3",
-			"speed_ratio":  1.0,
-			"volume_ratio": 1.0,
-			"pitch_ratio":  1.0,
-		},
+		"audio": audioParams,
 		"request": {
 			"reqid":     uuid.New().String(),
 			"text":      text,
-			"text_type": "plain",
+			"text_type": textType,
 			"operation": "submit", // 使用流式合成
 		},
 	}
@@ -103,13 +111,15 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// 序列化并压缩请求参数
 	jsonData, err := json.Marshal(reqParams)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求参数失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
 	var b bytes.Buffer
 	w := gzip.NewWriter(&b)
 	if _, err := w.Write(jsonData); err != nil {
-		return "", fmt.Errorf("压缩请求数据失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("压缩请求数据失败: %v", err)
 	}
 	w.Close()
 	compressed := b.Bytes()
@@ -124,8 +134,51 @@ func (p *Provider) ToTTS(text string) (string, error) {
 
 	// 发送请求
 	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	return conn, nil
+}
+
+// convertProsody 尽量将韵律标记转换为豆包支持的形式：停顿转为SSML的break，
+// 语速、音量和音调只能整体调节，取第一段设置了韵律的文本的倍率，重读无法表达而被忽略
+func convertProsody(text string) (string, string, float64, float64, float64) {
+	if !tts.HasProsody(text) {
+		return text, "plain", 1.0, 1.0, 1.0
+	}
+	speed, volume, pitch := 1.0, 1.0, 1.0
+	adjusted, hasBreak := false, false
+	var sb strings.Builder
+	for _, seg := range tts.ParseProsody(text) {
+		if seg.Break > 0 {
+			fmt.Fprintf(&sb, `<break time="%dms"/>`, seg.Break.Milliseconds())
+			hasBreak = true
+			continue
+		}
+		if !adjusted && (seg.Rate != "" || seg.Pitch != "" || seg.Volume != "") {
+			speed, pitch, volume = seg.Ratios()
+			adjusted = true
+		}
+		sb.WriteString(html.EscapeString(seg.Text))
+	}
+	if !hasBreak {
+		return tts.StripProsody(text), "plain", speed, volume, pitch
+	}
+	return "<speak>" + sb.String() + "</speak>", "ssml", speed, volume, pitch
+}
+
+// ToTTS 实现文本到语音的转换
+func (p *Provider) ToTTS(text string) (string, error) {
+	return p.ToTTSWithVoice(text, "")
+}
+
+// ToTTSWithVoice 使用指定的音色合成音频文件，voice为空时使用当前音色
+func (p *Provider) ToTTSWithVoice(text string, voice string) (string, error) {
+	conn, err := p.dial(text, voice, "mp3", 0)
+	if err != nil {
+		return "", err
 	}
+	defer conn.Close()
 
 	// 创建临时文件
 	outputDir := p.Config().OutputDir
@@ -165,6 +218,57 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	return tempFile, nil
 }
 
+// ToTTSStream 流式合成，服务端每返回一段音频就立即输出一个PCM数据块
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (<-chan types.AudioChunk, error) {
+	return p.ToTTSStreamWithVoice(ctx, text, "")
+}
+
+// ToTTSStreamWithVoice 使用指定的音色流式合成，voice为空时使用当前音色
+func (p *Provider) ToTTSStreamWithVoice(ctx context.Context, text string, voice string) (<-chan types.AudioChunk, error) {
+	conn, err := p.dial(text, voice, "pcm", tts.StreamSampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan types.AudioChunk, tts.StreamChunkBuffer)
+	go func() {
+		defer close(stream)
+		defer conn.Close()
+		// ctx取消时关闭连接，使阻塞中的读取立即返回
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		defer stop()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				stream <- types.AudioChunk{Text: text, EOF: true, Error: fmt.Sprintf("接收响应失败: %v", err)}
+				return
+			}
+
+			resp, err := p.parseResponse(message)
+			if err != nil {
+				stream <- types.AudioChunk{Text: text, EOF: true, Error: fmt.Sprintf("解析响应失败: %v", err)}
+				return
+			}
+
+			if len(resp.Audio) > 0 || resp.IsLast {
+				stream <- types.AudioChunk{
+					Text:      text,
+					Data:      resp.Audio,
+					Timestamp: time.Now().UnixMilli(),
+					EOF:       resp.IsLast,
+					Encoding:  "pcm",
+				}
+			}
+			if resp.IsLast {
+				return
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
 // parseResponse 解析服务器响应
 func (p *Provider) parseResponse(res []byte) (resp synResp, err error) {
 	if len(res) < 4 {
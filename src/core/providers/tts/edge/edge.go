@@ -2,6 +2,8 @@ package edge
 
 import (
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,9 +28,14 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 // ToTTS 将文本转换为音频文件，并返回文件路径
 // 使用的edge库是github.com/wujunwei928/edge-tts-go，默认使用24k采样率
 func (p *Provider) ToTTS(text string) (string, error) {
+	return p.ToTTSWithVoice(text, "")
+}
+
+// ToTTSWithVoice 使用指定的音色合成音频文件，voice为空时使用当前音色
+func (p *Provider) ToTTSWithVoice(text string, voice string) (string, error) {
 	// 获取配置的声音，如果未配置则使用默认值
 	edgeTTSStartTime := time.Now()
-	voice := p.BaseProvider.Config().Voice
+	voice = p.VoiceOrDefault(voice)
 	if voice == "" {
 		voice = "zh-CN-XiaoxiaoNeural" // 默认声音
 	}
@@ -50,8 +57,8 @@ func (p *Provider) ToTTS(text string) (string, error) {
 		edge_tts.SetVoice(voice),
 	}
 
-	// 创建 Communicate 实例
-	conn, err := edge_tts.NewCommunicate(text, connOptions...)
+	// 创建 Communicate 实例，文本会被直接嵌入SSML，转换韵律标记并转义特殊字符
+	conn, err := edge_tts.NewCommunicate(tts.SSML(text), connOptions...)
 	if err != nil {
 		return "", fmt.Errorf("创建 edge-tts-go Communicate 失败: %v", err)
 	}
@@ -82,6 +89,19 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	return tempFile, nil
 }
 
+// ToTTSStream 以数据块流的形式返回合成音频
+// Edge TTS不支持增量返回音频，先完整合成文件再适配为数据块流
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (<-chan types.AudioChunk, error) {
+	return tts.FileStream(ctx, p, text, p.DeleteFile())
+}
+
+// ToTTSStreamWithVoice 使用指定的音色合成，并以数据块流的形式返回
+func (p *Provider) ToTTSStreamWithVoice(ctx context.Context, text string, voice string) (<-chan types.AudioChunk, error) {
+	return tts.FileStreamFunc(ctx, func(text string) (string, error) {
+		return p.ToTTSWithVoice(text, voice)
+	}, text, p.DeleteFile())
+}
+
 func init() {
 	// 注册Edge TTS提供者
 	tts.Register("edge", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
@@ -2,6 +2,7 @@ package gosherpa
 
 import (
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 	"context"
 	"fmt"
 	"os"
@@ -52,7 +53,8 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// Use a unique filename
 	tempFile := filepath.Join(outputDir, fmt.Sprintf("go_sherpa_tts_%d.wav", time.Now().UnixNano()))
 
-	p.conn.WriteMessage(websocket.TextMessage, []byte(text))
+	// Sherpa不支持SSML，去除韵律标记后合成
+	p.conn.WriteMessage(websocket.TextMessage, []byte(tts.StripProsody(text)))
 	_, bytes, err := p.conn.ReadMessage()
 
 	if err != nil {
@@ -77,6 +79,12 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	return tempFile, nil
 }
 
+// ToTTSStream 以数据块流的形式返回合成音频
+// Sherpa TTS不支持增量返回音频，先完整合成文件再适配为数据块流
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (<-chan types.AudioChunk, error) {
+	return tts.FileStream(ctx, p, text, p.DeleteFile())
+}
+
 func init() {
 	// 注册Sherpa TTS提供者
 	tts.Register("gosherpa", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
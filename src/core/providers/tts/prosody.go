@@ -0,0 +1,268 @@
+package tts
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 韵律标记是回复中可以使用的SSML子集，由LLM或工具生成，各TTS提供者转换为自身支持的形式：
+//
+//	<break time="500ms"/>                                 停顿，时长单位为ms或s
+//	<emphasis>文本</emphasis>                              重读
+//	<prosody rate="slow" pitch="high" volume="loud">文本</prosody>  语速、音调和音量
+//
+// rate取值为x-slow/slow/medium/fast/x-fast或百分比（如80%），pitch取值为x-low/low/medium/high/x-high，
+// volume取值为x-soft/soft/medium/loud/x-loud。无法识别的标签按普通文本处理，缺少结束标签时作用到文本末尾
+var (
+	reProsodyTag  = regexp.MustCompile(`(?i)<\s*(/?)\s*(break|emphasis|prosody)\b([^<>]*)>`)
+	reProsodyAttr = regexp.MustCompile(`(\w+)\s*=\s*["']([^"']*)["']`)
+)
+
+const (
+	defaultBreak = 500 * time.Millisecond
+	maxBreak     = 5 * time.Second
+)
+
+// ProsodySegment 韵律标记解析后的一段文本或一次停顿
+type ProsodySegment struct {
+	Text     string
+	Break    time.Duration // 大于0时表示停顿，此时Text为空
+	Rate     string
+	Pitch    string
+	Volume   string
+	Emphasis bool
+}
+
+// Plain 是否没有任何韵律设置
+func (s ProsodySegment) Plain() bool {
+	return s.Rate == "" && s.Pitch == "" && s.Volume == "" && !s.Emphasis
+}
+
+type prosodyState struct {
+	tag                 string
+	rate, pitch, volume string
+	emphasis            bool
+}
+
+// HasProsody 文本中是否包含韵律标记
+func HasProsody(text string) bool {
+	return strings.Contains(text, "<") && reProsodyTag.MatchString(text)
+}
+
+// ParseProsody 解析文本中的韵律标记
+func ParseProsody(text string) []ProsodySegment {
+	var segments []ProsodySegment
+	var stack []prosodyState
+	current := func() prosodyState {
+		if len(stack) == 0 {
+			return prosodyState{}
+		}
+		return stack[len(stack)-1]
+	}
+	appendText := func(s string) {
+		if s == "" {
+			return
+		}
+		state := current()
+		segments = append(segments, ProsodySegment{
+			Text:     s,
+			Rate:     state.rate,
+			Pitch:    state.pitch,
+			Volume:   state.volume,
+			Emphasis: state.emphasis,
+		})
+	}
+
+	last := 0
+	for _, loc := range reProsodyTag.FindAllStringSubmatchIndex(text, -1) {
+		appendText(text[last:loc[0]])
+		last = loc[1]
+		closing := loc[3] > loc[2]
+		tag := strings.ToLower(text[loc[4]:loc[5]])
+		attrs := parseProsodyAttrs(text[loc[6]:loc[7]])
+
+		switch {
+		case tag == "break":
+			segments = append(segments, ProsodySegment{Break: parseBreak(attrs["time"])})
+		case closing:
+			// 弹出最近的同名标签，没有对应的开始标签时忽略
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].tag == tag {
+					stack = stack[:i]
+					break
+				}
+			}
+		default:
+			state := current()
+			state.tag = tag
+			if tag == "emphasis" {
+				state.emphasis = true
+			} else {
+				if v := attrs["rate"]; v != "" {
+					state.rate = v
+				}
+				if v := attrs["pitch"]; v != "" {
+					state.pitch = v
+				}
+				if v := attrs["volume"]; v != "" {
+					state.volume = v
+				}
+			}
+			stack = append(stack, state)
+		}
+	}
+	appendText(text[last:])
+	return segments
+}
+
+func parseProsodyAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range reProsodyAttr.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = strings.ToLower(strings.TrimSpace(m[2]))
+	}
+	return attrs
+}
+
+// parseBreak 解析停顿时长，无法解析时使用默认值，超过上限时截断
+func parseBreak(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return defaultBreak
+	}
+	if d > maxBreak {
+		return maxBreak
+	}
+	return d
+}
+
+// StripProsody 去除韵律标记，得到显示给用户的文本
+func StripProsody(text string) string {
+	if !HasProsody(text) {
+		return text
+	}
+	return reProsodyTag.ReplaceAllString(text, "")
+}
+
+// MapProsodyText 对韵律标记之间的文本逐段处理，标记保持不变
+func MapProsodyText(text string, fn func(string) string) string {
+	if !HasProsody(text) {
+		return fn(text)
+	}
+	segments := ParseProsody(text)
+	for i := range segments {
+		if segments[i].Break == 0 {
+			segments[i].Text = fn(segments[i].Text)
+		}
+	}
+	return FormatProsody(segments)
+}
+
+// FormatProsody 将解析结果重新格式化为韵律标记
+func FormatProsody(segments []ProsodySegment) string {
+	return formatSegments(segments, func(s string) string { return s })
+}
+
+// SSML 将韵律标记转换为SSML片段，文本中的特殊字符会被转义，不包含外层的speak元素
+func SSML(text string) string {
+	return formatSegments(ParseProsody(text), escapeXML)
+}
+
+// SSMLDocument 将韵律标记转换为完整的SSML文档
+func SSMLDocument(text string) string {
+	return "<speak>" + SSML(text) + "</speak>"
+}
+
+func formatSegments(segments []ProsodySegment, escape func(string) string) string {
+	var sb strings.Builder
+	for _, seg := range segments {
+		if seg.Break > 0 {
+			fmt.Fprintf(&sb, `<break time="%dms"/>`, seg.Break.Milliseconds())
+			continue
+		}
+		if seg.Text == "" {
+			continue
+		}
+		text := escape(seg.Text)
+		if seg.Emphasis {
+			text = "<emphasis>" + text + "</emphasis>"
+		}
+		var attrs []string
+		for _, attr := range [][2]string{{"rate", seg.Rate}, {"pitch", seg.Pitch}, {"volume", seg.Volume}} {
+			if attr[1] != "" {
+				attrs = append(attrs, fmt.Sprintf(`%s="%s"`, attr[0], escapeXML(attr[1])))
+			}
+		}
+		if len(attrs) > 0 {
+			text = "<prosody " + strings.Join(attrs, " ") + ">" + text + "</prosody>"
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+
+func escapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
+
+// UnclosedProsody 返回文本末尾仍未结束的开始标签，分段播报时拼接到下一段开头，使韵律设置延续到后续分段
+func UnclosedProsody(text string) string {
+	if !HasProsody(text) {
+		return ""
+	}
+	type openTag struct{ name, tag string }
+	var open []openTag
+	for _, m := range reProsodyTag.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(m[2])
+		switch {
+		case name == "break":
+		case m[1] != "":
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].name == name {
+					open = open[:i]
+					break
+				}
+			}
+		default:
+			open = append(open, openTag{name, m[0]})
+		}
+	}
+	var sb strings.Builder
+	for _, t := range open {
+		sb.WriteString(t.tag)
+	}
+	return sb.String()
+}
+
+// 语速、音调和音量的档位对应的倍率
+var (
+	rateRatios   = map[string]float64{"x-slow": 0.6, "slow": 0.8, "medium": 1, "fast": 1.2, "x-fast": 1.4}
+	pitchRatios  = map[string]float64{"x-low": 0.8, "low": 0.9, "medium": 1, "high": 1.1, "x-high": 1.2}
+	volumeRatios = map[string]float64{"silent": 0.1, "x-soft": 0.5, "soft": 0.75, "medium": 1, "loud": 1.25, "x-loud": 1.5}
+)
+
+// Ratios 返回语速、音调和音量相对默认值的倍率，供只支持整体调节的提供者使用
+func (s ProsodySegment) Ratios() (rate, pitch, volume float64) {
+	return prosodyRatio(s.Rate, rateRatios), prosodyRatio(s.Pitch, pitchRatios), prosodyRatio(s.Volume, volumeRatios)
+}
+
+// prosodyRatio 将档位或百分比转换为倍率，带符号的百分比表示相对变化（如+20%），无法识别时为1
+func prosodyRatio(value string, levels map[string]float64) float64 {
+	if ratio, ok := levels[value]; ok {
+		return ratio
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || !strings.HasSuffix(value, "%") {
+		return 1
+	}
+	ratio := n / 100
+	if value[0] == '+' || value[0] == '-' {
+		ratio = 1 + n/100
+	}
+	return math.Max(ratio, 0.1)
+}
@@ -0,0 +1,157 @@
+package tts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProsody(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []ProsodySegment
+	}{
+		{
+			name:     "纯文本",
+			input:    "你好",
+			expected: []ProsodySegment{{Text: "你好"}},
+		},
+		{
+			name:  "停顿",
+			input: `你好<break time="300ms"/>世界`,
+			expected: []ProsodySegment{
+				{Text: "你好"},
+				{Break: 300 * time.Millisecond},
+				{Text: "世界"},
+			},
+		},
+		{
+			name:     "停顿缺少时长时使用默认值",
+			input:    `<break/>`,
+			expected: []ProsodySegment{{Break: defaultBreak}},
+		},
+		{
+			name:     "停顿超过上限时截断",
+			input:    `<break time="10s"/>`,
+			expected: []ProsodySegment{{Break: maxBreak}},
+		},
+		{
+			name:  "嵌套标签继承外层设置",
+			input: `<prosody rate="slow">慢<emphasis>重</emphasis><prosody pitch="high">高</prosody>回</prosody>正常`,
+			expected: []ProsodySegment{
+				{Text: "慢", Rate: "slow"},
+				{Text: "重", Rate: "slow", Emphasis: true},
+				{Text: "高", Rate: "slow", Pitch: "high"},
+				{Text: "回", Rate: "slow"},
+				{Text: "正常"},
+			},
+		},
+		{
+			name:     "缺少结束标签时作用到末尾",
+			input:    `前<prosody volume="loud">大声`,
+			expected: []ProsodySegment{{Text: "前"}, {Text: "大声", Volume: "loud"}},
+		},
+		{
+			name:     "没有开始标签的结束标签被忽略",
+			input:    `文本</emphasis>后`,
+			expected: []ProsodySegment{{Text: "文本"}, {Text: "后"}},
+		},
+		{
+			name:     "结束外层标签时一并结束内层",
+			input:    `<emphasis>重<prosody pitch="high">高</emphasis>后`,
+			expected: []ProsodySegment{{Text: "重", Emphasis: true}, {Text: "高", Pitch: "high", Emphasis: true}, {Text: "后"}},
+		},
+		{
+			name:     "标签和属性不区分大小写",
+			input:    `<PROSODY RATE="FAST">快</Prosody>`,
+			expected: []ProsodySegment{{Text: "快", Rate: "fast"}},
+		},
+		{
+			name:     "无法识别的标签按普通文本处理",
+			input:    `<b>粗</b>`,
+			expected: []ProsodySegment{{Text: "<b>粗</b>"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseProsody(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParseProsody(%q) = %+v, want %+v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnclosedProsody(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "没有标签", input: "你好", expected: ""},
+		{name: "全部闭合", input: `<prosody rate="slow">慢</prosody>`, expected: ""},
+		{name: "停顿不需要延续", input: `你好<break time="1s"/>`, expected: ""},
+		{
+			name:     "嵌套未闭合按顺序返回",
+			input:    `<prosody rate="slow">慢<emphasis>重`,
+			expected: `<prosody rate="slow"><emphasis>`,
+		},
+		{
+			name:     "内层已闭合",
+			input:    `<prosody rate="slow">慢<emphasis>重</emphasis>`,
+			expected: `<prosody rate="slow">`,
+		},
+		{
+			name:     "结束外层标签时一并结束内层",
+			input:    `<emphasis>重<prosody pitch="high">高</emphasis>`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := UnclosedProsody(tt.input); result != tt.expected {
+				t.Errorf("UnclosedProsody(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnclosedProsodyCarriedOver(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		next     string
+		expected []ProsodySegment
+	}{
+		{
+			name:     "设置延续到下一段",
+			previous: `<prosody rate="slow">第一句。`,
+			next:     `第二句</prosody>。`,
+			expected: []ProsodySegment{{Text: "第二句", Rate: "slow"}, {Text: "。"}},
+		},
+		{
+			name:     "嵌套设置延续到下一段",
+			previous: `<prosody volume="loud">大<emphasis>重。`,
+			next:     `还是重</emphasis>大</prosody>`,
+			expected: []ProsodySegment{{Text: "还是重", Volume: "loud", Emphasis: true}, {Text: "大", Volume: "loud"}},
+		},
+		{
+			name:     "上一段已闭合时不影响下一段",
+			previous: `<emphasis>重</emphasis>。`,
+			next:     `正常`,
+			expected: []ProsodySegment{{Text: "正常"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseProsody(UnclosedProsody(tt.previous) + tt.next)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("延续 %q 后 ParseProsody(%q) = %+v, want %+v", tt.previous, tt.next, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package tts
+
+import (
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// StreamSampleRate 流式合成输出的PCM采样率
+const StreamSampleRate = 16000
+
+// StreamChunkBuffer 流式合成数据块通道的缓冲大小
+const StreamChunkBuffer = 64
+
+// fileStreamChunkSize 文件适配器每块输出的PCM字节数（约480ms）
+const fileStreamChunkSize = StreamSampleRate * 2 * 480 / 1000
+
+// FileStream 将基于文件的合成结果适配为音频数据块流
+// 用于不支持流式合成的提供者：先完整合成音频文件，再解码为16kHz单声道PCM分块输出
+func FileStream(ctx context.Context, provider providers.TTSProvider, text string, deleteFile bool) (<-chan types.AudioChunk, error) {
+	return FileStreamFunc(ctx, provider.ToTTS, text, deleteFile)
+}
+
+// FileStreamFunc 与FileStream相同，由synthesize合成音频文件，用于按次指定音色等场景
+func FileStreamFunc(ctx context.Context, synthesize func(text string) (string, error), text string, deleteFile bool) (<-chan types.AudioChunk, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	audioFile, err := synthesize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	pcmData, err := decodeAudioFile(audioFile)
+	if deleteFile {
+		os.Remove(audioFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 通道容量足以容纳全部数据块，无需额外的协程
+	chunkCount := len(pcmData)/fileStreamChunkSize + 1
+	stream := make(chan types.AudioChunk, chunkCount)
+	for offset := 0; offset < len(pcmData); offset += fileStreamChunkSize {
+		end := offset + fileStreamChunkSize
+		if end > len(pcmData) {
+			end = len(pcmData)
+		}
+		stream <- types.AudioChunk{
+			Text:      text,
+			Data:      pcmData[offset:end],
+			Timestamp: time.Now().UnixMilli(),
+			EOF:       end == len(pcmData),
+			Encoding:  "pcm",
+		}
+	}
+	close(stream)
+	return stream, nil
+}
+
+// decodeAudioFile 将音频文件解码为16kHz单声道PCM数据
+func decodeAudioFile(audioFile string) ([]byte, error) {
+	if strings.HasSuffix(audioFile, ".mp3") {
+		pcmSlices, _, err := utils.AudioToPCMData(audioFile)
+		if err != nil {
+			return nil, fmt.Errorf("音频转PCM失败: %v", err)
+		}
+		return bytes.Join(pcmSlices, nil), nil
+	}
+	return utils.ReadPCMDataFromWavFile(audioFile)
+}
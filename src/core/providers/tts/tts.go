@@ -94,6 +94,35 @@ func (p *BaseProvider) SetVoice(voice string) error {
 	return nil
 }
 
+// VoiceForLanguage 从支持的音色中选择适合朗读指定语言的音色
+// 当前音色的语言与之相同、未识别出语言或没有配置该语言的音色时返回空，继续使用当前音色
+func (p *BaseProvider) VoiceForLanguage(language string) string {
+	if language == "" {
+		return ""
+	}
+	candidate := ""
+	for _, v := range p.config.SupportedVoices {
+		if v.Name == p.config.Voice {
+			if v.Language == "" || v.Language == language {
+				return ""
+			}
+			continue
+		}
+		if candidate == "" && v.Language == language {
+			candidate = v.Name
+		}
+	}
+	return candidate
+}
+
+// VoiceOrDefault 返回本次合成使用的音色，voice为空时使用当前音色
+func (p *BaseProvider) VoiceOrDefault(voice string) string {
+	if voice == "" {
+		return p.config.Voice
+	}
+	return voice
+}
+
 // Cleanup 清理资源
 func (p *BaseProvider) Cleanup() error {
 	if p.deleteFile {
@@ -0,0 +1,39 @@
+package tts
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+)
+
+func TestVoiceForLanguage(t *testing.T) {
+	voices := []configs.VoiceInfo{
+		{Name: "xiaoyun", Language: "zh"},
+		{Name: "emma", Language: "en"},
+		{Name: "jenny", Language: "en"},
+		{Name: "multi"},
+	}
+
+	tests := []struct {
+		name     string
+		current  string
+		language string
+		expected string
+	}{
+		{name: "当前音色语言相同", current: "xiaoyun", language: "zh", expected: ""},
+		{name: "切换到第一个该语言的音色", current: "xiaoyun", language: "en", expected: "emma"},
+		{name: "未识别出语言", current: "xiaoyun", language: "", expected: ""},
+		{name: "没有配置该语言的音色", current: "xiaoyun", language: "ja", expected: ""},
+		{name: "当前音色未指定语言", current: "multi", language: "en", expected: ""},
+		{name: "当前音色不在列表中", current: "unknown", language: "zh", expected: "xiaoyun"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewBaseProvider(&Config{Voice: tt.current, SupportedVoices: voices}, false)
+			if result := p.VoiceForLanguage(tt.language); result != tt.expected {
+				t.Errorf("VoiceForLanguage(%q) = %q, want %q", tt.language, result, tt.expected)
+			}
+		})
+	}
+}
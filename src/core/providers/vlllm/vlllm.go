@@ -13,6 +13,7 @@ import (
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/image"
 	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 
 	"github.com/sashabaranov/go-openai"
@@ -146,70 +147,100 @@ func (p *Provider) Cleanup() error {
 	return nil
 }
 
-// ResponseWithImage 处理包含图片的请求 - 核心方法
+// ResponseWithImage 处理包含图片的请求，图片和text作为最后一条用户消息追加到messages之后
 func (p *Provider) ResponseWithImage(ctx context.Context, sessionID string, messages []providers.Message, imageData image.ImageData, text string) (<-chan string, error) {
+	visionMessage := types.NewMultimodalMessage(openai.ChatMessageRoleUser,
+		types.TextPart(text),
+		types.ImagePart(imageData.URL, imageData.Data, imageData.Format, ""),
+	)
+	return p.ResponseWithMessages(ctx, sessionID, append(messages[:len(messages):len(messages)], visionMessage))
+}
+
+// ResponseWithMessages 处理包含多模态消息的对话 - 核心方法
+func (p *Provider) ResponseWithMessages(ctx context.Context, sessionID string, messages []providers.Message) (<-chan string, error) {
 	// 处理图片
-	base64Image, err := p.imageProcessor.ProcessImage(ctx, imageData)
+	messages, err := p.processImages(ctx, messages)
 	if err != nil {
-		return nil, fmt.Errorf("图片处理失败: %v", err)
+		return nil, err
 	}
 
 	p.logger.Debug("开始调用多模态API %v", map[string]interface{}{
-		"type":       p.config.Type,
-		"model_name": p.config.ModelName,
-		"text":       text,
-		"image_size": len(base64Image),
+		"type":          p.config.Type,
+		"model_name":    p.config.ModelName,
+		"message_count": len(messages),
 	})
 
 	// 根据类型调用对应的多模态API
 	switch strings.ToLower(p.config.Type) {
 	case "openai":
-		return p.responseWithOpenAIVision(ctx, messages, base64Image, text, imageData.Format)
+		return p.responseWithOpenAIVision(ctx, messages)
 	case "ollama":
-		return p.responseWithOllamaVision(ctx, messages, base64Image, text, imageData.Format)
+		return p.responseWithOllamaVision(ctx, messages)
 	default:
 		return nil, fmt.Errorf("不支持的VLLLM类型: %s", p.config.Type)
 	}
 }
 
+// processImages 校验消息中的图片并统一转换为base64数据
+// 最后一条用户消息中的图片处理失败时返回错误，更早的图片（如URL已失效）改为使用文字说明
+func (p *Provider) processImages(ctx context.Context, messages []providers.Message) ([]providers.Message, error) {
+	lastUser := -1
+	for i, msg := range messages {
+		if msg.Role == openai.ChatMessageRoleUser {
+			lastUser = i
+		}
+	}
+
+	processed := make([]providers.Message, len(messages))
+	for i, msg := range messages {
+		processed[i] = msg
+		if !msg.HasMedia() {
+			continue
+		}
+		parts := make([]types.ContentPart, len(msg.Parts))
+		for j, part := range msg.Parts {
+			parts[j] = part
+			if part.Type != types.ContentPartImage || (part.URL == "" && part.Data == "") {
+				continue
+			}
+			base64Image, err := p.imageProcessor.ProcessImage(ctx, image.ImageData{
+				URL:    part.URL,
+				Data:   part.Data,
+				Format: part.Format,
+			})
+			if err != nil {
+				if i == lastUser {
+					return nil, fmt.Errorf("图片处理失败: %v", err)
+				}
+				p.logger.Warn("历史消息中的图片处理失败，改为使用文字说明: %v", err)
+				parts[j] = types.TextPart(part.TextForm())
+				continue
+			}
+			parts[j].URL, parts[j].Data = "", base64Image
+		}
+		processed[i].Parts = parts
+	}
+	return processed, nil
+}
+
 // responseWithOpenAIVision 使用OpenAI Vision API
-func (p *Provider) responseWithOpenAIVision(ctx context.Context, messages []providers.Message, base64Image string, text string, format string) (<-chan string, error) {
+func (p *Provider) responseWithOpenAIVision(ctx context.Context, messages []providers.Message) (<-chan string, error) {
 	responseChan := make(chan string, 10)
 
 	go func() {
 		defer close(responseChan)
 
 		// 构建OpenAI多模态消息
-		chatMessages := make([]openai.ChatCompletionMessage, 0, len(messages)+1)
-
-		// 添加历史消息
+		chatMessages := make([]openai.ChatCompletionMessage, 0, len(messages))
 		for _, msg := range messages {
+			content, parts := msg.OpenAIContent(true)
 			chatMessages = append(chatMessages, openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:         msg.Role,
+				Content:      content,
+				MultiContent: parts,
 			})
 		}
 
-		// 构建包含图片的多模态消息
-		visionMessage := openai.ChatCompletionMessage{
-			Role: openai.ChatMessageRoleUser,
-			MultiContent: []openai.ChatMessagePart{
-				{
-					Type: openai.ChatMessagePartTypeText,
-					Text: text,
-				},
-				{
-					Type: openai.ChatMessagePartTypeImageURL,
-					ImageURL: &openai.ChatMessageImageURL{
-						URL: fmt.Sprintf("data:image/%s;base64,%s", format, base64Image),
-					},
-				},
-			},
-		}
-		// 打印visionMessage的内容
-		p.logger.Debug("构建的OpenAI Vision消息: %v", visionMessage)
-		chatMessages = append(chatMessages, visionMessage)
-
 		// 调用OpenAI Vision API
 		stream, err := p.openaiClient.CreateChatCompletionStream(
 			ctx,
@@ -257,30 +288,32 @@ func (p *Provider) responseWithOpenAIVision(ctx context.Context, messages []prov
 }
 
 // responseWithOllamaVision 使用Ollama Vision API
-func (p *Provider) responseWithOllamaVision(ctx context.Context, messages []providers.Message, base64Image string, text string, format string) (<-chan string, error) {
+func (p *Provider) responseWithOllamaVision(ctx context.Context, messages []providers.Message) (<-chan string, error) {
 	responseChan := make(chan string, 10)
 
 	go func() {
 		defer close(responseChan)
 
-		// 构建Ollama请求
-		ollamaMessages := make([]OllamaMessage, 0, len(messages)+1)
-
-		// 添加历史消息
+		// 构建Ollama请求，图片放在消息的images字段，其余片段拼接为文本
+		ollamaMessages := make([]OllamaMessage, 0, len(messages))
 		for _, msg := range messages {
-			ollamaMessages = append(ollamaMessages, OllamaMessage{
+			ollamaMessage := OllamaMessage{
 				Role:    msg.Role,
 				Content: msg.Content,
-			})
-		}
-
-		// 添加包含图片的用户消息
-		visionMessage := OllamaMessage{
-			Role:    "user",
-			Content: text,
-			Images:  []string{base64Image}, // Ollama需要纯base64，不需要data URL前缀
+			}
+			if msg.HasMedia() {
+				var texts []string
+				for _, part := range msg.Parts {
+					if part.Type == types.ContentPartImage && part.Data != "" {
+						ollamaMessage.Images = append(ollamaMessage.Images, part.Data) // Ollama需要纯base64，不需要data URL前缀
+					} else if text := part.TextForm(); text != "" {
+						texts = append(texts, text)
+					}
+				}
+				ollamaMessage.Content = strings.Join(texts, " ")
+			}
+			ollamaMessages = append(ollamaMessages, ollamaMessage)
 		}
-		ollamaMessages = append(ollamaMessages, visionMessage)
 
 		// 构建请求
 		request := OllamaRequest{
@@ -313,9 +346,9 @@ func (p *Provider) responseWithOllamaVision(ctx context.Context, messages []prov
 		req.Header.Set("Content-Type", "application/json")
 
 		p.logger.Info("向Ollama发送多模态请求", map[string]interface{}{
-			"url":   url,
-			"model": p.config.ModelName,
-			"text":  text,
+			"url":           url,
+			"model":         p.config.ModelName,
+			"message_count": len(ollamaMessages),
 		})
 
 		resp, err := p.httpClient.Do(req)
@@ -0,0 +1,322 @@
+// Package quota 按用户级别限制每日的LLM token、TTS字符、ASR时长和视觉调用次数
+// 用量先在内存中累计，定期写入存储，每天零点（服务器时区）重置
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/task"
+)
+
+// Resource 计量的资源
+type Resource string
+
+const (
+	ResourceLLMTokens   Resource = "llm_tokens"   // LLM请求和回复的token数（估算）
+	ResourceTTSChars    Resource = "tts_chars"    // 语音合成的字符数
+	ResourceASRSeconds  Resource = "asr_seconds"  // 语音识别的音频秒数
+	ResourceVisionCalls Resource = "vision_calls" // 图片识别的调用次数
+)
+
+// Resources 全部计量的资源
+var Resources = []Resource{ResourceLLMTokens, ResourceTTSChars, ResourceASRSeconds, ResourceVisionCalls}
+
+const (
+	dayLayout     = "2006-01-02"
+	flushInterval = 30 * time.Second
+	storeTimeout  = 5 * time.Second
+)
+
+// Limits 每日用量上限，未设置或为0的资源不限制
+type Limits map[Resource]int64
+
+// DefaultTiers 各用户级别默认的每日用量上限
+var DefaultTiers = map[task.UserLevel]Limits{
+	task.UserLevelBasic: {
+		ResourceLLMTokens:   200000,
+		ResourceTTSChars:    20000,
+		ResourceASRSeconds:  3600,
+		ResourceVisionCalls: 50,
+	},
+	task.UserLevelPremium: {
+		ResourceLLMTokens:   1000000,
+		ResourceTTSChars:    100000,
+		ResourceASRSeconds:  4 * 3600,
+		ResourceVisionCalls: 300,
+	},
+	task.UserLevelBusiness: {
+		ResourceLLMTokens:   5000000,
+		ResourceTTSChars:    500000,
+		ResourceASRSeconds:  12 * 3600,
+		ResourceVisionCalls: 2000,
+	},
+}
+
+// Store 用量和用户级别的存储接口
+type Store interface {
+	// LoadUsage 读取用户某天的用量
+	LoadUsage(ctx context.Context, userKey, day string) (map[Resource]int64, error)
+
+	// AddUsage 累加用户某天的用量
+	AddUsage(ctx context.Context, userKey, day string, usage map[Resource]int64) error
+
+	// LoadTier 读取用户级别，未设置时返回空字符串
+	LoadTier(ctx context.Context, userKey string) (task.UserLevel, error)
+
+	// SaveTier 设置用户级别
+	SaveTier(ctx context.Context, userKey string, tier task.UserLevel) error
+}
+
+// ExceededError 用户当日的某项用量已达上限
+type ExceededError struct {
+	Resource Resource
+	Used     int64
+	Limit    int64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("今日%s用量已达上限: %d/%d", e.Resource, e.Used, e.Limit)
+}
+
+// Usage 用户当日的用量
+type Usage struct {
+	UserKey string             `json:"user_key"`
+	Day     string             `json:"day"`
+	Tier    task.UserLevel     `json:"tier"`
+	Used    map[Resource]int64 `json:"used"`
+	Limits  Limits             `json:"limits"`
+	Remain  map[Resource]int64 `json:"remain"` // 不限制的资源不出现在其中
+}
+
+// account 缓存的用户当日用量
+type account struct {
+	day     string
+	tier    task.UserLevel
+	used    map[Resource]int64
+	pending map[Resource]int64 // 尚未写入存储的用量
+}
+
+// Config 用量配额配置
+type Config struct {
+	DefaultTier task.UserLevel            // 未设置级别的用户使用的级别
+	Tiers       map[task.UserLevel]Limits // 覆盖各级别默认的上限
+}
+
+// Manager 用量配额管理器，所有连接共享，按用户缓存当日用量
+type Manager struct {
+	store       Store
+	logger      *utils.Logger
+	defaultTier task.UserLevel
+	tiers       map[task.UserLevel]Limits
+
+	mu       sync.Mutex
+	accounts map[string]*account
+}
+
+// NewManager 创建用量配额管理器
+func NewManager(store Store, logger *utils.Logger, config Config) *Manager {
+	m := &Manager{
+		store:       store,
+		logger:      logger,
+		defaultTier: task.UserLevelBasic,
+		tiers:       make(map[task.UserLevel]Limits),
+		accounts:    make(map[string]*account),
+	}
+	for tier, limits := range DefaultTiers {
+		m.tiers[tier] = copyLimits(limits)
+	}
+	for tier, limits := range config.Tiers {
+		if m.tiers[tier] == nil {
+			m.tiers[tier] = make(Limits)
+		}
+		for resource, limit := range limits {
+			m.tiers[tier][resource] = limit
+		}
+	}
+	if config.DefaultTier != "" {
+		m.defaultTier = config.DefaultTier
+	}
+	return m
+}
+
+// Run 定期将用量写入存储，ctx结束时写入剩余的用量后返回
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.Flush()
+			return
+		case <-ticker.C:
+			m.Flush()
+		}
+	}
+}
+
+// Check 检查用户当日的用量，任一资源达到上限时返回*ExceededError
+func (m *Manager) Check(userKey string, resources ...Resource) error {
+	acc := m.lockAccount(userKey)
+	defer m.mu.Unlock()
+	limits := m.tiers[acc.tier]
+	for _, resource := range resources {
+		if limit := limits[resource]; limit > 0 && acc.used[resource] >= limit {
+			return &ExceededError{Resource: resource, Used: acc.used[resource], Limit: limit}
+		}
+	}
+	return nil
+}
+
+// Consume 记录用户的用量，只累计不拦截，是否超限由Check在请求前判断
+func (m *Manager) Consume(userKey string, resource Resource, amount int64) {
+	if amount <= 0 {
+		return
+	}
+	acc := m.lockAccount(userKey)
+	defer m.mu.Unlock()
+	acc.used[resource] += amount
+	acc.pending[resource] += amount
+}
+
+// Usage 查询用户当日的用量
+func (m *Manager) Usage(userKey string) *Usage {
+	acc := m.lockAccount(userKey)
+	defer m.mu.Unlock()
+	usage := &Usage{
+		UserKey: userKey,
+		Day:     acc.day,
+		Tier:    acc.tier,
+		Used:    make(map[Resource]int64, len(Resources)),
+		Limits:  copyLimits(m.tiers[acc.tier]),
+		Remain:  make(map[Resource]int64),
+	}
+	for _, resource := range Resources {
+		usage.Used[resource] = acc.used[resource]
+		if limit := usage.Limits[resource]; limit > 0 {
+			usage.Remain[resource] = max(limit-acc.used[resource], 0)
+		}
+	}
+	return usage
+}
+
+// SetTier 调整用户级别，立即生效
+func (m *Manager) SetTier(userKey string, tier task.UserLevel) error {
+	if _, ok := m.tiers[tier]; !ok {
+		return fmt.Errorf("未知的用户级别: %s", tier)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.store.SaveTier(ctx, userKey, tier); err != nil {
+		return err
+	}
+
+	m.lockAccount(userKey).tier = tier
+	m.mu.Unlock()
+	return nil
+}
+
+// Tiers 各用户级别的每日用量上限
+func (m *Manager) Tiers() map[task.UserLevel]Limits {
+	tiers := make(map[task.UserLevel]Limits, len(m.tiers))
+	for tier, limits := range m.tiers {
+		tiers[tier] = copyLimits(limits)
+	}
+	return tiers
+}
+
+// Flush 将尚未写入的用量写入存储，并清理不在当天的缓存
+func (m *Manager) Flush() {
+	type batch struct {
+		userKey, day string
+		usage        map[Resource]int64
+	}
+	today := time.Now().Format(dayLayout)
+
+	m.mu.Lock()
+	var batches []batch
+	for userKey, acc := range m.accounts {
+		if len(acc.pending) > 0 {
+			batches = append(batches, batch{userKey: userKey, day: acc.day, usage: acc.pending})
+			acc.pending = make(map[Resource]int64)
+		}
+		if acc.day != today {
+			delete(m.accounts, userKey)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, b := range batches {
+		m.save(b.userKey, b.day, b.usage)
+	}
+}
+
+// lockAccount 加锁并获取用户当日的缓存，未缓存或跨天时在锁外从存储加载，调用方负责解锁
+func (m *Manager) lockAccount(userKey string) *account {
+	today := time.Now().Format(dayLayout)
+	m.mu.Lock()
+	if acc, ok := m.accounts[userKey]; ok && acc.day == today {
+		return acc
+	}
+	m.mu.Unlock()
+
+	loaded := m.load(userKey, today)
+
+	m.mu.Lock()
+	acc, ok := m.accounts[userKey]
+	if ok && acc.day == today {
+		// 加载期间其他连接已经缓存
+		return acc
+	}
+	if ok && len(acc.pending) > 0 {
+		// 前一天的用量尚未写入，在后台补写
+		go m.save(userKey, acc.day, acc.pending)
+	}
+	m.accounts[userKey] = loaded
+	return loaded
+}
+
+// load 从存储加载用户的级别和某天的用量，读取失败时按默认级别和零用量处理
+func (m *Manager) load(userKey, day string) *account {
+	acc := &account{
+		day:     day,
+		tier:    m.defaultTier,
+		used:    make(map[Resource]int64),
+		pending: make(map[Resource]int64),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if tier, err := m.store.LoadTier(ctx, userKey); err != nil {
+		m.logger.Error("读取用户 %s 的级别失败: %v", userKey, err)
+	} else if _, known := m.tiers[tier]; known {
+		acc.tier = tier
+	}
+	if used, err := m.store.LoadUsage(ctx, userKey, day); err != nil {
+		m.logger.Error("读取用户 %s 的用量失败: %v", userKey, err)
+	} else {
+		for resource, amount := range used {
+			acc.used[resource] = amount
+		}
+	}
+	return acc
+}
+
+// save 将用量写入存储
+func (m *Manager) save(userKey, day string, usage map[Resource]int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.store.AddUsage(ctx, userKey, day, usage); err != nil {
+		m.logger.Error("保存用户 %s 的用量失败: %v", userKey, err)
+	}
+}
+
+func copyLimits(limits Limits) Limits {
+	copied := make(Limits, len(limits))
+	for resource, limit := range limits {
+		copied[resource] = limit
+	}
+	return copied
+}
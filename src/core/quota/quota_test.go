@@ -0,0 +1,217 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/task"
+)
+
+// addCall 一次AddUsage调用
+type addCall struct {
+	userKey, day string
+	usage        map[Resource]int64
+}
+
+// memoryStore 内存中的用量存储，记录每次写入
+type memoryStore struct {
+	mu    sync.Mutex
+	usage map[string]map[Resource]int64 // userKey/day
+	tiers map[string]task.UserLevel
+	adds  chan addCall
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		usage: make(map[string]map[Resource]int64),
+		tiers: make(map[string]task.UserLevel),
+		adds:  make(chan addCall, 10),
+	}
+}
+
+func (s *memoryStore) LoadUsage(ctx context.Context, userKey, day string) (map[Resource]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used := make(map[Resource]int64)
+	for resource, amount := range s.usage[userKey+"/"+day] {
+		used[resource] = amount
+	}
+	return used, nil
+}
+
+func (s *memoryStore) AddUsage(ctx context.Context, userKey, day string, usage map[Resource]int64) error {
+	s.mu.Lock()
+	key := userKey + "/" + day
+	if s.usage[key] == nil {
+		s.usage[key] = make(map[Resource]int64)
+	}
+	for resource, amount := range usage {
+		s.usage[key][resource] += amount
+	}
+	s.mu.Unlock()
+	s.adds <- addCall{userKey: userKey, day: day, usage: usage}
+	return nil
+}
+
+func (s *memoryStore) LoadTier(ctx context.Context, userKey string) (task.UserLevel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tiers[userKey], nil
+}
+
+func (s *memoryStore) SaveTier(ctx context.Context, userKey string, tier task.UserLevel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tiers[userKey] = tier
+	return nil
+}
+
+func newTestManager(t *testing.T, store Store) *Manager {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	return NewManager(store, logger, Config{
+		Tiers: map[task.UserLevel]Limits{
+			task.UserLevelBasic:   {ResourceLLMTokens: 100, ResourceVisionCalls: 0},
+			task.UserLevelPremium: {ResourceLLMTokens: 1000},
+		},
+	})
+}
+
+func TestCheckAndConsume(t *testing.T) {
+	today := time.Now().Format(dayLayout)
+	tests := []struct {
+		name     string
+		tier     task.UserLevel
+		stored   int64 // 存储中当天已有的LLM用量
+		consume  []int64
+		resource Resource
+		wantErr  bool
+	}{
+		{name: "未达上限", consume: []int64{50, 49}, resource: ResourceLLMTokens, wantErr: false},
+		{name: "达到上限", consume: []int64{50, 50}, resource: ResourceLLMTokens, wantErr: true},
+		{name: "加上存储中已有的用量", stored: 80, consume: []int64{20}, resource: ResourceLLMTokens, wantErr: true},
+		{name: "上限为0不限制", consume: []int64{1000000}, resource: ResourceVisionCalls, wantErr: false},
+		{name: "非正数不计入", consume: []int64{-200, 0, 99}, resource: ResourceLLMTokens, wantErr: false},
+		{name: "存储中的用户级别", tier: task.UserLevelPremium, consume: []int64{500}, resource: ResourceLLMTokens, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			if tt.tier != "" {
+				store.tiers["u1"] = tt.tier
+			}
+			if tt.stored > 0 {
+				store.usage["u1/"+today] = map[Resource]int64{ResourceLLMTokens: tt.stored}
+			}
+			m := newTestManager(t, store)
+			for _, amount := range tt.consume {
+				m.Consume("u1", tt.resource, amount)
+			}
+
+			err := m.Check("u1", ResourceTTSChars, tt.resource)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var exceeded *ExceededError
+			if err != nil && (!errors.As(err, &exceeded) || exceeded.Resource != tt.resource) {
+				t.Errorf("Check() error = %v, want *ExceededError for %s", err, tt.resource)
+			}
+		})
+	}
+}
+
+func TestDayRollover(t *testing.T) {
+	store := newMemoryStore()
+	m := newTestManager(t, store)
+	m.Consume("u1", ResourceLLMTokens, 100)
+	if err := m.Check("u1", ResourceLLMTokens); err == nil {
+		t.Fatal("Check() 达到上限后 error = nil")
+	}
+
+	// 模拟缓存的用量属于前一天
+	m.mu.Lock()
+	m.accounts["u1"].day = "2000-01-01"
+	m.mu.Unlock()
+
+	if err := m.Check("u1", ResourceLLMTokens); err != nil {
+		t.Errorf("跨天后 Check() error = %v, 新的一天应重新计算", err)
+	}
+	if used := m.Usage("u1").Used[ResourceLLMTokens]; used != 0 {
+		t.Errorf("跨天后用量 = %d, want 0", used)
+	}
+
+	select {
+	case call := <-store.adds:
+		want := addCall{userKey: "u1", day: "2000-01-01", usage: map[Resource]int64{ResourceLLMTokens: 100}}
+		if !reflect.DeepEqual(call, want) {
+			t.Errorf("补写前一天的用量 = %+v, want %+v", call, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("前一天尚未写入的用量没有在后台补写")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	store := newMemoryStore()
+	m := newTestManager(t, store)
+	today := time.Now().Format(dayLayout)
+
+	m.Consume("u1", ResourceLLMTokens, 10)
+	m.Consume("u1", ResourceLLMTokens, 20)
+	m.Consume("u1", ResourceTTSChars, 5)
+	m.Consume("u2", ResourceASRSeconds, 3)
+	m.Check("u3") // 只读取没有用量
+
+	m.Flush()
+	close(store.adds)
+	calls := make(map[string]addCall)
+	for call := range store.adds {
+		if _, ok := calls[call.userKey]; ok {
+			t.Errorf("用户 %s 的用量写入了多次", call.userKey)
+		}
+		calls[call.userKey] = call
+	}
+	want := map[string]addCall{
+		"u1": {userKey: "u1", day: today, usage: map[Resource]int64{ResourceLLMTokens: 30, ResourceTTSChars: 5}},
+		"u2": {userKey: "u2", day: today, usage: map[Resource]int64{ResourceASRSeconds: 3}},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Flush() 写入 = %+v, want %+v", calls, want)
+	}
+
+	// 已写入的用量不会重复写入，缓存的用量保留
+	store.adds = make(chan addCall, 10)
+	m.Flush()
+	if len(store.adds) != 0 {
+		t.Errorf("再次 Flush() 写入了 %d 次, want 0", len(store.adds))
+	}
+	if used := m.Usage("u1").Used[ResourceLLMTokens]; used != 30 {
+		t.Errorf("Flush() 后用量 = %d, want 30", used)
+	}
+
+	// 不在当天的缓存被清理
+	m.Consume("u2", ResourceASRSeconds, 1)
+	m.mu.Lock()
+	m.accounts["u2"].day = "2000-01-01"
+	m.mu.Unlock()
+	m.Flush()
+	if call := <-store.adds; call.day != "2000-01-01" || call.usage[ResourceASRSeconds] != 1 {
+		t.Errorf("跨天缓存写入 = %+v, want 2000-01-01 的 1 秒", call)
+	}
+	m.mu.Lock()
+	_, cached := m.accounts["u2"]
+	m.mu.Unlock()
+	if cached {
+		t.Error("Flush() 没有清理不在当天的缓存")
+	}
+}
@@ -0,0 +1,282 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+// Kind 录制事件类型
+type Kind string
+
+const (
+	KindSession     Kind = "session"      // 会话信息，始终是存档的第一条事件
+	KindInbound     Kind = "inbound"      // 客户端发来的文本消息或音频帧
+	KindOutbound    Kind = "outbound"     // 发送给客户端的文本消息，音频帧只记录大小
+	KindASR         Kind = "asr_result"   // ASR识别结果
+	KindLLMRequest  Kind = "llm_request"  // 请求LLM的消息和工具
+	KindLLMResponse Kind = "llm_response" // LLM返回的流式响应
+	KindToolCall    Kind = "tool_call"    // 工具调用及其结果
+)
+
+// 与websocket消息类型保持一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// SessionInfo 录制会话的连接信息，回放时用于构造相同的连接处理器
+type SessionInfo struct {
+	SessionID string            `json:"session_id"`
+	DeviceID  string            `json:"device_id,omitempty"`
+	ClientID  string            `json:"client_id,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	StartTime time.Time         `json:"start_time"`
+}
+
+// ToolCallRecord 一次工具调用的记录
+type ToolCallRecord struct {
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments,omitempty"`
+	Action     int    `json:"action"` // types.Action
+	Result     string `json:"result,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Event 存档中的一条事件，按发生顺序逐行写入
+type Event struct {
+	OffsetMs    int64            `json:"offset_ms"` // 距录制开始的毫秒数
+	Kind        Kind             `json:"kind"`
+	Round       int              `json:"round,omitempty"`
+	MessageType int              `json:"message_type,omitempty"`
+	Text        string           `json:"text,omitempty"`
+	Audio       []byte           `json:"audio,omitempty"`
+	Size        int              `json:"size,omitempty"`
+	AudioFrames int              `json:"audio_frames,omitempty"` // ASR结果产生前收到的音频帧数
+	Session     *SessionInfo     `json:"session,omitempty"`
+	Messages    []types.Message  `json:"messages,omitempty"`
+	Tools       []string         `json:"tools,omitempty"`
+	Chunks      []types.Response `json:"chunks,omitempty"`
+	Tool        *ToolCallRecord  `json:"tool,omitempty"`
+}
+
+// Recorder 会话录制器，将一个连接的输入、识别结果、LLM交互、工具调用和输出事件写入JSON Lines存档
+// 所有方法都可以在nil上调用，未启用录制时调用方无需判断
+type Recorder struct {
+	mu          sync.Mutex
+	file        *os.File
+	writer      *bufio.Writer
+	encoder     *json.Encoder
+	path        string
+	start       time.Time
+	audioFrames int
+	closed      bool
+}
+
+// New 在dir目录下创建会话存档并写入会话信息
+func New(dir string, session SessionInfo) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %v", err)
+	}
+	if session.StartTime.IsZero() {
+		session.StartTime = time.Now()
+	}
+	name := fmt.Sprintf("%s_%s.jsonl",
+		strings.NewReplacer("/", "_", ":", "_").Replace(session.SessionID),
+		session.StartTime.Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	return Create(path, session)
+}
+
+// sensitiveHeaders 不写入存档的请求头，回放时也不会再发送
+var sensitiveHeaders = []string{"authorization", "proxy-authorization", "cookie", "set-cookie"}
+
+// sensitiveHeaderWords 名称包含这些词的请求头视为凭证，不写入存档
+var sensitiveHeaderWords = []string{"token", "secret", "password", "key", "signature"}
+
+// RedactHeaders 返回去掉认证信息、Cookie和各类令牌后的请求头
+func RedactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if !isSensitiveHeader(key) {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+func isSensitiveHeader(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range sensitiveHeaders {
+		if key == name {
+			return true
+		}
+	}
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Create 在指定路径创建会话存档并写入会话信息，请求头中的凭证不会写入存档
+func Create(path string, session SessionInfo) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建录制文件失败: %v", err)
+	}
+	if session.StartTime.IsZero() {
+		session.StartTime = time.Now()
+	}
+	session.Headers = RedactHeaders(session.Headers)
+
+	writer := bufio.NewWriter(file)
+	r := &Recorder{
+		file:    file,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		path:    path,
+		start:   session.StartTime,
+	}
+	r.record(Event{Kind: KindSession, Session: &session})
+	return r, nil
+}
+
+// Path 存档文件路径
+func (r *Recorder) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// Inbound 记录客户端发来的消息
+func (r *Recorder) Inbound(messageType int, data []byte) {
+	if r == nil {
+		return
+	}
+	event := Event{Kind: KindInbound, MessageType: messageType}
+	if messageType == BinaryMessage {
+		event.Audio = data
+		r.mu.Lock()
+		r.audioFrames++
+		r.mu.Unlock()
+	} else {
+		event.Text = string(data)
+	}
+	r.record(event)
+}
+
+// Outbound 记录发送给客户端的消息，音频帧只记录大小
+func (r *Recorder) Outbound(messageType int, data []byte) {
+	if r == nil {
+		return
+	}
+	event := Event{Kind: KindOutbound, MessageType: messageType}
+	if messageType == BinaryMessage {
+		event.Size = len(data)
+	} else {
+		event.Text = string(data)
+	}
+	r.record(event)
+}
+
+// ASRResult 记录ASR识别结果以及此前收到的音频帧数
+func (r *Recorder) ASRResult(text string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	frames := r.audioFrames
+	r.mu.Unlock()
+	r.record(Event{Kind: KindASR, Text: text, AudioFrames: frames})
+}
+
+// LLMRequest 记录请求LLM的消息和可用工具名称
+func (r *Recorder) LLMRequest(round int, messages []types.Message, tools []string) {
+	if r == nil {
+		return
+	}
+	r.record(Event{
+		Kind:     KindLLMRequest,
+		Round:    round,
+		Messages: append([]types.Message(nil), messages...),
+		Tools:    tools,
+	})
+}
+
+// LLMResponse 记录LLM一次请求返回的全部流式响应
+func (r *Recorder) LLMResponse(round int, chunks []types.Response) {
+	if r == nil {
+		return
+	}
+	r.record(Event{Kind: KindLLMResponse, Round: round, Chunks: chunks})
+}
+
+// ToolCall 记录一次工具调用
+func (r *Recorder) ToolCall(round int, call ToolCallRecord) {
+	if r == nil {
+		return
+	}
+	r.record(Event{Kind: KindToolCall, Round: round, Tool: &call})
+}
+
+// Close 写入缓冲并关闭存档，关闭后的记录会被忽略
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+func (r *Recorder) record(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	event.OffsetMs = time.Since(r.start).Milliseconds()
+	// 写入失败不影响对话流程，存档会缺少该事件
+	_ = r.encoder.Encode(event)
+}
+
+// Load 读取会话存档中的全部事件
+func Load(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			return nil, fmt.Errorf("解析第 %d 条事件失败: %v", len(events)+1, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 || events[0].Kind != KindSession || events[0].Session == nil {
+		return nil, fmt.Errorf("存档缺少会话信息: %s", path)
+	}
+	return events, nil
+}
@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected map[string]string
+	}{
+		{
+			name:     "去掉认证信息和Cookie",
+			headers:  map[string]string{"Authorization": "Bearer abc", "Cookie": "a=1", "Device-Id": "aa:bb"},
+			expected: map[string]string{"Device-Id": "aa:bb"},
+		},
+		{
+			name:     "名称包含凭证关键词的请求头",
+			headers:  map[string]string{"Token": "abc", "X-Access-Token": "abc", "X-Api-Key": "k", "Client-Secret": "s", "Client-Id": "web"},
+			expected: map[string]string{"Client-Id": "web"},
+		},
+		{
+			name:     "不区分大小写",
+			headers:  map[string]string{"PROXY-AUTHORIZATION": "Basic abc", "x-signature": "sig", "Session-Id": "s1"},
+			expected: map[string]string{"Session-Id": "s1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := RedactHeaders(tt.headers); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("RedactHeaders(%v) = %v, want %v", tt.headers, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRecordAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	rec, err := Create(path, SessionInfo{
+		SessionID: "device-aa_bb",
+		DeviceID:  "aa:bb",
+		Headers: map[string]string{
+			"Authorization":  "Bearer abc",
+			"X-Access-Token": "abc",
+			"Device-Id":      "aa:bb",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	rec.Inbound(TextMessage, []byte(`{"type":"hello"}`))
+	rec.Inbound(BinaryMessage, []byte{1, 2, 3})
+	rec.ASRResult("今天天气怎么样")
+	rec.LLMRequest(1, []types.Message{{Role: "user", Content: "今天天气怎么样"}}, []string{"get_weather"})
+	rec.LLMResponse(1, []types.Response{{Content: "晴天"}})
+	rec.ToolCall(1, ToolCallRecord{Name: "get_weather", Arguments: `{"city":"北京"}`, Action: int(types.ActionTypeReqLLM), Result: "晴"})
+	rec.Outbound(BinaryMessage, make([]byte, 10))
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	rec.Outbound(TextMessage, []byte("关闭后的记录"))
+
+	events, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var kinds []Kind
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	expectedKinds := []Kind{KindSession, KindInbound, KindInbound, KindASR, KindLLMRequest, KindLLMResponse, KindToolCall, KindOutbound}
+	if !reflect.DeepEqual(kinds, expectedKinds) {
+		t.Fatalf("事件类型 = %v, want %v", kinds, expectedKinds)
+	}
+
+	if headers := events[0].Session.Headers; !reflect.DeepEqual(headers, map[string]string{"Device-Id": "aa:bb"}) {
+		t.Errorf("存档中的请求头 = %v, 不应包含凭证", headers)
+	}
+	if events[1].Text != `{"type":"hello"}` || !reflect.DeepEqual(events[2].Audio, []byte{1, 2, 3}) {
+		t.Errorf("客户端消息 = %q / %v", events[1].Text, events[2].Audio)
+	}
+	if events[3].AudioFrames != 1 {
+		t.Errorf("ASR结果前的音频帧数 = %d, want 1", events[3].AudioFrames)
+	}
+	if events[5].Chunks[0].Content != "晴天" {
+		t.Errorf("LLM响应 = %+v", events[5].Chunks)
+	}
+	if tool := events[6].Tool; tool == nil || tool.Name != "get_weather" || tool.Result != "晴" {
+		t.Errorf("工具调用 = %+v", tool)
+	}
+	if events[7].Size != 10 || events[7].Audio != nil {
+		t.Errorf("下发音频帧 = size %d, audio %v, 只应记录大小", events[7].Size, events[7].Audio)
+	}
+}
@@ -0,0 +1,375 @@
+// Package reminder 实现计时器、闹钟和提醒
+// 提醒作为定时任务交给task.TaskManager调度，到期时通过设备当前连接的安全回调播报，
+// 设备不在线时暂存，在设备下次连接时补发。提醒保存在Store中，送达或取消后删除，
+// 服务重启后通过Restore恢复未到期和未送达的提醒。
+// 用户设置提醒的工具需要在local_mcp_fun中加入reminder
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/task"
+
+	"github.com/google/uuid"
+)
+
+// TaskType 提醒使用的定时任务类型
+const TaskType task.TaskType = "reminder"
+
+// 提醒种类
+const (
+	KindTimer    = "timer"    // 计时器，经过一段时间后提醒
+	KindAlarm    = "alarm"    // 闹钟，在指定时刻提醒
+	KindReminder = "reminder" // 在指定时间提醒某件事
+)
+
+const (
+	// maxPending 每个设备最多暂存的未送达提醒条数，超过时丢弃最早的提醒
+	maxPending = 20
+	// idLength 提醒短ID的长度，用户按短ID取消提醒
+	idLength = 6
+)
+
+// Reminder 一条提醒
+type Reminder struct {
+	ID        string // 短ID，列出和取消提醒时使用
+	Kind      string
+	Content   string // 提醒的内容，计时器和闹钟可以为空
+	DueAt     time.Time
+	CreatedAt time.Time
+	DeviceKey string // 提醒所属的设备
+
+	taskID string
+}
+
+// Speech 到期时播报的文本，late表示设备离线期间到期、在重新连接后补发
+func (r *Reminder) Speech(late bool) string {
+	var text string
+	switch r.Kind {
+	case KindTimer:
+		text = "计时时间到了"
+	case KindAlarm:
+		text = "闹钟响了，现在是" + r.DueAt.Format("15点04分")
+	default:
+		text = "提醒你一下"
+	}
+	if r.Content != "" {
+		text += "：" + r.Content
+	}
+	if late {
+		text = fmt.Sprintf("你不在的时候有一条%s的提醒，%s", r.DueAt.Format("1月2日15:04"), text)
+	}
+	return text + "。"
+}
+
+// Describe 列出提醒时的描述
+func (r *Reminder) Describe() string {
+	names := map[string]string{KindTimer: "计时器", KindAlarm: "闹钟", KindReminder: "提醒"}
+	text := fmt.Sprintf("%s(编号%s) %s", names[r.Kind], r.ID, r.DueAt.Format("1月2日15:04"))
+	if r.Content != "" {
+		text += " " + r.Content
+	}
+	return text
+}
+
+// Store 提醒的持久化存储
+type Store interface {
+	// List 按到期时间列出全部设备的提醒
+	List(ctx context.Context) ([]*Reminder, error)
+
+	// Save 保存新创建的提醒
+	Save(ctx context.Context, r *Reminder) error
+
+	// Delete 删除设备的指定提醒
+	Delete(ctx context.Context, deviceKey string, ids []string) error
+}
+
+// Deliverer 向设备当前的连接播报提醒，连接已关闭无法播报时返回false
+type Deliverer func(r *Reminder, late bool) bool
+
+// device 设备当前的连接
+type device struct {
+	seq     uint64 // 连接序号，设备重连后会话ID可能不变，按序号区分新旧连接
+	deliver Deliverer
+}
+
+// Manager 提醒管理器，所有连接共享，按设备保存提醒
+type Manager struct {
+	taskMgr *task.TaskManager
+	store   Store
+	logger  *utils.Logger
+
+	mu        sync.Mutex
+	scheduled map[string]map[string]*Reminder // 设备 -> 短ID -> 未到期的提醒
+	pending   map[string][]*Reminder          // 设备 -> 离线期间到期的提醒
+	online    map[string]device               // 设备 -> 当前连接
+	seq       uint64
+}
+
+// NewManager 创建提醒管理器，并注册提醒任务的执行器，store为nil时提醒只保存在内存中
+func NewManager(taskMgr *task.TaskManager, store Store, logger *utils.Logger) *Manager {
+	task.RegisterTaskExecutor(TaskType, func(t *task.Task) error {
+		t.Result = t.Params
+		return nil
+	})
+	return &Manager{
+		taskMgr:   taskMgr,
+		store:     store,
+		logger:    logger,
+		scheduled: make(map[string]map[string]*Reminder),
+		pending:   make(map[string][]*Reminder),
+		online:    make(map[string]device),
+	}
+}
+
+// Schedule 为设备创建一条提醒，到期时间必须晚于当前时间
+func (m *Manager) Schedule(deviceKey, kind, content string, dueAt time.Time) (*Reminder, error) {
+	if !dueAt.After(time.Now()) {
+		return nil, fmt.Errorf("提醒时间已经过去: %s", dueAt.Format("2006-01-02 15:04:05"))
+	}
+
+	r := &Reminder{
+		ID:        m.newID(deviceKey),
+		Kind:      kind,
+		Content:   content,
+		DueAt:     dueAt,
+		CreatedAt: time.Now(),
+		DeviceKey: deviceKey,
+	}
+	if err := m.submit(r); err != nil {
+		return nil, err
+	}
+	if m.store != nil {
+		if err := m.store.Save(context.Background(), r); err != nil {
+			m.logger.Warn("保存提醒 %s 失败，服务重启后将丢失: %v", r.ID, err)
+		}
+	}
+	m.logger.Info("设备 %s 创建%s %s，到期时间 %s", deviceKey, kind, r.ID, dueAt.Format("2006-01-02 15:04:05"))
+	return r, nil
+}
+
+// Restore 从存储中恢复提醒，未到期的重新提交定时任务，已经到期的暂存到设备下次连接时补发
+func (m *Manager) Restore(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	reminders, err := m.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("加载提醒失败: %w", err)
+	}
+
+	now := time.Now()
+	scheduled := 0
+	for _, r := range reminders {
+		if !r.DueAt.After(now) {
+			m.requeue(r.DeviceKey, []*Reminder{r})
+			continue
+		}
+		if err := m.submit(r); err != nil {
+			m.logger.Warn("恢复设备 %s 的提醒 %s 失败: %v", r.DeviceKey, r.ID, err)
+			continue
+		}
+		scheduled++
+	}
+	m.logger.Info("已恢复%d条未到期的提醒，%d条提醒将在设备下次连接时补发", scheduled, len(reminders)-scheduled)
+	return nil
+}
+
+// submit 登记提醒并提交定时任务
+func (m *Manager) submit(r *Reminder) error {
+	// 提醒需要在连接断开后继续等待，不使用连接的上下文
+	t, taskID := task.NewTask(context.Background(), TaskType, r)
+	dueAt := r.DueAt
+	t.ScheduledTime = &dueAt
+	t.Callback = task.NewCallBack(func(result interface{}) {
+		if _, ok := result.(*Reminder); !ok {
+			m.logger.Warn("提醒任务执行异常: %v, 仍然播报提醒 %s", result, r.ID)
+		}
+		m.fire(r)
+	})
+	r.taskID = taskID
+
+	// 先登记再提交，避免到期回调早于登记
+	m.mu.Lock()
+	if m.scheduled[r.DeviceKey] == nil {
+		m.scheduled[r.DeviceKey] = make(map[string]*Reminder)
+	}
+	m.scheduled[r.DeviceKey][r.ID] = r
+	m.mu.Unlock()
+
+	if err := m.taskMgr.SubmitTask(r.DeviceKey, t); err != nil {
+		m.mu.Lock()
+		delete(m.scheduled[r.DeviceKey], r.ID)
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// List 按到期时间列出设备未到期的提醒
+func (m *Manager) List(deviceKey string) []*Reminder {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reminders := make([]*Reminder, 0, len(m.scheduled[deviceKey]))
+	for _, r := range m.scheduled[deviceKey] {
+		reminders = append(reminders, r)
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].DueAt.Before(reminders[j].DueAt)
+	})
+	return reminders
+}
+
+// Cancel 取消设备的一条提醒，提醒不存在或已经到期时返回nil
+func (m *Manager) Cancel(deviceKey, id string) *Reminder {
+	m.mu.Lock()
+	r, ok := m.scheduled[deviceKey][strings.ToLower(strings.TrimSpace(id))]
+	if ok {
+		delete(m.scheduled[deviceKey], r.ID)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if !m.taskMgr.CancelTask(deviceKey, r.taskID) {
+		// 任务已经交给工作者执行，到期回调时提醒已不在列表中，不会再播报
+		m.logger.Warn("提醒 %s 的定时任务已开始执行", r.ID)
+	}
+	m.remove(deviceKey, r.ID)
+	m.logger.Info("设备 %s 取消提醒 %s", deviceKey, r.ID)
+	return r
+}
+
+// Attach 登记设备当前的连接，并补发设备离线期间到期的提醒
+// 返回的函数在连接关闭时调用以注销，设备已经通过新的连接登记时不会影响新连接
+func (m *Manager) Attach(deviceKey string, deliver Deliverer) func() {
+	m.mu.Lock()
+	m.seq++
+	seq := m.seq
+	m.online[deviceKey] = device{seq: seq, deliver: deliver}
+	pending := m.pending[deviceKey]
+	delete(m.pending, deviceKey)
+	m.mu.Unlock()
+
+	for i, r := range pending {
+		if !deliver(r, true) {
+			m.requeue(deviceKey, pending[i:])
+			break
+		}
+		m.remove(deviceKey, r.ID)
+		m.logger.Info("设备 %s 重新连接，已补发提醒 %s", deviceKey, r.ID)
+	}
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if current, ok := m.online[deviceKey]; ok && current.seq == seq {
+			delete(m.online, deviceKey)
+		}
+	}
+}
+
+// fire 提醒到期，设备在线时立即播报，否则暂存到设备下次连接
+func (m *Manager) fire(r *Reminder) {
+	m.mu.Lock()
+	if _, ok := m.scheduled[r.DeviceKey][r.ID]; !ok {
+		// 已被取消
+		m.mu.Unlock()
+		return
+	}
+	delete(m.scheduled[r.DeviceKey], r.ID)
+	current, online := m.online[r.DeviceKey]
+	m.mu.Unlock()
+
+	if online && current.deliver(r, false) {
+		m.remove(r.DeviceKey, r.ID)
+		m.logger.Info("设备 %s 的提醒 %s 已播报", r.DeviceKey, r.ID)
+		return
+	}
+	m.logger.Info("设备 %s 不在线，提醒 %s 将在下次连接时补发", r.DeviceKey, r.ID)
+	m.requeue(r.DeviceKey, []*Reminder{r})
+}
+
+// requeue 暂存未送达的提醒，超过上限时丢弃最早的提醒
+func (m *Manager) requeue(deviceKey string, reminders []*Reminder) {
+	m.mu.Lock()
+	pending := append(m.pending[deviceKey], reminders...)
+	var dropped []*Reminder
+	if len(pending) > maxPending {
+		dropped = pending[:len(pending)-maxPending]
+		pending = pending[len(pending)-maxPending:]
+	}
+	m.pending[deviceKey] = pending
+	m.mu.Unlock()
+
+	for _, r := range dropped {
+		m.logger.Warn("设备 %s 未送达的提醒过多，丢弃提醒 %s", deviceKey, r.ID)
+		m.remove(deviceKey, r.ID)
+	}
+}
+
+// remove 从存储中删除已送达、已取消或被丢弃的提醒
+func (m *Manager) remove(deviceKey, id string) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Delete(context.Background(), deviceKey, []string{id}); err != nil {
+		m.logger.Error("删除提醒 %s 失败: %v", id, err)
+	}
+}
+
+// newID 生成设备内不重复的短ID，离线期间到期待补发的提醒仍占用其ID
+func (m *Manager) newID(deviceKey string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		id := strings.ReplaceAll(uuid.New().String(), "-", "")[:idLength]
+		if _, exists := m.scheduled[deviceKey][id]; exists {
+			continue
+		}
+		if !containsID(m.pending[deviceKey], id) {
+			return id
+		}
+	}
+}
+
+// containsID 提醒列表中是否有指定短ID的提醒
+func containsID(reminders []*Reminder, id string) bool {
+	for _, r := range reminders {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDueTime 根据延迟秒数或时刻计算到期时间，两者都提供时优先使用延迟
+// 时刻支持"15:04"和"2006-01-02 15:04"，只有时分且今天已过时取明天的该时刻
+func ParseDueTime(now time.Time, delaySeconds float64, at string) (time.Time, error) {
+	if delaySeconds > 0 {
+		return now.Add(time.Duration(delaySeconds * float64(time.Second))), nil
+	}
+	at = strings.TrimSpace(strings.ReplaceAll(at, "：", ":"))
+	if at == "" {
+		return time.Time{}, fmt.Errorf("缺少提醒时间")
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", at, now.Location()); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("15:04", at, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法识别的提醒时间: %s", at)
+	}
+	due := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !due.After(now) {
+		due = due.AddDate(0, 0, 1)
+	}
+	return due, nil
+}
@@ -0,0 +1,155 @@
+package reminder
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/task"
+)
+
+// memoryStore 保存在内存中的提醒存储
+type memoryStore struct {
+	mu        sync.Mutex
+	reminders map[string]*Reminder // 设备/短ID -> 提醒
+}
+
+func newMemoryStore(reminders ...*Reminder) *memoryStore {
+	s := &memoryStore{reminders: make(map[string]*Reminder)}
+	for _, r := range reminders {
+		s.reminders[r.DeviceKey+"/"+r.ID] = r
+	}
+	return s
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reminders []*Reminder
+	for _, r := range s.reminders {
+		copied := *r
+		reminders = append(reminders, &copied)
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].DueAt.Before(reminders[j].DueAt)
+	})
+	return reminders, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, r *Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reminders[r.DeviceKey+"/"+r.ID] = r
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, deviceKey string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.reminders, deviceKey+"/"+id)
+	}
+	return nil
+}
+
+func (s *memoryStore) ids() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, r := range s.reminders {
+		ids = append(ids, r.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func newTestManager(t *testing.T, store Store) *Manager {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	return NewManager(task.NewTaskManager(task.ResourceConfig{MaxWorkers: 1}), store, logger)
+}
+
+func TestRestore(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(
+		&Reminder{ID: "past01", Kind: KindTimer, DueAt: now.Add(-time.Hour), DeviceKey: "dev"},
+		&Reminder{ID: "next01", Kind: KindAlarm, DueAt: now.Add(time.Hour), DeviceKey: "dev"},
+	)
+	m := newTestManager(t, store)
+	if err := m.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	scheduled := m.List("dev")
+	if len(scheduled) != 1 || scheduled[0].ID != "next01" {
+		t.Fatalf("恢复后未到期的提醒 = %v, want [next01]", scheduled)
+	}
+
+	var delivered []string
+	m.Attach("dev", func(r *Reminder, late bool) bool {
+		if !late {
+			t.Errorf("补发的提醒 %s late = false", r.ID)
+		}
+		delivered = append(delivered, r.ID)
+		return true
+	})
+	if len(delivered) != 1 || delivered[0] != "past01" {
+		t.Errorf("设备连接后补发的提醒 = %v, want [past01]", delivered)
+	}
+	if ids := store.ids(); len(ids) != 1 || ids[0] != "next01" {
+		t.Errorf("补发后存储中的提醒 = %v, want [next01]", ids)
+	}
+
+	if r := m.Cancel("dev", "next01"); r == nil {
+		t.Fatal("Cancel(next01) = nil")
+	}
+	if ids := store.ids(); len(ids) != 0 {
+		t.Errorf("取消后存储中的提醒 = %v, want []", ids)
+	}
+}
+
+func TestScheduleDoesNotHoldConcurrency(t *testing.T) {
+	store := newMemoryStore()
+	m := newTestManager(t, store)
+	// 未到期的提醒只占用每日总配额，数量可以超过并发上限
+	for i := 0; i < 15; i++ {
+		if _, err := m.Schedule("dev", KindTimer, "", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("第%d条提醒 Schedule() error = %v", i+1, err)
+		}
+	}
+	if n := len(m.List("dev")); n != 15 {
+		t.Errorf("未到期的提醒数 = %d, want 15", n)
+	}
+	if n := len(store.ids()); n != 15 {
+		t.Errorf("存储中的提醒数 = %d, want 15", n)
+	}
+}
+
+func TestUndeliveredReminderIsKept(t *testing.T) {
+	store := newMemoryStore(&Reminder{ID: "past01", Kind: KindTimer, DueAt: time.Now().Add(-time.Minute), DeviceKey: "dev"})
+	m := newTestManager(t, store)
+	if err := m.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	// 连接已关闭无法播报时仍保留，等待下次连接
+	m.Attach("dev", func(r *Reminder, late bool) bool { return false })
+	if ids := store.ids(); len(ids) != 1 {
+		t.Errorf("未送达后存储中的提醒 = %v, want [past01]", ids)
+	}
+	var delivered []string
+	m.Attach("dev", func(r *Reminder, late bool) bool {
+		delivered = append(delivered, r.ID)
+		return true
+	})
+	if len(delivered) != 1 {
+		t.Errorf("再次连接后补发的提醒 = %v, want [past01]", delivered)
+	}
+}
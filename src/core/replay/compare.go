@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"angrymiao-ai-server/src/core/recorder"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxDiffLines 每类差异最多输出的行数
+const maxDiffLines = 50
+
+// compare 比对录制和回放的工具调用序列与下发消息序列
+func compare(recorded, replayed []recorder.Event) []string {
+	recordedTools, recordedOutbound := signatures(recorded)
+	replayedTools, replayedOutbound := signatures(replayed)
+
+	var diffs []string
+	diffs = append(diffs, diffSequence("工具调用", recordedTools, replayedTools)...)
+	diffs = append(diffs, diffSequence("下发消息", recordedOutbound, replayedOutbound)...)
+	return diffs
+}
+
+// signatures 提取事件中与时间、ID无关的部分，用于比对
+func signatures(events []recorder.Event) (tools []string, outbound []string) {
+	for _, event := range events {
+		switch event.Kind {
+		case recorder.KindToolCall:
+			if event.Tool != nil {
+				tools = append(tools, fmt.Sprintf("%s(%s) action=%d", event.Tool.Name, event.Tool.Arguments, event.Tool.Action))
+			}
+		case recorder.KindOutbound:
+			if event.MessageType == recorder.TextMessage {
+				outbound = append(outbound, messageSignature(event.Text))
+			}
+		}
+	}
+	return tools, outbound
+}
+
+// messageSignature 下发JSON消息的类型、状态和文本
+func messageSignature(text string) string {
+	var msg map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &msg); err != nil {
+		return text
+	}
+
+	parts := []string{fmt.Sprint(msg["type"])}
+	for _, key := range []string{"state", "emotion", "text"} {
+		if value, ok := msg[key]; ok && value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	if payload, ok := msg["payload"].(map[string]interface{}); ok {
+		if method, ok := payload["method"]; ok {
+			parts = append(parts, fmt.Sprintf("method=%v", method))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// diffSequence 基于最长公共子序列输出两个序列的差异，-为只出现在录制中，+为只出现在回放中
+func diffSequence(name string, recorded, replayed []string) []string {
+	n, m := len(recorded), len(replayed)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if recorded[i] == replayed[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diffs []string
+	add := func(line string) {
+		if len(diffs) < maxDiffLines {
+			diffs = append(diffs, line)
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && recorded[i] == replayed[j]:
+			i++
+			j++
+		case j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			add(fmt.Sprintf("[%s] - #%d %s", name, i+1, recorded[i]))
+			i++
+		default:
+			add(fmt.Sprintf("[%s] + #%d %s", name, j+1, replayed[j]))
+			j++
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"angrymiao-ai-server/src/core/recorder"
+	"io"
+	"sync"
+	"time"
+)
+
+// replayConn 按录制时的时间间隔返回存档中的客户端消息，下发的消息由连接处理器的录制器记录
+type replayConn struct {
+	inbound []recorder.Event
+	asr     *stubASR
+	speed   float64
+	settle  time.Duration
+
+	start      time.Time
+	next       int
+	closeOnce  sync.Once
+	closeCh    chan struct{}
+	mu         sync.Mutex
+	lastActive time.Time
+}
+
+func newReplayConn(events []recorder.Event, asr *stubASR, speed float64, settle time.Duration) *replayConn {
+	c := &replayConn{
+		asr:        asr,
+		speed:      speed,
+		settle:     settle,
+		start:      time.Now(),
+		closeCh:    make(chan struct{}),
+		lastActive: time.Now(),
+	}
+	for _, event := range events {
+		if event.Kind == recorder.KindInbound {
+			c.inbound = append(c.inbound, event)
+		}
+	}
+	return c
+}
+
+func (c *replayConn) ReadMessage(stopChan <-chan struct{}) (int, []byte, error) {
+	if c.next >= len(c.inbound) {
+		// 输入回放完毕，补发未触发的识别结果并等待对话流程处理完成
+		if c.next == len(c.inbound) {
+			c.next++
+			c.asr.flush()
+		}
+		c.wait(c.settle, stopChan)
+		return 0, nil, io.EOF
+	}
+
+	event := c.inbound[c.next]
+	c.next++
+	if c.speed > 0 {
+		at := c.start.Add(time.Duration(float64(event.OffsetMs)/c.speed) * time.Millisecond)
+		if !c.wait(time.Until(at), stopChan) {
+			return 0, nil, io.EOF
+		}
+	}
+
+	c.mu.Lock()
+	c.lastActive = time.Now()
+	c.mu.Unlock()
+	if event.MessageType == recorder.BinaryMessage {
+		return recorder.BinaryMessage, event.Audio, nil
+	}
+	return recorder.TextMessage, []byte(event.Text), nil
+}
+
+// wait 等待指定时长，连接关闭时返回false
+func (c *replayConn) wait(d time.Duration, stopChan <-chan struct{}) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stopChan:
+		return false
+	case <-c.closeCh:
+		return false
+	}
+}
+
+func (c *replayConn) WriteMessage(messageType int, data []byte) error {
+	return nil
+}
+
+func (c *replayConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+	})
+	return nil
+}
+
+func (c *replayConn) GetID() string {
+	return "replay"
+}
+
+func (c *replayConn) GetType() string {
+	return "replay"
+}
+
+func (c *replayConn) IsClosed() bool {
+	select {
+	case <-c.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *replayConn) GetLastActiveTime() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastActive
+}
+
+func (c *replayConn) IsStale(timeout time.Duration) bool {
+	return false
+}
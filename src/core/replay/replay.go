@@ -0,0 +1,102 @@
+// Package replay 将录制的会话存档通过桩提供者重新送入对话流程，
+// 用于离线复现断句、工具路由和状态处理上的问题
+package replay
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/mcp"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/recorder"
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultSettle = 3 * time.Second
+
+// Options 回放选项
+type Options struct {
+	Speed  float64       // 回放速度倍数，1为按录制时的节奏回放，<=0为不等待尽快回放
+	Settle time.Duration // 输入全部回放后等待处理完成的时长
+	Output string        // 回放过程的存档路径，为空时写入临时文件
+}
+
+// Report 回放结果，Diffs为录制与回放在工具调用和下发消息上的差异
+type Report struct {
+	Archive string
+	Output  string
+	Diffs   []string
+}
+
+// OK 回放结果与录制一致
+func (r *Report) OK() bool {
+	return len(r.Diffs) == 0
+}
+
+// Run 回放会话存档并与录制结果比对
+// ASR、LLM、TTS和工具调用使用按存档返回结果的桩，分段、工具路由和状态处理使用真实的对话流程
+func Run(ctx context.Context, config *configs.Config, logger *utils.Logger, archive string, opts Options) (*Report, error) {
+	events, err := recorder.Load(archive)
+	if err != nil {
+		return nil, fmt.Errorf("加载会话存档失败: %v", err)
+	}
+	session := *events[0].Session
+	if opts.Settle <= 0 {
+		opts.Settle = defaultSettle
+	}
+
+	output := opts.Output
+	if output == "" {
+		file, err := os.CreateTemp("", "replay-*.jsonl")
+		if err != nil {
+			return nil, fmt.Errorf("创建回放存档失败: %v", err)
+		}
+		file.Close()
+		output = file.Name()
+	}
+	replaySession := session
+	replaySession.StartTime = time.Now()
+	rec, err := recorder.Create(output, replaySession)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/replay", nil)
+	if err != nil {
+		rec.Close()
+		return nil, err
+	}
+	for key, value := range session.Headers {
+		req.Header[key] = []string{value}
+	}
+
+	asr := newStubASR(logger, events)
+	defer asr.Cleanup()
+	providerSet := &pool.ProviderSet{
+		ASR: asr,
+		LLM: newStubLLM(events),
+		TTS: &stubTTS{},
+		MCP: mcp.NewLocalManager(logger, config),
+	}
+	handler := core.NewConnectionHandler(config, providerSet, logger, req, ctx)
+	handler.SetRecorder(rec)
+	handler.SetToolExecutor(newStubTools(logger, events))
+
+	logger.Info("开始回放会话存档: %s, 事件数: %d", archive, len(events))
+	handler.Handle(newReplayConn(events, asr, opts.Speed, opts.Settle))
+	handler.Close() // 同时关闭回放存档
+
+	replayed, err := recorder.Load(output)
+	if err != nil {
+		return nil, fmt.Errorf("加载回放存档失败: %v", err)
+	}
+	return &Report{
+		Archive: archive,
+		Output:  output,
+		Diffs:   compare(events, replayed),
+	}, nil
+}
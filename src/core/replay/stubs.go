@@ -0,0 +1,284 @@
+package replay
+
+import (
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/recorder"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// stubASR 按存档中的顺序返回识别结果
+// 每个结果在收到与录制时相同数量的音频帧后才返回，以复现原始的断句时机
+type stubASR struct {
+	mu           sync.Mutex
+	logger       *utils.Logger
+	listener     providers.AsrEventListener
+	pending      []recorder.Event
+	frames       int
+	silenceCount int
+	results      chan string
+	stop         chan struct{}
+}
+
+func newStubASR(logger *utils.Logger, events []recorder.Event) *stubASR {
+	asr := &stubASR{
+		logger:  logger,
+		results: make(chan string, 100),
+		stop:    make(chan struct{}),
+	}
+	for _, event := range events {
+		if event.Kind == recorder.KindASR {
+			asr.pending = append(asr.pending, event)
+		}
+	}
+	go asr.dispatch()
+	return asr
+}
+
+// dispatch 与真实ASR一样在独立协程中依次回调监听器
+func (a *stubASR) dispatch() {
+	for {
+		select {
+		case <-a.stop:
+			return
+		case result := <-a.results:
+			a.mu.Lock()
+			listener := a.listener
+			a.mu.Unlock()
+			if listener == nil {
+				a.logger.Warn("回放ASR结果时尚未设置监听器，丢弃结果: %s", result)
+				continue
+			}
+			listener.OnAsrResult(result)
+		}
+	}
+}
+
+func (a *stubASR) Initialize() error { return nil }
+
+func (a *stubASR) Cleanup() error {
+	close(a.stop)
+	return nil
+}
+
+func (a *stubASR) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	return "", nil
+}
+
+func (a *stubASR) AddAudio(data []byte) error {
+	a.mu.Lock()
+	a.frames++
+	var ready []recorder.Event
+	for len(a.pending) > 0 && a.pending[0].AudioFrames <= a.frames {
+		ready = append(ready, a.pending[0])
+		a.pending = a.pending[1:]
+	}
+	a.mu.Unlock()
+
+	for _, event := range ready {
+		a.results <- event.Text
+	}
+	return nil
+}
+
+// flush 输入回放完毕后返回剩余的识别结果
+func (a *stubASR) flush() {
+	a.mu.Lock()
+	ready := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	for _, event := range ready {
+		a.results <- event.Text
+	}
+}
+
+func (a *stubASR) SetListener(listener providers.AsrEventListener) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.listener = listener
+}
+
+func (a *stubASR) Reset() error { return nil }
+
+func (a *stubASR) GetSilenceCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.silenceCount
+}
+
+func (a *stubASR) IncreaseSilenceCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.silenceCount++
+	return a.silenceCount
+}
+
+func (a *stubASR) ResetSilenceCount() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.silenceCount = 0
+}
+
+func (a *stubASR) ResetStartListenTime() {}
+
+// stubLLM 按存档中的顺序返回每次请求的流式响应
+type stubLLM struct {
+	mu        sync.Mutex
+	responses [][]types.Response
+	sessionID string
+}
+
+func newStubLLM(events []recorder.Event) *stubLLM {
+	llm := &stubLLM{}
+	for _, event := range events {
+		if event.Kind == recorder.KindLLMResponse {
+			llm.responses = append(llm.responses, event.Chunks)
+		}
+	}
+	return llm
+}
+
+func (l *stubLLM) Initialize() error { return nil }
+
+func (l *stubLLM) Cleanup() error { return nil }
+
+func (l *stubLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	l.mu.Lock()
+	var chunks []types.Response
+	if len(l.responses) > 0 {
+		chunks = l.responses[0]
+		l.responses = l.responses[1:]
+	} else {
+		chunks = []types.Response{{Error: "回放存档中没有更多的LLM响应"}}
+	}
+	l.mu.Unlock()
+
+	responses := make(chan types.Response, len(chunks))
+	for _, chunk := range chunks {
+		responses <- chunk
+	}
+	close(responses)
+	return responses, nil
+}
+
+// Response 摘要、记忆提取等辅助请求没有录制，返回固定文本
+func (l *stubLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responses := make(chan string, 1)
+	responses <- "回放"
+	close(responses)
+	return responses, nil
+}
+
+func (l *stubLLM) GetSessionID() string { return l.sessionID }
+
+func (l *stubLLM) SetIdentityFlag(idType string, flag string) {
+	if idType == "session" {
+		l.sessionID = flag
+	}
+}
+
+// stubTTSFrameBytes 每句合成结果的静音时长：60ms的16kHz单声道16位PCM
+const stubTTSFrameBytes = 16000 * 2 * 60 / 1000
+
+// stubTTS 流式返回一小段静音，保留句子开始、结束等状态消息的时序
+type stubTTS struct{}
+
+func (t *stubTTS) Initialize() error { return nil }
+
+func (t *stubTTS) Cleanup() error { return nil }
+
+func (t *stubTTS) ToTTS(text string) (string, error) {
+	return "", errors.New("回放时不支持文件语音合成")
+}
+
+func (t *stubTTS) ToTTSStream(ctx context.Context, text string) (<-chan types.AudioChunk, error) {
+	stream := make(chan types.AudioChunk, 1)
+	stream <- types.AudioChunk{
+		Text:     text,
+		Data:     make([]byte, stubTTSFrameBytes),
+		EOF:      true,
+		Encoding: "pcm",
+	}
+	close(stream)
+	return stream, nil
+}
+
+func (t *stubTTS) SetVoice(voice string) error { return nil }
+
+// stubTools 按存档返回工具调用结果，不调用外部MCP服务、设备工具和用户自定义函数
+// 按调用顺序匹配同名的录制记录，参数相同的记录优先；本地处理函数（切换角色、提醒等）仍然真实执行
+type stubTools struct {
+	mu      sync.Mutex
+	logger  *utils.Logger
+	pending []recorder.ToolCallRecord
+}
+
+var _ core.ToolExecutor = (*stubTools)(nil)
+
+func newStubTools(logger *utils.Logger, events []recorder.Event) *stubTools {
+	tools := &stubTools{logger: logger}
+	for _, event := range events {
+		if event.Kind == recorder.KindToolCall && event.Tool != nil {
+			tools.pending = append(tools.pending, *event.Tool)
+		}
+	}
+	return tools
+}
+
+func (t *stubTools) ExecuteTool(ctx context.Context, call types.ToolCall) (types.ActionResponse, bool) {
+	record, ok := t.take(call)
+	if !ok {
+		t.logger.Warn("回放存档中没有工具调用的记录: %s(%s)", call.Function.Name, call.Function.Arguments)
+		return types.ActionResponse{
+			Action: types.ActionTypeError,
+			Result: "回放存档中没有该工具调用的记录",
+		}, true
+	}
+
+	switch action := types.Action(record.Action); action {
+	case types.ActionTypeCallHandler:
+		return types.ActionResponse{}, false
+	case types.ActionTypeError:
+		return types.ActionResponse{Action: action, Result: strings.TrimPrefix(record.Result, "调用工具失败: ")}, true
+	case types.ActionTypeResponse:
+		return types.ActionResponse{Action: action, Response: record.Result}, true
+	case types.ActionTypeNone:
+		return types.ActionResponse{Action: action}, true
+	default:
+		return types.ActionResponse{Action: action, Result: record.Result}, true
+	}
+}
+
+// take 取出与调用匹配的第一条录制记录
+func (t *stubTools) take(call types.ToolCall) (recorder.ToolCallRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	index := -1
+	for i, record := range t.pending {
+		if record.Name != call.Function.Name {
+			continue
+		}
+		if record.Arguments == call.Function.Arguments {
+			index = i
+			break
+		}
+		if index < 0 {
+			index = i
+		}
+	}
+	if index < 0 {
+		return recorder.ToolCallRecord{}, false
+	}
+	record := t.pending[index]
+	t.pending = append(t.pending[:index], t.pending[index+1:]...)
+	return record, true
+}
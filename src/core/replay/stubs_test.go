@@ -0,0 +1,97 @@
+package replay
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"angrymiao-ai-server/src/core/recorder"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func toolCall(name, arguments string) types.ToolCall {
+	return types.ToolCall{Function: types.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func TestStubToolsReplaysArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	rec, err := recorder.Create(path, recorder.SessionInfo{SessionID: "device-aa_bb"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	rec.ToolCall(1, recorder.ToolCallRecord{Name: "get_weather", Arguments: `{"city":"上海"}`, Action: int(types.ActionTypeReqLLM), Result: "上海小雨"})
+	rec.ToolCall(1, recorder.ToolCallRecord{Name: "get_weather", Arguments: `{"city":"北京"}`, Action: int(types.ActionTypeReqLLM), Result: "北京晴"})
+	rec.ToolCall(2, recorder.ToolCallRecord{Name: "play_music", Arguments: `{}`, Action: int(types.ActionTypeResponse), Result: "开始播放"})
+	rec.ToolCall(2, recorder.ToolCallRecord{Name: "set_volume", Arguments: `{"volume":50}`, Action: int(types.ActionTypeError), Result: "调用工具失败: 设备离线"})
+	rec.ToolCall(3, recorder.ToolCallRecord{Name: "change_role", Arguments: `{}`, Action: int(types.ActionTypeCallHandler)})
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	events, err := recorder.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	tools := newStubTools(logger, events)
+
+	// 按顺序执行，每条录制记录只使用一次
+	tests := []struct {
+		name     string
+		call     types.ToolCall
+		expected types.ActionResponse
+		handled  bool
+	}{
+		{
+			name:     "参数相同的记录优先",
+			call:     toolCall("get_weather", `{"city":"北京"}`),
+			expected: types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "北京晴"},
+			handled:  true,
+		},
+		{
+			name:     "参数不同时使用第一条同名记录",
+			call:     toolCall("get_weather", `{"city":"广州"}`),
+			expected: types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "上海小雨"},
+			handled:  true,
+		},
+		{
+			name:     "同名记录已用完",
+			call:     toolCall("get_weather", `{"city":"北京"}`),
+			expected: types.ActionResponse{Action: types.ActionTypeError, Result: "回放存档中没有该工具调用的记录"},
+			handled:  true,
+		},
+		{
+			name:     "直接回复",
+			call:     toolCall("play_music", `{}`),
+			expected: types.ActionResponse{Action: types.ActionTypeResponse, Response: "开始播放"},
+			handled:  true,
+		},
+		{
+			name:     "调用失败时还原错误信息",
+			call:     toolCall("set_volume", `{"volume":50}`),
+			expected: types.ActionResponse{Action: types.ActionTypeError, Result: "设备离线"},
+			handled:  true,
+		},
+		{
+			name:    "本地处理函数交给连接执行",
+			call:    toolCall("change_role", `{}`),
+			handled: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, handled := tools.ExecuteTool(context.Background(), tt.call)
+			if handled != tt.handled {
+				t.Fatalf("ExecuteTool() handled = %v, want %v", handled, tt.handled)
+			}
+			if result.Action != tt.expected.Action || result.Result != tt.expected.Result || result.Response != tt.expected.Response {
+				t.Errorf("ExecuteTool() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -8,7 +8,15 @@ import (
 
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/intent"
+	"angrymiao-ai-server/src/core/memory"
+	"angrymiao-ai-server/src/core/moderation"
+	"angrymiao-ai-server/src/core/persona"
 	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/core/reminder"
+	"angrymiao-ai-server/src/core/ttscache"
 	"angrymiao-ai-server/src/core/utils"
 	"angrymiao-ai-server/src/services"
 	"angrymiao-ai-server/src/task"
@@ -156,6 +164,14 @@ type DefaultConnectionHandlerFactory struct {
 	taskMgr           *task.TaskManager
 	logger            *utils.Logger
 	userConfigService services.UserAIConfigService
+	dialogueStore     chat.DialogueStore
+	memoryManager     *memory.Manager
+	reminderManager   *reminder.Manager
+	quotaManager      *quota.Manager
+	moderationManager *moderation.Manager
+	intentRouter      *intent.Router
+	ttsCache          *ttscache.Cache
+	personaManager    *persona.Manager
 }
 
 // NewDefaultConnectionHandlerFactory 创建默认连接处理器工厂
@@ -175,6 +191,46 @@ func NewDefaultConnectionHandlerFactory(
 	}
 }
 
+// SetDialogueStore 设置对话历史存储，新建的连接会在重连时恢复对话上下文
+func (f *DefaultConnectionHandlerFactory) SetDialogueStore(store chat.DialogueStore) {
+	f.dialogueStore = store
+}
+
+// SetMemoryManager 设置长期记忆管理器，新建的连接会检索和提取用户的长期记忆
+func (f *DefaultConnectionHandlerFactory) SetMemoryManager(manager *memory.Manager) {
+	f.memoryManager = manager
+}
+
+// SetReminderManager 设置提醒管理器，新建的连接支持计时器、闹钟和提醒
+func (f *DefaultConnectionHandlerFactory) SetReminderManager(manager *reminder.Manager) {
+	f.reminderManager = manager
+}
+
+// SetQuotaManager 设置用量配额管理器，新建的连接按用户级别限制每日用量
+func (f *DefaultConnectionHandlerFactory) SetQuotaManager(manager *quota.Manager) {
+	f.quotaManager = manager
+}
+
+// SetModerationManager 设置内容审核管理器，新建的连接会审核用户输入和回复
+func (f *DefaultConnectionHandlerFactory) SetModerationManager(manager *moderation.Manager) {
+	f.moderationManager = manager
+}
+
+// SetIntentRouter 设置本地意图路由器，新建的连接命中意图时不再请求LLM
+func (f *DefaultConnectionHandlerFactory) SetIntentRouter(router *intent.Router) {
+	f.intentRouter = router
+}
+
+// SetTTSCache 设置TTS结果缓存，新建的连接重复播报相同文本时不再合成
+func (f *DefaultConnectionHandlerFactory) SetTTSCache(cache *ttscache.Cache) {
+	f.ttsCache = cache
+}
+
+// SetPersonaManager 设置角色管理器，新建的连接可以切换用户和全局角色
+func (f *DefaultConnectionHandlerFactory) SetPersonaManager(manager *persona.Manager) {
+	f.personaManager = manager
+}
+
 // CreateHandler 实现ConnectionHandlerFactory接口
 func (f *DefaultConnectionHandlerFactory) CreateHandler(
 	conn Connection,
@@ -198,6 +254,30 @@ func (f *DefaultConnectionHandlerFactory) CreateHandler(
 		req,
 		f.userConfigService,
 	)
+	if f.dialogueStore != nil {
+		adapter.GetConnectionHandler().SetDialogueStore(f.dialogueStore)
+	}
+	if f.memoryManager != nil {
+		adapter.GetConnectionHandler().SetMemoryManager(f.memoryManager)
+	}
+	if f.reminderManager != nil {
+		adapter.GetConnectionHandler().SetReminderManager(f.reminderManager)
+	}
+	if f.quotaManager != nil {
+		adapter.GetConnectionHandler().SetQuotaManager(f.quotaManager)
+	}
+	if f.moderationManager != nil {
+		adapter.GetConnectionHandler().SetModerationManager(f.moderationManager)
+	}
+	if f.intentRouter != nil {
+		adapter.GetConnectionHandler().SetIntentRouter(f.intentRouter)
+	}
+	if f.ttsCache != nil {
+		adapter.GetConnectionHandler().SetTTSCache(f.ttsCache)
+	}
+	if f.personaManager != nil {
+		adapter.GetConnectionHandler().SetPersonaManager(f.personaManager)
+	}
 
 	return adapter
 }
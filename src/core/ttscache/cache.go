@@ -0,0 +1,339 @@
+// Package ttscache 缓存语音合成后编码好的音频帧
+// 缓存以TTS类型、音色、音频格式和规范化后的文本为键，命中时跳过语音合成和转码直接下发音频帧；
+// 音频帧保存在磁盘上，按最近使用顺序在条目数和磁盘占用超出上限时淘汰
+package ttscache
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"angrymiao-ai-server/src/core/metrics"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const (
+	fileExt = ".frames"
+
+	DefaultDir          = "tts_cache"
+	DefaultMaxEntries   = 5000
+	DefaultMaxBytes     = 256 << 20
+	DefaultMaxTextRunes = 50
+)
+
+// maxFrameSize 单帧的最大字节数，读取时超出视为文件损坏
+const maxFrameSize = 1 << 20
+
+// Config 缓存配置，为0的字段使用默认值
+type Config struct {
+	Dir          string
+	MaxEntries   int
+	MaxBytes     int64
+	MaxTextRunes int // 只缓存不超过该长度的文本，较长的文本很少重复
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Stores    int64   `json:"stores"`
+	Evictions int64   `json:"evictions"`
+	Entries   int     `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// Cache 语音合成结果缓存，所有连接共享
+type Cache struct {
+	config Config
+	logger *utils.Logger
+
+	mu      sync.Mutex
+	lru     *list.List // 最近使用的条目在前
+	entries map[string]*list.Element
+	bytes   int64
+	stats   Stats
+}
+
+// New 创建缓存，并按修改时间载入目录中已有的缓存文件
+func New(config Config, logger *utils.Logger) (*Cache, error) {
+	if config.Dir == "" {
+		config.Dir = DefaultDir
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultMaxEntries
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultMaxBytes
+	}
+	if config.MaxTextRunes <= 0 {
+		config.MaxTextRunes = DefaultMaxTextRunes
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建TTS缓存目录失败: %v", err)
+	}
+	c := &Cache{
+		config:  config,
+		logger:  logger,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load 载入已有的缓存文件，最近修改的排在前面
+func (c *Cache) load() error {
+	files, err := os.ReadDir(c.config.Dir)
+	if err != nil {
+		return fmt.Errorf("读取TTS缓存目录失败: %v", err)
+	}
+	type cachedFile struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var cached []cachedFile
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		cached = append(cached, cachedFile{strings.TrimSuffix(name, fileExt), info.Size(), info.ModTime()})
+	}
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].modTime.After(cached[j].modTime)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, file := range cached {
+		c.entries[file.key] = c.lru.PushBack(&entry{key: file.key, size: file.size})
+		c.bytes += file.size
+	}
+	c.evictLocked()
+	c.updateGauges()
+	c.logger.Info("TTS缓存已载入 %d 条, 共 %d 字节", c.lru.Len(), c.bytes)
+	return nil
+}
+
+// Key 生成缓存键，文本先经过规范化
+func Key(provider, voice, format, text string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{provider, voice, format, NormalizeText(text)}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeText 规范化文本：合并空白并去除句末的句号，读音相同的文本共用缓存
+func NormalizeText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.TrimRight(text, "。.")
+}
+
+// Cacheable 文本是否适合缓存
+func (c *Cache) Cacheable(text string) bool {
+	n := utf8.RuneCountInString(strings.TrimSpace(text))
+	return n > 0 && n <= c.config.MaxTextRunes
+}
+
+// Get 读取缓存的音频帧
+func (c *Cache) Get(key string) ([][]byte, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+
+	var frames [][]byte
+	if ok {
+		var err error
+		frames, err = readFrames(c.path(key))
+		if err != nil {
+			c.logger.Warn("读取TTS缓存失败: %v", err)
+			c.remove(key)
+			ok = false
+		} else {
+			// 更新修改时间，重启后按此恢复使用顺序
+			now := time.Now()
+			os.Chtimes(c.path(key), now, now)
+		}
+	}
+
+	c.mu.Lock()
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	c.mu.Unlock()
+	if ok {
+		metrics.TTSCacheRequests.Inc("hit")
+	} else {
+		metrics.TTSCacheRequests.Inc("miss")
+	}
+	return frames, ok
+}
+
+// Put 写入音频帧，超出上限时淘汰最久未使用的条目
+func (c *Cache) Put(key string, frames [][]byte) {
+	if len(frames) == 0 {
+		return
+	}
+	c.mu.Lock()
+	_, exists := c.entries[key]
+	c.mu.Unlock()
+	if exists {
+		return
+	}
+
+	size, err := writeFrames(c.path(key), frames)
+	if err != nil {
+		c.logger.Warn("写入TTS缓存失败: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; exists {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&entry{key: key, size: size})
+	c.bytes += size
+	c.stats.Stores++
+	c.evictLocked()
+	c.updateGauges()
+}
+
+// Stats 返回缓存统计
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.config.Dir, key+fileExt)
+}
+
+// remove 删除条目及其文件
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+		c.updateGauges()
+	}
+}
+
+func (c *Cache) removeLocked(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+	if err := os.Remove(c.path(e.key)); err != nil && !os.IsNotExist(err) {
+		c.logger.Warn("删除TTS缓存文件失败: %v", err)
+	}
+}
+
+// evictLocked 淘汰最久未使用的条目，直到条目数和占用空间都不超过上限
+func (c *Cache) evictLocked() {
+	for c.lru.Len() > c.config.MaxEntries || (c.bytes > c.config.MaxBytes && c.lru.Len() > 0) {
+		c.removeLocked(c.lru.Back())
+		c.stats.Evictions++
+		metrics.TTSCacheEvictions.Inc()
+	}
+}
+
+func (c *Cache) updateGauges() {
+	metrics.TTSCacheEntries.Set(float64(c.lru.Len()))
+	metrics.TTSCacheBytes.Set(float64(c.bytes))
+}
+
+// writeFrames 以长度前缀的格式写入音频帧，先写临时文件再重命名，避免读到写了一半的文件
+func writeFrames(path string, frames [][]byte) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	var size int64
+	var prefix [4]byte
+	for _, frame := range frames {
+		binary.BigEndian.PutUint32(prefix[:], uint32(len(frame)))
+		w.Write(prefix[:])
+		w.Write(frame)
+		size += int64(len(prefix) + len(frame))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), path)
+}
+
+// readFrames 读取writeFrames写入的音频帧
+func readFrames(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var frames [][]byte
+	var prefix [4]byte
+	for {
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("缓存文件 %s 已损坏: %v", path, err)
+		}
+		n := binary.BigEndian.Uint32(prefix[:])
+		if n > maxFrameSize {
+			return nil, fmt.Errorf("缓存文件 %s 已损坏: 帧长度 %d", path, n)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, fmt.Errorf("缓存文件 %s 已损坏: %v", path, err)
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("缓存文件 %s 为空", path)
+	}
+	return frames, nil
+}
@@ -0,0 +1,153 @@
+package ttscache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func newTestCache(t *testing.T, config Config) *Cache {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	cache, err := New(config, logger)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return cache
+}
+
+// frames 生成一帧n字节的音频，写入文件后占用4+n字节
+func frames(n int) [][]byte {
+	return [][]byte{make([]byte, n)}
+}
+
+// cachedKeys 返回缓存中的条目，最近使用的在前
+func cachedKeys(c *Cache) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*entry).key)
+	}
+	return keys
+}
+
+func TestPutAndGet(t *testing.T) {
+	c := newTestCache(t, Config{Dir: t.TempDir()})
+	want := [][]byte{{1, 2, 3}, {}, {4}}
+	c.Put("a", want)
+
+	got, ok := c.Get("a")
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Get(a) = %v, %v, want %v", got, ok, want)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("Get(b) 未写入的条目命中")
+	}
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Stores != 1 || stats.Entries != 1 || stats.Bytes != 4*3+4 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expected  []string
+		evictions int64
+	}{
+		{name: "按条目数淘汰最久未使用的条目", config: Config{MaxEntries: 2}, expected: []string{"c", "a"}, evictions: 1},
+		{name: "按磁盘占用淘汰最久未使用的条目", config: Config{MaxBytes: 30}, expected: []string{"c", "a"}, evictions: 1},
+		{name: "未超过上限不淘汰", config: Config{MaxEntries: 3, MaxBytes: 42}, expected: []string{"c", "a", "b"}, evictions: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Dir = t.TempDir()
+			c := newTestCache(t, tt.config)
+			c.Put("a", frames(10))
+			c.Put("b", frames(10))
+			c.Get("a") // a变为最近使用
+			c.Put("c", frames(10))
+
+			if keys := cachedKeys(c); !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("缓存条目 = %v, want %v", keys, tt.expected)
+			}
+			if stats := c.Stats(); stats.Evictions != tt.evictions || stats.Bytes != int64(len(tt.expected))*14 {
+				t.Errorf("Stats() = %+v, want evictions %d", stats, tt.evictions)
+			}
+			if _, err := os.Stat(c.path("b")); (err == nil) != (tt.evictions == 0) {
+				t.Errorf("淘汰条目的文件 stat error = %v", err)
+			}
+		})
+	}
+}
+
+func TestReloadOrder(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCache(t, Config{Dir: dir})
+	c.Put("a", frames(10))
+	c.Put("b", frames(10))
+	c.Put("c", frames(10))
+
+	// 重启后按文件修改时间恢复使用顺序：b最近使用，a最久未使用
+	now := time.Now()
+	for key, age := range map[string]time.Duration{"a": 3 * time.Hour, "b": time.Hour, "c": 2 * time.Hour} {
+		if err := os.Chtimes(c.path(key), now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+	// 不是缓存文件的文件不会被载入
+	os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("x"), 0o644)
+
+	reloaded := newTestCache(t, Config{Dir: dir, MaxEntries: 2})
+	if keys := cachedKeys(reloaded); !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Errorf("重新载入的条目 = %v, want [b c]", keys)
+	}
+	if _, err := os.Stat(reloaded.path("a")); !os.IsNotExist(err) {
+		t.Errorf("载入时超出上限的文件没有删除, stat error = %v", err)
+	}
+	if _, ok := reloaded.Get("c"); !ok {
+		t.Error("Get(c) 重新载入的条目未命中")
+	}
+}
+
+func TestCorruptFileRemoved(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "空文件", content: nil},
+		{name: "长度前缀不完整", content: []byte{0, 0}},
+		{name: "帧数据不完整", content: []byte{0, 0, 0, 5, 1, 2}},
+		{name: "帧长度超出上限", content: []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "bad"+fileExt), tt.content, 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			c := newTestCache(t, Config{Dir: dir})
+			if _, ok := c.Get("bad"); ok {
+				t.Fatal("Get() 损坏的缓存文件命中")
+			}
+			if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+				t.Errorf("Stats() = %+v, 损坏的条目应被移除", stats)
+			}
+			if _, err := os.Stat(c.path("bad")); !os.IsNotExist(err) {
+				t.Errorf("损坏的缓存文件没有删除, stat error = %v", err)
+			}
+		})
+	}
+}
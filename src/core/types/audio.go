@@ -8,4 +8,5 @@ type AudioChunk struct {
 	Timestamp int64  // 时间戳，用于标记数据块的顺序
 	EOF       bool   // 是否为最后一个数据块
 	Encoding  string // 音频编码格式，例如 "mp3", "wav"
+	Error     string // 错误信息，非空表示音频流异常结束
 }
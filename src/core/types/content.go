@@ -0,0 +1,124 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 消息内容片段的类型
+const (
+	ContentPartText  = "text"
+	ContentPartImage = "image"
+	ContentPartAudio = "audio"
+)
+
+// ContentPart 多模态消息的一个内容片段
+// 图片和音频可以通过URL、base64数据或引用提供，引用需由上层解析为URL或数据后才能发送给模型
+type ContentPart struct {
+	Type    string `json:"type"`
+	Text    string `json:"text,omitempty"`    // 文本片段的内容
+	URL     string `json:"url,omitempty"`     // 图片或音频的地址
+	Data    string `json:"data,omitempty"`    // base64编码的图片或音频数据
+	Ref     string `json:"ref,omitempty"`     // 图片或音频的引用，如存储中的文件ID
+	Format  string `json:"format,omitempty"`  // 图片或音频的格式，如jpeg、png、wav
+	Caption string `json:"caption,omitempty"` // 文字说明，不支持该类型的模型只会收到说明
+}
+
+// TextPart 创建文本片段
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// ImagePart 创建图片片段，url和data提供其一
+func ImagePart(url, data, format, caption string) ContentPart {
+	return ContentPart{Type: ContentPartImage, URL: url, Data: data, Format: format, Caption: caption}
+}
+
+// Media 是否为图片或音频片段
+func (p ContentPart) Media() bool {
+	return p.Type == ContentPartImage || p.Type == ContentPartAudio
+}
+
+// TextForm 片段的文字形式：文本片段为文本本身，图片和音频为文字说明
+func (p ContentPart) TextForm() string {
+	switch {
+	case p.Type == ContentPartText:
+		return p.Text
+	case p.Caption != "":
+		return p.Caption
+	case p.Type == ContentPartImage:
+		return "[图片]"
+	case p.Type == ContentPartAudio:
+		return "[音频]"
+	}
+	return ""
+}
+
+// DataURL 返回图片或音频的地址，只有base64数据时转换为data URL，两者都没有时返回空字符串
+func (p ContentPart) DataURL() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	if p.Data == "" {
+		return ""
+	}
+	kind := "image"
+	if p.Type == ContentPartAudio {
+		kind = "audio"
+	}
+	format := p.Format
+	if format == "" {
+		format = "jpeg"
+	}
+	return fmt.Sprintf("data:%s/%s;base64,%s", kind, format, p.Data)
+}
+
+// NewMultimodalMessage 创建多模态消息，Content为各片段的文字形式，供只支持文本的模型和上下文统计使用
+func NewMultimodalMessage(role string, parts ...ContentPart) Message {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if text := part.TextForm(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return Message{
+		Role:    role,
+		Content: strings.Join(texts, " "),
+		Parts:   parts,
+	}
+}
+
+// HasMedia 消息是否包含图片或音频
+func (m *Message) HasMedia() bool {
+	for _, part := range m.Parts {
+		if part.Media() {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAIContent 返回OpenAI格式的消息内容，multimodal为false或消息不含图片时只返回文本内容
+// 音频和无法直接访问的图片（只有引用）使用文字说明
+func (m *Message) OpenAIContent(multimodal bool) (string, []openai.ChatMessagePart) {
+	if !multimodal || !m.HasMedia() {
+		return m.Content, nil
+	}
+	parts := make([]openai.ChatMessagePart, 0, len(m.Parts))
+	for _, part := range m.Parts {
+		if url := part.DataURL(); part.Type == ContentPartImage && url != "" {
+			parts = append(parts, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: url},
+			})
+		} else if text := part.TextForm(); text != "" {
+			parts = append(parts, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: text,
+			})
+		}
+	}
+	return "", parts
+}
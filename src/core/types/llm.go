@@ -62,11 +62,13 @@ type ActionResponseCall struct {
 }
 
 // Message 对话消息结构
+// 包含图片或音频时，Content为各片段的文字形式，Parts保存完整内容
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"parts,omitempty"` // 多模态内容片段，为空时消息只有文本
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
 }
 
 func (m *Message) Print() {
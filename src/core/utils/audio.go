@@ -92,6 +92,118 @@ func (d *OpusDecoder) Close() error {
 	return nil
 }
 
+// PCMFramer 将任意长度的PCM数据切分为固定时长的帧，不足一帧的数据缓存到下一次
+type PCMFramer struct {
+	bytesPerFrame int
+	pending       []byte
+}
+
+// NewPCMFramer 创建PCM分帧器，frameDuration单位为毫秒
+func NewPCMFramer(sampleRate, channels, frameDuration int) *PCMFramer {
+	return &PCMFramer{
+		bytesPerFrame: sampleRate * frameDuration / 1000 * 2 * channels,
+	}
+}
+
+// Write 写入PCM数据，返回已凑满的完整帧
+func (f *PCMFramer) Write(pcmData []byte) [][]byte {
+	f.pending = append(f.pending, pcmData...)
+	var frames [][]byte
+	for len(f.pending) >= f.bytesPerFrame {
+		frame := make([]byte, f.bytesPerFrame)
+		copy(frame, f.pending[:f.bytesPerFrame])
+		frames = append(frames, frame)
+		f.pending = f.pending[f.bytesPerFrame:]
+	}
+	return frames
+}
+
+// Flush 取出剩余数据并用静音补齐为完整帧，没有剩余数据时返回nil
+func (f *PCMFramer) Flush() []byte {
+	if len(f.pending) == 0 {
+		return nil
+	}
+	frame := make([]byte, f.bytesPerFrame)
+	copy(frame, f.pending)
+	f.pending = nil
+	return frame
+}
+
+// OpusEncoder 封装opus编码器，支持流式写入PCM并逐帧编码
+type OpusEncoder struct {
+	encoder   *opus.OpusEncoder
+	framer    *PCMFramer
+	outBuffer []byte
+}
+
+// NewOpusEncoder 创建流式opus编码器，固定使用60ms帧长
+func NewOpusEncoder(sampleRate, channels int) (*OpusEncoder, error) {
+	encoder, err := opus.CreateOpusEncoder(&opus.OpusEncoderConfig{
+		SampleRate:    sampleRate,
+		MaxChannels:   channels,
+		Application:   opus.AppVoIP,
+		FrameDuration: opus.Framesize60Ms, // 使用60ms帧长
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建Opus编码器失败: %v", err)
+	}
+
+	framer := NewPCMFramer(sampleRate, channels, 60)
+	return &OpusEncoder{
+		encoder:   encoder,
+		framer:    framer,
+		outBuffer: make([]byte, framer.bytesPerFrame),
+	}, nil
+}
+
+// Encode 写入PCM数据，返回已编码的完整opus帧
+func (e *OpusEncoder) Encode(pcmData []byte) ([][]byte, error) {
+	var packets [][]byte
+	for _, frame := range e.framer.Write(pcmData) {
+		packet, err := e.encodeFrame(frame)
+		if err != nil {
+			return packets, err
+		}
+		if packet != nil {
+			packets = append(packets, packet)
+		}
+	}
+	return packets, nil
+}
+
+// Flush 编码剩余不足一帧的PCM数据
+func (e *OpusEncoder) Flush() ([]byte, error) {
+	frame := e.framer.Flush()
+	if frame == nil {
+		return nil, nil
+	}
+	return e.encodeFrame(frame)
+}
+
+func (e *OpusEncoder) encodeFrame(frame []byte) ([]byte, error) {
+	n, err := e.encoder.Encode(frame, e.outBuffer)
+	if err != nil {
+		return nil, fmt.Errorf("Opus编码失败: %v", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	packet := make([]byte, n)
+	copy(packet, e.outBuffer[:n])
+	return packet, nil
+}
+
+// Close 关闭编码器
+func (e *OpusEncoder) Close() error {
+	if e.encoder != nil {
+		if err := e.encoder.Close(); err != nil {
+			return fmt.Errorf("关闭Opus编码器失败: %v", err)
+		}
+		e.encoder = nil
+	}
+	return nil
+}
+
 func MP3ToPCMData(audioFile string) ([][]byte, error) {
 	file, err := os.Open(audioFile)
 	if err != nil {
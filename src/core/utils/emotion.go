@@ -1,6 +1,10 @@
 package utils
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
 
 // EmotionEmoji 定义情绪到表情的映射
 var EmotionEmoji = map[string]string{
@@ -43,3 +47,149 @@ var SimpleEmojiRegex = regexp.MustCompile(`[\x{1F000}-\x{1FFFF}]|` +
 func RemoveAllEmoji(text string) string {
 	return SimpleEmojiRegex.ReplaceAllString(text, "")
 }
+
+// emojiEmotion 表情到情绪的反向映射，包含EmotionEmoji之外的常见表情
+var emojiEmotion = map[string]string{
+	"😀": "happy",
+	"😃": "happy",
+	"😄": "happy",
+	"😁": "happy",
+	"🙂": "happy",
+	"😆": "laughing",
+	"🤣": "laughing",
+	"😡": "angry",
+	"😞": "sad",
+	"😔": "sad",
+	"😟": "sad",
+	"😍": "loving",
+	"❤": "loving",
+	"🤗": "loving",
+	"😲": "surprised",
+	"🤨": "confused",
+	"🥱": "sleepy",
+}
+
+// emotionAlias 情绪标签的中文别名
+var emotionAlias = map[string]string{
+	"中性": "neutral",
+	"平静": "neutral",
+	"开心": "happy",
+	"高兴": "happy",
+	"微笑": "happy",
+	"笑":  "laughing",
+	"大笑": "laughing",
+	"搞笑": "funny",
+	"难过": "sad",
+	"伤心": "sad",
+	"生气": "angry",
+	"哭":  "crying",
+	"大哭": "crying",
+	"喜欢": "loving",
+	"害羞": "embarrassed",
+	"尴尬": "embarrassed",
+	"惊讶": "surprised",
+	"震惊": "shocked",
+	"思考": "thinking",
+	"眨眼": "winking",
+	"酷":  "cool",
+	"放松": "relaxed",
+	"好吃": "delicious",
+	"亲亲": "kissy",
+	"自信": "confident",
+	"困":  "sleepy",
+	"调皮": "silly",
+	"疑惑": "confused",
+}
+
+func init() {
+	for emotion, emoji := range EmotionEmoji {
+		emojiEmotion[emoji] = emotion
+	}
+}
+
+// 情绪标签，如[happy]、<emotion:sad>、（笑）
+var emotionTagRegex = regexp.MustCompile(`[\[【<(（]\s*(?:emotion\s*[:：=]\s*)?([A-Za-z_]+|\p{Han}{1,2})\s*[\]】>)）]`)
+
+// 未闭合的带前缀情绪标签，如[emotion，其后的冒号不能作为分句位置
+var emotionTagPrefixRegex = regexp.MustCompile(`[\[【(（]\s*emotion\s*$`)
+
+// insideEmotionTag 位置i是否紧跟在未闭合的带前缀情绪标签之后
+func insideEmotionTag(text string, i int) bool {
+	return emotionTagPrefixRegex.MatchString(text[:i])
+}
+
+// ExtractEmotion 从一句回复中提取情绪，并返回去除情绪标记后的文本
+// 依次识别句首的表情符号和句中的情绪标签，都没有时情绪为空
+// 只有能识别出情绪的标签会被去除，表情符号在合成语音前统一过滤
+func ExtractEmotion(text string) (string, string) {
+	emotion := leadingEmojiEmotion(text)
+
+	cleaned := emotionTagRegex.ReplaceAllStringFunc(text, func(tag string) string {
+		name := lookupEmotion(emotionTagRegex.FindStringSubmatch(tag)[1])
+		if name == "" {
+			return tag
+		}
+		if emotion == "" {
+			emotion = name
+		}
+		return ""
+	})
+	return emotion, strings.TrimSpace(cleaned)
+}
+
+// leadingEmojiEmotion 返回句首表情符号对应的情绪
+func leadingEmojiEmotion(text string) string {
+	text = strings.TrimSpace(text)
+	for _, r := range text {
+		if r == '\uFE0F' || r == '\u200D' || unicode.IsSpace(r) {
+			continue
+		}
+		if !SimpleEmojiRegex.MatchString(string(r)) {
+			return ""
+		}
+		if emotion, ok := emojiEmotion[string(r)]; ok {
+			return emotion
+		}
+	}
+	return ""
+}
+
+// lookupEmotion 将情绪标签规范为EmotionEmoji中的情绪名称，无法识别时返回空
+func lookupEmotion(name string) string {
+	name = strings.ToLower(name)
+	if _, ok := EmotionEmoji[name]; ok {
+		return name
+	}
+	return emotionAlias[name]
+}
+
+// emotionKeywords 情绪分类使用的关键词，按优先级排列
+var emotionKeywords = []struct {
+	emotion  string
+	keywords []string
+}{
+	{"laughing", []string{"哈哈", "笑死", "haha", "lol"}},
+	{"sad", []string{"难过", "伤心", "遗憾", "可惜", "sorry to hear", "unfortunately"}},
+	{"angry", []string{"生气", "气死", "可恶", "angry"}},
+	{"surprised", []string{"哇", "居然", "竟然", "没想到", "真的吗", "wow", "really?"}},
+	{"loving", []string{"爱你", "喜欢你", "抱抱", "love you"}},
+	{"embarrassed", []string{"不好意思", "抱歉", "对不起", "sorry"}},
+	{"confused", []string{"不太明白", "不确定", "没听清", "not sure"}},
+	{"thinking", []string{"让我想想", "我想想", "let me think", "hmm"}},
+	{"sleepy", []string{"晚安", "好困", "good night"}},
+	{"delicious", []string{"好吃", "美味", "delicious", "yummy"}},
+	{"happy", []string{"开心", "高兴", "太好了", "恭喜", "不错", "棒", "great", "glad", "congrat"}},
+}
+
+// ClassifyEmotion 基于关键词对一句回复做简单的情绪分类，没有命中时返回空
+func ClassifyEmotion(text string) string {
+	text = strings.ToLower(text)
+	for _, item := range emotionKeywords {
+		for _, keyword := range item.keywords {
+			if strings.Contains(text, keyword) {
+				return item.emotion
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractEmotion(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		emotion  string
+		expected string
+	}{
+		{name: "方括号标签", input: "[happy]今天天气真好", emotion: "happy", expected: "今天天气真好"},
+		{name: "尖括号带前缀标签", input: "<emotion:sad>唉，真可惜", emotion: "sad", expected: "唉，真可惜"},
+		{name: "全角括号带前缀标签", input: "（emotion：生气）哼", emotion: "angry", expected: "哼"},
+		{name: "中文别名", input: "【开心】好呀", emotion: "happy", expected: "好呀"},
+		{name: "单字中文别名", input: "（笑）好的", emotion: "laughing", expected: "好的"},
+		{name: "大小写和空白", input: "[ Happy ] 你好", emotion: "happy", expected: "你好"},
+		{name: "句中标签", input: "你好[surprised]呀", emotion: "surprised", expected: "你好呀"},
+		{name: "多个标签取第一个并全部去除", input: "[happy]你好[sad]呀", emotion: "happy", expected: "你好呀"},
+		{name: "无法识别的标签保留", input: "[note]注意安全", emotion: "", expected: "[note]注意安全"},
+		{name: "括号内的普通文字保留", input: "他说（大概）会来", emotion: "", expected: "他说（大概）会来"},
+		{name: "句首表情", input: "😊 你好", emotion: "happy", expected: "😊 你好"},
+		{name: "句首扩展表情", input: "🤗抱抱", emotion: "loving", expected: "🤗抱抱"},
+		{name: "句首表情优先于标签", input: "😢[happy]好吧", emotion: "sad", expected: "😢好吧"},
+		{name: "句末表情不识别", input: "好的😊", emotion: "", expected: "好的😊"},
+		{name: "没有情绪", input: "纯文本回复", emotion: "", expected: "纯文本回复"},
+		{name: "只有标签", input: "[happy]", emotion: "happy", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emotion, text := ExtractEmotion(tt.input)
+			if emotion != tt.emotion || text != tt.expected {
+				t.Errorf("ExtractEmotion(%q) = (%q, %q), want (%q, %q)", tt.input, emotion, text, tt.emotion, tt.expected)
+			}
+		})
+	}
+}
+
+// splitStream 按LLM流式输出的方式分段：每收到一块在最后的标点处切分，结束时输出剩余文本
+func splitStream(chunks []string) []string {
+	var segments []string
+	var full string
+	processed := 0
+	for _, chunk := range chunks {
+		full += chunk
+		if segment, n := SplitAtLastPunctuation(full[processed:]); n > 0 {
+			processed += n
+			segments = append(segments, strings.TrimSpace(segment))
+		}
+	}
+	if processed < len(full) {
+		segments = append(segments, strings.TrimSpace(full[processed:]))
+	}
+	return segments
+}
+
+func TestEmotionTagAcrossChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		emotions []string
+		expected []string
+	}{
+		{
+			name:     "方括号标签在冒号后断开",
+			chunks:   []string{"今天天气很好，适合出去走走[emotion:", "happy]我们去公园吧。"},
+			emotions: []string{"", "happy"},
+			expected: []string{"今天天气很好，", "适合出去走走我们去公园吧。"},
+		},
+		{
+			name:     "全角括号标签在冒号后断开",
+			chunks:   []string{"这件事我也没想到会这样，（emotion：", "惊讶）真是太意外了！"},
+			emotions: []string{"", "surprised"},
+			expected: []string{"这件事我也没想到会这样，", "真是太意外了！"},
+		},
+		{
+			name:     "尖括号标签在冒号后断开",
+			chunks:   []string{"这件事我也没想到会这样，真的<emotion:", "sad>很遗憾。"},
+			emotions: []string{"", "sad"},
+			expected: []string{"这件事我也没想到会这样，", "真的很遗憾。"},
+		},
+		{
+			name:     "标签名被拆到多块",
+			chunks:   []string{"[hap", "py]你好", "呀。"},
+			emotions: []string{"happy"},
+			expected: []string{"你好呀。"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var emotions, texts []string
+			for _, segment := range splitStream(tt.chunks) {
+				emotion, text := ExtractEmotion(segment)
+				emotions = append(emotions, emotion)
+				texts = append(texts, text)
+			}
+			if !reflect.DeepEqual(emotions, tt.emotions) || !reflect.DeepEqual(texts, tt.expected) {
+				t.Errorf("分段情绪 = %q, 文本 = %q, want %q, %q", emotions, texts, tt.emotions, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookupEmotion(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "情绪名称", input: "happy", expected: "happy"},
+		{name: "大写情绪名称", input: "SAD", expected: "sad"},
+		{name: "中文别名", input: "害羞", expected: "embarrassed"},
+		{name: "未知名称", input: "note", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := lookupEmotion(tt.input); result != tt.expected {
+				t.Errorf("lookupEmotion(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 语言代码
+const (
+	LanguageChinese  = "zh"
+	LanguageEnglish  = "en"
+	LanguageJapanese = "ja"
+	LanguageKorean   = "ko"
+)
+
+// minEnglishWords 只含拉丁字母的文本至少包含的单词数，达到时才判断为英文
+// "OK"、"Wi-Fi"、"GPS"这类单个缩写常出现在中文对话中，不能据此切换语言
+const minEnglishWords = 2
+
+// DetectLanguage 根据文字的书写系统判断文本的语言，无法判断时返回空
+// 中文夹杂英文单词时按中文处理，中文音色可以朗读其中的英文；
+// 只含单个拉丁单词或以符号为主的短文本无法判断语言，由调用方按上下文处理
+func DetectLanguage(text string) string {
+	var han, kana, hangul, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		}
+	}
+
+	switch {
+	case kana > 0:
+		return LanguageJapanese
+	case hangul > 0:
+		return LanguageKorean
+	case han > 0:
+		return LanguageChinese
+	case latin > 0 && latinWords(text) >= minEnglishWords:
+		return LanguageEnglish
+	}
+	return ""
+}
+
+// latinWords 统计以空白分隔、包含拉丁字母的单词数
+func latinWords(text string) int {
+	count := 0
+	for _, word := range strings.Fields(text) {
+		for _, r := range word {
+			if r < unicode.MaxASCII && unicode.IsLetter(r) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,32 @@
+package utils
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "中文", input: "今天天气不错", expected: LanguageChinese},
+		{name: "中文夹杂英文单词", input: "打开Wi-Fi设置", expected: LanguageChinese},
+		{name: "英文句子", input: "How are you today?", expected: LanguageEnglish},
+		{name: "两个英文单词", input: "Thank you", expected: LanguageEnglish},
+		{name: "日文", input: "ありがとう", expected: LanguageJapanese},
+		{name: "韩文", input: "안녕하세요", expected: LanguageKorean},
+		{name: "单个缩写", input: "OK", expected: ""},
+		{name: "连字符连接的单词", input: "Wi-Fi", expected: ""},
+		{name: "大写缩写带标点", input: "GPS!", expected: ""},
+		{name: "以符号和数字为主", input: "A/B 100% 3.5", expected: ""},
+		{name: "纯数字", input: "13800138000", expected: ""},
+		{name: "空文本", input: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := DetectLanguage(tt.input); result != tt.expected {
+				t.Errorf("DetectLanguage(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,598 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 朗读文本规范化使用的正则表达式
+var (
+	reSpeechDate        = regexp.MustCompile(`(\d{4})[-/.年](\d{1,2})[-/.月](\d{1,2})日?`)
+	reSpeechTime        = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?`)
+	reSpeechRange       = regexp.MustCompile(`(\d)\s*[-~～]\s*(\d)`)
+	reSpeechCurrency    = regexp.MustCompile(`([¥￥$€£])\s?(\d+(?:\.\d+)?)`)
+	reSpeechPercent     = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?[%％]`)
+	reSpeechTemperature = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s?(°C|°c|℃|°F|°f|℉|°)`)
+	reSpeechUnit        = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?(km/h|m/s|mph|kWh|kHz|MHz|GHz|Hz|kW|km|cm|mm|m|kg|mg|g|ml|mL|L|TB|GB|MB|KB|min)([^A-Za-z]|$)`)
+	reSpeechOrdinal     = regexp.MustCompile(`(\d+)(st|nd|rd|th)([^A-Za-z]|$)`)
+	reSpeechFraction    = regexp.MustCompile(`(^|[^\d/])(\d{1,3})/(\d{1,3})([^\d/]|$)`)
+	reSpeechThousands   = regexp.MustCompile(`(\d),(\d{3})`)
+	reSpeechNumber      = regexp.MustCompile(`(^|[^0-9A-Za-z.])(-?)(\d+(?:\.\d+)?)`)
+)
+
+// speechUnits 单位的读法，依次为中文和英文
+var speechUnits = map[string][2]string{
+	"km/h": {"公里每小时", "kilometers per hour"},
+	"m/s":  {"米每秒", "meters per second"},
+	"mph":  {"英里每小时", "miles per hour"},
+	"kWh":  {"千瓦时", "kilowatt hours"},
+	"kHz":  {"千赫兹", "kilohertz"},
+	"MHz":  {"兆赫兹", "megahertz"},
+	"GHz":  {"吉赫兹", "gigahertz"},
+	"Hz":   {"赫兹", "hertz"},
+	"kW":   {"千瓦", "kilowatts"},
+	"km":   {"公里", "kilometers"},
+	"cm":   {"厘米", "centimeters"},
+	"mm":   {"毫米", "millimeters"},
+	"m":    {"米", "meters"},
+	"kg":   {"公斤", "kilograms"},
+	"mg":   {"毫克", "milligrams"},
+	"g":    {"克", "grams"},
+	"ml":   {"毫升", "milliliters"},
+	"mL":   {"毫升", "milliliters"},
+	"L":    {"升", "liters"},
+	"TB":   {"TB", "terabytes"},
+	"GB":   {"GB", "gigabytes"},
+	"MB":   {"MB", "megabytes"},
+	"KB":   {"KB", "kilobytes"},
+	"min":  {"分钟", "minutes"},
+}
+
+// speechCurrencies 货币符号的读法，依次为中文和英文单数、复数
+var speechCurrencies = map[string][3]string{
+	"¥": {"元", "yuan", "yuan"},
+	"￥": {"元", "yuan", "yuan"},
+	"$": {"美元", "dollar", "dollars"},
+	"€": {"欧元", "euro", "euros"},
+	"£": {"英镑", "pound", "pounds"},
+}
+
+// speechMinorCurrencies 英文中小数部分按辅币朗读的货币，依次为单数、复数；其他货币按小数朗读
+var speechMinorCurrencies = map[string][2]string{
+	"$": {"cent", "cents"},
+	"€": {"cent", "cents"},
+	"£": {"penny", "pence"},
+}
+
+// speechSeparators 无效日期等编号中分隔符的读法，依次为中文和英文
+var speechSeparators = map[rune][2]string{
+	'-': {"杠", "dash"},
+	'/': {"斜杠", "slash"},
+	'.': {"点", "dot"},
+}
+
+// speechAbbreviations 常见缩写和符号的读法
+var speechAbbreviations = map[string][]string{
+	LanguageChinese: {
+		" vs. ", "对", " vs ", "对", "&", "和", "×", "乘", "÷", "除以", "=", "等于", "@", "艾特",
+	},
+	LanguageEnglish: {
+		"Mr.", "Mister", "Mrs.", "Missus", "Dr.", "Doctor", "St.", "Street", "No.", "Number",
+		"vs.", "versus", "etc.", "et cetera", "e.g.", "for example", "i.e.", "that is",
+		"&", " and ", "×", " times ", "÷", " divided by ", "=", " equals ", "@", " at ",
+	},
+}
+
+var speechAbbreviationReplacers = map[string]*strings.Replacer{
+	LanguageChinese: strings.NewReplacer(speechAbbreviations[LanguageChinese]...),
+	LanguageEnglish: strings.NewReplacer(speechAbbreviations[LanguageEnglish]...),
+}
+
+// zhMeasureWords 数字2后面跟这些量词或千、万、亿时读作"两"
+const zhMeasureWords = "个位只次天年种条件本张点杯瓶份双台辆首周千万亿"
+
+// NormalizeForSpeech 将文本中的数字、日期、时间、货币、单位、缩写和符号转为朗读形式
+// language为zh或en，为空时按文本判断，无法判断或其他语言时按中文处理
+func NormalizeForSpeech(text string, language string) string {
+	if language == "" {
+		language = DetectLanguage(text)
+	}
+	if language != LanguageEnglish {
+		language = LanguageChinese
+	}
+	zh := language == LanguageChinese
+
+	text = reSpeechThousands.ReplaceAllString(text, "$1$2")
+	text = reSpeechThousands.ReplaceAllString(text, "$1$2")
+
+	text = reSpeechDate.ReplaceAllStringFunc(text, func(m string) string {
+		parts := reSpeechDate.FindStringSubmatch(m)
+		year, _ := strconv.Atoi(parts[1])
+		month, _ := strconv.Atoi(parts[2])
+		day, _ := strconv.Atoi(parts[3])
+		if !validSpeechDate(year, month, day) {
+			return speechCode(m, zh)
+		}
+		if zh {
+			return zhDigits(parts[1]) + "年" + zhNumber(int64(month)) + "月" + zhNumber(int64(day)) + "日"
+		}
+		return enMonths[month-1] + " " + enOrdinal(int64(day)) + ", " + enYear(year)
+	})
+
+	text = reSpeechTime.ReplaceAllStringFunc(text, func(m string) string {
+		parts := reSpeechTime.FindStringSubmatch(m)
+		hour, _ := strconv.Atoi(parts[1])
+		minute, _ := strconv.Atoi(parts[2])
+		second, _ := strconv.Atoi(parts[3])
+		if hour > 24 || minute > 59 || second > 59 {
+			return m
+		}
+		if zh {
+			s := zhCount(int64(hour)) + "点"
+			if minute > 0 {
+				s += zhTwoDigits(minute) + "分"
+			}
+			if second > 0 {
+				s += zhTwoDigits(second) + "秒"
+			}
+			return s
+		}
+		s := enNumber(int64(hour))
+		switch {
+		case minute == 0:
+			s += " o'clock"
+		case minute < 10:
+			s += " oh " + enNumber(int64(minute))
+		default:
+			s += " " + enNumber(int64(minute))
+		}
+		if second > 0 {
+			s += " and " + enNumber(int64(second)) + " seconds"
+		}
+		return s
+	})
+
+	if zh {
+		text = reSpeechRange.ReplaceAllString(text, "${1}到${2}")
+	} else {
+		text = reSpeechRange.ReplaceAllString(text, "${1} to ${2}")
+	}
+
+	text = reSpeechCurrency.ReplaceAllStringFunc(text, func(m string) string {
+		parts := reSpeechCurrency.FindStringSubmatch(m)
+		names := speechCurrencies[parts[1]]
+		amount := parts[2]
+		if zh {
+			if strings.Contains(amount, ".") {
+				amount = strings.TrimRight(strings.TrimRight(amount, "0"), ".")
+			}
+			return amount + names[0]
+		}
+		whole, cents, hasCents := strings.Cut(amount, ".")
+		if !hasCents {
+			return speechPlural(amount, names)
+		}
+		minor, ok := speechMinorCurrencies[parts[1]]
+		if !ok {
+			// 没有常用辅币读法的货币按小数朗读，去掉末尾的0，如¥12.50读作twelve point five yuan
+			return speechPlural(strings.TrimRight(strings.TrimRight(amount, "0"), "."), names)
+		}
+		n, _ := strconv.Atoi((cents + "00")[:2])
+		switch {
+		case n == 0:
+			return speechPlural(whole, names)
+		case strings.TrimLeft(whole, "0") == "":
+			return speechPlural(strconv.Itoa(n), [3]string{"", minor[0], minor[1]})
+		}
+		return speechPlural(whole, names) + " and " + speechPlural(strconv.Itoa(n), [3]string{"", minor[0], minor[1]})
+	})
+
+	text = reSpeechPercent.ReplaceAllStringFunc(text, func(m string) string {
+		number := reSpeechPercent.FindStringSubmatch(m)[1]
+		if zh {
+			return "百分之" + number
+		}
+		return number + " percent"
+	})
+
+	text = reSpeechTemperature.ReplaceAllStringFunc(text, func(m string) string {
+		parts := reSpeechTemperature.FindStringSubmatch(m)
+		number := parts[1]
+		var unit [2]string
+		switch strings.ToUpper(parts[2]) {
+		case "°C", "℃":
+			unit = [2]string{"摄氏度", "degrees Celsius"}
+		case "°F", "℉":
+			unit = [2]string{"华氏度", "degrees Fahrenheit"}
+		default:
+			unit = [2]string{"度", "degrees"}
+		}
+		if zh {
+			if strings.HasPrefix(number, "-") {
+				return "零下" + number[1:] + unit[0]
+			}
+			return number + unit[0]
+		}
+		if strings.HasPrefix(number, "-") {
+			number = "minus " + number[1:]
+		}
+		return number + " " + unit[1]
+	})
+
+	text = reSpeechUnit.ReplaceAllStringFunc(text, func(m string) string {
+		parts := reSpeechUnit.FindStringSubmatch(m)
+		unit := speechUnits[parts[2]]
+		if zh {
+			return parts[1] + unit[0] + parts[3]
+		}
+		return parts[1] + " " + unit[1] + parts[3]
+	})
+
+	if !zh {
+		text = reSpeechOrdinal.ReplaceAllStringFunc(text, func(m string) string {
+			parts := reSpeechOrdinal.FindStringSubmatch(m)
+			n, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return m
+			}
+			return enOrdinal(n) + parts[3]
+		})
+	}
+
+	text = reSpeechFraction.ReplaceAllStringFunc(text, func(m string) string {
+		parts := reSpeechFraction.FindStringSubmatch(m)
+		numerator, _ := strconv.ParseInt(parts[2], 10, 64)
+		denominator, _ := strconv.ParseInt(parts[3], 10, 64)
+		if denominator == 0 {
+			return m
+		}
+		if zh {
+			return parts[1] + zhNumber(denominator) + "分之" + zhNumber(numerator) + parts[4]
+		}
+		return parts[1] + enNumber(numerator) + " over " + enNumber(denominator) + parts[4]
+	})
+
+	text = speechAbbreviationReplacers[language].Replace(text)
+
+	text = replaceSpeechNumbers(text, zh)
+	if !zh {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	return strings.TrimSpace(text)
+}
+
+// validSpeechDate 判断年月日是否为有效日期，如2025-02-30不是有效日期
+func validSpeechDate(year, month, day int) bool {
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+	return day <= time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// speechCode 将无效日期等数字编号按位朗读，分隔符读出名称，如2025-02-30读作二零二五杠零二杠三零
+func speechCode(code string, zh bool) string {
+	var words []string
+	var sb strings.Builder
+	for _, r := range code {
+		switch separator, ok := speechSeparators[r]; {
+		case r >= '0' && r <= '9' && zh:
+			sb.WriteString(zhDigitNames[r-'0'])
+		case r >= '0' && r <= '9':
+			words = append(words, enOnes[r-'0'])
+		case ok && zh:
+			sb.WriteString(separator[0])
+		case ok:
+			words = append(words, separator[1])
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if zh {
+		return sb.String()
+	}
+	return strings.Join(words, " ")
+}
+
+// speechPlural 货币金额的英文读法，金额为1时使用单数
+func speechPlural(amount string, names [3]string) string {
+	if amount == "1" {
+		return amount + " " + names[1]
+	}
+	return amount + " " + names[2]
+}
+
+// replaceSpeechNumbers 将剩余的数字转为朗读形式
+// 英文中与字母相连的数字（如5G、MP3）保持不变；以0开头或超过10位的整数按位朗读，如电话号码
+func replaceSpeechNumbers(text string, zh bool) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range reSpeechNumber.FindAllStringSubmatchIndex(text, -1) {
+		prefix := text[loc[2]:loc[3]]
+		sign := text[loc[4]:loc[5]]
+		number := text[loc[6]:loc[7]]
+		next, _ := utf8.DecodeRuneInString(text[loc[1]:])
+
+		sb.WriteString(text[last:loc[0]])
+		last = loc[1]
+		if !zh && (next < utf8.RuneSelf && (next >= 'A' && next <= 'Z' || next >= 'a' && next <= 'z')) {
+			sb.WriteString(text[loc[0]:loc[1]])
+			continue
+		}
+
+		sb.WriteString(prefix)
+		if sign != "" {
+			if zh {
+				sb.WriteString("负")
+			} else {
+				sb.WriteString("minus ")
+			}
+		}
+		whole, fraction, hasFraction := strings.Cut(number, ".")
+		switch {
+		case zh && (len(whole) > 10 || (len(whole) > 1 && whole[0] == '0')):
+			sb.WriteString(zhDigits(whole))
+		case zh:
+			n, _ := strconv.ParseInt(whole, 10, 64)
+			if !hasFraction && strings.ContainsRune(zhMeasureWords, next) {
+				sb.WriteString(zhCount(n))
+			} else {
+				sb.WriteString(zhNumber(n))
+			}
+		case len(whole) > 10 || (len(whole) > 1 && whole[0] == '0'):
+			sb.WriteString(enDigits(whole))
+		default:
+			n, _ := strconv.ParseInt(whole, 10, 64)
+			sb.WriteString(enNumber(n))
+		}
+		if hasFraction {
+			if zh {
+				sb.WriteString("点" + zhDigits(fraction))
+			} else {
+				sb.WriteString(" point " + enDigits(fraction))
+			}
+		}
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+var (
+	zhDigitNames   = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	zhSectionUnits = []string{"", "万", "亿", "万亿"}
+)
+
+// zhDigits 按位读出数字，如2025读作二零二五
+func zhDigits(digits string) string {
+	var sb strings.Builder
+	for _, d := range digits {
+		if d >= '0' && d <= '9' {
+			sb.WriteString(zhDigitNames[d-'0'])
+		}
+	}
+	return sb.String()
+}
+
+// zhNumber 读出整数，如10005读作一万零五，15读作十五，22000读作两万两千
+func zhNumber(n int64) string {
+	if n < 0 {
+		return "负" + zhNumber(-n)
+	}
+	if n == 0 {
+		return zhDigitNames[0]
+	}
+	var sections []int
+	for ; n > 0; n /= 10000 {
+		sections = append(sections, int(n%10000))
+	}
+	if len(sections) > len(zhSectionUnits) {
+		return zhDigits(strconv.FormatInt(n, 10))
+	}
+
+	var sb strings.Builder
+	needZero := false
+	for i := len(sections) - 1; i >= 0; i-- {
+		section := sections[i]
+		if section == 0 {
+			needZero = sb.Len() > 0
+			continue
+		}
+		if sb.Len() > 0 && (needZero || section < 1000) {
+			sb.WriteString(zhDigitNames[0])
+		}
+		if section == 2 && i > 0 {
+			sb.WriteString("两" + zhSectionUnits[i])
+		} else {
+			sb.WriteString(zhSection(section) + zhSectionUnits[i])
+		}
+		needZero = false
+	}
+	return strings.TrimPrefix(sb.String(), "一十")
+}
+
+// zhSection 读出0到9999之间的整数，千位的2读作两
+func zhSection(n int) string {
+	units := []string{"千", "百", "十", ""}
+	var sb strings.Builder
+	zero := false
+	for i, base := range []int{1000, 100, 10, 1} {
+		d := n / base % 10
+		if d == 0 {
+			zero = sb.Len() > 0
+			continue
+		}
+		if zero {
+			sb.WriteString(zhDigitNames[0])
+			zero = false
+		}
+		if d == 2 && base == 1000 {
+			sb.WriteString("两" + units[i])
+		} else {
+			sb.WriteString(zhDigitNames[d] + units[i])
+		}
+	}
+	if strings.HasPrefix(sb.String(), "一十") && n < 20 {
+		return "十" + strings.TrimPrefix(sb.String(), "一十")
+	}
+	return sb.String()
+}
+
+// zhCount 读出量词前的整数，2读作两
+func zhCount(n int64) string {
+	if n == 2 {
+		return "两"
+	}
+	return zhNumber(n)
+}
+
+// zhTwoDigits 读出分钟和秒数，不足10时前面读零
+func zhTwoDigits(n int) string {
+	if n < 10 {
+		return zhDigitNames[0] + zhDigitNames[n]
+	}
+	return zhNumber(int64(n))
+}
+
+var (
+	enOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	enTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	enScales = []string{"", "thousand", "million", "billion", "trillion"}
+	enMonths = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	enOrdinalWords = map[string]string{
+		"one": "first", "two": "second", "three": "third", "five": "fifth",
+		"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+	}
+)
+
+// enDigits 按位读出数字
+func enDigits(digits string) string {
+	words := make([]string, 0, len(digits))
+	for _, d := range digits {
+		if d >= '0' && d <= '9' {
+			words = append(words, enOnes[d-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// enNumber 读出整数，如1234读作one thousand two hundred thirty-four
+func enNumber(n int64) string {
+	if n < 0 {
+		return "minus " + enNumber(-n)
+	}
+	if n < 20 {
+		return enOnes[n]
+	}
+	if n < 100 {
+		if n%10 == 0 {
+			return enTens[n/10]
+		}
+		return enTens[n/10] + "-" + enOnes[n%10]
+	}
+	if n < 1000 {
+		s := enOnes[n/100] + " hundred"
+		if n%100 > 0 {
+			s += " " + enNumber(n%100)
+		}
+		return s
+	}
+	var words []string
+	for i := 0; n > 0 && i < len(enScales); i++ {
+		if group := n % 1000; group > 0 {
+			word := enNumber(group)
+			if enScales[i] != "" {
+				word += " " + enScales[i]
+			}
+			words = append([]string{word}, words...)
+		}
+		n /= 1000
+	}
+	return strings.Join(words, " ")
+}
+
+// enOrdinal 读出序数词，如21读作twenty-first
+func enOrdinal(n int64) string {
+	words := enNumber(n)
+	i := strings.LastIndexAny(words, " -") + 1
+	last := words[i:]
+	if ordinal, ok := enOrdinalWords[last]; ok {
+		return words[:i] + ordinal
+	}
+	if strings.HasSuffix(last, "y") {
+		return words[:i] + strings.TrimSuffix(last, "y") + "ieth"
+	}
+	return words + "th"
+}
+
+// enYear 读出年份，如2025读作twenty twenty-five，2005读作two thousand five
+func enYear(year int) string {
+	switch {
+	case year%1000 < 10 && year >= 2000:
+		return enNumber(int64(year))
+	case year%100 == 0:
+		return enNumber(int64(year/100)) + " hundred"
+	case year%100 < 10:
+		return enNumber(int64(year/100)) + " oh " + enNumber(int64(year%100))
+	}
+	return enNumber(int64(year/100)) + " " + enNumber(int64(year%100))
+}
+
+// SplitForSpeech 将超过maxBytes字节的文本依次在句末标点、句中标点和空格处切分，不会切断UTF-8字符
+// maxBytes小于一个字符的长度时每段只包含一个字符
+func SplitForSpeech(text string, maxBytes int) []string {
+	text = strings.TrimSpace(text)
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		if text == "" {
+			return nil
+		}
+		return []string{text}
+	}
+
+	var pieces []string
+	for len(text) > maxBytes {
+		end := maxBytes
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		cut := speechCutPoint(text[:end])
+		if cut <= 0 {
+			cut = end
+		}
+		if cut == 0 {
+			// maxBytes小于一个字符的长度时至少前进一个字符，避免死循环
+			_, cut = utf8.DecodeRuneInString(text)
+		}
+		if piece := strings.TrimSpace(text[:cut]); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		pieces = append(pieces, text)
+	}
+	return pieces
+}
+
+// speechCutPoint 在window的后三分之二中查找切分位置，依次尝试句末标点、句中标点和空格，找不到时返回0
+// 尖括号标记内部的位置不作为切分位置
+func speechCutPoint(window string) int {
+	min := len(window) / 3
+	for _, marks := range []string{"。！？；!?;\n", "，、：,:", " "} {
+		best := 0
+		for _, mark := range marks {
+			if i := lastIndexOutsideTag(window, string(mark), 0); i >= min {
+				if end := i + utf8.RuneLen(mark); end > best {
+					best = end
+				}
+			}
+		}
+		if best > 0 {
+			return best
+		}
+	}
+	return 0
+}
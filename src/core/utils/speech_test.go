@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizeForSpeech(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		language string
+		expected string
+	}{
+		{
+			name:     "中文日期",
+			input:    "今天是2025-10-16",
+			language: "zh",
+			expected: "今天是二零二五年十月十六日",
+		},
+		{
+			name:     "中文时间和量词",
+			input:    "会议10:05开始，2个人参加",
+			language: "zh",
+			expected: "会议十点零五分开始，两个人参加",
+		},
+		{
+			name:     "中文温度和单位",
+			input:    "气温-5℃，风速3.5km/h，湿度50%",
+			language: "zh",
+			expected: "气温零下五摄氏度，风速三点五公里每小时，湿度百分之五十",
+		},
+		{
+			name:     "中文货币和千分位",
+			input:    "共¥1,234,567",
+			language: "zh",
+			expected: "共一百二十三万四千五百六十七元",
+		},
+		{
+			name:     "中文范围和分数",
+			input:    "20-25℃，3/4的人",
+			language: "zh",
+			expected: "二十到二十五摄氏度，四分之三的人",
+		},
+		{
+			name:     "中文电话号码按位读",
+			input:    "电话13800138000",
+			language: "zh",
+			expected: "电话一三八零零一三八零零零",
+		},
+		{
+			name:     "中文零的读法",
+			input:    "10005人，110次",
+			language: "zh",
+			expected: "一万零五人，一百一十次",
+		},
+		{
+			name:     "中文千万亿前的2读作两",
+			input:    "2000人，22000元，2亿",
+			language: "zh",
+			expected: "两千人，两万两千元，两亿",
+		},
+		{
+			name:     "中文无效日期按编号读",
+			input:    "编号2025-13-45",
+			language: "zh",
+			expected: "编号二零二五杠一三杠四五",
+		},
+		{
+			name:     "中文日期超出当月天数",
+			input:    "2025-02-30",
+			language: "zh",
+			expected: "二零二五杠零二杠三零",
+		},
+		{
+			name:     "英文日期超出当月天数",
+			input:    "Due 2024-02-30",
+			language: "en",
+			expected: "Due two zero two four dash zero two dash three zero",
+		},
+		{
+			name:     "英文日期",
+			input:    "Today is 2025-10-16.",
+			language: "en",
+			expected: "Today is October sixteenth, twenty twenty-five.",
+		},
+		{
+			name:     "英文时间和缩写",
+			input:    "Meet Dr. Smith at 10:05",
+			language: "en",
+			expected: "Meet Doctor Smith at ten oh five",
+		},
+		{
+			name:     "英文货币",
+			input:    "It costs $12.50 or €1",
+			language: "en",
+			expected: "It costs twelve dollars and fifty cents or one euro",
+		},
+		{
+			name:     "英文人民币按小数读",
+			input:    "It costs ¥12.50",
+			language: "en",
+			expected: "It costs twelve point five yuan",
+		},
+		{
+			name:     "英文不足一英镑只读便士",
+			input:    "only £0.99 or £1.01",
+			language: "en",
+			expected: "only ninety-nine pence or one pound and one penny",
+		},
+		{
+			name:     "英文电话号码按位读",
+			input:    "call 13800138000",
+			language: "en",
+			expected: "call one three eight zero zero one three eight zero zero zero",
+		},
+		{
+			name:     "英文单位和序数",
+			input:    "25°C, 50% and 1st place",
+			language: "en",
+			expected: "twenty-five degrees Celsius, fifty percent and first place",
+		},
+		{
+			name:     "英文与字母相连的数字保持不变",
+			input:    "a 5G phone",
+			language: "en",
+			expected: "a 5G phone",
+		},
+		{
+			name:     "自动判断语言",
+			input:    "明天25°C",
+			language: "",
+			expected: "明天二十五摄氏度",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NormalizeForSpeech(tt.input, tt.language)
+			if result != tt.expected {
+				t.Errorf("NormalizeForSpeech(%q, %q) = %q, want %q", tt.input, tt.language, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitForSpeech(t *testing.T) {
+	t.Run("短文本不切分", func(t *testing.T) {
+		result := SplitForSpeech("你好。", 255)
+		if len(result) != 1 || result[0] != "你好。" {
+			t.Errorf("SplitForSpeech = %q, want [\"你好。\"]", result)
+		}
+	})
+
+	t.Run("在句末标点处切分", func(t *testing.T) {
+		text := strings.Repeat("这是一个很长的句子，用来测试切分。", 30)
+		for _, piece := range SplitForSpeech(text, 255) {
+			if len(piece) > 255 {
+				t.Errorf("分段长度 %d 超过上限", len(piece))
+			}
+			if !strings.HasSuffix(piece, "。") {
+				t.Errorf("分段没有在句末切分: %q", piece)
+			}
+		}
+	})
+
+	t.Run("上限小于一个字符时逐字切分", func(t *testing.T) {
+		pieces := SplitForSpeech("你好世界", 2)
+		if strings.Join(pieces, "") != "你好世界" || len(pieces) != 4 {
+			t.Errorf("SplitForSpeech = %q, want 4个单字分段", pieces)
+		}
+	})
+
+	t.Run("没有标点时不切断字符", func(t *testing.T) {
+		text := strings.Repeat("一二三四五六七八九十", 10)
+		pieces := SplitForSpeech(text, 10)
+		if strings.Join(pieces, "") != text {
+			t.Errorf("切分后内容不一致: %q", pieces)
+		}
+		for _, piece := range pieces {
+			if !utf8.ValidString(piece) || len(piece) > 10 {
+				t.Errorf("分段无效: %q", piece)
+			}
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 var (
 	// 预编译正则表达式
 	reSplitString          = regexp.MustCompile(`[.,!?;。！？；：]+`)
+	reMarkupTag            = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
 	reMarkdownChars        = regexp.MustCompile(`(\*\*|__|\*|_|#{1,6}\s|` + "`" + `{1,3}|~~|>\s|\[.*?\]\(.*?\)|\!\[.*?\]\(.*?\)|\|.*?\|)`)
 	reRemoveAllPunctuation = regexp.MustCompile(
 		`[.,!?;:，。！？、；：""''「」『』（）\(\)【】\[\]{}《》〈〉—–\-_~·…‖\|\\/*&\^%\$#@\+=<>]`,
@@ -105,9 +106,7 @@ func findLastPunctuationWithMinLength(text string, punctuations []string, minLen
 
 	for _, punct := range punctuations {
 		// 从最小长度位置开始查找
-		searchText := text[minLength:]
-		if idx := strings.LastIndex(searchText, punct); idx != -1 {
-			actualIdx := idx + minLength
+		if actualIdx := lastIndexOutsideTag(text, punct, minLength); actualIdx != -1 {
 			if actualIdx > lastIndex {
 				lastIndex = actualIdx
 				foundPunctuation = punct
@@ -138,22 +137,57 @@ func findLastSpaceWithMinLength(text string, minLength int) (string, int) {
 	}
 
 	// 从最小长度位置开始查找空格
-	searchText := text[minLength:]
-	if idx := strings.LastIndex(searchText, " "); idx != -1 {
-		actualIdx := idx + minLength
+	if actualIdx := lastIndexOutsideTag(text, " ", minLength); actualIdx != -1 {
 		return text[:actualIdx], actualIdx
 	}
 	return "", 0
 }
 
+// lastIndexOutsideTag 查找sub在text[from:]中最后一次出现且不在尖括号标记或情绪标签内部的位置，避免切断标记
+func lastIndexOutsideTag(text string, sub string, from int) int {
+	end := len(text)
+	for end > from {
+		idx := strings.LastIndex(text[from:end], sub)
+		if idx == -1 {
+			return -1
+		}
+		idx += from
+		if !insideTag(text, idx) && !insideEmotionTag(text, idx) {
+			return idx
+		}
+		end = idx
+	}
+	return -1
+}
+
+// insideTag 位置i是否在尚未闭合的尖括号标记内部，标记以<加字母或/开头
+func insideTag(text string, i int) bool {
+	open := strings.LastIndex(text[:i], "<")
+	if open == -1 || open < strings.LastIndex(text[:i], ">") || open+1 >= len(text) {
+		return false
+	}
+	c := text[open+1]
+	return c == '/' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
 // SplitByPunctuation 使用正则表达式分割文本
 func SplitByPunctuation(text string) []string {
+	// 尖括号标记（如韵律标记）中的标点不作为分隔符，分割前先替换为占位符
+	tags := reMarkupTag.FindAllString(text, -1)
+	if len(tags) > 0 {
+		text = reMarkupTag.ReplaceAllString(text, "\x00")
+	}
+
 	// 使用正则表达式分割文本
 	parts := reSplitString.Split(text, -1)
 
 	// 过滤掉空字符串
 	var result []string
 	for _, part := range parts {
+		for strings.Contains(part, "\x00") && len(tags) > 0 {
+			part = strings.Replace(part, "\x00", tags[0], 1)
+			tags = tags[1:]
+		}
 		if strings.TrimSpace(part) != "" {
 			result = append(result, part)
 		}
@@ -0,0 +1,103 @@
+package vad
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// energyWindow 能量计算的窗口时长
+const energyWindow = 20 * time.Millisecond
+
+// EnergyVAD 基于短时能量的语音活动检测
+// 按20ms窗口计算归一化RMS能量，超过阈值的窗口视为语音，
+// 连续语音/静音的时长达到配置值时切换说话状态
+type EnergyVAD struct {
+	config       *Config
+	windowBytes  int
+	pending      []byte // 不足一个窗口的剩余数据
+	speaking     bool
+	speechRun    time.Duration // 当前连续语音时长
+	silenceRun   time.Duration // 当前连续静音时长
+	totalElapsed time.Duration // 已处理的音频总时长
+}
+
+// NewEnergyVAD 创建能量检测VAD
+func NewEnergyVAD(config *Config) *EnergyVAD {
+	samples := int(int64(config.SampleRate) * int64(energyWindow) / int64(time.Second))
+	return &EnergyVAD{
+		config:      config,
+		windowBytes: samples * 2,
+	}
+}
+
+// Process 处理一段PCM数据，返回其中检测到的事件
+func (v *EnergyVAD) Process(pcm []byte) []Event {
+	var events []Event
+	data := pcm
+	if len(v.pending) > 0 {
+		data = append(v.pending, pcm...)
+		v.pending = nil
+	}
+
+	for len(data) >= v.windowBytes {
+		window := data[:v.windowBytes]
+		data = data[v.windowBytes:]
+		v.totalElapsed += energyWindow
+
+		if Energy(window) >= v.config.Threshold {
+			v.speechRun += energyWindow
+			v.silenceRun = 0
+			if !v.speaking && v.speechRun >= v.config.MinSpeech {
+				v.speaking = true
+				events = append(events, Event{Type: EventSpeechStart, Offset: v.totalElapsed - v.speechRun})
+			}
+		} else {
+			v.silenceRun += energyWindow
+			v.speechRun = 0
+			if v.speaking && v.silenceRun >= v.config.MinSilence {
+				v.speaking = false
+				events = append(events, Event{Type: EventSpeechEnd, Offset: v.totalElapsed - v.silenceRun})
+			}
+		}
+	}
+
+	if len(data) > 0 {
+		v.pending = append([]byte(nil), data...)
+	}
+	return events
+}
+
+// IsSpeaking 当前是否处于说话状态
+func (v *EnergyVAD) IsSpeaking() bool {
+	return v.speaking
+}
+
+// Reset 复位检测状态
+func (v *EnergyVAD) Reset() {
+	v.pending = nil
+	v.speaking = false
+	v.speechRun = 0
+	v.silenceRun = 0
+	v.totalElapsed = 0
+}
+
+// Energy 计算16位小端PCM数据的归一化RMS能量，范围0~1
+func Energy(pcm []byte) float64 {
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < samples; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768.0
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(samples))
+}
+
+func init() {
+	Register("energy", func(config *Config) (VAD, error) {
+		return NewEnergyVAD(config), nil
+	})
+}
@@ -0,0 +1,140 @@
+package vad
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+const testSampleRate = 16000
+
+// tone 生成指定时长和振幅（0~1）的正弦波PCM数据
+func tone(d time.Duration, amplitude float64) []byte {
+	samples := int(int64(testSampleRate) * int64(d) / int64(time.Second))
+	pcm := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		value := amplitude * 32767 * math.Sin(2*math.Pi*440*float64(i)/testSampleRate)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(value)))
+	}
+	return pcm
+}
+
+func newTestVAD(t *testing.T) VAD {
+	t.Helper()
+	detector, err := Create(&Config{SampleRate: testSampleRate})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return detector
+}
+
+func TestEnergy(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []byte
+		min, max float64
+	}{
+		{name: "空数据", pcm: nil, min: 0, max: 0},
+		{name: "静音", pcm: tone(20*time.Millisecond, 0), min: 0, max: 0},
+		{name: "满幅正弦波", pcm: tone(20*time.Millisecond, 1), min: 0.70, max: 0.71},
+		{name: "半幅正弦波", pcm: tone(20*time.Millisecond, 0.5), min: 0.35, max: 0.36},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Energy(tt.pcm)
+			if result < tt.min || result > tt.max {
+				t.Errorf("Energy() = %f, want [%f, %f]", result, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestEnergyVADEvents(t *testing.T) {
+	t.Run("语音和静音达到时长后触发事件", func(t *testing.T) {
+		detector := newTestVAD(t)
+		if events := detector.Process(tone(200*time.Millisecond, 0)); len(events) != 0 {
+			t.Fatalf("静音产生了事件: %v", events)
+		}
+
+		events := detector.Process(tone(300*time.Millisecond, 0.5))
+		if len(events) != 1 || events[0].Type != EventSpeechStart {
+			t.Fatalf("语音开始事件 = %v, want [speech_start]", events)
+		}
+		if events[0].Offset != 200*time.Millisecond {
+			t.Errorf("语音开始位置 = %s, want 200ms", events[0].Offset)
+		}
+		if !detector.IsSpeaking() {
+			t.Error("检测到语音后IsSpeaking() = false")
+		}
+
+		if events := detector.Process(tone(500*time.Millisecond, 0)); len(events) != 0 {
+			t.Fatalf("静音未达到MinSilence就产生了事件: %v", events)
+		}
+		events = detector.Process(tone(400*time.Millisecond, 0))
+		if len(events) != 1 || events[0].Type != EventSpeechEnd {
+			t.Fatalf("语音结束事件 = %v, want [speech_end]", events)
+		}
+		if events[0].Offset != 500*time.Millisecond {
+			t.Errorf("语音结束位置 = %s, want 500ms", events[0].Offset)
+		}
+		if detector.IsSpeaking() {
+			t.Error("说话结束后IsSpeaking() = true")
+		}
+	})
+
+	t.Run("短促噪声不算开始说话", func(t *testing.T) {
+		detector := newTestVAD(t)
+		pcm := append(tone(60*time.Millisecond, 0.5), tone(100*time.Millisecond, 0)...)
+		if events := detector.Process(pcm); len(events) != 0 {
+			t.Errorf("短促噪声产生了事件: %v", events)
+		}
+	})
+
+	t.Run("不足一个窗口的数据累积到下次处理", func(t *testing.T) {
+		detector := newTestVAD(t)
+		pcm := tone(200*time.Millisecond, 0.5)
+		var events []Event
+		for len(pcm) > 0 {
+			n := 101 // 奇数长度，跨越窗口和采样边界
+			if n > len(pcm) {
+				n = len(pcm)
+			}
+			events = append(events, detector.Process(pcm[:n])...)
+			pcm = pcm[n:]
+		}
+		if len(events) != 1 || events[0].Type != EventSpeechStart || events[0].Offset != 0 {
+			t.Errorf("分块输入的事件 = %v, want [speech_start@0]", events)
+		}
+	})
+
+	t.Run("复位后重新检测", func(t *testing.T) {
+		detector := newTestVAD(t)
+		detector.Process(tone(200*time.Millisecond, 0.5))
+		detector.Reset()
+		if detector.IsSpeaking() {
+			t.Error("复位后IsSpeaking() = true")
+		}
+		events := detector.Process(tone(200*time.Millisecond, 0.5))
+		if len(events) != 1 || events[0].Offset != 0 {
+			t.Errorf("复位后的事件 = %v, want [speech_start@0]", events)
+		}
+	})
+}
+
+func TestCreate(t *testing.T) {
+	if _, err := Create(&Config{Type: "unknown", SampleRate: testSampleRate}); err == nil {
+		t.Error("未知类型应返回错误")
+	}
+	if _, err := Create(&Config{SampleRate: 0}); err == nil {
+		t.Error("无效采样率应返回错误")
+	}
+	config := &Config{SampleRate: testSampleRate}
+	if _, err := Create(config); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if config.Threshold != DefaultThreshold || config.MinSpeech != DefaultMinSpeech || config.MinSilence != DefaultMinSilence {
+		t.Errorf("未设置的参数没有使用默认值: %+v", config)
+	}
+}
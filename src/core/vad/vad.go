@@ -0,0 +1,94 @@
+package vad
+
+import (
+	"fmt"
+	"time"
+)
+
+// 默认参数与ASR基础提供者的静音检测默认值保持一致
+const (
+	DefaultThreshold  = 0.01
+	DefaultMinSpeech  = 100 * time.Millisecond
+	DefaultMinSilence = 800 * time.Millisecond
+)
+
+// Config VAD配置结构
+type Config struct {
+	Type       string
+	SampleRate int           // 输入PCM的采样率
+	Threshold  float64       // 判定为语音的能量阈值（归一化RMS，0~1）
+	MinSpeech  time.Duration // 连续语音超过该时长才判定为开始说话
+	MinSilence time.Duration // 说话后连续静音超过该时长判定为说话结束
+}
+
+// EventType VAD事件类型
+type EventType int
+
+const (
+	EventSpeechStart EventType = iota // 开始说话
+	EventSpeechEnd                    // 说话结束
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSpeechStart:
+		return "speech_start"
+	case EventSpeechEnd:
+		return "speech_end"
+	default:
+		return "unknown"
+	}
+}
+
+// Event VAD事件
+type Event struct {
+	Type EventType
+	// Offset 事件发生的位置，相对于检测开始后输入音频的总时长
+	Offset time.Duration
+}
+
+// VAD 语音活动检测接口
+// 输入为16位小端单声道PCM数据，实现不要求线程安全
+type VAD interface {
+	// Process 处理一段PCM数据，返回其中检测到的事件
+	Process(pcm []byte) []Event
+	// IsSpeaking 当前是否处于说话状态
+	IsSpeaking() bool
+	// Reset 复位检测状态
+	Reset()
+}
+
+// Factory VAD工厂函数类型
+type Factory func(config *Config) (VAD, error)
+
+var factories = make(map[string]Factory)
+
+// Register 注册VAD实现
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Create 创建VAD实例，未指定类型时使用能量检测
+func Create(config *Config) (VAD, error) {
+	name := config.Type
+	if name == "" {
+		name = "energy"
+	}
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的VAD类型: %s", name)
+	}
+	if config.SampleRate <= 0 {
+		return nil, fmt.Errorf("无效的采样率: %d", config.SampleRate)
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultThreshold
+	}
+	if config.MinSpeech <= 0 {
+		config.MinSpeech = DefaultMinSpeech
+	}
+	if config.MinSilence <= 0 {
+		config.MinSilence = DefaultMinSilence
+	}
+	return factory(config)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"angrymiao-ai-server/src/core/auth/am_token"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorResponder 各处理器按自己的响应格式返回错误
+type errorResponder func(c *gin.Context, statusCode int, message string, err error)
+
+// jwtMiddleware JWT认证中间件，验证通过后把用户ID和token声明存入上下文
+// admin为true时只允许管理员角色访问
+func jwtMiddleware(admin bool, respondError errorResponder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			respondError(c, http.StatusUnauthorized, "无效的认证token或token已过期", nil)
+			c.Abort()
+			return
+		}
+
+		claims, err := am_token.ParseToken(authHeader[7:]) // 移除"Bearer "前缀
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, "token验证失败", err)
+			c.Abort()
+			return
+		}
+		if admin && claims.Role != "admin" {
+			respondError(c, http.StatusForbidden, "需要管理员权限", nil)
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", uint(claims.UserID))
+		c.Set("jwt_claims", claims)
+		c.Next()
+	}
+}
+
+// contextUserID 从JWT认证中间件设置的上下文中获取用户ID，未认证时返回空字符串
+func contextUserID(c *gin.Context) string {
+	if userID, exists := c.Get("user_id"); exists {
+		if uid, ok := userID.(uint); ok {
+			return strconv.FormatUint(uint64(uid), 10)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/moderation"
+	"angrymiao-ai-server/src/core/persona"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultChatModel 请求未指定角色时返回的模型名称
+const defaultChatModel = "default"
+
+// ChatCompletionsHandler OpenAI兼容的对话接口处理器
+// 每个请求创建一个无音频会话，复用语音对话的系统提示词、角色、MCP工具和用户自定义函数
+type ChatCompletionsHandler struct {
+	config            *configs.Config
+	poolManager       *pool.PoolManager
+	userConfigService services.UserAIConfigService
+	quotaManager      *quota.Manager
+	personaManager    *persona.Manager
+	moderationManager *moderation.Manager
+	logger            *utils.Logger
+}
+
+// NewChatCompletionsHandler 创建对话接口处理器
+func NewChatCompletionsHandler(
+	config *configs.Config,
+	poolManager *pool.PoolManager,
+	userConfigService services.UserAIConfigService,
+	logger *utils.Logger,
+) *ChatCompletionsHandler {
+	return &ChatCompletionsHandler{
+		config:            config,
+		poolManager:       poolManager,
+		userConfigService: userConfigService,
+		logger:            logger,
+	}
+}
+
+// SetQuotaManager 设置用量配额管理器，为nil时不限制用量
+func (h *ChatCompletionsHandler) SetQuotaManager(manager *quota.Manager) {
+	h.quotaManager = manager
+}
+
+// SetPersonaManager 设置角色管理器，为nil时只能使用配置中的角色
+func (h *ChatCompletionsHandler) SetPersonaManager(manager *persona.Manager) {
+	h.personaManager = manager
+}
+
+// SetModerationManager 设置内容审核管理器，为nil时不审核用户输入和回复
+func (h *ChatCompletionsHandler) SetModerationManager(manager *moderation.Manager) {
+	h.moderationManager = manager
+}
+
+// RegisterRoutes 注册路由
+func (h *ChatCompletionsHandler) RegisterRoutes(router gin.IRouter) {
+	v1Group := router.Group("/v1")
+	v1Group.Use(jwtMiddleware(false, h.respondError))
+	{
+		v1Group.POST("/chat/completions", h.ChatCompletions)
+	}
+}
+
+// chatCompletionRequest OpenAI兼容的对话请求，model为角色名称或别名，为空时使用默认提示词
+type chatCompletionRequest struct {
+	Model    string                  `json:"model"`
+	Messages []chatCompletionMessage `json:"messages" binding:"required"`
+	Stream   bool                    `json:"stream"`
+}
+
+// chatCompletionMessage 对话消息，content可以是字符串或内容片段数组
+type chatCompletionMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text 取出消息中的文本内容，非文本片段被忽略
+func (m chatCompletionMessage) text() string {
+	var content string
+	if err := json.Unmarshal(m.Content, &content); err == nil {
+		return content
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type chatCompletionChoice struct {
+	Index        int               `json:"index"`
+	Message      *chatResponseText `json:"message,omitempty"`
+	Delta        *chatResponseText `json:"delta,omitempty"`
+	FinishReason *string           `json:"finish_reason"`
+}
+
+type chatResponseText struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// chatCompletionResponse 对话响应，在OpenAI格式的基础上增加本轮的工具调用记录
+type chatCompletionResponse struct {
+	ID         string                 `json:"id"`
+	Object     string                 `json:"object"`
+	Created    int64                  `json:"created"`
+	Model      string                 `json:"model"`
+	Choices    []chatCompletionChoice `json:"choices"`
+	ToolTraces []core.ToolTrace       `json:"tool_traces,omitempty"`
+	ToolTrace  *core.ToolTrace        `json:"tool_trace,omitempty"`
+}
+
+// ChatCompletions 对话接口
+// @Summary OpenAI兼容的对话接口
+// @Description 使用与语音对话相同的人设和工具生成文本回复，stream为true时以SSE流式返回，响应中附带工具调用记录
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Param request body chatCompletionRequest true "对话请求"
+// @Success 200 {object} chatCompletionResponse "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /v1/chat/completions [post]
+func (h *ChatCompletionsHandler) ChatCompletions(c *gin.Context) {
+	var req chatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	messages, err := convertChatMessages(req.Messages)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求消息无效", err)
+		return
+	}
+
+	providerSet, err := h.poolManager.GetProviderSet()
+	if err != nil {
+		h.respondError(c, http.StatusServiceUnavailable, "获取提供者失败", err)
+		return
+	}
+	defer func() {
+		if err := h.poolManager.ReturnProviderSet(providerSet); err != nil {
+			h.logger.Error("对话接口归还资源失败: %v", err)
+		}
+	}()
+
+	handler := core.NewConnectionHandler(h.config, providerSet, h.logger, h.sessionRequest(c), c.Request.Context())
+	handler.SetUserConfigService(h.userConfigService)
+	if h.personaManager != nil {
+		handler.SetPersonaManager(h.personaManager)
+	}
+	if h.moderationManager != nil {
+		handler.SetModerationManager(h.moderationManager)
+	}
+	defer handler.Close()
+	if h.quotaManager != nil {
+		handler.SetQuotaManager(h.quotaManager)
+		if err := handler.CheckQuota(quota.ResourceLLMTokens); err != nil {
+			h.respondError(c, http.StatusTooManyRequests, "今日用量已达上限", err)
+			return
+		}
+	}
+
+	model := req.Model
+	if model == "" {
+		model = defaultChatModel
+	} else if model != defaultChatModel && !handler.UseRole(model) {
+		h.respondError(c, http.StatusBadRequest, "角色不存在", fmt.Errorf("没有名为 %s 的角色", model))
+		return
+	}
+
+	response := chatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+	if req.Stream {
+		h.streamChat(c, handler, messages, response)
+	} else {
+		h.completeChat(c, handler, messages, response)
+	}
+}
+
+// completeChat 等待本轮对话完成后一次性返回回复文本和工具调用记录
+func (h *ChatCompletionsHandler) completeChat(c *gin.Context, handler *core.ConnectionHandler, messages []chat.Message, response chatCompletionResponse) {
+	var content strings.Builder
+	err := handler.StartHeadless(func(event core.HeadlessEvent) {
+		if event.Tool != nil {
+			response.ToolTraces = append(response.ToolTraces, *event.Tool)
+			return
+		}
+		content.WriteString(event.Text)
+	})
+	if err == nil {
+		err = handler.Chat(c.Request.Context(), messages)
+	}
+	finishReason := "stop"
+	if errors.Is(err, core.ErrInputBlocked) {
+		finishReason = "content_filter"
+	} else if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "生成回复失败", err)
+		return
+	}
+
+	response.Object = "chat.completion"
+	response.Choices = []chatCompletionChoice{{
+		Message:      &chatResponseText{Role: "assistant", Content: content.String()},
+		FinishReason: &finishReason,
+	}}
+	c.JSON(http.StatusOK, response)
+}
+
+// streamChat 以SSE流式返回回复文本增量，工具调用记录通过tool_trace字段单独下发
+func (h *ChatCompletionsHandler) streamChat(c *gin.Context, handler *core.ConnectionHandler, messages []chat.Message, response chatCompletionResponse) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	response.Object = "chat.completion.chunk"
+	send := func(delta chatResponseText, trace *core.ToolTrace, finishReason *string) {
+		chunk := response
+		chunk.ToolTrace = trace
+		chunk.Choices = []chatCompletionChoice{{Delta: &delta, FinishReason: finishReason}}
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			h.logger.Error("序列化对话响应失败: %v", err)
+			return
+		}
+		fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		c.Writer.Flush()
+	}
+
+	send(chatResponseText{Role: "assistant"}, nil, nil)
+	err := handler.StartHeadless(func(event core.HeadlessEvent) {
+		if event.Tool != nil {
+			send(chatResponseText{}, event.Tool, nil)
+			return
+		}
+		if event.Text != "" {
+			send(chatResponseText{Content: event.Text}, nil, nil)
+		}
+	})
+	if err == nil {
+		err = handler.Chat(c.Request.Context(), messages)
+	}
+
+	finishReason := "stop"
+	if errors.Is(err, core.ErrInputBlocked) {
+		finishReason = "content_filter"
+	} else if err != nil {
+		h.logger.Error("对话接口生成回复失败: %v", err)
+		finishReason = "error"
+	}
+	send(chatResponseText{}, nil, &finishReason)
+	fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+// sessionRequest 构造会话使用的请求，携带用户ID和预加载的用户自定义函数
+func (h *ChatCompletionsHandler) sessionRequest(c *gin.Context) *http.Request {
+	req := c.Request.Clone(c.Request.Context())
+	userID := contextUserID(c)
+	req.Header.Set("User-Id", userID)
+	if req.Header.Get("Transport-Type") == "" {
+		req.Header.Set("Transport-Type", "http")
+	}
+
+	userConfigs, err := h.userConfigService.GetUserConfigs(req.Context(), userID)
+	if err != nil {
+		h.logger.Warn("预加载用户配置失败: %v", err)
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), "user_configs", userConfigs))
+}
+
+// convertChatMessages 转换请求中的历史消息，只保留用户和助手的文本消息
+// 系统提示词由服务端的人设决定，请求中的system消息被忽略
+func convertChatMessages(messages []chatCompletionMessage) ([]chat.Message, error) {
+	var result []chat.Message
+	for _, msg := range messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		text := msg.text()
+		if text == "" {
+			continue
+		}
+		result = append(result, chat.Message{Role: msg.Role, Content: text})
+	}
+	if len(result) == 0 || result[len(result)-1].Role != "user" {
+		return nil, errors.New("最后一条消息必须是非空的用户消息")
+	}
+	return result, nil
+}
+
+// respondError 以OpenAI的错误格式返回错误响应
+func (h *ChatCompletionsHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	h.logger.Error("%s: %v", message, err)
+
+	if err != nil {
+		message = message + ": " + err.Error()
+	}
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    http.StatusText(statusCode),
+			"code":    statusCode,
+		},
+	})
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"angrymiao-ai-server/src/core/persona"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownerKey 上下文中角色所属用户的键，管理员接口为空字符串，表示全局角色
+const ownerKey = "persona_owner"
+
+// PersonaHandler 角色管理处理器，用户管理自己的角色，管理员管理全局角色
+type PersonaHandler struct {
+	personaManager *persona.Manager
+	logger         *utils.Logger
+}
+
+// personaRequest 创建或更新角色请求结构，更新时未传的字段保持不变
+type personaRequest struct {
+	Name        *string           `json:"name"`
+	Aliases     []string          `json:"aliases"`
+	Prompt      *string           `json:"prompt"`
+	Voices      map[string]string `json:"voices"` // TTS类型到音色的映射，如{"edge": "zh-CN-XiaoyiNeural"}
+	Greeting    *string           `json:"greeting"`
+	Tools       []string          `json:"tools"` // 可用的工具名称，为空时不限制
+	Temperature *float64          `json:"temperature"`
+	MaxTokens   *int              `json:"max_tokens"`
+	TopP        *float64          `json:"top_p"`
+	IsActive    *bool             `json:"is_active"`
+}
+
+// apply 将请求中的字段写入角色
+func (r *personaRequest) apply(p *persona.Persona) {
+	if r.Name != nil {
+		p.Name = *r.Name
+	}
+	if r.Aliases != nil {
+		p.Aliases = r.Aliases
+	}
+	if r.Prompt != nil {
+		p.Prompt = *r.Prompt
+	}
+	if r.Voices != nil {
+		p.Voices = r.Voices
+	}
+	if r.Greeting != nil {
+		p.Greeting = *r.Greeting
+	}
+	if r.Tools != nil {
+		p.Tools = r.Tools
+	}
+	if r.Temperature != nil {
+		p.LLM.Temperature = r.Temperature
+	}
+	if r.MaxTokens != nil {
+		p.LLM.MaxTokens = r.MaxTokens
+	}
+	if r.TopP != nil {
+		p.LLM.TopP = r.TopP
+	}
+	if r.IsActive != nil {
+		p.IsActive = *r.IsActive
+	}
+}
+
+// NewPersonaHandler 创建角色管理处理器
+func NewPersonaHandler(personaManager *persona.Manager, logger *utils.Logger) *PersonaHandler {
+	return &PersonaHandler{
+		personaManager: personaManager,
+		logger:         logger,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *PersonaHandler) RegisterRoutes(apiGroup *gin.RouterGroup) {
+	userGroup := apiGroup.Group("/personas")
+	userGroup.Use(jwtMiddleware(false, h.respondError), h.ownerMiddleware(false))
+	h.registerCRUD(userGroup)
+
+	adminGroup := apiGroup.Group("/admin/personas")
+	adminGroup.Use(jwtMiddleware(true, h.respondError), h.ownerMiddleware(true))
+	h.registerCRUD(adminGroup)
+}
+
+func (h *PersonaHandler) registerCRUD(group *gin.RouterGroup) {
+	group.GET("", h.ListPersonas)
+	group.POST("", h.CreatePersona)
+	group.GET("/:id", h.GetPersona)
+	group.PUT("/:id", h.UpdatePersona)
+	group.DELETE("/:id", h.DeletePersona)
+}
+
+// ListPersonas 获取角色列表
+// @Summary 获取角色列表
+// @Description 用户接口返回当前用户创建的角色和启用中的全局角色，管理员接口返回全部全局角色
+// @Tags 角色管理
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/personas [get]
+// @Router /api/admin/personas [get]
+func (h *PersonaHandler) ListPersonas(c *gin.Context) {
+	owner := c.GetString(ownerKey)
+	personas, err := h.personaManager.List(c.Request.Context(), owner)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "获取角色列表失败", err)
+		return
+	}
+	data := gin.H{
+		"personas": personas,
+		"total":    len(personas),
+	}
+
+	if owner != "" {
+		global, err := h.personaManager.List(c.Request.Context(), "")
+		if err != nil {
+			h.respondError(c, http.StatusInternalServerError, "获取全局角色失败", err)
+			return
+		}
+		active := make([]persona.Persona, 0, len(global))
+		for _, p := range global {
+			if p.IsActive {
+				active = append(active, p)
+			}
+		}
+		data["global"] = active
+	}
+	h.respondSuccess(c, data)
+}
+
+// CreatePersona 创建角色
+// @Summary 创建角色
+// @Description 创建角色，名称和提示词必填，名称和别名不能与已有角色重复
+// @Tags 角色管理
+// @Accept json
+// @Produce json
+// @Param persona body personaRequest true "角色信息"
+// @Success 201 {object} map[string]interface{} "创建成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/personas [post]
+// @Router /api/admin/personas [post]
+func (h *PersonaHandler) CreatePersona(c *gin.Context) {
+	var req personaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+
+	p := &persona.Persona{
+		UserID:   c.GetString(ownerKey),
+		IsActive: true,
+	}
+	req.apply(p)
+	if err := h.personaManager.Create(c.Request.Context(), p); err != nil {
+		h.respondPersonaError(c, "创建角色失败", err)
+		return
+	}
+
+	h.logger.Info("创建角色成功: %s (ID: %d, 用户: %s)", p.Name, p.ID, p.UserID)
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "角色创建成功",
+		"data":    p,
+	})
+}
+
+// GetPersona 获取角色详情
+// @Summary 获取角色详情
+// @Description 获取当前用户创建的角色，管理员接口获取全局角色
+// @Tags 角色管理
+// @Produce json
+// @Param id path int true "角色ID"
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "角色不存在"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/personas/{id} [get]
+// @Router /api/admin/personas/{id} [get]
+func (h *PersonaHandler) GetPersona(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的角色ID", err)
+		return
+	}
+
+	p, err := h.personaManager.Get(c.Request.Context(), c.GetString(ownerKey), uint(id))
+	if err != nil {
+		h.respondPersonaError(c, "获取角色失败", err)
+		return
+	}
+	h.respondSuccess(c, p)
+}
+
+// UpdatePersona 更新角色
+// @Summary 更新角色
+// @Description 更新角色，未传的字段保持不变
+// @Tags 角色管理
+// @Accept json
+// @Produce json
+// @Param id path int true "角色ID"
+// @Param persona body personaRequest true "角色信息"
+// @Success 200 {object} map[string]interface{} "更新成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "角色不存在"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/personas/{id} [put]
+// @Router /api/admin/personas/{id} [put]
+func (h *PersonaHandler) UpdatePersona(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的角色ID", err)
+		return
+	}
+
+	var req personaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+
+	p, err := h.personaManager.Get(c.Request.Context(), c.GetString(ownerKey), uint(id))
+	if err != nil {
+		h.respondPersonaError(c, "获取角色失败", err)
+		return
+	}
+	req.apply(p)
+	if err := h.personaManager.Update(c.Request.Context(), p); err != nil {
+		h.respondPersonaError(c, "更新角色失败", err)
+		return
+	}
+
+	h.logger.Info("更新角色成功: %s (ID: %d, 用户: %s)", p.Name, p.ID, p.UserID)
+	h.respondSuccess(c, p)
+}
+
+// DeletePersona 删除角色
+// @Summary 删除角色
+// @Description 删除当前用户创建的角色，管理员接口删除全局角色
+// @Tags 角色管理
+// @Produce json
+// @Param id path int true "角色ID"
+// @Success 200 {object} map[string]interface{} "删除成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "角色不存在"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/personas/{id} [delete]
+// @Router /api/admin/personas/{id} [delete]
+func (h *PersonaHandler) DeletePersona(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的角色ID", err)
+		return
+	}
+
+	owner := c.GetString(ownerKey)
+	if err := h.personaManager.Delete(c.Request.Context(), owner, uint(id)); err != nil {
+		h.respondPersonaError(c, "删除角色失败", err)
+		return
+	}
+
+	h.logger.Info("删除角色成功 (ID: %d, 用户: %s)", id, owner)
+	h.respondSuccess(c, gin.H{
+		"message": "角色删除成功",
+	})
+}
+
+// ownerMiddleware 设置角色所属用户，管理员接口管理的是全局角色
+func (h *PersonaHandler) ownerMiddleware(admin bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if admin {
+			c.Set(ownerKey, "")
+		} else {
+			c.Set(ownerKey, contextUserID(c))
+		}
+		c.Next()
+	}
+}
+
+// respondPersonaError 按角色管理器返回的错误类型选择状态码
+func (h *PersonaHandler) respondPersonaError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, persona.ErrNotFound):
+		h.respondError(c, http.StatusNotFound, "角色不存在", err)
+	case errors.Is(err, persona.ErrInvalid), errors.Is(err, persona.ErrDuplicateName):
+		h.respondError(c, http.StatusBadRequest, message, err)
+	default:
+		h.respondError(c, http.StatusInternalServerError, message, err)
+	}
+}
+
+// respondSuccess 返回成功响应
+func (h *PersonaHandler) respondSuccess(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "操作成功",
+		"data":    data,
+	})
+}
+
+// respondError 返回错误响应
+func (h *PersonaHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	h.logger.Error("%s: %v", message, err)
+
+	response := gin.H{
+		"code":    statusCode,
+		"message": message,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(statusCode, response)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/task"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaHandler 用量配额管理处理器，只允许管理员访问
+type QuotaHandler struct {
+	quotaManager *quota.Manager
+	logger       *utils.Logger
+}
+
+// setTierRequest 调整用户级别请求结构
+type setTierRequest struct {
+	Tier string `json:"tier" binding:"required"` // basic/premium/business
+}
+
+// NewQuotaHandler 创建用量配额管理处理器
+func NewQuotaHandler(quotaManager *quota.Manager, logger *utils.Logger) *QuotaHandler {
+	return &QuotaHandler{
+		quotaManager: quotaManager,
+		logger:       logger,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *QuotaHandler) RegisterRoutes(apiGroup *gin.RouterGroup) {
+	quotaGroup := apiGroup.Group("/admin/quotas")
+	quotaGroup.Use(jwtMiddleware(true, h.respondError))
+	{
+		quotaGroup.GET("/tiers", h.GetTiers)
+		quotaGroup.GET("/users/:user_key", h.GetUsage)
+		quotaGroup.PUT("/users/:user_key/tier", h.SetTier)
+	}
+}
+
+// GetTiers 获取各用户级别的每日用量上限
+// @Summary 获取用户级别的用量上限
+// @Description 获取各用户级别每项资源的每日用量上限，0表示不限制
+// @Tags 用量配额管理
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 403 {object} map[string]interface{} "不是管理员"
+// @Router /api/admin/quotas/tiers [get]
+func (h *QuotaHandler) GetTiers(c *gin.Context) {
+	h.respondSuccess(c, h.quotaManager.Tiers())
+}
+
+// GetUsage 获取用户当日的用量
+// @Summary 获取用户当日的用量
+// @Description 获取用户的级别、当日各项资源的用量、上限和剩余额度
+// @Tags 用量配额管理
+// @Produce json
+// @Param user_key path string true "用户ID，未登录设备为device-<设备ID>"
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 403 {object} map[string]interface{} "不是管理员"
+// @Router /api/admin/quotas/users/{user_key} [get]
+func (h *QuotaHandler) GetUsage(c *gin.Context) {
+	h.respondSuccess(c, h.quotaManager.Usage(c.Param("user_key")))
+}
+
+// SetTier 调整用户级别
+// @Summary 调整用户级别
+// @Description 调整用户级别，立即生效
+// @Tags 用量配额管理
+// @Accept json
+// @Produce json
+// @Param user_key path string true "用户ID，未登录设备为device-<设备ID>"
+// @Param request body setTierRequest true "用户级别"
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 403 {object} map[string]interface{} "不是管理员"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/admin/quotas/users/{user_key}/tier [put]
+func (h *QuotaHandler) SetTier(c *gin.Context) {
+	userKey := c.Param("user_key")
+
+	var req setTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	tier := task.UserLevel(req.Tier)
+	if _, ok := h.quotaManager.Tiers()[tier]; !ok {
+		h.respondError(c, http.StatusBadRequest, "未知的用户级别: "+req.Tier, nil)
+		return
+	}
+	if err := h.quotaManager.SetTier(userKey, tier); err != nil {
+		h.respondError(c, http.StatusInternalServerError, "调整用户级别失败", err)
+		return
+	}
+
+	h.logger.Info("管理员将用户 %s 的级别调整为 %s", userKey, tier)
+	h.respondSuccess(c, h.quotaManager.Usage(userKey))
+}
+
+// respondSuccess 返回成功响应
+func (h *QuotaHandler) respondSuccess(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "操作成功",
+		"data":    data,
+	})
+}
+
+// respondError 返回错误响应
+func (h *QuotaHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	h.logger.Error("%s: %v", message, err)
+
+	response := gin.H{
+		"code":    statusCode,
+		"message": message,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(statusCode, response)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"angrymiao-ai-server/src/core/ttscache"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TTSCacheHandler TTS缓存统计处理器，只允许管理员访问
+type TTSCacheHandler struct {
+	cache  *ttscache.Cache
+	logger *utils.Logger
+}
+
+// NewTTSCacheHandler 创建TTS缓存统计处理器
+func NewTTSCacheHandler(cache *ttscache.Cache, logger *utils.Logger) *TTSCacheHandler {
+	return &TTSCacheHandler{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// RegisterRoutes 注册路由
+func (h *TTSCacheHandler) RegisterRoutes(apiGroup *gin.RouterGroup) {
+	cacheGroup := apiGroup.Group("/admin/tts-cache")
+	cacheGroup.Use(jwtMiddleware(true, h.respondError))
+	{
+		cacheGroup.GET("/stats", h.GetStats)
+	}
+}
+
+// GetStats 获取TTS缓存统计
+// @Summary 获取TTS缓存统计
+// @Description 获取服务启动以来TTS缓存的命中、未命中、写入和淘汰次数，命中率以及当前的条目数和占用空间
+// @Tags TTS缓存
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 403 {object} map[string]interface{} "不是管理员"
+// @Router /api/admin/tts-cache/stats [get]
+func (h *TTSCacheHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "操作成功",
+		"data":    h.cache.Stats(),
+	})
+}
+
+// respondError 返回错误响应
+func (h *TTSCacheHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	h.logger.Error("%s: %v", message, err)
+
+	response := gin.H{
+		"code":    statusCode,
+		"message": message,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(statusCode, response)
+}
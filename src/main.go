@@ -31,10 +31,20 @@ import (
 	"angrymiao-ai-server/src/core/auth"
 	"angrymiao-ai-server/src/core/auth/am_token"
 	"angrymiao-ai-server/src/core/auth/store"
+	"angrymiao-ai-server/src/core/hooks"
+	"angrymiao-ai-server/src/core/intent"
+	"angrymiao-ai-server/src/core/memory"
+	"angrymiao-ai-server/src/core/metrics"
+	"angrymiao-ai-server/src/core/moderation"
+	"angrymiao-ai-server/src/core/persona"
 	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/core/reminder"
 	"angrymiao-ai-server/src/core/transport"
 	"angrymiao-ai-server/src/core/transport/grpcgateway"
 	"angrymiao-ai-server/src/core/transport/websocket"
+	"angrymiao-ai-server/src/core/ttscache"
 	"angrymiao-ai-server/src/core/utils"
 
 	// 项目内部包 - 业务模块
@@ -72,6 +82,14 @@ type Application struct {
 	ctx           context.Context
 	cancel        context.CancelFunc
 	errGroup      *errgroup.Group
+
+	// 传输层创建的资源，HTTP对话接口复用
+	poolManager       *pool.PoolManager
+	userConfigService services.UserAIConfigService
+	quotaManager      *quota.Manager  // 未启用用量配额时为nil
+	ttsCache          *ttscache.Cache // 未启用TTS缓存时为nil
+	personaManager    *persona.Manager
+	moderationManager *moderation.Manager // 未启用内容审核时为nil
 }
 
 // ServerManager 服务管理器，负责管理所有服务的启动和关闭
@@ -242,6 +260,201 @@ func (app *Application) Start() error {
 	return nil
 }
 
+// newDialogueStore 创建对话历史存储，并定期清理超过保留时长的对话历史
+func (app *Application) newDialogueStore() *services.GormDialogueStore {
+	retention := 24 * time.Hour
+	if app.config.DialogueStore.Retention != "" {
+		if d, err := time.ParseDuration(app.config.DialogueStore.Retention); err == nil {
+			retention = d
+		} else {
+			app.logger.Warn("对话历史保留时长配置无效: %s, 使用默认值 %s", app.config.DialogueStore.Retention, retention)
+		}
+	}
+	store := services.NewGormDialogueStore(app.db, app.logger, retention)
+
+	app.errGroup.Go(func() error {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if _, err := store.DeleteExpired(app.ctx); err != nil {
+				app.logger.Error("清理过期对话历史失败: %v", err)
+			}
+			select {
+			case <-app.ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+
+	app.logger.Info("对话历史持久化已启用，保留时长: %s", retention)
+	return store
+}
+
+// newLLM 按配置名创建独立于资源池的LLM实例，配置名为空时使用selected_module中的LLM
+func (app *Application) newLLM(llmName string) (llm.Provider, string, error) {
+	if llmName == "" {
+		llmName = app.config.SelectedModule["LLM"]
+	}
+	llmCfg, ok := app.config.LLM[llmName]
+	if !ok {
+		return nil, llmName, fmt.Errorf("未找到LLM配置: %s", llmName)
+	}
+	provider, err := llm.Create(llmCfg.Type, &llm.Config{
+		Name:        llmName,
+		Type:        llmCfg.Type,
+		ModelName:   llmCfg.ModelName,
+		BaseURL:     llmCfg.BaseURL,
+		APIKey:      llmCfg.APIKey,
+		Temperature: llmCfg.Temperature,
+		MaxTokens:   llmCfg.MaxTokens,
+		TopP:        llmCfg.TopP,
+		Extra:       llmCfg.Extra,
+	})
+	return provider, llmName, err
+}
+
+// newMemoryManager 创建长期记忆管理器，使用独立的LLM实例从对话中提取记忆
+func (app *Application) newMemoryManager() (*memory.Manager, error) {
+	provider, llmName, err := app.newLLM(app.config.Memory.LLM)
+	if err != nil {
+		return nil, fmt.Errorf("创建长期记忆使用的LLM失败: %w", err)
+	}
+
+	app.logger.Info("长期记忆已启用，提取记忆使用LLM: %s", llmName)
+	return memory.NewManager(services.NewGormMemoryStore(app.db), provider, app.logger, memory.Config{
+		TopK:     app.config.Memory.TopK,
+		MaxFacts: app.config.Memory.MaxFacts,
+	}), nil
+}
+
+// newModerationManager 创建内容审核管理器，只有审核配置用到llm提供者时才创建LLM实例
+func (app *Application) newModerationManager() (*moderation.Manager, error) {
+	cfg := app.config.Moderation
+	var rules []moderation.KeywordRule
+	for _, keyword := range cfg.Keywords {
+		rules = append(rules, moderation.KeywordRule{
+			Category: keyword.Category,
+			Words:    keyword.Words,
+			Patterns: keyword.Patterns,
+		})
+	}
+	keywordProvider, err := moderation.NewKeywordProvider(rules)
+	if err != nil {
+		return nil, err
+	}
+	var llmProvider *moderation.LLMProvider
+
+	defaultProfile := cfg.DefaultProfile
+	if defaultProfile == "" {
+		defaultProfile = "default"
+	}
+	profileConfigs := cfg.Profiles
+	if _, ok := profileConfigs[defaultProfile]; !ok {
+		profileConfigs = make(map[string]configs.ModerationProfileConfig, len(cfg.Profiles)+1)
+		for name, profileCfg := range cfg.Profiles {
+			profileConfigs[name] = profileCfg
+		}
+		profileConfigs[defaultProfile] = configs.ModerationProfileConfig{}
+	}
+
+	var profiles []*moderation.Profile
+	for name, profileCfg := range profileConfigs {
+		profile := &moderation.Profile{
+			Name:       name,
+			Reply:      profileCfg.Reply,
+			Categories: profileCfg.Categories,
+		}
+		if profile.Reply == "" {
+			profile.Reply = "这个话题我们还是换一个吧"
+		}
+		if profile.InputAction, err = moderation.ParseAction(profileCfg.InputAction, moderation.ActionLog); err != nil {
+			return nil, fmt.Errorf("审核配置 %s 无效: %w", name, err)
+		}
+		if profile.OutputAction, err = moderation.ParseAction(profileCfg.OutputAction, moderation.ActionLog); err != nil {
+			return nil, fmt.Errorf("审核配置 %s 无效: %w", name, err)
+		}
+
+		providerNames := profileCfg.Providers
+		if len(providerNames) == 0 {
+			providerNames = []string{"keyword"}
+		}
+		for _, providerName := range providerNames {
+			switch providerName {
+			case "keyword":
+				profile.Providers = append(profile.Providers, keywordProvider)
+			case "llm":
+				if llmProvider == nil {
+					provider, llmName, err := app.newLLM(cfg.LLM)
+					if err != nil {
+						return nil, fmt.Errorf("创建内容审核使用的LLM失败: %w", err)
+					}
+					llmProvider = moderation.NewLLMProvider(provider, cfg.LLMCategories)
+					app.logger.Info("内容审核LLM分类器使用LLM: %s", llmName)
+				}
+				profile.Providers = append(profile.Providers, llmProvider)
+			default:
+				return nil, fmt.Errorf("审核配置 %s 使用了未知的审核提供者: %s", name, providerName)
+			}
+		}
+		profiles = append(profiles, profile)
+	}
+
+	app.logger.Info("内容审核已启用，默认审核配置: %s", defaultProfile)
+	return moderation.NewManager(profiles, defaultProfile, app.logger)
+}
+
+// newQuotaManager 创建用量配额管理器，并定期将用量写入数据库
+func (app *Application) newQuotaManager() *quota.Manager {
+	config := quota.Config{
+		DefaultTier: task.UserLevel(app.config.Quota.DefaultTier),
+		Tiers:       make(map[task.UserLevel]quota.Limits),
+	}
+	for tier, limits := range app.config.Quota.Tiers {
+		config.Tiers[task.UserLevel(tier)] = make(quota.Limits)
+		for resource, limit := range limits {
+			config.Tiers[task.UserLevel(tier)][quota.Resource(resource)] = limit
+		}
+	}
+	manager := quota.NewManager(services.NewGormQuotaStore(app.db), app.logger, config)
+
+	app.errGroup.Go(func() error {
+		manager.Run(app.ctx)
+		return nil
+	})
+
+	app.logger.Info("用量配额已启用")
+	return manager
+}
+
+// newIntentRouter 按配置创建本地意图路由器
+func (app *Application) newIntentRouter() (*intent.Router, error) {
+	config := app.config.Intent
+	rules := make([]intent.Rule, 0, len(config.Intents))
+	for _, rule := range config.Intents {
+		rules = append(rules, intent.Rule{
+			Name:      rule.Name,
+			Patterns:  rule.Patterns,
+			Slots:     rule.Slots,
+			Tool:      rule.Tool,
+			Arguments: rule.Arguments,
+			Reply:     rule.Reply,
+		})
+	}
+	router, err := intent.NewRouter(rules, config.Synonyms, config.MinConfidence)
+	if err != nil {
+		return nil, err
+	}
+	app.logger.Info("本地意图识别已启用，意图数: %d", len(rules))
+	return router, nil
+}
+
+// registerHooks 注册对话钩子，钩子按注册顺序执行，需要在创建连接之前注册
+// 自定义的提示词注入、日志、过滤和统计等钩子也在这里注册
+func (app *Application) registerHooks() {
+	hooks.Register(hooks.NewRoundLogger(app.logger))
+}
+
 // startTransportServer 启动传输层服务
 func (app *Application) startTransportServer() error {
 	// 初始化资源池管理器
@@ -257,7 +470,11 @@ func (app *Application) startTransportServer() error {
 	})
 	taskMgr.Start()
 
+	app.registerHooks()
+
 	userConfigService := services.NewUserAIConfigService(app.db, app.logger)
+	app.poolManager = poolManager
+	app.userConfigService = userConfigService
 
 	// 创建传输管理器
 	transportManager := transport.NewTransportManager(app.config, app.logger)
@@ -271,6 +488,57 @@ func (app *Application) startTransportServer() error {
 		app.logger,
 		userConfigService,
 	)
+	if app.config.DialogueStore.Enabled {
+		handlerFactory.SetDialogueStore(app.newDialogueStore())
+	}
+	if app.config.Memory.Enabled {
+		memoryManager, err := app.newMemoryManager()
+		if err != nil {
+			return fmt.Errorf("初始化长期记忆失败: %w", err)
+		}
+		handlerFactory.SetMemoryManager(memoryManager)
+	}
+	reminderManager := reminder.NewManager(taskMgr, services.NewGormReminderStore(app.db), app.logger)
+	if err := reminderManager.Restore(app.ctx); err != nil {
+		app.logger.Warn("恢复提醒失败: %v", err)
+	}
+	handlerFactory.SetReminderManager(reminderManager)
+	if app.config.Quota.Enabled {
+		app.quotaManager = app.newQuotaManager()
+		handlerFactory.SetQuotaManager(app.quotaManager)
+	}
+	if app.config.Moderation.Enabled {
+		app.moderationManager, err = app.newModerationManager()
+		if err != nil {
+			return fmt.Errorf("初始化内容审核失败: %w", err)
+		}
+		handlerFactory.SetModerationManager(app.moderationManager)
+	}
+	if app.config.TTSCache.Enabled {
+		cacheConfig := app.config.TTSCache
+		app.ttsCache, err = ttscache.New(ttscache.Config{
+			Dir:          cacheConfig.Dir,
+			MaxEntries:   cacheConfig.MaxEntries,
+			MaxBytes:     cacheConfig.MaxMB << 20,
+			MaxTextRunes: cacheConfig.MaxTextLength,
+		}, app.logger)
+		if err != nil {
+			return fmt.Errorf("初始化TTS缓存失败: %w", err)
+		}
+		handlerFactory.SetTTSCache(app.ttsCache)
+	}
+	app.personaManager = persona.NewManager(services.NewGormPersonaStore(app.db), app.logger)
+	if err := app.personaManager.ImportRoles(app.ctx, app.config.Roles); err != nil {
+		app.logger.Warn("导入配置中的角色失败: %v", err)
+	}
+	handlerFactory.SetPersonaManager(app.personaManager)
+	if app.config.Intent.Enabled {
+		intentRouter, err := app.newIntentRouter()
+		if err != nil {
+			return fmt.Errorf("初始化本地意图识别失败: %w", err)
+		}
+		handlerFactory.SetIntentRouter(intentRouter)
+	}
 
 	// 根据默认选择只注册一个传输层
 	switch app.config.Transport.Default {
@@ -421,6 +689,36 @@ func (app *Application) registerRoutes(router *gin.Engine) error {
 	aiConfigHandler.RegisterRoutes(apiGroup)
 	app.logger.Info("AI配置管理服务已注册，访问地址: /api/ai-configs")
 
+	// 注册角色管理接口
+	personaHandler := handlers.NewPersonaHandler(app.personaManager, app.logger)
+	personaHandler.RegisterRoutes(apiGroup)
+	app.logger.Info("角色管理服务已注册，访问地址: /api/personas, /api/admin/personas")
+
+	// 注册用量配额管理接口
+	if app.quotaManager != nil {
+		quotaHandler := handlers.NewQuotaHandler(app.quotaManager, app.logger)
+		quotaHandler.RegisterRoutes(apiGroup)
+		app.logger.Info("用量配额管理服务已注册，访问地址: /api/admin/quotas")
+	}
+
+	// 注册TTS缓存统计接口
+	if app.ttsCache != nil {
+		ttsCacheHandler := handlers.NewTTSCacheHandler(app.ttsCache, app.logger)
+		ttsCacheHandler.RegisterRoutes(apiGroup)
+		app.logger.Info("TTS缓存统计服务已注册，访问地址: /api/admin/tts-cache/stats")
+	}
+
+	// 注册OpenAI兼容的对话接口
+	chatHandler := handlers.NewChatCompletionsHandler(app.config, app.poolManager, app.userConfigService, app.logger)
+	chatHandler.SetQuotaManager(app.quotaManager)
+	chatHandler.SetPersonaManager(app.personaManager)
+	chatHandler.SetModerationManager(app.moderationManager)
+	chatHandler.RegisterRoutes(router)
+	app.logger.Info("对话接口已注册，访问地址: /v1/chat/completions")
+
+	// 注册Prometheus指标接口
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// 注册Swagger文档路由
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// DialogueHistory 会话的对话历史，用于设备断线重连后恢复上下文
+type DialogueHistory struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SessionKey string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"session_key"`
+	DeviceID   string    `gorm:"type:varchar(64);index" json:"device_id"`
+	UserID     string    `gorm:"index" json:"user_id"`
+	Dialogue   string    `gorm:"type:text" json:"dialogue"` // JSON格式的消息列表
+	Summary    string    `gorm:"type:text" json:"summary"`  // 已移出对话历史的早期对话摘要
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `gorm:"index" json:"updated_at"`
+}
+
+// TableName 指定DialogueHistory表名
+func (DialogueHistory) TableName() string {
+	return "dialogue_histories"
+}
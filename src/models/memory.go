@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UserMemory 从对话中提取的用户长期记忆，每条记录为一条关于用户的事实
+type UserMemory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserKey   string    `gorm:"type:varchar(255);index;not null" json:"user_key"` // 用户ID，未登录设备为device-<设备ID>
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定UserMemory表名
+func (UserMemory) TableName() string {
+	return "user_memories"
+}
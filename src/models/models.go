@@ -39,6 +39,8 @@ type UserSetting struct {
 	SelectedVLLLM   string
 	PromptOverride  string `gorm:"type:text"`
 	QuickReplyWords datatypes.JSON
+	SilencePolicy   datatypes.JSON // 覆盖全局的静音策略，字段同configs.SilencePolicyConfig
+	Moderation      string         // 内容审核配置名，如child，为空时使用默认配置
 }
 
 // 模块配置（可选）
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Persona 角色表，UserID为空的是全局角色，对所有用户可用
+type Persona struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      string         `gorm:"type:varchar(255);index" json:"user_id"`
+	Name        string         `gorm:"type:varchar(64);not null" json:"name"`
+	Aliases     datatypes.JSON `json:"aliases,omitempty"` // JSON数组，角色别名
+	Prompt      string         `gorm:"type:text;not null" json:"prompt"`
+	Voices      datatypes.JSON `json:"voices,omitempty"` // JSON对象，TTS类型到音色的映射
+	Greeting    string         `gorm:"type:text" json:"greeting,omitempty"`
+	Tools       datatypes.JSON `json:"tools,omitempty"`       // JSON数组，可用的工具，为空时不限制
+	Temperature *float64       `json:"temperature,omitempty"` // 为空时使用LLM配置
+	MaxTokens   *int           `json:"max_tokens,omitempty"`
+	TopP        *float64       `json:"top_p,omitempty"`
+	IsActive    bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// TableName 指定Persona表名
+func (Persona) TableName() string {
+	return "personas"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Reminder 计时器、闹钟和提醒，服务重启后恢复未到期和未送达的提醒，送达或取消后删除
+type Reminder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DeviceKey string    `gorm:"type:varchar(255);uniqueIndex:idx_reminder_device_short;not null" json:"device_key"` // 设备ID，没有设备ID时为会话ID
+	ShortID   string    `gorm:"type:varchar(16);uniqueIndex:idx_reminder_device_short;not null" json:"short_id"`    // 用户取消提醒时使用的短ID
+	Kind      string    `gorm:"type:varchar(16);not null" json:"kind"`
+	Content   string    `gorm:"type:text" json:"content"`
+	DueAt     time.Time `gorm:"index" json:"due_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定Reminder表名
+func (Reminder) TableName() string {
+	return "reminders"
+}
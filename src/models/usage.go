@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// UserUsage 用户每天每项资源的用量，用于按用户级别限制用量
+type UserUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserKey   string    `gorm:"type:varchar(255);uniqueIndex:idx_user_usage;not null" json:"user_key"` // 用户ID，未登录设备为device-<设备ID>
+	Day       string    `gorm:"type:varchar(10);uniqueIndex:idx_user_usage;not null" json:"day"`       // 日期，格式为2006-01-02
+	Resource  string    `gorm:"type:varchar(32);uniqueIndex:idx_user_usage;not null" json:"resource"`
+	Amount    int64     `gorm:"not null;default:0" json:"amount"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定UserUsage表名
+func (UserUsage) TableName() string {
+	return "user_usages"
+}
+
+// UserTier 用户级别，未设置的用户使用配置中的默认级别
+type UserTier struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserKey   string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"user_key"`
+	Tier      string    `gorm:"type:varchar(32);not null" json:"tier"` // basic/premium/business
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定UserTier表名
+func (UserTier) TableName() string {
+	return "user_tiers"
+}
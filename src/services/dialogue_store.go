@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormDialogueStore 基于gorm的对话历史存储，支持sqlite和postgres
+type GormDialogueStore struct {
+	db        *gorm.DB
+	logger    *utils.Logger
+	retention time.Duration // 对话历史保留时长，<=0表示永久保留
+}
+
+var _ chat.DialogueStore = (*GormDialogueStore)(nil)
+
+// NewGormDialogueStore 创建对话历史存储
+func NewGormDialogueStore(db *gorm.DB, logger *utils.Logger, retention time.Duration) *GormDialogueStore {
+	return &GormDialogueStore{
+		db:        db,
+		logger:    logger,
+		retention: retention,
+	}
+}
+
+// Load 加载属于该设备和用户的会话对话历史
+func (s *GormDialogueStore) Load(ctx context.Context, sessionKey, deviceID, userID string) (*chat.DialogueRecord, error) {
+	var history models.DialogueHistory
+	query := s.db.WithContext(ctx).
+		Where("session_key = ? AND device_id = ? AND user_id = ?", sessionKey, deviceID, userID)
+	if s.retention > 0 {
+		query = query.Where("updated_at > ?", time.Now().Add(-s.retention))
+	}
+	if err := query.First(&history).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &chat.DialogueRecord{
+		SessionKey: history.SessionKey,
+		DeviceID:   history.DeviceID,
+		UserID:     history.UserID,
+		Dialogue:   history.Dialogue,
+		Summary:    history.Summary,
+	}, nil
+}
+
+// Save 保存会话的对话历史，已有记录属于其他设备或用户时不覆盖
+func (s *GormDialogueStore) Save(ctx context.Context, record chat.DialogueRecord) error {
+	history := models.DialogueHistory{
+		SessionKey: record.SessionKey,
+		DeviceID:   record.DeviceID,
+		UserID:     record.UserID,
+		Dialogue:   record.Dialogue,
+		Summary:    record.Summary,
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"dialogue", "summary", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "dialogue_histories.device_id = excluded.device_id AND dialogue_histories.user_id = excluded.user_id"},
+		}},
+	}).Create(&history).Error
+}
+
+// SaveSummary 只更新属于该设备和用户的会话的早期对话摘要
+func (s *GormDialogueStore) SaveSummary(ctx context.Context, record chat.DialogueRecord) error {
+	return s.db.WithContext(ctx).Model(&models.DialogueHistory{}).
+		Where("session_key = ? AND device_id = ? AND user_id = ?", record.SessionKey, record.DeviceID, record.UserID).
+		Update("summary", record.Summary).Error
+}
+
+// DeleteExpired 删除超过保留时长的对话历史
+func (s *GormDialogueStore) DeleteExpired(ctx context.Context) (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	result := s.db.WithContext(ctx).
+		Where("updated_at <= ?", time.Now().Add(-s.retention)).
+		Delete(&models.DialogueHistory{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.logger.Info("已清理 %d 个过期的对话历史", result.RowsAffected)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+
+	"angrymiao-ai-server/src/core/memory"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+// GormMemoryStore 基于gorm的用户长期记忆存储，支持sqlite和postgres
+type GormMemoryStore struct {
+	db *gorm.DB
+}
+
+var _ memory.Store = (*GormMemoryStore)(nil)
+
+// NewGormMemoryStore 创建用户长期记忆存储
+func NewGormMemoryStore(db *gorm.DB) *GormMemoryStore {
+	return &GormMemoryStore{db: db}
+}
+
+// List 按创建时间从早到晚列出用户的全部记忆
+func (s *GormMemoryStore) List(ctx context.Context, userKey string) ([]memory.Fact, error) {
+	var records []models.UserMemory
+	if err := s.db.WithContext(ctx).
+		Where("user_key = ?", userKey).
+		Order("created_at, id").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	facts := make([]memory.Fact, len(records))
+	for i, record := range records {
+		facts[i] = memory.Fact{
+			ID:        record.ID,
+			Content:   record.Content,
+			CreatedAt: record.CreatedAt,
+		}
+	}
+	return facts, nil
+}
+
+// Add 为用户添加记忆
+func (s *GormMemoryStore) Add(ctx context.Context, userKey string, contents []string) error {
+	if len(contents) == 0 {
+		return nil
+	}
+	records := make([]models.UserMemory, len(contents))
+	for i, content := range contents {
+		records[i] = models.UserMemory{
+			UserKey: userKey,
+			Content: content,
+		}
+	}
+	return s.db.WithContext(ctx).Create(&records).Error
+}
+
+// Delete 删除用户的指定记忆
+func (s *GormMemoryStore) Delete(ctx context.Context, userKey string, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).
+		Where("user_key = ? AND id IN ?", userKey, ids).
+		Delete(&models.UserMemory{}).Error
+}
+
+// Clear 删除用户的全部记忆
+func (s *GormMemoryStore) Clear(ctx context.Context, userKey string) error {
+	return s.db.WithContext(ctx).
+		Where("user_key = ?", userKey).
+		Delete(&models.UserMemory{}).Error
+}
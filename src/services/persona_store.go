@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"angrymiao-ai-server/src/core/persona"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// GormPersonaStore 基于gorm的角色存储，支持sqlite和postgres
+type GormPersonaStore struct {
+	db *gorm.DB
+}
+
+var _ persona.Store = (*GormPersonaStore)(nil)
+
+// NewGormPersonaStore 创建角色存储
+func NewGormPersonaStore(db *gorm.DB) *GormPersonaStore {
+	return &GormPersonaStore{db: db}
+}
+
+// List 列出用户创建的角色，userID为空时列出全局角色
+func (s *GormPersonaStore) List(ctx context.Context, userID string) ([]persona.Persona, error) {
+	var records []models.Persona
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	personas := make([]persona.Persona, len(records))
+	for i := range records {
+		personas[i] = fromPersonaRecord(&records[i])
+	}
+	return personas, nil
+}
+
+// Get 获取角色，不存在时返回persona.ErrNotFound
+func (s *GormPersonaStore) Get(ctx context.Context, id uint) (*persona.Persona, error) {
+	var record models.Persona
+	if err := s.db.WithContext(ctx).First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, persona.ErrNotFound
+		}
+		return nil, err
+	}
+	p := fromPersonaRecord(&record)
+	return &p, nil
+}
+
+// Create 创建角色，成功后设置ID
+func (s *GormPersonaStore) Create(ctx context.Context, p *persona.Persona) error {
+	record := toPersonaRecord(p)
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return err
+	}
+	p.ID = record.ID
+	return nil
+}
+
+// Update 更新角色
+func (s *GormPersonaStore) Update(ctx context.Context, p *persona.Persona) error {
+	var record models.Persona
+	if err := s.db.WithContext(ctx).First(&record, p.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return persona.ErrNotFound
+		}
+		return err
+	}
+	updated := toPersonaRecord(p)
+	updated.CreatedAt = record.CreatedAt
+	return s.db.WithContext(ctx).Save(updated).Error
+}
+
+// Delete 删除角色
+func (s *GormPersonaStore) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Delete(&models.Persona{}, id).Error
+}
+
+func toPersonaRecord(p *persona.Persona) *models.Persona {
+	return &models.Persona{
+		ID:          p.ID,
+		UserID:      p.UserID,
+		Name:        p.Name,
+		Aliases:     marshalJSON(p.Aliases),
+		Prompt:      p.Prompt,
+		Voices:      marshalJSON(p.Voices),
+		Greeting:    p.Greeting,
+		Tools:       marshalJSON(p.Tools),
+		Temperature: p.LLM.Temperature,
+		MaxTokens:   p.LLM.MaxTokens,
+		TopP:        p.LLM.TopP,
+		IsActive:    p.IsActive,
+	}
+}
+
+func fromPersonaRecord(record *models.Persona) persona.Persona {
+	p := persona.Persona{
+		ID:       record.ID,
+		UserID:   record.UserID,
+		Name:     record.Name,
+		Prompt:   record.Prompt,
+		Greeting: record.Greeting,
+		LLM: llm.Params{
+			Temperature: record.Temperature,
+			MaxTokens:   record.MaxTokens,
+			TopP:        record.TopP,
+		},
+		IsActive: record.IsActive,
+	}
+	unmarshalJSON(record.Aliases, &p.Aliases)
+	unmarshalJSON(record.Voices, &p.Voices)
+	unmarshalJSON(record.Tools, &p.Tools)
+	return p
+}
+
+// marshalJSON 将切片或映射序列化为JSON字段，为空时返回nil
+func marshalJSON(v interface{}) datatypes.JSON {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return datatypes.JSON(data)
+}
+
+// unmarshalJSON 解析JSON字段，为空或格式错误时保持零值
+func unmarshalJSON(data datatypes.JSON, v interface{}) {
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, v)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+
+	"angrymiao-ai-server/src/core/quota"
+	"angrymiao-ai-server/src/models"
+	"angrymiao-ai-server/src/task"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormQuotaStore 基于gorm的用量和用户级别存储，支持sqlite和postgres
+type GormQuotaStore struct {
+	db *gorm.DB
+}
+
+var _ quota.Store = (*GormQuotaStore)(nil)
+
+// NewGormQuotaStore 创建用量存储
+func NewGormQuotaStore(db *gorm.DB) *GormQuotaStore {
+	return &GormQuotaStore{db: db}
+}
+
+// LoadUsage 读取用户某天的用量
+func (s *GormQuotaStore) LoadUsage(ctx context.Context, userKey, day string) (map[quota.Resource]int64, error) {
+	var records []models.UserUsage
+	if err := s.db.WithContext(ctx).
+		Where("user_key = ? AND day = ?", userKey, day).
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	usage := make(map[quota.Resource]int64, len(records))
+	for _, record := range records {
+		usage[quota.Resource(record.Resource)] = record.Amount
+	}
+	return usage, nil
+}
+
+// AddUsage 累加用户某天的用量
+func (s *GormQuotaStore) AddUsage(ctx context.Context, userKey, day string, usage map[quota.Resource]int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for resource, amount := range usage {
+			record := models.UserUsage{
+				UserKey:  userKey,
+				Day:      day,
+				Resource: string(resource),
+				Amount:   amount,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_key"}, {Name: "day"}, {Name: "resource"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"amount":     gorm.Expr("user_usages.amount + ?", amount),
+					"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+				}),
+			}).Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadTier 读取用户级别，未设置时返回空字符串
+func (s *GormQuotaStore) LoadTier(ctx context.Context, userKey string) (task.UserLevel, error) {
+	var record models.UserTier
+	if err := s.db.WithContext(ctx).Where("user_key = ?", userKey).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return task.UserLevel(record.Tier), nil
+}
+
+// SaveTier 设置用户级别
+func (s *GormQuotaStore) SaveTier(ctx context.Context, userKey string, tier task.UserLevel) error {
+	record := models.UserTier{
+		UserKey: userKey,
+		Tier:    string(tier),
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"tier", "updated_at"}),
+	}).Create(&record).Error
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+
+	"angrymiao-ai-server/src/core/reminder"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+// GormReminderStore 基于gorm的提醒存储，支持sqlite和postgres
+type GormReminderStore struct {
+	db *gorm.DB
+}
+
+var _ reminder.Store = (*GormReminderStore)(nil)
+
+// NewGormReminderStore 创建提醒存储
+func NewGormReminderStore(db *gorm.DB) *GormReminderStore {
+	return &GormReminderStore{db: db}
+}
+
+// List 按到期时间列出全部设备的提醒
+func (s *GormReminderStore) List(ctx context.Context) ([]*reminder.Reminder, error) {
+	var records []models.Reminder
+	if err := s.db.WithContext(ctx).Order("due_at, id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	reminders := make([]*reminder.Reminder, len(records))
+	for i, record := range records {
+		reminders[i] = &reminder.Reminder{
+			ID:        record.ShortID,
+			Kind:      record.Kind,
+			Content:   record.Content,
+			DueAt:     record.DueAt,
+			CreatedAt: record.CreatedAt,
+			DeviceKey: record.DeviceKey,
+		}
+	}
+	return reminders, nil
+}
+
+// Save 保存新创建的提醒
+func (s *GormReminderStore) Save(ctx context.Context, r *reminder.Reminder) error {
+	return s.db.WithContext(ctx).Create(&models.Reminder{
+		DeviceKey: r.DeviceKey,
+		ShortID:   r.ID,
+		Kind:      r.Kind,
+		Content:   r.Content,
+		DueAt:     r.DueAt,
+		CreatedAt: r.CreatedAt,
+	}).Error
+}
+
+// Delete 删除设备的指定提醒
+func (s *GormReminderStore) Delete(ctx context.Context, deviceKey string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).
+		Where("device_key = ? AND short_id IN ?", deviceKey, ids).
+		Delete(&models.Reminder{}).Error
+}
@@ -37,6 +37,9 @@ type UserAIConfigService interface {
 
 	// 从缓存获取用户配置
 	GetCachedUserConfigs(userID string) ([]*models.UserAIConfig, error)
+
+	// 获取用户设置，用户没有设置时返回nil
+	GetUserSetting(ctx context.Context, userID string) (*models.UserSetting, error)
 }
 
 // DefaultUserAIConfigService 默认用户AI配置服务实现
@@ -283,6 +286,19 @@ func (s *DefaultUserAIConfigService) GetCachedUserConfigs(userID string) ([]*mod
 	return configs, nil
 }
 
+// GetUserSetting 获取用户设置，用户没有设置时返回nil
+func (s *DefaultUserAIConfigService) GetUserSetting(ctx context.Context, userID string) (*models.UserSetting, error) {
+	var setting models.UserSetting
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&setting).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &setting, nil
+}
+
 // CheckFunctionNameUnique 检查function name在用户范围内是否唯一
 func (s *DefaultUserAIConfigService) CheckFunctionNameUnique(ctx context.Context, userID string, functionName string, excludeID uint) error {
 	if functionName == "" {
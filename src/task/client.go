@@ -141,6 +141,26 @@ func (rq *ResourceQuota) TryIncrementQuota() error {
 	return nil
 }
 
+// TryReserveQuota 定时任务提交时只占用每日总配额，到期执行时再通过StartTask占用并发数
+func (rq *ResourceQuota) TryReserveQuota() error {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	if rq.TotalUsedQuota >= rq.MaxTotalTasks {
+		return fmt.Errorf("daily task quota exceeded")
+	}
+	rq.TotalUsedQuota++
+	return nil
+}
+
+// StartTask 定时任务到期开始执行，增加并发计数，执行完成后由CompleteTask减少
+func (rq *ResourceQuota) StartTask(taskType TaskType) {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	rq.TotalRunningTasks++
+}
+
 // CompleteTask marks a task as completed and decrements the running count
 func (rq *ResourceQuota) CompleteTask(taskType TaskType) {
 	rq.mu.Lock()
@@ -87,15 +87,31 @@ func (tm *TaskManager) scheduleTask(clientID string, task *Task) error {
 		return fmt.Errorf("failed to get client context: %v", err)
 	}
 
-	// 检查是否可以接受任务（使用总配额检查）
-	if err := ctx.ResourceQuota.TryIncrementQuota(); err != nil {
+	// 等待期间只占用总配额，不占用并发数，到期执行时再计入并发
+	if err := ctx.ResourceQuota.TryReserveQuota(); err != nil {
 		return err
 	}
 
+	// 执行完成后由工作者按客户端减少并发计数
+	task.ClinetID = clientID
 	tm.scheduledTasks.AddTask(task)
 	return nil
 }
 
+// CancelTask cancels a scheduled task that has not been executed yet
+func (tm *TaskManager) CancelTask(clientID string, taskID string) bool {
+	task := tm.scheduledTasks.RemoveTask(taskID)
+	if task == nil {
+		return false
+	}
+
+	// 归还定时任务占用的总配额，未执行的任务没有占用并发数
+	if ctx, err := tm.clientManager.GetClientContext(clientID); err == nil {
+		ctx.ResourceQuota.DecrementQuota(task.Type)
+	}
+	return true
+}
+
 // ScheduledTasks manages scheduled tasks
 type ScheduledTasks struct {
 	tasks      map[string]*Task
@@ -133,6 +149,18 @@ func (st *ScheduledTasks) AddTask(task *Task) {
 	st.tasks[task.ID] = task
 }
 
+// RemoveTask removes a scheduled task, returns nil if the task is not found
+func (st *ScheduledTasks) RemoveTask(taskID string) *Task {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	task, exists := st.tasks[taskID]
+	if !exists {
+		return nil
+	}
+	delete(st.tasks, taskID)
+	return task
+}
+
 // run processes scheduled tasks
 func (st *ScheduledTasks) run() {
 	for {
@@ -158,6 +186,11 @@ func (st *ScheduledTasks) processScheduledTasks() {
 
 	for id, task := range st.tasks {
 		if task.ScheduledTime.Before(now) || task.ScheduledTime.Equal(now) {
+			// 到期后开始计入客户端的并发数，由工作者执行完成后减少
+			quota := st.clientQuota(task)
+			if quota != nil {
+				quota.StartTask(task.Type)
+			}
 			// 使用工作者池执行，而非直接go
 			if err := st.workerPool.Submit(task); err != nil {
 				// 提交失败的降级处理
@@ -166,6 +199,9 @@ func (st *ScheduledTasks) processScheduledTasks() {
 						if r := recover(); r != nil {
 							fmt.Printf("Scheduled task panic: %v\n", r)
 						}
+						if quota != nil {
+							quota.CompleteTask(t.Type)
+						}
 					}()
 					t.Execute()
 				}(task)
@@ -174,3 +210,15 @@ func (st *ScheduledTasks) processScheduledTasks() {
 		}
 	}
 }
+
+// clientQuota 返回定时任务所属客户端的配额，没有客户端时返回nil
+func (st *ScheduledTasks) clientQuota(task *Task) *ResourceQuota {
+	if task.ClinetID == "" || st.workerPool.clientManager == nil {
+		return nil
+	}
+	ctx, err := st.workerPool.clientManager.GetClientContext(task.ClinetID)
+	if err != nil {
+		return nil
+	}
+	return ctx.ResourceQuota
+}